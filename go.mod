@@ -3,6 +3,8 @@ module github.com/entando/entando-nxfs
 go 1.13
 
 require (
+	github.com/andybalholm/brotli v1.0.4
+	github.com/go-jose/go-jose/v3 v3.0.3
 	github.com/gorilla/mux v1.7.3
 	github.com/pkg/errors v0.9.1
 )
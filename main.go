@@ -12,18 +12,142 @@ package main
 import (
 	nxsiteman "github.com/entando/entando-nxfs/server"
 	"github.com/entando/entando-nxfs/server/controller"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfsauth"
+	"github.com/entando/entando-nxfs/server/nxfsblob"
+	"github.com/entando/entando-nxfs/server/nxfsdelivery"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfstenant"
 	"github.com/entando/entando-nxfs/server/service"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		verifyAudit()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "gc-blobs" {
+		gcBlobs()
+		return
+	}
+
 	log.Printf("Server started")
 
-	DefaultApiService := service.NewDefaultApiService()
+	verifier, err := nxfsauth.NewVerifier(nxfsauth.Config{
+		Secret:        helper.GetTokenSecret(),
+		PublicKeyFile: helper.GetTokenPublicKeyFile(),
+		JWKSURL:       helper.GetTokenJWKSURL(),
+		Issuer:        helper.GetTokenIssuer(),
+	})
+	if err != nil {
+		log.Fatalf("Can't load the keys verifying the tokens: %s", err.Error())
+	}
+
+	var handler http.Handler
+	if tenantsFile := helper.GetTenantsFile(); "" != tenantsFile {
+		// the tenant of a caller comes from its token, which can be trusted only if verified
+		if verifier == nil && !helper.IsTrustedGateway() {
+			log.Fatalf("The tenants need verified tokens: set NXFS_JWT_SECRET, NXFS_JWT_PUBLIC_KEY_FILE or NXFS_JWT_JWKS_URL, or NXFS_TRUSTED_GATEWAY=true if a gateway verifies them")
+		}
+		tenants := nxfstenant.NewRegistry(tenantsFile)
+		if err := tenants.Load(); err != nil {
+			log.Fatalf("Can't load the tenants: %s", err.Error())
+		}
+		selector := nxfstenant.DefaultSelector()
+		handler = nxfstenant.NewHandler(tenants, selector, func(tenant nxfstenant.Tenant) (http.Handler, func(), error) {
+			return newFsHandler(tenant.FsConfig)
+		})
+		tenants.Watch(helper.GetTenantsReloadInterval())
+		log.Printf("Serving the tenants of %s, selected by the request %s", tenantsFile, selector.Source)
+	} else {
+		if handler, _, err = newFsHandler(helper.GetDefaultFsConfig()); err != nil {
+			log.Fatalf("Can't serve the browsable fs: %s", err.Error())
+		}
+	}
+
+	if verifier == nil {
+		log.Printf("The bearer tokens are not verified, they must be verified by a gateway in front of nxfs")
+	}
+	log.Fatal(http.ListenAndServe(":8080", nxfsauth.Handler(handler, verifier)))
+}
+
+// newFsHandler - create the handler serving the api, and the published pages if their delivery is enabled, of the
+// browsable fs of the received configuration, with the function releasing its resources
+func newFsHandler(config helper.FsConfig) (http.Handler, func(), error) {
+	for _, root := range []string{config.Root, config.DataDir} {
+		if removed, err := nxfsfiles.SweepTempFiles(root); err != nil {
+			log.Printf("Sweep of the orphaned temporary files in %s failed: %s", root, err.Error())
+		} else if removed > 0 {
+			log.Printf("Removed %d orphaned temporary files from %s", removed, root)
+		}
+	}
+
+	DefaultApiService, err := service.NewFsApiService(config)
+	if err != nil {
+		return nil, nil, err
+	}
 	DefaultApiController := controller.NewDefaultApiController(DefaultApiService)
 
 	router := nxsiteman.NewRouter(DefaultApiController)
+	if deliveryPrefix := helper.GetDeliveryPrefix(); "" != deliveryPrefix && helper.GetStorage() != helper.StorageLocal {
+		log.Printf("The published pages can't be served from the %s storage, %s is not served", helper.GetStorage(), deliveryPrefix)
+	} else if "" != deliveryPrefix {
+		router.PathPrefix(deliveryPrefix).Handler(helper.Logger(nxfsdelivery.NewHandler(deliveryPrefix, config.Root, helper.GetDeliveryMaxAge()), "Delivery"))
+		log.Printf("Serving the published pages of %s under %s", config.Root, deliveryPrefix)
+	}
+	return router, DefaultApiService.Close, nil
+}
+
+// verifyAudit - check the hash chain of the audit log and exit with a non zero status if it has been tampered with
+func verifyAudit() {
+	auditLogPath := helper.GetAuditLogPath()
+	if len(os.Args) > 2 {
+		auditLogPath = os.Args[2]
+	}
+
+	verified, err := nxfsaudit.Verify(auditLogPath)
+	if err != nil {
+		log.Fatalf("Audit log %s is NOT valid after %d records: %s", auditLogPath, verified, err.Error())
+	}
+	log.Printf("Audit log %s is valid: %d records verified", auditLogPath, verified)
+}
+
+// gcBlobs - remove the blobs no published page or snapshot uses anymore, from the blob store of every tenant when they
+// are configured. blobs younger than the optional minimum age (a Go duration, 10m by default) are kept, as they may be in
+// use by a running nxfs
+func gcBlobs() {
+	minAge := nxfsblob.DefaultGCMinAge
+	if len(os.Args) > 2 {
+		var err error
+		if minAge, err = time.ParseDuration(os.Args[2]); err != nil {
+			log.Fatalf("Invalid minimum age %q: %s", os.Args[2], err.Error())
+		}
+	}
+
+	blobsPaths := []string{helper.GetBlobsPath()}
+	if tenantsFile := helper.GetTenantsFile(); "" != tenantsFile {
+		tenants := nxfstenant.NewRegistry(tenantsFile)
+		if err := tenants.Load(); err != nil {
+			log.Fatalf("Can't load the tenants: %s", err.Error())
+		}
+		blobsPaths = blobsPaths[:0]
+		for _, tenant := range tenants.Tenants() {
+			blobsPaths = append(blobsPaths, tenant.BlobsPath())
+		}
+		sort.Strings(blobsPaths)
+	}
 
-	log.Fatal(http.ListenAndServe(":8080", router))
+	for _, blobsPath := range blobsPaths {
+		result, err := nxfsblob.NewStore(blobsPath).GC(minAge)
+		if err != nil {
+			log.Fatalf("Garbage collection of the blobs in %s failed after removing %d blobs: %s", blobsPath, result.Removed, err.Error())
+		}
+		log.Printf("Removed %d of %d blobs from %s, %d bytes freed", result.Removed, result.Blobs, blobsPath, result.Freed)
+	}
 }
@@ -13,6 +13,7 @@ import (
 	"context"
 	"github.com/entando/entando-nxfs/server/model"
 	"github.com/entando/entando-nxfs/server/net"
+	"io"
 	"net/http"
 )
 
@@ -23,9 +24,43 @@ type DefaultApiRouter interface {
 	ApiNxfsBrowseEncodedPathGet(http.ResponseWriter, *http.Request)
 	ApiNxfsObjectsEncodedPathDelete(http.ResponseWriter, *http.Request)
 	ApiNxfsObjectsEncodedPathGet(http.ResponseWriter, *http.Request)
+	ApiNxfsRawEncodedPathGet(http.ResponseWriter, *http.Request)
 	ApiNxfsObjectsEncodedPathPublishPost(http.ResponseWriter, *http.Request)
 	ApiNxfsObjectsEncodedPathPut(http.ResponseWriter, *http.Request)
 	ApiNxfsObjectsEncodedPathUnpublishPost(http.ResponseWriter, *http.Request)
+	ApiNxfsAuditGet(http.ResponseWriter, *http.Request)
+	ApiNxfsUsageGet(http.ResponseWriter, *http.Request)
+	ApiNxfsObjectsEncodedPathLockGet(http.ResponseWriter, *http.Request)
+	ApiNxfsObjectsEncodedPathLockPost(http.ResponseWriter, *http.Request)
+	ApiNxfsObjectsEncodedPathLockPut(http.ResponseWriter, *http.Request)
+	ApiNxfsObjectsEncodedPathLockDelete(http.ResponseWriter, *http.Request)
+	ApiNxfsAdminLocksEncodedPathDelete(http.ResponseWriter, *http.Request)
+	ApiNxfsSchemasPageVersionGet(http.ResponseWriter, *http.Request)
+	ApiNxfsSchedulesGet(http.ResponseWriter, *http.Request)
+	ApiNxfsSchedulesIdDelete(http.ResponseWriter, *http.Request)
+	ApiNxfsReleasesPost(http.ResponseWriter, *http.Request)
+	ApiNxfsReleasesGet(http.ResponseWriter, *http.Request)
+	ApiNxfsReleasesIdGet(http.ResponseWriter, *http.Request)
+	ApiNxfsReleasesIdRollbackPost(http.ResponseWriter, *http.Request)
+	ApiNxfsDiffGet(http.ResponseWriter, *http.Request)
+	ApiNxfsDiffEncodedPathGet(http.ResponseWriter, *http.Request)
+	ApiNxfsWorkflowGet(http.ResponseWriter, *http.Request)
+	ApiNxfsWorkflowEncodedPathGet(http.ResponseWriter, *http.Request)
+	ApiNxfsWorkflowEncodedPathPost(http.ResponseWriter, *http.Request)
+	ApiNxfsReferencesEncodedPathGet(http.ResponseWriter, *http.Request)
+	ApiNxfsExportEncodedPathGet(http.ResponseWriter, *http.Request)
+	ApiNxfsImportEncodedPathPost(http.ResponseWriter, *http.Request)
+	ApiNxfsUploadsPost(http.ResponseWriter, *http.Request)
+	ApiNxfsUploadsIdGet(http.ResponseWriter, *http.Request)
+	ApiNxfsUploadsIdPatch(http.ResponseWriter, *http.Request)
+	ApiNxfsUploadsIdDelete(http.ResponseWriter, *http.Request)
+	ApiNxfsUploadsIdFinalizePost(http.ResponseWriter, *http.Request)
+	ApiNxfsBatchPost(http.ResponseWriter, *http.Request)
+	ApiNxfsHistoryEncodedPathGet(http.ResponseWriter, *http.Request)
+	ApiNxfsCommitsIdGet(http.ResponseWriter, *http.Request)
+	ApiNxfsCommitsIdRevertPost(http.ResponseWriter, *http.Request)
+	ApiNxfsGitPushPost(http.ResponseWriter, *http.Request)
+	ApiNxfsGitPullPost(http.ResponseWriter, *http.Request)
 }
 
 // DefaultApiServicer defines the api actions for the DefaultApi service
@@ -33,10 +68,44 @@ type DefaultApiRouter interface {
 // while the service implementation can ignored with the .openapi-generator-ignore file
 // and updated with the logic required for the API.
 type DefaultApiServicer interface {
-	ApiNxfsBrowseEncodedPathGet(context.Context, string, int32) (net.NxfsResponse, error)
+	ApiNxfsBrowseEncodedPathGet(context.Context, string, int32, []string) (net.NxfsResponse, error)
 	ApiNxfsObjectsEncodedPathDelete(context.Context, string) (net.NxfsResponse, error)
 	ApiNxfsObjectsEncodedPathGet(context.Context, string) (net.NxfsResponse, error)
-	ApiNxfsObjectsEncodedPathPublishPost(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsRawEncodedPathGet(context.Context, string, string, string, string, string) (net.NxfsResponse, error)
+	ApiNxfsObjectsEncodedPathPublishPost(context.Context, string, string, bool) (net.NxfsResponse, error)
 	ApiNxfsObjectsEncodedPathPut(context.Context, string, model.FileObject) (net.NxfsResponse, error)
-	ApiNxfsObjectsEncodedPathUnpublishPost(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsObjectsEncodedPathUnpublishPost(context.Context, string, string) (net.NxfsResponse, error)
+	ApiNxfsAuditGet(context.Context, string, string, string, string) (net.NxfsResponse, error)
+	ApiNxfsUsageGet(context.Context) (net.NxfsResponse, error)
+	ApiNxfsObjectsEncodedPathLockGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsObjectsEncodedPathLockPost(context.Context, string, model.LockRequest) (net.NxfsResponse, error)
+	ApiNxfsObjectsEncodedPathLockPut(context.Context, string, model.LockRequest) (net.NxfsResponse, error)
+	ApiNxfsObjectsEncodedPathLockDelete(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsAdminLocksEncodedPathDelete(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsSchemasPageVersionGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsSchedulesGet(context.Context) (net.NxfsResponse, error)
+	ApiNxfsSchedulesIdDelete(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsReleasesPost(context.Context, model.ReleaseRequest) (net.NxfsResponse, error)
+	ApiNxfsReleasesGet(context.Context) (net.NxfsResponse, error)
+	ApiNxfsReleasesIdGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsReleasesIdRollbackPost(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsDiffGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsDiffEncodedPathGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsWorkflowGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsWorkflowEncodedPathGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsWorkflowEncodedPathPost(context.Context, string, model.WorkflowTransition) (net.NxfsResponse, error)
+	ApiNxfsReferencesEncodedPathGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsExportEncodedPathGet(context.Context, string, string) (net.NxfsResponse, error)
+	ApiNxfsImportEncodedPathPost(context.Context, string, string, bool, io.Reader) (net.NxfsResponse, error)
+	ApiNxfsUploadsPost(context.Context, model.UploadRequest) (net.NxfsResponse, error)
+	ApiNxfsUploadsIdGet(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsUploadsIdPatch(context.Context, string, string, io.Reader) (net.NxfsResponse, error)
+	ApiNxfsUploadsIdDelete(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsUploadsIdFinalizePost(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsBatchPost(context.Context, model.BatchRequest) (net.NxfsResponse, error)
+	ApiNxfsHistoryEncodedPathGet(context.Context, string, int32) (net.NxfsResponse, error)
+	ApiNxfsCommitsIdGet(context.Context, string, string) (net.NxfsResponse, error)
+	ApiNxfsCommitsIdRevertPost(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsGitPushPost(context.Context, string) (net.NxfsResponse, error)
+	ApiNxfsGitPullPost(context.Context) (net.NxfsResponse, error)
 }
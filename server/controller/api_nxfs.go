@@ -11,9 +11,14 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/entando/entando-nxfs/server"
+	"github.com/entando/entando-nxfs/server/helper"
 	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -33,63 +38,277 @@ func NewDefaultApiController(s DefaultApiServicer) nxsiteman.Router {
 func (c *DefaultApiController) Routes() nxsiteman.Routes {
 	return nxsiteman.Routes{
 		{
-			"ApiNxfsBrowseEncodedPathGet",
-			strings.ToUpper("Get"),
-			"/api/nxfs/browse/{EncodedPath}",
-			c.ApiNxfsBrowseEncodedPathGet,
+			Name:        "ApiNxfsBrowseEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/browse/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsBrowseEncodedPathGet,
 		},
 		{
-			"ApiNxfsObjectsEncodedPathDelete",
-			strings.ToUpper("Delete"),
-			"/api/nxfs/objects/{EncodedPath}",
-			c.ApiNxfsObjectsEncodedPathDelete,
+			Name:        "ApiNxfsObjectsEncodedPathDelete",
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathDelete,
 		},
 		{
-			"ApiNxfsObjectsEncodedPathGet",
-			strings.ToUpper("Get"),
-			"/api/nxfs/objects/{EncodedPath}",
-			c.ApiNxfsObjectsEncodedPathGet,
+			Name:        "ApiNxfsObjectsEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathGet,
 		},
 		{
-			"ApiNxfsObjectsEncodedPathPublishPost",
-			strings.ToUpper("Post"),
-			"/api/nxfs/objects/{EncodedPath}/publish",
-			c.ApiNxfsObjectsEncodedPathPublishPost,
+			Name:        "ApiNxfsRawEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/raw/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsRawEncodedPathGet,
 		},
 		{
-			"ApiNxfsObjectsEncodedPathPut",
-			strings.ToUpper("Put"),
-			"/api/nxfs/objects/{EncodedPath}",
-			c.ApiNxfsObjectsEncodedPathPut,
+			Name:        "ApiNxfsObjectsEncodedPathPublishPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}/publish",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathPublishPost,
 		},
 		{
-			"ApiNxfsObjectsEncodedPathUnpublishPost",
-			strings.ToUpper("Post"),
-			"/api/nxfs/objects/{EncodedPath}/unpublish",
-			c.ApiNxfsObjectsEncodedPathUnpublishPost,
+			Name:        "ApiNxfsObjectsEncodedPathPut",
+			Method:      strings.ToUpper("Put"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathPut,
+		},
+		{
+			Name:        "ApiNxfsObjectsEncodedPathUnpublishPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}/unpublish",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathUnpublishPost,
+		},
+		{
+			Name:        "ApiNxfsAuditGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/audit",
+			HandlerFunc: c.ApiNxfsAuditGet,
+		},
+		{
+			Name:        "ApiNxfsUsageGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/usage",
+			HandlerFunc: c.ApiNxfsUsageGet,
+		},
+		{
+			Name:        "ApiNxfsObjectsEncodedPathLockGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}/lock",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathLockGet,
+		},
+		{
+			Name:        "ApiNxfsObjectsEncodedPathLockPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}/lock",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathLockPost,
+		},
+		{
+			Name:        "ApiNxfsObjectsEncodedPathLockPut",
+			Method:      strings.ToUpper("Put"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}/lock",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathLockPut,
+		},
+		{
+			Name:        "ApiNxfsObjectsEncodedPathLockDelete",
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "/api/nxfs/objects/{EncodedPath}/lock",
+			HandlerFunc: c.ApiNxfsObjectsEncodedPathLockDelete,
+		},
+		{
+			Name:        "ApiNxfsAdminLocksEncodedPathDelete",
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "/api/nxfs/admin/locks/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsAdminLocksEncodedPathDelete,
+		},
+		{
+			Name:        "ApiNxfsSchemasPageVersionGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/schemas/page/{Version}",
+			HandlerFunc: c.ApiNxfsSchemasPageVersionGet,
+		},
+		{
+			Name:        "ApiNxfsSchedulesGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/schedules",
+			HandlerFunc: c.ApiNxfsSchedulesGet,
+		},
+		{
+			Name:        "ApiNxfsSchedulesIdDelete",
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "/api/nxfs/schedules/{Id}",
+			HandlerFunc: c.ApiNxfsSchedulesIdDelete,
+		},
+		{
+			Name:        "ApiNxfsReleasesPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/releases",
+			HandlerFunc: c.ApiNxfsReleasesPost,
+		},
+		{
+			Name:        "ApiNxfsReleasesGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/releases",
+			HandlerFunc: c.ApiNxfsReleasesGet,
+		},
+		{
+			Name:        "ApiNxfsReleasesIdGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/releases/{Id}",
+			HandlerFunc: c.ApiNxfsReleasesIdGet,
+		},
+		{
+			Name:        "ApiNxfsReleasesIdRollbackPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/releases/{Id}/rollback",
+			HandlerFunc: c.ApiNxfsReleasesIdRollbackPost,
+		},
+		{
+			Name:        "ApiNxfsDiffGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/diff",
+			HandlerFunc: c.ApiNxfsDiffGet,
+		},
+		{
+			Name:        "ApiNxfsDiffEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/diff/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsDiffEncodedPathGet,
+		},
+		{
+			Name:        "ApiNxfsWorkflowGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/workflow",
+			HandlerFunc: c.ApiNxfsWorkflowGet,
+		},
+		{
+			Name:        "ApiNxfsWorkflowEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/workflow/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsWorkflowEncodedPathGet,
+		},
+		{
+			Name:        "ApiNxfsWorkflowEncodedPathPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/workflow/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsWorkflowEncodedPathPost,
+		},
+		{
+			Name:        "ApiNxfsReferencesEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/references/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsReferencesEncodedPathGet,
+		},
+		{
+			Name:        "ApiNxfsExportEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/export/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsExportEncodedPathGet,
+		},
+		{
+			Name:        "ApiNxfsImportEncodedPathPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/import/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsImportEncodedPathPost,
+		},
+		{
+			Name:        "ApiNxfsUploadsPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/uploads",
+			HandlerFunc: c.ApiNxfsUploadsPost,
+		},
+		{
+			Name:        "ApiNxfsUploadsIdGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/uploads/{Id}",
+			HandlerFunc: c.ApiNxfsUploadsIdGet,
+		},
+		{
+			Name:        "ApiNxfsUploadsIdPatch",
+			Method:      strings.ToUpper("Patch"),
+			Pattern:     "/api/nxfs/uploads/{Id}",
+			HandlerFunc: c.ApiNxfsUploadsIdPatch,
+		},
+		{
+			Name:        "ApiNxfsUploadsIdDelete",
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "/api/nxfs/uploads/{Id}",
+			HandlerFunc: c.ApiNxfsUploadsIdDelete,
+		},
+		{
+			Name:        "ApiNxfsUploadsIdFinalizePost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/uploads/{Id}/finalize",
+			HandlerFunc: c.ApiNxfsUploadsIdFinalizePost,
+		},
+		{
+			Name:        "ApiNxfsBatchPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/batch",
+			HandlerFunc: c.ApiNxfsBatchPost,
+		},
+		{
+			Name:        "ApiNxfsHistoryEncodedPathGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/history/{EncodedPath}",
+			HandlerFunc: c.ApiNxfsHistoryEncodedPathGet,
+		},
+		{
+			Name:        "ApiNxfsCommitsIdGet",
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "/api/nxfs/commits/{Id}",
+			HandlerFunc: c.ApiNxfsCommitsIdGet,
+		},
+		{
+			Name:        "ApiNxfsCommitsIdRevertPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/commits/{Id}/revert",
+			HandlerFunc: c.ApiNxfsCommitsIdRevertPost,
+		},
+		{
+			Name:        "ApiNxfsGitPushPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/git/push",
+			HandlerFunc: c.ApiNxfsGitPushPost,
+		},
+		{
+			Name:        "ApiNxfsGitPullPost",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/api/nxfs/git/pull",
+			HandlerFunc: c.ApiNxfsGitPullPost,
 		},
 	}
 }
 
-// ApiNxfsBrowseEncodedPathGet - Gets the list of objects in a directory
+// ApiNxfsBrowseEncodedPathGet - Gets the list of objects in a directory, optionally filtered by type
 func (c *DefaultApiController) ApiNxfsBrowseEncodedPathGet(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	query := r.URL.Query()
 	encodedPath := params["EncodedPath"]
-	maxdepth, err := nxsiteman.ParseInt32Parameter(query.Get("maxdepth"))
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	var maxdepth int32
+	if maxdepthParam := query.Get("maxdepth"); "" != maxdepthParam {
+		var err error
+		if maxdepth, err = nxsiteman.ParseInt32Parameter(maxdepthParam); err != nil || maxdepth < 0 {
+			nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_maxdepth", "The maxdepth parameter must be a non negative integer"), w, r)
+			return
+		}
+	}
+
+	var mimeTypes []string
+	for _, mimeType := range strings.Split(query.Get("mimeType"), ",") {
+		if mimeType = strings.TrimSpace(mimeType); "" != mimeType {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
 	}
 
-	result, err := c.service.ApiNxfsBrowseEncodedPathGet(r.Context(), encodedPath, maxdepth)
+	result, err := c.service.ApiNxfsBrowseEncodedPathGet(r.Context(), encodedPath, maxdepth, mimeTypes)
 	//If an error occured, encode the error with the status code
 	if err != nil {
-		nxsiteman.EncodeJSONResponse(err.Error(), &result.Code, w)
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 	//If no error, encode the body and the result code
-	nxsiteman.EncodeJSONResponse(result.Body, &result.Code, w)
+	nxsiteman.EncodeResponse(result, w, r)
 
 }
 
@@ -100,11 +319,11 @@ func (c *DefaultApiController) ApiNxfsObjectsEncodedPathDelete(w http.ResponseWr
 	result, err := c.service.ApiNxfsObjectsEncodedPathDelete(r.Context(), encodedPath)
 	//If an error occured, encode the error with the status code
 	if err != nil {
-		nxsiteman.EncodeJSONResponse(err.Error(), &result.Code, w)
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 	//If no error, encode the body and the result code
-	nxsiteman.EncodeJSONResponse(result.Body, &result.Code, w)
+	nxsiteman.EncodeResponse(result, w, r)
 
 }
 
@@ -115,26 +334,49 @@ func (c *DefaultApiController) ApiNxfsObjectsEncodedPathGet(w http.ResponseWrite
 	result, err := c.service.ApiNxfsObjectsEncodedPathGet(r.Context(), encodedPath)
 	//If an error occured, encode the error with the status code
 	if err != nil {
-		nxsiteman.EncodeJSONResponse(err.Error(), &result.Code, w)
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsRawEncodedPathGet - Gets the content of a file as is, or a rendition of an image
+func (c *DefaultApiController) ApiNxfsRawEncodedPathGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsRawEncodedPathGet(r.Context(), encodedPath, query.Get("width"), query.Get("height"), query.Get("fit"), query.Get("format"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 	//If no error, encode the body and the result code
-	nxsiteman.EncodeJSONResponse(result.Body, &result.Code, w)
+	nxsiteman.EncodeResponse(result, w, r)
 
 }
 
 // ApiNxfsObjectsEncodedPathPublishPost - Publishes a page
 func (c *DefaultApiController) ApiNxfsObjectsEncodedPathPublishPost(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
+	query := r.URL.Query()
 	encodedPath := params["EncodedPath"]
-	result, err := c.service.ApiNxfsObjectsEncodedPathPublishPost(r.Context(), encodedPath)
+	withAssets, err := parseOptionalBool(query.Get("withAssets"))
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_with_assets", "The withAssets parameter must be a boolean"), w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsObjectsEncodedPathPublishPost(r.Context(), encodedPath, query.Get("at"), withAssets)
 	//If an error occured, encode the error with the status code
 	if err != nil {
-		nxsiteman.EncodeJSONResponse(err.Error(), &result.Code, w)
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 	//If no error, encode the body and the result code
-	nxsiteman.EncodeJSONResponse(result.Body, &result.Code, w)
+	nxsiteman.EncodeResponse(result, w, r)
 
 }
 
@@ -143,33 +385,612 @@ func (c *DefaultApiController) ApiNxfsObjectsEncodedPathPut(w http.ResponseWrite
 	params := mux.Vars(r)
 	encodedPath := params["EncodedPath"]
 	fileObject := &model.FileObject{}
-	if err := json.NewDecoder(r.Body).Decode(&fileObject); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJSONBody(r, &fileObject); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 
 	result, err := c.service.ApiNxfsObjectsEncodedPathPut(r.Context(), encodedPath, *fileObject)
 	//If an error occured, encode the error with the status code
 	if err != nil {
-		nxsiteman.EncodeJSONResponse(err.Error(), &result.Code, w)
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 	//If no error, encode the body and the result code
-	nxsiteman.EncodeJSONResponse(result.Body, &result.Code, w)
+	nxsiteman.EncodeResponse(result, w, r)
 
 }
 
 // ApiNxfsObjectsEncodedPathUnpublishPost - Unpublishes a page
 func (c *DefaultApiController) ApiNxfsObjectsEncodedPathUnpublishPost(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
+	query := r.URL.Query()
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsObjectsEncodedPathUnpublishPost(r.Context(), encodedPath, query.Get("at"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsAuditGet - Queries the audit log
+func (c *DefaultApiController) ApiNxfsAuditGet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result, err := c.service.ApiNxfsAuditGet(r.Context(), query.Get("path"), query.Get("user"), query.Get("from"), query.Get("to"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsUsageGet - Gets the space used by the browsable fs and by the folders having a quota
+func (c *DefaultApiController) ApiNxfsUsageGet(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.ApiNxfsUsageGet(r.Context())
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsObjectsEncodedPathLockGet - Gets the client lock held on an object
+func (c *DefaultApiController) ApiNxfsObjectsEncodedPathLockGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsObjectsEncodedPathLockGet(r.Context(), encodedPath)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsObjectsEncodedPathLockPost - Acquires a client lock on an object
+func (c *DefaultApiController) ApiNxfsObjectsEncodedPathLockPost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	lockRequest := &model.LockRequest{}
+	if err := decodeOptionalJSONBody(r, lockRequest); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsObjectsEncodedPathLockPost(r.Context(), encodedPath, *lockRequest)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsObjectsEncodedPathLockPut - Refreshes the client lock held on an object
+func (c *DefaultApiController) ApiNxfsObjectsEncodedPathLockPut(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	lockRequest := &model.LockRequest{}
+	if err := decodeOptionalJSONBody(r, lockRequest); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsObjectsEncodedPathLockPut(r.Context(), encodedPath, *lockRequest)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsObjectsEncodedPathLockDelete - Releases the client lock held on an object
+func (c *DefaultApiController) ApiNxfsObjectsEncodedPathLockDelete(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsObjectsEncodedPathLockDelete(r.Context(), encodedPath)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsAdminLocksEncodedPathDelete - Forcibly releases the client lock held on an object
+func (c *DefaultApiController) ApiNxfsAdminLocksEncodedPathDelete(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsAdminLocksEncodedPathDelete(r.Context(), encodedPath)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsSchemasPageVersionGet - Gets the JSON schema of a page document version
+func (c *DefaultApiController) ApiNxfsSchemasPageVersionGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	version := params["Version"]
+	result, err := c.service.ApiNxfsSchemasPageVersionGet(r.Context(), version)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsSchedulesGet - Lists the pending and the failed schedules
+func (c *DefaultApiController) ApiNxfsSchedulesGet(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.ApiNxfsSchedulesGet(r.Context())
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsSchedulesIdDelete - Cancels a pending schedule or dismisses a failed one
+func (c *DefaultApiController) ApiNxfsSchedulesIdDelete(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsSchedulesIdDelete(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsReleasesPost - Publishes a set of draft pages as a single release
+func (c *DefaultApiController) ApiNxfsReleasesPost(w http.ResponseWriter, r *http.Request) {
+	releaseRequest := &model.ReleaseRequest{}
+	if err := decodeJSONBody(r, releaseRequest); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsReleasesPost(r.Context(), *releaseRequest)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsReleasesGet - Lists the releases
+func (c *DefaultApiController) ApiNxfsReleasesGet(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.ApiNxfsReleasesGet(r.Context())
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsReleasesIdGet - Gets a release
+func (c *DefaultApiController) ApiNxfsReleasesIdGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsReleasesIdGet(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsReleasesIdRollbackPost - Restores the pages of a release to their content before it
+func (c *DefaultApiController) ApiNxfsReleasesIdRollbackPost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsReleasesIdRollbackPost(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsDiffGet - Lists the pages whose draft differs from the published copy
+func (c *DefaultApiController) ApiNxfsDiffGet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result, err := c.service.ApiNxfsDiffGet(r.Context(), query.Get("path"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsDiffEncodedPathGet - Compares a draft page with its published copy
+func (c *DefaultApiController) ApiNxfsDiffEncodedPathGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsDiffEncodedPathGet(r.Context(), encodedPath)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsWorkflowGet - Lists the draft pages subject to the review workflow
+func (c *DefaultApiController) ApiNxfsWorkflowGet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result, err := c.service.ApiNxfsWorkflowGet(r.Context(), query.Get("state"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsWorkflowEncodedPathGet - Gets the review workflow state of a draft page
+func (c *DefaultApiController) ApiNxfsWorkflowEncodedPathGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsWorkflowEncodedPathGet(r.Context(), encodedPath)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsWorkflowEncodedPathPost - Moves a draft page to another review workflow state
+func (c *DefaultApiController) ApiNxfsWorkflowEncodedPathPost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	workflowTransition := &model.WorkflowTransition{}
+	if err := decodeJSONBody(r, workflowTransition); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsWorkflowEncodedPathPost(r.Context(), encodedPath, *workflowTransition)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsReferencesEncodedPathGet - Lists the pages referencing an object
+func (c *DefaultApiController) ApiNxfsReferencesEncodedPathGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	encodedPath := params["EncodedPath"]
+	result, err := c.service.ApiNxfsReferencesEncodedPathGet(r.Context(), encodedPath)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsExportEncodedPathGet - Exports an object and everything under it as an archive
+func (c *DefaultApiController) ApiNxfsExportEncodedPathGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
 	encodedPath := params["EncodedPath"]
-	result, err := c.service.ApiNxfsObjectsEncodedPathUnpublishPost(r.Context(), encodedPath)
+	result, err := c.service.ApiNxfsExportEncodedPathGet(r.Context(), encodedPath, query.Get("format"))
 	//If an error occured, encode the error with the status code
 	if err != nil {
-		nxsiteman.EncodeJSONResponse(err.Error(), &result.Code, w)
+		nxsiteman.EncodeErrorResponse(err, w, r)
 		return
 	}
 	//If no error, encode the body and the result code
-	nxsiteman.EncodeJSONResponse(result.Body, &result.Code, w)
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
 
+// ApiNxfsImportEncodedPathPost - Imports an archive into a directory
+func (c *DefaultApiController) ApiNxfsImportEncodedPathPost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+	encodedPath := params["EncodedPath"]
+	dryRun, err := parseOptionalBool(query.Get("dryRun"))
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_dry_run", "The dryRun parameter must be a boolean"), w, r)
+		return
+	}
+
+	if maxSize := helper.GetMaxArchiveSize(); r.ContentLength > maxSize {
+		nxsiteman.EncodeErrorResponse(helper.BodyTooLargeError(maxSize), w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsImportEncodedPathPost(r.Context(), encodedPath, query.Get("policy"), dryRun, helper.LimitBody(r.Body, helper.GetMaxArchiveSize()))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsUploadsPost - Starts a resumable upload
+func (c *DefaultApiController) ApiNxfsUploadsPost(w http.ResponseWriter, r *http.Request) {
+	uploadRequest := &model.UploadRequest{}
+	if err := decodeJSONBody(r, uploadRequest); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsUploadsPost(r.Context(), *uploadRequest)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsUploadsIdGet - Gets a resumable upload and its progress
+func (c *DefaultApiController) ApiNxfsUploadsIdGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsUploadsIdGet(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsUploadsIdPatch - Appends a chunk to a resumable upload
+func (c *DefaultApiController) ApiNxfsUploadsIdPatch(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+	id := params["Id"]
+	if maxSize := helper.GetMaxChunkSize(); r.ContentLength > maxSize {
+		nxsiteman.EncodeErrorResponse(helper.BodyTooLargeError(maxSize), w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsUploadsIdPatch(r.Context(), id, query.Get("offset"), helper.LimitBody(r.Body, helper.GetMaxChunkSize()))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsUploadsIdDelete - Aborts a resumable upload, discarding the received chunks
+func (c *DefaultApiController) ApiNxfsUploadsIdDelete(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsUploadsIdDelete(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsUploadsIdFinalizePost - Writes the file of a complete resumable upload to its path
+func (c *DefaultApiController) ApiNxfsUploadsIdFinalizePost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsUploadsIdFinalizePost(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsBatchPost - Executes a list of operations in order, all or nothing or each one on its own
+func (c *DefaultApiController) ApiNxfsBatchPost(w http.ResponseWriter, r *http.Request) {
+	batchRequest := &model.BatchRequest{}
+	if err := decodeJSONBody(r, batchRequest); err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	result, err := c.service.ApiNxfsBatchPost(r.Context(), *batchRequest)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsHistoryEncodedPathGet - Lists the commits changing an object
+func (c *DefaultApiController) ApiNxfsHistoryEncodedPathGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+	encodedPath := params["EncodedPath"]
+	var limit int32
+	if limitParam := query.Get("limit"); "" != limitParam {
+		var err error
+		if limit, err = nxsiteman.ParseInt32Parameter(limitParam); err != nil || limit < 0 {
+			nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_limit", "The limit parameter must be a non negative integer"), w, r)
+			return
+		}
+	}
+
+	result, err := c.service.ApiNxfsHistoryEncodedPathGet(r.Context(), encodedPath, limit)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsCommitsIdGet - Gets a commit with its diff
+func (c *DefaultApiController) ApiNxfsCommitsIdGet(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+	id := params["Id"]
+	result, err := c.service.ApiNxfsCommitsIdGet(r.Context(), id, query.Get("path"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsCommitsIdRevertPost - Commits the changes undoing a commit
+func (c *DefaultApiController) ApiNxfsCommitsIdRevertPost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["Id"]
+	result, err := c.service.ApiNxfsCommitsIdRevertPost(r.Context(), id)
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsGitPushPost - Pushes the committed changes to a branch of the git remote
+func (c *DefaultApiController) ApiNxfsGitPushPost(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result, err := c.service.ApiNxfsGitPushPost(r.Context(), query.Get("branch"))
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// ApiNxfsGitPullPost - Fast forwards the browsable fs to the branch of the git remote
+func (c *DefaultApiController) ApiNxfsGitPullPost(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.ApiNxfsGitPullPost(r.Context())
+	//If an error occured, encode the error with the status code
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+	//If no error, encode the body and the result code
+	nxsiteman.EncodeResponse(result, w, r)
+
+}
+
+// parseOptionalBool - parse a boolean query parameter, false if missing
+func parseOptionalBool(value string) (bool, error) {
+	if "" == value {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// decodeJSONBody - decode the JSON request body into target
+func decodeJSONBody(r *http.Request, target interface{}) error {
+	return decodeLimitedJSONBody(r, target, false)
+}
+
+// decodeOptionalJSONBody - decode the JSON request body into target, leaving target untouched if the body is empty
+func decodeOptionalJSONBody(r *http.Request, target interface{}) error {
+	return decodeLimitedJSONBody(r, target, true)
+}
+
+// decodeLimitedJSONBody - decode the JSON request body into target, failing with a body_too_large error if it's larger than the configured maximum
+func decodeLimitedJSONBody(r *http.Request, target interface{}, optional bool) error {
+	maxSize := helper.GetMaxBodySize()
+	if r.ContentLength > maxSize {
+		return helper.BodyTooLargeError(maxSize)
+	}
+
+	err := json.NewDecoder(helper.LimitBody(r.Body, maxSize)).Decode(target)
+	switch {
+	case err == nil, optional && err == io.EOF:
+		return nil
+	case errors.Is(err, nxfserrors.ErrTooLarge):
+		return err
+	}
+	return nxfserrors.New(nxfserrors.ErrInvalid, "invalid_body", "The request body is not a valid object: "+err.Error())
 }
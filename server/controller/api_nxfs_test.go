@@ -0,0 +1,832 @@
+package controller_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	nxsiteman "github.com/entando/entando-nxfs/server"
+	"github.com/entando/entando-nxfs/server/controller"
+	"github.com/entando/entando-nxfs/server/nxfsauth"
+	"github.com/entando/entando-nxfs/server/service"
+	"github.com/gorilla/mux"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const homePage = `{"schemaVersion":1,"title":"Home","template":"templates/main.ftl","frames":[{"pos":0,"name":"body"}]}`
+
+// seedDir - the browsable fs directory the memory storage starts from, it must never be changed by the tests
+var seedDir string
+
+// dataDir - the directory of the audit log, the schedules, the workflow, the scan results, the quarantine and the renditions
+var dataDir string
+
+// logoPNG - the content of the 40x20 images/logo.png image of the browsable fs
+var logoPNG []byte
+
+// serveFakeClamd - answer the INSTREAM commands like a clamd daemon finding the EICAR malware in the streams containing EICAR
+func serveFakeClamd(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		var stream bytes.Buffer
+		command := make([]byte, len("zINSTREAM\x00"))
+		if _, err = io.ReadFull(conn, command); err == nil {
+			for {
+				var size uint32
+				if err = binary.Read(conn, binary.BigEndian, &size); err != nil || size == 0 {
+					break
+				}
+				if _, err = io.CopyN(&stream, conn, int64(size)); err != nil {
+					break
+				}
+			}
+		}
+		if strings.Contains(stream.String(), "EICAR") {
+			io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+		} else {
+			io.WriteString(conn, "stream: OK\x00")
+		}
+		conn.Close()
+	}
+}
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "nxfs-api")
+	if err != nil {
+		panic(err)
+	}
+	seedDir = filepath.Join(dir, "fs")
+	if err = os.MkdirAll(filepath.Join(seedDir, "templates"), 0755); err != nil {
+		panic(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(seedDir, "templates", "main.ftl"), []byte("<html/>"), 0644); err != nil {
+		panic(err)
+	}
+	if err = os.MkdirAll(filepath.Join(seedDir, "images"), 0755); err != nil {
+		panic(err)
+	}
+	var logo bytes.Buffer
+	if err = png.Encode(&logo, image.NewGray(image.Rect(0, 0, 40, 20))); err != nil {
+		panic(err)
+	}
+	logoPNG = logo.Bytes()
+	if err = ioutil.WriteFile(filepath.Join(seedDir, "images", "logo.png"), logoPNG, 0644); err != nil {
+		panic(err)
+	}
+	dataDir = filepath.Join(dir, "data")
+	clamd, err := net.Listen("unix", filepath.Join(dir, "clamd.sock"))
+	if err != nil {
+		panic(err)
+	}
+	go serveFakeClamd(clamd)
+
+	// the memory storage keeps the tests off the disk, only the data dir holds the audit log, the schedules and the workflow
+	_ = os.Setenv("NXFS_STORAGE", "memory")
+	_ = os.Setenv("BROWSABLE_FS", seedDir)
+	_ = os.Setenv("NXFS_DATA_DIR", dataDir)
+	_ = os.Setenv("NXFS_WORKFLOW_PATHS", "news")
+	_ = os.Setenv("NXFS_QUOTAS", "quota=20:2")
+	_ = os.Setenv("NXFS_MAX_FILE_SIZE", "1K")
+	_ = os.Setenv("NXFS_MAX_BODY_SIZE", "8K")
+	_ = os.Setenv("NXFS_SCANNER", "clamd")
+	_ = os.Setenv("NXFS_CLAMD_ADDRESS", "unix:"+filepath.Join(dir, "clamd.sock"))
+	_ = os.Setenv("NXFS_TYPE_RULES", `[{"path":"types","allow":["text/*"],"deny":[".md"]},{"path":"/","deny":["application/x-executable"]}]`)
+
+	code := m.Run()
+	_ = clamd.Close()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// apiClient - send the requests of a test to the nxfs router, recording the routes they reach
+type apiClient struct {
+	t       *testing.T
+	router  *mux.Router
+	server  *httptest.Server
+	reached map[string]bool
+}
+
+// apiResponse - the status, the headers and the decoded body of a response
+type apiResponse struct {
+	status int
+	header http.Header
+	body   map[string]interface{}
+	raw    []byte
+}
+
+func newApiClient(t *testing.T) *apiClient {
+	router := nxsiteman.NewRouter(controller.NewDefaultApiController(service.NewDefaultApiService()))
+	return &apiClient{t: t, router: router, server: httptest.NewServer(nxfsauth.Handler(router, nil)), reached: map[string]bool{}}
+}
+
+// token - a bearer JWT carrying the received user and realm roles, unsigned as the tokens verified by a trusted gateway
+func token(user string, roles ...string) string {
+	encode := func(value interface{}) string {
+		data, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	claims := map[string]interface{}{"preferred_username": user, "realm_access": map[string]interface{}{"roles": roles}}
+	return encode(map[string]string{"alg": "none"}) + "." + encode(claims) + ".x"
+}
+
+// do - send a request as the received user and check its status
+func (c *apiClient) do(user string, roles []string, method string, path string, body interface{}, expectedStatus int) apiResponse {
+	c.t.Helper()
+
+	var reader *bytes.Reader
+	switch value := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case []byte:
+		reader = bytes.NewReader(value)
+	default:
+		data, _ := json.Marshal(value)
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+token(user, roles...))
+
+	var match mux.RouteMatch
+	if c.router.Match(request, &match) && match.Route != nil {
+		c.reached[match.Route.GetName()] = true
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	result := apiResponse{status: response.StatusCode, header: response.Header}
+	if result.raw, err = ioutil.ReadAll(response.Body); err != nil {
+		c.t.Fatal(err)
+	}
+	_ = json.Unmarshal(result.raw, &result.body)
+	if result.status != expectedStatus {
+		c.t.Fatalf("%s %s: expected status %d, got %d %s", method, path, expectedStatus, result.status, result.raw)
+	}
+	return result
+}
+
+// as - send a request as an editor without roles
+func (c *apiClient) as(user string, method string, path string, body interface{}, expectedStatus int) apiResponse {
+	c.t.Helper()
+	return c.do(user, nil, method, path, body, expectedStatus)
+}
+
+// code - check the code of an error response
+func (r apiResponse) code(t *testing.T, expectedCode string) {
+	t.Helper()
+	if r.body["code"] != expectedCode {
+		t.Fatalf("expected the error code %s, got %s", expectedCode, r.raw)
+	}
+}
+
+// list - return the list of a list response
+func (r apiResponse) list() []interface{} {
+	list, _ := r.body["list"].([]interface{})
+	return list
+}
+
+// names - return the path and the name of the objects of a browse response
+func (r apiResponse) names() string {
+	var names []string
+	for _, object := range r.list() {
+		object := object.(map[string]interface{})
+		names = append(names, fmt.Sprintf("%v/%v", object["path"], object["name"]))
+	}
+	return strings.Join(names, ",")
+}
+
+func file(name string, content string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "type": "f", "content": content}
+}
+
+func dir(name string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "type": "d"}
+}
+
+func encode(relPath string) string {
+	return url.PathEscape(relPath)
+}
+
+func TestApi(t *testing.T) {
+	c := newApiClient(t)
+	defer c.server.Close()
+
+	t.Run("objects", func(t *testing.T) {
+		c.t = t
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs"), dir("docs"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/sub"), dir("sub"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/a.txt"), file("a.txt", "a"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/sub/b.txt"), file("b.txt", "b"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("missing/c.txt"), file("c.txt", "c"), http.StatusNotFound)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs"), file("docs", "x"), http.StatusConflict)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/a.txt"), []byte("{"), http.StatusBadRequest).code(t, "invalid_body")
+
+		object := c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/a.txt"), nil, http.StatusOK)
+		if object.body["content"] != "a" || object.body["name"] != "a.txt" {
+			t.Fatalf("unexpected object %s", object.raw)
+		}
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs"), nil, http.StatusBadRequest).code(t, "dir_requested")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/none.txt"), nil, http.StatusNotFound)
+
+		// the browse lists the files, down to maxdepth levels of directories
+		if names := c.as("alice", "GET", "/api/nxfs/browse/"+encode("docs"), nil, http.StatusOK).names(); names != "docs/a.txt,docs/sub/b.txt" {
+			t.Fatalf("unexpected browse %s", names)
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/"+encode("docs")+"?maxdepth=1", nil, http.StatusOK).names(); names != "docs/a.txt" {
+			t.Fatalf("unexpected browse %s", names)
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/"+encode("docs/a.txt"), nil, http.StatusOK).names(); names != "docs/a.txt" {
+			t.Fatalf("unexpected browse %s", names)
+		}
+		c.as("alice", "GET", "/api/nxfs/browse/"+encode("docs")+"?maxdepth=-1", nil, http.StatusBadRequest).code(t, "invalid_maxdepth")
+		c.as("alice", "GET", "/api/nxfs/browse/"+encode("none"), nil, http.StatusNotFound)
+
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("docs/sub"), nil, http.StatusUnprocessableEntity).code(t, "dir_not_empty")
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("docs/sub/b.txt"), nil, http.StatusNoContent)
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("docs/sub"), nil, http.StatusNoContent)
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("docs/sub"), nil, http.StatusNoContent)
+		if names := c.as("alice", "GET", "/api/nxfs/browse/"+encode("docs"), nil, http.StatusOK).names(); names != "docs/a.txt" {
+			t.Fatalf("unexpected browse %s", names)
+		}
+	})
+
+	t.Run("locks", func(t *testing.T) {
+		c.t = t
+		path := "/api/nxfs/objects/" + encode("docs/a.txt")
+		c.as("alice", "GET", path+"/lock", nil, http.StatusNotFound)
+		lock := c.as("alice", "POST", path+"/lock", map[string]interface{}{"ttl": 60}, http.StatusOK)
+		if lock.body["owner"] != "alice" {
+			t.Fatalf("unexpected lock %s", lock.raw)
+		}
+		c.as("bob", "POST", path+"/lock", nil, http.StatusLocked)
+		c.as("bob", "PUT", path, file("a.txt", "bob"), http.StatusLocked)
+		c.as("alice", "PUT", path, file("a.txt", "alice"), http.StatusCreated)
+		// an operation on a folder covers the objects locked in it
+		c.as("bob", "DELETE", "/api/nxfs/objects/"+encode("docs"), nil, http.StatusLocked).code(t, "object_locked")
+		c.as("bob", "GET", path+"/lock", nil, http.StatusOK)
+		c.as("alice", "PUT", path+"/lock", map[string]interface{}{"ttl": 120}, http.StatusOK)
+		c.as("alice", "DELETE", path+"/lock", nil, http.StatusNoContent)
+		c.as("alice", "GET", path+"/lock", nil, http.StatusNotFound)
+
+		adminPath := "/api/nxfs/admin/locks/" + encode("docs/a.txt")
+		c.as("alice", "POST", path+"/lock", nil, http.StatusOK)
+		c.as("bob", "DELETE", adminPath, nil, http.StatusForbidden).code(t, "admin_required")
+		c.do("carol", []string{"nxfs-admin"}, "DELETE", adminPath, nil, http.StatusOK)
+		c.as("bob", "PUT", path, file("a.txt", "bob"), http.StatusCreated)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		c.t = t
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages"), dir("draft_pages"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/home.page"), file("home.page", homePage), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/broken.page"), file("broken.page", `{"title":1}`), http.StatusUnprocessableEntity).code(t, "invalid_page")
+
+		c.as("alice", "GET", "/api/nxfs/schemas/page/1", nil, http.StatusOK)
+		c.as("alice", "GET", "/api/nxfs/schemas/page/99", nil, http.StatusNotFound).code(t, "schema_not_found")
+
+		if diffs := c.as("alice", "GET", "/api/nxfs/diff", nil, http.StatusOK).list(); len(diffs) != 1 {
+			t.Fatalf("expected one unpublished page, got %v", diffs)
+		}
+		c.as("alice", "POST", "/api/nxfs/objects/missing/publish", nil, http.StatusNotFound)
+		c.as("alice", "POST", "/api/nxfs/objects/home/publish", nil, http.StatusOK)
+		if diff := c.as("alice", "GET", "/api/nxfs/diff/home", nil, http.StatusOK); diff.body["status"] != "unchanged" {
+			t.Fatalf("unexpected diff %s", diff.raw)
+		}
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("pages/home.page"), nil, http.StatusOK)
+
+		// the template loaded from the seed directory is referenced by the page and can't be deleted
+		references := c.as("alice", "GET", "/api/nxfs/references/"+encode("templates/main.ftl"), nil, http.StatusOK).list()
+		if len(references) != 2 {
+			t.Fatalf("expected the draft and the published page to reference the template, got %v", references)
+		}
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("templates/main.ftl"), nil, http.StatusConflict).code(t, "object_in_use")
+
+		// a path climbing out of the pages folders is cleaned, the lock of another client on the written page is honoured
+		lockPath := "/api/nxfs/objects/" + encode("pages/home.page") + "/lock"
+		c.as("bob", "POST", lockPath, nil, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/objects/"+encode("../home")+"/publish", nil, http.StatusLocked)
+		c.as("alice", "POST", "/api/nxfs/objects/"+encode("../home")+"/unpublish", nil, http.StatusLocked)
+		c.as("bob", "DELETE", lockPath, nil, http.StatusNoContent)
+		c.as("alice", "POST", "/api/nxfs/objects/"+encode("../home")+"/publish", nil, http.StatusOK)
+
+		// the assets are published with a page only through their published path
+		assetPage := func(reference string) map[string]interface{} {
+			return file("page", `{"schemaVersion":1,"title":"Assets","template":"templates/main.ftl","frames":[{"pos":0,"name":"body",`+
+				`"widget":{"code":"style","config":{"css":"`+reference+`","logo":"/images/logo.png"}}}]}`)
+		}
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/assets"), dir("assets"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/assets/style.css"), file("style.css", "body {}"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/styled.page"), assetPage("/pages/assets/style.css"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/drafty.page"), assetPage("/draft_pages/assets/style.css"), http.StatusCreated)
+		c.as("alice", "POST", "/api/nxfs/objects/styled/publish", nil, http.StatusUnprocessableEntity).code(t, "missing_references")
+		c.as("alice", "POST", "/api/nxfs/objects/drafty/publish?withAssets=true", nil, http.StatusUnprocessableEntity).code(t, "draft_references")
+		draftLockPath := "/api/nxfs/objects/" + encode("draft_pages/home.page") + "/lock"
+		c.as("bob", "POST", draftLockPath, nil, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/objects/styled/publish?withAssets=true", nil, http.StatusLocked).code(t, "object_locked")
+		c.as("bob", "DELETE", draftLockPath, nil, http.StatusNoContent)
+		c.as("alice", "POST", "/api/nxfs/objects/styled/publish?withAssets=true", nil, http.StatusOK)
+		if content := c.as("alice", "GET", "/api/nxfs/objects/"+encode("pages/assets/style.css"), nil, http.StatusOK).body["content"]; content != "body {}" {
+			t.Fatalf("expected the asset to be published with the page, got %v", content)
+		}
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("pages/assets/style.css"), nil, http.StatusConflict).code(t, "object_in_use")
+
+		c.as("alice", "POST", "/api/nxfs/objects/home/unpublish", nil, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/objects/home/unpublish", nil, http.StatusNotFound)
+		if diff := c.as("alice", "GET", "/api/nxfs/diff/home", nil, http.StatusOK); diff.body["status"] != "added" {
+			t.Fatalf("unexpected diff %s", diff.raw)
+		}
+	})
+
+	t.Run("schedules", func(t *testing.T) {
+		c.t = t
+		at := url.QueryEscape(time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		c.as("alice", "POST", "/api/nxfs/objects/home/publish?at=yesterday", nil, http.StatusBadRequest).code(t, "invalid_at")
+		schedule := c.as("alice", "POST", "/api/nxfs/objects/home/publish?at="+at, nil, http.StatusAccepted)
+		c.as("alice", "POST", "/api/nxfs/objects/home/unpublish?at="+at, nil, http.StatusAccepted)
+		if schedules := c.as("alice", "GET", "/api/nxfs/schedules", nil, http.StatusOK).list(); len(schedules) != 2 {
+			t.Fatalf("expected two schedules, got %v", schedules)
+		}
+		c.as("alice", "DELETE", fmt.Sprintf("/api/nxfs/schedules/%v", schedule.body["id"]), nil, http.StatusNoContent)
+		c.as("alice", "DELETE", fmt.Sprintf("/api/nxfs/schedules/%v", schedule.body["id"]), nil, http.StatusNotFound)
+		if schedules := c.as("alice", "GET", "/api/nxfs/schedules", nil, http.StatusOK).list(); len(schedules) != 1 {
+			t.Fatalf("expected one schedule, got %v", schedules)
+		}
+	})
+
+	t.Run("workflow", func(t *testing.T) {
+		c.t = t
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/news"), dir("news"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("draft_pages/news/item.page"), file("item.page", homePage), http.StatusCreated)
+
+		c.as("alice", "POST", "/api/nxfs/objects/news%2Fitem/publish", nil, http.StatusConflict).code(t, "not_approved")
+		c.as("alice", "POST", "/api/nxfs/workflow/news%2Fitem", map[string]string{"action": "submit"}, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/workflow/news%2Fitem", map[string]string{"action": "approve"}, http.StatusForbidden)
+		c.do("rita", []string{"nxfs-reviewer"}, "POST", "/api/nxfs/workflow/news%2Fitem", map[string]string{"action": "approve"}, http.StatusOK)
+		if state := c.as("alice", "GET", "/api/nxfs/workflow/news%2Fitem", nil, http.StatusOK); state.body["state"] != "approved" {
+			t.Fatalf("unexpected workflow %s", state.raw)
+		}
+		if pages := c.as("alice", "GET", "/api/nxfs/workflow?state=approved", nil, http.StatusOK).list(); len(pages) != 1 {
+			t.Fatalf("expected one approved page, got %v", pages)
+		}
+		c.as("alice", "POST", "/api/nxfs/objects/news%2Fitem/publish", nil, http.StatusOK)
+		c.as("alice", "GET", "/api/nxfs/workflow/home", nil, http.StatusUnprocessableEntity).code(t, "workflow_disabled")
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		c.t = t
+		quotaUsage := func() map[string]interface{} {
+			t.Helper()
+			usage := c.as("alice", "GET", "/api/nxfs/usage", nil, http.StatusOK).list()
+			if len(usage) != 2 || usage[0].(map[string]interface{})["path"] != "." {
+				t.Fatalf("expected the usage of the fs and of the quota folder, got %v", usage)
+			}
+			return usage[1].(map[string]interface{})
+		}
+
+		c.as("alice", "PUT", "/api/nxfs/objects/quota", dir("quota"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("quota/a.txt"), file("a.txt", "1234567890"), http.StatusCreated)
+		if usage := quotaUsage(); usage["path"] != "quota" || usage["bytes"] != 10.0 || usage["files"] != 1.0 || usage["maxBytes"] != 20.0 || usage["maxFiles"] != 2.0 {
+			t.Fatalf("unexpected usage %v", usage)
+		}
+
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("quota/b.txt"), file("b.txt", "12345678901"), http.StatusInsufficientStorage).code(t, "quota_exceeded")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("quota/b.txt"), nil, http.StatusNotFound)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("quota/b.txt"), file("b.txt", "12345"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("quota/c.txt"), file("c.txt", "1"), http.StatusInsufficientStorage).code(t, "quota_exceeded")
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("quota/a.txt"), file("a.txt", "123456789012345"), http.StatusCreated)
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("quota/b.txt"), nil, http.StatusNoContent)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("quota/c.txt"), file("c.txt", "1"), http.StatusCreated)
+		if usage := quotaUsage(); usage["bytes"] != 16.0 || usage["files"] != 2.0 {
+			t.Fatalf("unexpected usage %v", usage)
+		}
+
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/big.txt"), file("big.txt", strings.Repeat("x", 1025)), http.StatusRequestEntityTooLarge).code(t, "file_too_large")
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/big.txt"), file("big.txt", strings.Repeat("x", 8<<10)), http.StatusRequestEntityTooLarge).code(t, "body_too_large")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/big.txt"), nil, http.StatusNotFound)
+	})
+
+	t.Run("content types", func(t *testing.T) {
+		c.t = t
+		c.as("alice", "PUT", "/api/nxfs/objects/types", dir("types"), http.StatusCreated)
+		created := c.as("alice", "PUT", "/api/nxfs/objects/"+encode("types/style.css"), file("style.css", "body {}"), http.StatusCreated)
+		if created.body["mimeType"] != "text/css" {
+			t.Fatalf("expected the type of the created file, got %s", created.raw)
+		}
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("types/notes"), file("notes", "some notes"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("types/sub"), dir("sub"), http.StatusCreated)
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("types/logo.png"), file("logo.png", "png"), http.StatusUnsupportedMediaType).code(t, "type_not_allowed")
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("types/readme.md"), file("readme.md", "# readme"), http.StatusUnsupportedMediaType).code(t, "type_not_allowed")
+		// executables are detected by their content, whatever their name
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/tool.txt"), file("tool.txt", "\x7fELF\x02\x01\x01"), http.StatusUnsupportedMediaType).code(t, "type_not_allowed")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/tool.txt"), nil, http.StatusNotFound)
+
+		if fetched := c.as("alice", "GET", "/api/nxfs/objects/"+encode("types/notes"), nil, http.StatusOK); fetched.body["mimeType"] != "text/plain" {
+			t.Fatalf("expected the sniffed type of the file, got %s", fetched.raw)
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/types?mimeType=text/css", nil, http.StatusOK).names(); names != "types/style.css" {
+			t.Fatalf("expected only the css file, got %s", names)
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/types?mimeType=.css,text/plain", nil, http.StatusOK).names(); names != "types/notes,types/style.css" {
+			t.Fatalf("expected the css and the text files, got %s", names)
+		}
+	})
+
+	t.Run("scanning", func(t *testing.T) {
+		c.t = t
+		created := c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/clean.txt"), file("clean.txt", "clean content"), http.StatusCreated)
+		if scan, _ := created.body["_scan"].(map[string]interface{}); scan["status"] != "clean" || scan["scanner"] != "clamd" {
+			t.Fatalf("expected the result of the scan, got %s", created.raw)
+		}
+		if fetched := c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/clean.txt"), nil, http.StatusOK); fetched.body["_scan"] == nil {
+			t.Fatalf("expected the result of the scan to be recorded, got %s", fetched.raw)
+		}
+
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/virus.txt"), file("virus.txt", "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR"), http.StatusUnprocessableEntity).code(t, "infected")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/virus.txt"), nil, http.StatusNotFound)
+		if quarantined, _ := ioutil.ReadDir(filepath.Join(dataDir, "quarantine")); len(quarantined) != 2 {
+			t.Fatalf("expected the infected file and its description in the quarantine, got %d entries", len(quarantined))
+		}
+
+		listed := false
+		for _, object := range c.as("alice", "GET", "/api/nxfs/browse/docs?mimeType=text/plain", nil, http.StatusOK).list() {
+			object := object.(map[string]interface{})
+			listed = listed || (object["name"] == "clean.txt" && object["_scan"] != nil)
+		}
+		if !listed {
+			t.Fatal("expected the result of the scan in the listing")
+		}
+
+		// the result of a deleted file is forgotten
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("docs/clean.txt"), nil, http.StatusNoContent)
+		if scans, err := ioutil.ReadFile(filepath.Join(dataDir, "scans.json")); err != nil || strings.Contains(string(scans), "docs/clean.txt") {
+			t.Fatalf("expected the result of the deleted file to be removed, got %s %v", scans, err)
+		}
+	})
+
+	t.Run("uploads", func(t *testing.T) {
+		c.t = t
+		checksum := sha256.Sum256([]byte("0123456789"))
+		created := c.as("alice", "POST", "/api/nxfs/uploads", map[string]interface{}{"path": "docs/video.txt", "length": 10, "checksum": hex.EncodeToString(checksum[:])}, http.StatusCreated)
+		uploadPath := "/api/nxfs/uploads/" + created.body["id"].(string)
+
+		if patched := c.as("alice", "PATCH", uploadPath+"?offset=0", []byte("01234"), http.StatusOK); patched.body["offset"] != 5.0 {
+			t.Fatalf("expected the upload to continue from 5, got %s", patched.raw)
+		}
+		c.as("alice", "PATCH", uploadPath+"?offset=0", []byte("01234"), http.StatusConflict).code(t, "offset_mismatch")
+		c.as("alice", "PATCH", uploadPath+"?offset=5", []byte("56789X"), http.StatusBadRequest).code(t, "upload_overflow")
+		c.as("alice", "POST", uploadPath+"/finalize", nil, http.StatusConflict).code(t, "upload_incomplete")
+		c.as("bob", "GET", uploadPath, nil, http.StatusForbidden).code(t, "upload_forbidden")
+		if progress := c.as("alice", "GET", uploadPath, nil, http.StatusOK); progress.body["offset"] != 5.0 || progress.body["length"] != 10.0 {
+			t.Fatalf("expected the progress of the upload, got %s", progress.raw)
+		}
+
+		c.as("alice", "PATCH", uploadPath+"?offset=5", []byte("56789"), http.StatusOK)
+		finalized := c.as("alice", "POST", uploadPath+"/finalize", nil, http.StatusCreated)
+		if finalized.body["mimeType"] != "text/plain" || finalized.body["_scan"] == nil {
+			t.Fatalf("expected the written file, got %s", finalized.raw)
+		}
+		if fetched := c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/video.txt"), nil, http.StatusOK); fetched.body["content"] != "0123456789" {
+			t.Fatalf("expected the uploaded content, got %s", fetched.raw)
+		}
+		c.as("alice", "GET", uploadPath, nil, http.StatusNotFound).code(t, "upload_not_found")
+
+		// the uploads are checked like the PUTs, when they start and when they're finalized
+		c.as("alice", "POST", "/api/nxfs/uploads", map[string]interface{}{"path": "docs/big.bin", "length": 2000}, http.StatusRequestEntityTooLarge).code(t, "file_too_large")
+		c.as("alice", "POST", "/api/nxfs/uploads", map[string]interface{}{"path": "types/readme.md", "length": 10}, http.StatusUnsupportedMediaType).code(t, "type_not_allowed")
+		c.as("alice", "POST", "/api/nxfs/uploads", map[string]interface{}{"path": "/", "length": 10}, http.StatusBadRequest).code(t, "invalid_upload")
+
+		virus := "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR"
+		created = c.as("alice", "POST", "/api/nxfs/uploads", map[string]interface{}{"path": "docs/virus.txt", "length": len(virus)}, http.StatusCreated)
+		uploadPath = "/api/nxfs/uploads/" + created.body["id"].(string)
+		c.as("alice", "PATCH", uploadPath+"?offset=0", []byte(virus), http.StatusOK)
+		c.as("alice", "POST", uploadPath+"/finalize", nil, http.StatusUnprocessableEntity).code(t, "infected")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/virus.txt"), nil, http.StatusNotFound)
+		c.as("alice", "DELETE", uploadPath, nil, http.StatusNoContent)
+		c.as("alice", "GET", uploadPath, nil, http.StatusNotFound).code(t, "upload_not_found")
+
+		created = c.as("alice", "POST", "/api/nxfs/uploads", map[string]interface{}{"path": "docs/corrupted.txt", "length": 3, "checksum": hex.EncodeToString(checksum[:])}, http.StatusCreated)
+		uploadPath = "/api/nxfs/uploads/" + created.body["id"].(string)
+		c.as("alice", "PATCH", uploadPath+"?offset=0", []byte("abc"), http.StatusOK)
+		c.as("alice", "POST", uploadPath+"/finalize", nil, http.StatusUnprocessableEntity).code(t, "checksum_mismatch")
+		c.as("alice", "GET", uploadPath, nil, http.StatusNotFound).code(t, "upload_not_found")
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/corrupted.txt"), nil, http.StatusNotFound)
+	})
+
+	t.Run("renditions", func(t *testing.T) {
+		c.t = t
+		raw := c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/logo.png"), nil, http.StatusOK)
+		if !bytes.Equal(raw.raw, logoPNG) || raw.header.Get("Content-Type") != "image/png" || raw.header.Get("Cache-Control") != "private, max-age=3600" {
+			t.Fatalf("expected the image as is, got %v", raw.header)
+		}
+		if raw.header.Get("Content-Disposition") != `inline; filename=logo.png` || raw.header.Get("X-Content-Type-Options") != "nosniff" || raw.header.Get("Content-Security-Policy") != "sandbox" {
+			t.Fatalf("expected the image to be displayed without sniffing, got %v", raw.header)
+		}
+		// the documents that could run scripts on the api origin are downloaded
+		for name, content := range map[string]string{"page.html": "<script>alert(1)</script>", "logo.svg": `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`} {
+			c.as("alice", "PUT", "/api/nxfs/objects/"+encode("images/"+name), file(name, content), http.StatusCreated)
+			raw := c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/"+name), nil, http.StatusOK)
+			if string(raw.raw) != content || raw.header.Get("Content-Disposition") != "attachment; filename="+name || raw.header.Get("Content-Security-Policy") != "sandbox" {
+				t.Fatalf("expected %s to be served as an attachment, got %v", name, raw.header)
+			}
+			c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("images/"+name), nil, http.StatusNoContent)
+		}
+
+		rendition := c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/logo.png")+"?width=10&format=jpeg", nil, http.StatusOK)
+		if img, err := jpeg.Decode(bytes.NewReader(rendition.raw)); err != nil || img.Bounds().Dx() != 10 || img.Bounds().Dy() != 5 {
+			t.Fatalf("expected a 10x5 jpeg rendition, got %v", err)
+		}
+		if cover := c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/logo.png")+"?width=10&height=10&fit=cover", nil, http.StatusOK); cover.header.Get("Content-Type") != "image/png" {
+			t.Fatalf("expected a png rendition, got %v", cover.header)
+		} else if img, err := png.Decode(bytes.NewReader(cover.raw)); err != nil || img.Bounds().Dx() != 10 || img.Bounds().Dy() != 10 {
+			t.Fatalf("expected a 10x10 png rendition, got %v", err)
+		}
+
+		// the clients revalidate their copy by its etag
+		request, _ := http.NewRequest("GET", c.server.URL+"/api/nxfs/raw/"+encode("images/logo.png")+"?width=10&format=jpeg", nil)
+		request.Header.Set("Authorization", "Bearer "+token("alice"))
+		request.Header.Set("If-None-Match", rendition.header.Get("ETag"))
+		if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected the rendition not to be modified, got %v %v", response, err)
+		} else {
+			response.Body.Close()
+		}
+
+		c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/logo.png")+"?format=webp", nil, http.StatusNotImplemented).code(t, "format_unsupported")
+		c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/logo.png")+"?width=0", nil, http.StatusBadRequest).code(t, "invalid_rendition")
+		c.as("alice", "GET", "/api/nxfs/raw/images?width=10", nil, http.StatusBadRequest).code(t, "dir_requested")
+
+		// overwriting the image removes its renditions
+		renditions := filepath.Join(dataDir, "renditions", "images", "logo.png")
+		if cached, _ := ioutil.ReadDir(renditions); len(cached) != 2 {
+			t.Fatalf("expected the two renditions to be cached, got %d", len(cached))
+		}
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("images/logo.png"), file("logo.png", "no longer an image"), http.StatusCreated)
+		if _, err := os.Stat(renditions); !os.IsNotExist(err) {
+			t.Fatalf("expected the renditions to be removed, got %v", err)
+		}
+		c.as("alice", "GET", "/api/nxfs/raw/"+encode("images/logo.png")+"?width=10", nil, http.StatusUnsupportedMediaType).code(t, "image_unsupported")
+	})
+
+	t.Run("caching", func(t *testing.T) {
+		c.t = t
+		// conditional - send a GET request with the received header, returning the response and its raw body
+		conditional := func(path string, name string, value string) (*http.Response, []byte) {
+			t.Helper()
+			request, _ := http.NewRequest("GET", c.server.URL+path, nil)
+			request.Header.Set("Authorization", "Bearer "+token("alice"))
+			request.Header.Set(name, value)
+			response, err := http.DefaultTransport.RoundTrip(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			body, _ := ioutil.ReadAll(response.Body)
+			return response, body
+		}
+
+		listing := c.as("alice", "GET", "/api/nxfs/browse/docs", nil, http.StatusOK)
+		etag := listing.header.Get("ETag")
+		if "" == etag || "" == listing.header.Get("Last-Modified") || listing.header.Get("Cache-Control") != "private, no-cache" {
+			t.Fatalf("expected the listing to have validators, got %v", listing.header)
+		}
+		if response, body := conditional("/api/nxfs/browse/docs", "If-None-Match", etag); response.StatusCode != http.StatusNotModified || len(body) > 0 {
+			t.Fatalf("expected the listing not to be modified, got %d %s", response.StatusCode, body)
+		}
+
+		// the etag of the listing changes with its entries
+		c.as("alice", "PUT", "/api/nxfs/objects/"+encode("docs/cached.txt"), file("cached.txt", "cached"), http.StatusCreated)
+		if response, _ := conditional("/api/nxfs/browse/docs", "If-None-Match", etag); response.StatusCode != http.StatusOK || response.Header.Get("ETag") == etag {
+			t.Fatalf("expected a new listing, got %d %v", response.StatusCode, response.Header)
+		}
+		listing = c.as("alice", "GET", "/api/nxfs/browse/docs", nil, http.StatusOK)
+		if response, _ := conditional("/api/nxfs/browse/docs", "If-Modified-Since", listing.header.Get("Last-Modified")); response.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected the listing not to be modified since its last change, got %d", response.StatusCode)
+		}
+		if response, _ := conditional("/api/nxfs/browse/docs", "If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)); response.StatusCode != http.StatusOK {
+			t.Fatalf("expected the listing to be modified, got %d", response.StatusCode)
+		}
+
+		object := c.as("alice", "GET", "/api/nxfs/objects/"+encode("docs/cached.txt"), nil, http.StatusOK)
+		if response, _ := conditional("/api/nxfs/objects/"+encode("docs/cached.txt"), "If-None-Match", "W/"+object.header.Get("ETag")); response.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected the object not to be modified, got %d", response.StatusCode)
+		}
+		c.as("alice", "DELETE", "/api/nxfs/objects/"+encode("docs/cached.txt"), nil, http.StatusNoContent)
+		if response, _ := conditional("/api/nxfs/browse/docs", "If-None-Match", listing.header.Get("ETag")); response.StatusCode != http.StatusOK {
+			t.Fatalf("expected the listing to change with the removal, got %d", response.StatusCode)
+		}
+
+		// the long responses are compressed for the clients accepting it, like the go client transparently does
+		uncompressed := c.as("alice", "GET", "/api/nxfs/browse/"+encode("/")+"?maxdepth=5", nil, http.StatusOK)
+		response, body := conditional("/api/nxfs/browse/"+encode("/")+"?maxdepth=5", "Accept-Encoding", "br;q=0.5, gzip;q=1")
+		if response.Header.Get("Content-Encoding") != "gzip" || !strings.HasPrefix(response.Header.Get("ETag"), "W/") || response.Header.Get("ETag") != uncompressed.header.Get("ETag") {
+			t.Fatalf("expected a compressed listing of %d bytes, got %v", len(uncompressed.raw), response.Header)
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decompressed, err := ioutil.ReadAll(reader); err != nil || !bytes.Equal(decompressed, uncompressed.raw) {
+			t.Fatalf("expected the decompressed listing to be the uncompressed one, got %v", err)
+		}
+		response, body = conditional("/api/nxfs/browse/"+encode("/")+"?maxdepth=5", "Accept-Encoding", "br")
+		if response.Header.Get("Content-Encoding") != "br" || response.Header.Get("ETag") != uncompressed.header.Get("ETag") {
+			t.Fatalf("expected a brotli compressed listing, got %v", response.Header)
+		}
+		if decompressed, err := ioutil.ReadAll(brotli.NewReader(bytes.NewReader(body))); err != nil || !bytes.Equal(decompressed, uncompressed.raw) {
+			t.Fatalf("expected the decompressed listing to be the uncompressed one, got %v", err)
+		}
+		if response, _ := conditional("/api/nxfs/browse/"+encode("/"), "Accept-Encoding", "identity"); "" != response.Header.Get("Content-Encoding") {
+			t.Fatalf("expected an uncompressed listing, got %v", response.Header)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		c.t = t
+		put := func(path string, object map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{"op": "put", "path": path, "object": object}
+		}
+		batch := func(mode string, operations ...map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{"mode": mode, "operations": operations}
+		}
+		content := func(path string) interface{} {
+			return c.as("alice", "GET", "/api/nxfs/objects/"+encode(path), nil, http.StatusOK).body["content"]
+		}
+
+		c.as("alice", "POST", "/api/nxfs/batch", batch("atomic"), http.StatusBadRequest).code(t, "invalid_batch")
+		c.as("alice", "POST", "/api/nxfs/batch", batch("sometimes", put("batch", dir("batch"))), http.StatusBadRequest).code(t, "invalid_batch")
+		// nothing is executed when an operation is malformed
+		c.as("alice", "POST", "/api/nxfs/batch", batch("best-effort", put("batch", dir("batch")), map[string]interface{}{"op": "rename", "path": "batch"}), http.StatusBadRequest).code(t, "invalid_batch")
+		c.as("alice", "GET", "/api/nxfs/browse/batch", nil, http.StatusNotFound)
+
+		applied := c.as("alice", "POST", "/api/nxfs/batch", batch("",
+			put("batch", dir("batch")),
+			put("batch/a.txt", file("a.txt", "a")),
+			map[string]interface{}{"op": "copy", "path": "batch/a.txt", "to": "batch/b.txt"},
+			map[string]interface{}{"op": "move", "path": "batch/b.txt", "to": "batch/c.txt"},
+			map[string]interface{}{"op": "publish", "path": "home"},
+		), http.StatusOK)
+		if applied.body["mode"] != "atomic" || len(applied.list()) != 5 {
+			t.Fatalf("expected the results of the five operations, got %s", applied.raw)
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/batch", nil, http.StatusOK).names(); names != "batch/a.txt,batch/c.txt" {
+			t.Fatalf("unexpected browse %s", names)
+		}
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("pages/home.page"), nil, http.StatusOK)
+
+		// an atomic batch is rolled back when an operation fails, the failure is reported with its own status and code
+		schedules := len(c.as("alice", "GET", "/api/nxfs/schedules", nil, http.StatusOK).list())
+		failed := c.as("alice", "POST", "/api/nxfs/batch", batch("atomic",
+			put("batch/a.txt", file("a.txt", "changed")),
+			map[string]interface{}{"op": "delete", "path": "batch/c.txt"},
+			put("batch/new", dir("new")),
+			put("batch/new/d.txt", file("d.txt", "d")),
+			map[string]interface{}{"op": "unpublish", "path": "home"},
+			map[string]interface{}{"op": "publish", "path": "home", "at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+			put("types/readme.md", file("readme.md", "# readme")),
+		), http.StatusUnsupportedMediaType)
+		failed.code(t, "type_not_allowed")
+		if details, _ := failed.body["details"].([]interface{}); len(details) == 0 || details[0].(map[string]interface{})["field"] != "operations[6]" {
+			t.Fatalf("expected the failed operation to be identified, got %s", failed.raw)
+		}
+		if content("batch/a.txt") != "a" || content("batch/c.txt") != "a" {
+			t.Fatal("expected the files to be restored")
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/batch", nil, http.StatusOK).names(); names != "batch/a.txt,batch/c.txt" {
+			t.Fatalf("expected the created objects to be removed, got %s", names)
+		}
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("pages/home.page"), nil, http.StatusOK)
+		if after := len(c.as("alice", "GET", "/api/nxfs/schedules", nil, http.StatusOK).list()); after != schedules {
+			t.Fatalf("expected the schedule to be cancelled, got %d schedules", after)
+		}
+		restored := false
+		for _, record := range c.as("alice", "GET", "/api/nxfs/audit?path=batch/a.txt", nil, http.StatusOK).list() {
+			restored = restored || record.(map[string]interface{})["operation"] == "restore"
+		}
+		if !restored {
+			t.Fatal("expected the restoration to be audited")
+		}
+
+		// the workflow of the pages whose publication is rolled back is restored with them
+		failing := put("types/readme.md", file("readme.md", "# readme"))
+		workflowState := func() interface{} {
+			return c.as("alice", "GET", "/api/nxfs/workflow/news%2Fitem", nil, http.StatusOK).body["state"]
+		}
+		c.as("alice", "POST", "/api/nxfs/batch", batch("atomic", map[string]interface{}{"op": "unpublish", "path": "news/item"}, failing), http.StatusUnsupportedMediaType)
+		if state := workflowState(); state != "published" {
+			t.Fatalf("expected the unpublished page to be published again, got %v", state)
+		}
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("pages/news/item.page"), nil, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/objects/news%2Fitem/unpublish", nil, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/workflow/news%2Fitem", map[string]string{"action": "submit"}, http.StatusOK)
+		c.do("rita", []string{"nxfs-reviewer"}, "POST", "/api/nxfs/workflow/news%2Fitem", map[string]string{"action": "approve"}, http.StatusOK)
+		c.as("alice", "POST", "/api/nxfs/batch", batch("atomic", map[string]interface{}{"op": "publish", "path": "news/item"}, failing), http.StatusUnsupportedMediaType)
+		if state := workflowState(); state != "approved" {
+			t.Fatalf("expected the published page to be approved again, got %v", state)
+		}
+		c.as("alice", "GET", "/api/nxfs/objects/"+encode("pages/news/item.page"), nil, http.StatusNotFound)
+		c.as("alice", "POST", "/api/nxfs/batch", batch("atomic", map[string]interface{}{"op": "publish", "path": "news/item"}), http.StatusOK)
+		if state := workflowState(); state != "published" {
+			t.Fatalf("expected the approved page to be published, got %v", state)
+		}
+
+		// a best effort batch executes every operation, a failed one leaves nothing behind
+		results := c.as("alice", "POST", "/api/nxfs/batch", batch("best-effort",
+			put("batch/e.txt", file("e.txt", "e")),
+			map[string]interface{}{"op": "move", "path": "batch/missing.txt", "to": "batch/f.txt"},
+			map[string]interface{}{"op": "move", "path": "batch/c.txt", "to": "quota/c.txt"},
+			map[string]interface{}{"op": "move", "path": "templates/main.ftl", "to": "batch/main.ftl"},
+			map[string]interface{}{"op": "move", "path": "batch", "to": "moved"},
+		), http.StatusOK).list()
+		var statuses []string
+		for _, result := range results {
+			result := result.(map[string]interface{})
+			statuses = append(statuses, fmt.Sprint(result["status"]))
+			if errorResult, ok := result["error"].(map[string]interface{}); ok {
+				statuses[len(statuses)-1] += " " + fmt.Sprint(errorResult["code"])
+			}
+		}
+		if joined := strings.Join(statuses, ","); joined != "201,404 path_not_found,201,409 object_in_use,400 dir_requested" {
+			t.Fatalf("unexpected results %s", joined)
+		}
+		if names := c.as("alice", "GET", "/api/nxfs/browse/batch", nil, http.StatusOK).names(); names != "batch/a.txt,batch/e.txt" {
+			t.Fatalf("expected the copy of the file in use to be removed, got %s", names)
+		}
+		if content("quota/c.txt") != "a" {
+			t.Fatal("expected the file to be moved")
+		}
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		c.t = t
+		records := c.as("alice", "GET", "/api/nxfs/audit?path=docs/a.txt", nil, http.StatusOK).list()
+		if len(records) == 0 {
+			t.Fatal("expected the changes of docs/a.txt to be audited")
+		}
+		c.as("alice", "GET", "/api/nxfs/audit?from=never", nil, http.StatusBadRequest)
+	})
+
+	// the releases and the archives are stored next to the local files, the memory storage doesn't support them
+	t.Run("unsupported by the storage", func(t *testing.T) {
+		c.t = t
+		c.as("alice", "POST", "/api/nxfs/releases", map[string]interface{}{"pages": []string{"home"}}, http.StatusNotImplemented).code(t, "storage_unsupported")
+		if releases := c.as("alice", "GET", "/api/nxfs/releases", nil, http.StatusOK).list(); len(releases) != 0 {
+			t.Fatalf("expected no releases, got %v", releases)
+		}
+		c.as("alice", "GET", "/api/nxfs/releases/none", nil, http.StatusNotFound)
+		c.as("alice", "POST", "/api/nxfs/releases/none/rollback", nil, http.StatusNotImplemented).code(t, "storage_unsupported")
+		c.as("alice", "GET", "/api/nxfs/export/docs", nil, http.StatusNotImplemented).code(t, "storage_unsupported")
+		c.as("alice", "POST", "/api/nxfs/import/docs", []byte("archive"), http.StatusNotImplemented).code(t, "storage_unsupported")
+	})
+
+	t.Run("git disabled", func(t *testing.T) {
+		c.t = t
+		c.as("alice", "GET", "/api/nxfs/history/docs", nil, http.StatusNotFound).code(t, "git_disabled")
+		c.as("alice", "GET", "/api/nxfs/commits/HEAD", nil, http.StatusNotFound).code(t, "git_disabled")
+		c.as("alice", "POST", "/api/nxfs/commits/HEAD/revert", nil, http.StatusNotFound).code(t, "git_disabled")
+		c.as("alice", "POST", "/api/nxfs/git/push", nil, http.StatusNotFound).code(t, "git_disabled")
+		c.as("alice", "POST", "/api/nxfs/git/pull", nil, http.StatusNotFound).code(t, "git_disabled")
+	})
+
+	c.t = t
+	for _, route := range controller.NewDefaultApiController(nil).Routes() {
+		if !c.reached[route.Name] {
+			t.Errorf("route %s is not tested", route.Name)
+		}
+	}
+
+	// the changes are kept in memory, the seed directory is untouched
+	entries, err := ioutil.ReadDir(seedDir)
+	if err != nil || len(entries) != 2 || entries[0].Name() != "images" || entries[1].Name() != "templates" {
+		t.Fatalf("the browsable fs directory has been changed: %v %v", entries, err)
+	}
+	if logo, err := ioutil.ReadFile(filepath.Join(seedDir, "images", "logo.png")); err != nil || !bytes.Equal(logo, logoPNG) {
+		t.Fatalf("the image of the browsable fs directory has been changed: %v", err)
+	}
+}
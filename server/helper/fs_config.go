@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"path/filepath"
+)
+
+// FsConfig - where a browsable fs keeps its objects and nxfs its own data about it, and the policies applying to it.
+// nxfs serves the fs configured by the environment, or one for every tenant
+type FsConfig struct {
+	// Root - the directory of the objects, copied in memory by the memory storage
+	Root string
+	// DataDir - the directory in which nxfs keeps its own data (audit log, schedules, releases, ...)
+	DataDir string
+	// S3Prefix - the prefix of the keys of the objects in the S3 storage
+	S3Prefix string
+	// WorkflowPaths - the directories whose pages must be reviewed before publishing, see GetWorkflowPaths
+	WorkflowPaths []string
+	// MaxFileSize - the maximum size of a file, zero for no limit
+	MaxFileSize int64
+	// Quotas - the maximum bytes and files of the folders of the fs
+	Quotas []Quota
+	// TypeRules - the types of files accepted by the folders of the fs
+	TypeRules []TypeRule
+}
+
+// GetDefaultFsConfig - return the configuration of the browsable fs served when no tenant is configured
+func GetDefaultFsConfig() FsConfig {
+	return FsConfig{
+		Root:          GetBrowsableFsRootPath(),
+		DataDir:       GetDataDirPath(),
+		S3Prefix:      GetS3Prefix(),
+		WorkflowPaths: GetWorkflowPaths(),
+		MaxFileSize:   GetMaxFileSize(),
+		Quotas:        GetQuotas(),
+		TypeRules:     GetTypeRules(),
+	}
+}
+
+// FullPath - return the full path of a path relative to the root
+func (c FsConfig) FullPath(relPath string) string {
+	return filepath.Join(c.Root, filepath.FromSlash(relPath))
+}
+
+// PublishedPagesPath - return the base path in which published pages are saved
+func (c FsConfig) PublishedPagesPath() string {
+	return filepath.Join(c.Root, publishedPagesRelativePath)
+}
+
+// DraftPagesPath - return the base path in which draft pages are saved
+func (c FsConfig) DraftPagesPath() string {
+	return filepath.Join(c.Root, draftPagesRelativePath)
+}
+
+// AuditLogPath - return the path of the audit log file
+func (c FsConfig) AuditLogPath() string {
+	return filepath.Join(c.DataDir, auditLogFileName)
+}
+
+// SchedulesPath - return the path of the file storing the pending publish schedules
+func (c FsConfig) SchedulesPath() string {
+	return filepath.Join(c.DataDir, schedulesFileName)
+}
+
+// ReleasesPath - return the path of the directory storing the releases and the snapshots needed to roll them back
+func (c FsConfig) ReleasesPath() string {
+	return filepath.Join(c.DataDir, releasesDirName)
+}
+
+// WorkflowPath - return the path of the file storing the review workflow state of the pages
+func (c FsConfig) WorkflowPath() string {
+	return filepath.Join(c.DataDir, workflowFileName)
+}
+
+// BlobsPath - return the path of the directory storing the content addressed blobs shared by the published pages and the snapshots
+func (c FsConfig) BlobsPath() string {
+	return filepath.Join(c.DataDir, blobsDirName)
+}
+
+// GitPath - return the path of the git directory of the browsable fs, kept out of the browsable fs itself
+func (c FsConfig) GitPath() string {
+	return filepath.Join(c.DataDir, gitDirName)
+}
+
+// ScansPath - return the path of the file storing the results of the scans of the files
+func (c FsConfig) ScansPath() string {
+	return filepath.Join(c.DataDir, scansFileName)
+}
+
+// QuarantinePath - return the path of the directory keeping the files rejected by the scanner
+func (c FsConfig) QuarantinePath() string {
+	return filepath.Join(c.DataDir, quarantineDirName)
+}
+
+// RenditionsPath - return the path of the directory caching the renditions of the images
+func (c FsConfig) RenditionsPath() string {
+	return filepath.Join(c.DataDir, renditionsDirName)
+}
+
+// UploadsPath - return the path of the directory keeping the resumable uploads until they're finalized
+func (c FsConfig) UploadsPath() string {
+	return filepath.Join(c.DataDir, uploadsDirName)
+}
@@ -10,27 +10,129 @@
 package helper
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"github.com/entando/entando-nxfs/server/model"
 	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"log"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const envVarBrowsableFs = "BROWSABLE_FS"
 const fsBaseDir = "./browsableFS"
 const publishedPagesRelativePath = "pages"
 const draftPagesRelativePath = "draft_pages"
+const layoutsRelativePath = "layouts"
+const envVarDataDir = "NXFS_DATA_DIR"
+const dataBaseDir = "./nxfsData"
+const auditLogFileName = "audit.log"
+const schedulesFileName = "schedules.json"
+const releasesDirName = "releases"
+const workflowFileName = "workflow.json"
+const blobsDirName = "blobs"
+const envVarWorkflowPaths = "NXFS_WORKFLOW_PATHS"
+const envVarReviewerRole = "NXFS_REVIEWER_ROLE"
+const defaultReviewerRole = "nxfs-reviewer"
+const envVarLockTimeout = "NXFS_LOCK_TIMEOUT"
+const defaultLockTimeout = 5 * time.Second
+const envVarAdminRole = "NXFS_ADMIN_ROLE"
+const defaultAdminRole = "nxfs-admin"
+const envVarDeliveryPrefix = "NXFS_DELIVERY_PREFIX"
+const envVarDeliveryMaxAge = "NXFS_DELIVERY_MAX_AGE"
+const defaultDeliveryMaxAge = time.Minute
+const envVarGitEnabled = "NXFS_GIT_ENABLED"
+const envVarGitRemote = "NXFS_GIT_REMOTE"
+const envVarGitBranch = "NXFS_GIT_BRANCH"
+const defaultGitBranch = "main"
+const gitDirName = "git"
+const envVarStorage = "NXFS_STORAGE"
+const envVarS3Endpoint = "NXFS_S3_ENDPOINT"
+const envVarS3Region = "NXFS_S3_REGION"
+const defaultS3Region = "us-east-1"
+const envVarS3Bucket = "NXFS_S3_BUCKET"
+const envVarS3Prefix = "NXFS_S3_PREFIX"
+const envVarS3AccessKey = "NXFS_S3_ACCESS_KEY"
+const envVarS3SecretKey = "NXFS_S3_SECRET_KEY"
+const envVarTenantsFile = "NXFS_TENANTS_FILE"
+const envVarTenantSource = "NXFS_TENANT_SOURCE"
+const envVarTenantHeader = "NXFS_TENANT_HEADER"
+const defaultTenantHeader = "X-Nxfs-Tenant"
+const envVarTenantClaim = "NXFS_TENANT_CLAIM"
+const defaultTenantClaim = "tenant"
+const noTenantClaim = "none"
+const envVarTokenSecret = "NXFS_JWT_SECRET"
+const envVarTokenPublicKeyFile = "NXFS_JWT_PUBLIC_KEY_FILE"
+const envVarTokenJWKSURL = "NXFS_JWT_JWKS_URL"
+const envVarTokenIssuer = "NXFS_JWT_ISSUER"
+const envVarTrustedGateway = "NXFS_TRUSTED_GATEWAY"
+const envVarTrustedProxies = "NXFS_TRUSTED_PROXIES"
+const envVarTenantsReloadInterval = "NXFS_TENANTS_RELOAD_INTERVAL"
+const defaultTenantsReloadInterval = 10 * time.Second
+const envVarMaxBodySize = "NXFS_MAX_BODY_SIZE"
+const defaultMaxBodySize = 32 << 20
+const envVarMaxArchiveSize = "NXFS_MAX_ARCHIVE_SIZE"
+const defaultMaxArchiveSize = 1 << 30
+const envVarMaxFileSize = "NXFS_MAX_FILE_SIZE"
+const envVarQuotas = "NXFS_QUOTAS"
+const envVarTypeRules = "NXFS_TYPE_RULES"
+const envVarScanner = "NXFS_SCANNER"
+const envVarClamdAddress = "NXFS_CLAMD_ADDRESS"
+const defaultClamdAddress = "unix:/var/run/clamav/clamd.ctl"
+const envVarScanCommand = "NXFS_SCAN_COMMAND"
+const envVarScanTimeout = "NXFS_SCAN_TIMEOUT"
+const defaultScanTimeout = time.Minute
+const scansFileName = "scans.json"
+const quarantineDirName = "quarantine"
+const renditionsDirName = "renditions"
+const envVarRenditionMaxAge = "NXFS_RENDITION_MAX_AGE"
+const defaultRenditionMaxAge = time.Hour
+const uploadsDirName = "uploads"
+const envVarUploadExpiration = "NXFS_UPLOAD_EXPIRATION"
+const defaultUploadExpiration = 24 * time.Hour
+const envVarMaxChunkSize = "NXFS_MAX_CHUNK_SIZE"
+const defaultMaxChunkSize = 64 << 20
+const envVarCompressionLevel = "NXFS_COMPRESSION_LEVEL"
+const defaultCompressionLevel = 6
+const envVarCompressionMinSize = "NXFS_COMPRESSION_MIN_SIZE"
+const defaultCompressionMinSize = 1 << 10
+
+// storage backends of the browsable fs
+const (
+	StorageLocal  = "local"
+	StorageS3     = "s3"
+	StorageMemory = "memory"
+)
+
+// scanners of the files written to the browsable fs
+const (
+	ScannerNone  = ""
+	ScannerClamd = "clamd"
+	ScannerExec  = "exec"
+)
+
+// the parts of a request from which the tenant is taken
+const (
+	TenantFromPath   = "path"
+	TenantFromHeader = "header"
+	TenantFromClaim  = "claim"
+)
 
 var browsableFsPath = ""
+var dataDirPath = ""
 
 //SuccessResponse return a NxfsResponse struct filled
 func SuccessResponse(code int, body interface{}) net.NxfsResponse {
 	return net.NxfsResponse{Code: code, Body: body}
 }
 
-//ErrorResponse return a NxfsResponse struct filled with an error
-func ErrorResponse(code int, errorCode string, errorMessage string) *net.NxfsResponse {
-	return &net.NxfsResponse{Code: code, Body: &model.Result{Code: errorCode, Message: errorMessage}}
+//ErrorResponse return a NxfsResponse struct filled with an error, the status is given by the kind of the error
+func ErrorResponse(err error) *net.NxfsResponse {
+	nxfsErr := nxfserrors.Wrap(err, "internal_error")
+	return &net.NxfsResponse{Code: nxfsErr.Status(), Body: &model.Result{Code: nxfsErr.Code, Message: nxfsErr.Message, Details: nxfsErr.Details}}
 }
 
 // GetBrowsableFsRootPath - return the root path of the file system to browse
@@ -44,12 +146,402 @@ func GetBrowsableFsRootPath() string {
 	return browsableFsPath
 }
 
-// GetPublishedPagesPath - return the base path in which published pages are saved
-func GetPublishedPagesPath() string {
-	return filepath.Join(GetBrowsableFsRootPath(), publishedPagesRelativePath)
+// GetPublishedPagesRelativePath - return the path of the published pages folder, relative to the browsable fs root
+func GetPublishedPagesRelativePath() string {
+	return publishedPagesRelativePath
+}
+
+// GetDraftPagesRelativePath - return the path of the draft pages folder, relative to the browsable fs root
+func GetDraftPagesRelativePath() string {
+	return draftPagesRelativePath
+}
+
+// GetLayoutsRelativePath - return the path of the folder of the layout templates of the delivered pages, relative to
+// the browsable fs root
+func GetLayoutsRelativePath() string {
+	return layoutsRelativePath
+}
+
+// GetDataDirPath - return the path of the directory in which nxfs keeps its own data (audit log, indexes, ...)
+func GetDataDirPath() string {
+	if "" == dataDirPath {
+		dataDirPath = os.Getenv(envVarDataDir)
+		if "" == dataDirPath {
+			dataDirPath = dataBaseDir
+		}
+	}
+	return dataDirPath
+}
+
+// GetAuditLogPath - return the path of the audit log file of the browsable fs configured by the environment
+func GetAuditLogPath() string {
+	return GetDefaultFsConfig().AuditLogPath()
+}
+
+// GetBlobsPath - return the path of the blob store of the browsable fs configured by the environment
+func GetBlobsPath() string {
+	return GetDefaultFsConfig().BlobsPath()
+}
+
+// GetWorkflowPaths - return the directories, relative to the pages folders, whose pages must be reviewed before publishing.
+// "/" enables the workflow on every page, none is returned if the workflow is disabled
+func GetWorkflowPaths() []string {
+	var workflowPaths []string
+	for _, workflowPath := range strings.Split(os.Getenv(envVarWorkflowPaths), ",") {
+		if workflowPath = strings.TrimSpace(workflowPath); "" != workflowPath {
+			workflowPaths = append(workflowPaths, workflowPath)
+		}
+	}
+	return workflowPaths
+}
+
+// GetReviewerRole - return the realm role allowed to approve and reject the pages in review
+func GetReviewerRole() string {
+	if reviewerRole := os.Getenv(envVarReviewerRole); "" != reviewerRole {
+		return reviewerRole
+	}
+	return defaultReviewerRole
+}
+
+// GetLockWaitTimeout - return how long an operation waits for the locks on its paths before failing
+func GetLockWaitTimeout() time.Duration {
+	if value := os.Getenv(envVarLockTimeout); "" != value {
+		timeout, err := time.ParseDuration(value)
+		if err == nil && timeout > 0 {
+			return timeout
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarLockTimeout, value)
+	}
+	return defaultLockTimeout
+}
+
+// GetAdminRole - return the realm role granting the nxfs administration operations
+func GetAdminRole() string {
+	if adminRole := os.Getenv(envVarAdminRole); "" != adminRole {
+		return adminRole
+	}
+	return defaultAdminRole
+}
+
+// GetDeliveryPrefix - return the url prefix under which the published pages are served, an empty string if their delivery is disabled
+func GetDeliveryPrefix() string {
+	prefix := strings.TrimSpace(os.Getenv(envVarDeliveryPrefix))
+	if "" == prefix {
+		return ""
+	}
+	if prefix = strings.TrimRight("/"+strings.TrimLeft(prefix, "/"), "/"); "" == prefix {
+		return "/"
+	}
+	return prefix
+}
+
+// GetDeliveryMaxAge - return for how long the clients can cache the delivered pages without revalidating them
+func GetDeliveryMaxAge() time.Duration {
+	if value := os.Getenv(envVarDeliveryMaxAge); "" != value {
+		maxAge, err := time.ParseDuration(value)
+		if err == nil && maxAge >= 0 {
+			return maxAge
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarDeliveryMaxAge, value)
+	}
+	return defaultDeliveryMaxAge
+}
+
+// IsGitEnabled - return true if the browsable fs is a git working tree where every change is committed
+func IsGitEnabled() bool {
+	if value := os.Getenv(envVarGitEnabled); "" != value {
+		enabled, err := strconv.ParseBool(value)
+		if err == nil {
+			return enabled
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarGitEnabled, value)
+	}
+	return false
+}
+
+// GetGitRemote - return the url of the git repository the browsable fs is pushed to and pulled from, empty if there's none
+func GetGitRemote() string {
+	return strings.TrimSpace(os.Getenv(envVarGitRemote))
+}
+
+// GetGitBranch - return the branch of the git remote the browsable fs is pulled from and pushed to by default
+func GetGitBranch() string {
+	if branch := strings.TrimSpace(os.Getenv(envVarGitBranch)); "" != branch {
+		return branch
+	}
+	return defaultGitBranch
 }
 
-// GetDraftPagesPath - return the base path in which draft pages are saved
-func GetDraftPagesPath() string {
-	return filepath.Join(GetBrowsableFsRootPath(), draftPagesRelativePath)
+// GetStorage - return the backend storing the objects of the browsable fs, the local directory by default
+func GetStorage() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv(envVarStorage))); value {
+	case "", StorageLocal:
+		return StorageLocal
+	case StorageS3, StorageMemory:
+		return value
+	default:
+		log.Printf("Ignoring invalid %s value %q", envVarStorage, value)
+		return StorageLocal
+	}
+}
+
+// GetS3Endpoint - return the url of the S3 compatible service storing the browsable fs
+func GetS3Endpoint() string {
+	return strings.TrimSpace(os.Getenv(envVarS3Endpoint))
+}
+
+// GetS3Region - return the region of the S3 bucket storing the browsable fs
+func GetS3Region() string {
+	if region := strings.TrimSpace(os.Getenv(envVarS3Region)); "" != region {
+		return region
+	}
+	return defaultS3Region
+}
+
+// GetS3Bucket - return the S3 bucket storing the browsable fs
+func GetS3Bucket() string {
+	return strings.TrimSpace(os.Getenv(envVarS3Bucket))
+}
+
+// GetS3Prefix - return the prefix of the keys of the browsable fs objects in the S3 bucket, empty for the whole bucket
+func GetS3Prefix() string {
+	return strings.TrimSpace(os.Getenv(envVarS3Prefix))
+}
+
+// GetS3Credentials - return the access key and the secret key signing the S3 requests, empty for anonymous requests
+func GetS3Credentials() (accessKey string, secretKey string) {
+	return os.Getenv(envVarS3AccessKey), os.Getenv(envVarS3SecretKey)
+}
+
+// NewRandomId - return a random identifier, 32 hex characters long
+func NewRandomId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic("can't read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(id)
+}
+
+// GetTenantsFile - return the path of the file configuring the tenants, an empty string if nxfs serves a single browsable fs
+func GetTenantsFile() string {
+	return strings.TrimSpace(os.Getenv(envVarTenantsFile))
+}
+
+// GetTenantSource - return the part of the requests carrying their tenant: the path prefix /tenants/{tenant} by default
+func GetTenantSource() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv(envVarTenantSource))); value {
+	case "", TenantFromPath:
+		return TenantFromPath
+	case TenantFromHeader, TenantFromClaim:
+		return value
+	default:
+		log.Printf("Ignoring invalid %s value %q", envVarTenantSource, value)
+		return TenantFromPath
+	}
+}
+
+// GetTenantHeader - return the request header carrying the tenant when the tenant source is the header
+func GetTenantHeader() string {
+	if header := strings.TrimSpace(os.Getenv(envVarTenantHeader)); "" != header {
+		return header
+	}
+	return defaultTenantHeader
+}
+
+// GetTenantClaim - return the JWT claim carrying the tenant of the caller. whatever the tenant source, a caller can only
+// access the tenant named by the claim of its token. none disables the check for the path and header sources, when a
+// trusted gateway routes the requests of every caller to its tenant
+func GetTenantClaim() string {
+	claim := strings.TrimSpace(os.Getenv(envVarTenantClaim))
+	if noTenantClaim == claim {
+		if TenantFromClaim != GetTenantSource() {
+			return ""
+		}
+		log.Printf("Ignoring the %s value %q, the tenant source is the claim", envVarTenantClaim, claim)
+	} else if "" != claim {
+		return claim
+	}
+	return defaultTenantClaim
+}
+
+// GetTokenSecret - return the shared key verifying the HMAC signed bearer tokens, empty if they aren't accepted
+func GetTokenSecret() string {
+	return os.Getenv(envVarTokenSecret)
+}
+
+// GetTokenPublicKeyFile - return the PEM file with the public keys verifying the RSA and EC signed bearer tokens, empty if none
+func GetTokenPublicKeyFile() string {
+	return strings.TrimSpace(os.Getenv(envVarTokenPublicKeyFile))
+}
+
+// GetTokenJWKSURL - return the url of the JWKS with the public keys verifying the bearer tokens, empty if none
+func GetTokenJWKSURL() string {
+	return strings.TrimSpace(os.Getenv(envVarTokenJWKSURL))
+}
+
+// GetTokenIssuer - return the issuer the bearer tokens must have, empty for any issuer
+func GetTokenIssuer() string {
+	return strings.TrimSpace(os.Getenv(envVarTokenIssuer))
+}
+
+// IsTrustedGateway - return true if the bearer tokens are verified by a gateway in front of nxfs, so that nxfs can
+// serve tenants without verifying them itself
+func IsTrustedGateway() bool {
+	if value := os.Getenv(envVarTrustedGateway); "" != value {
+		trusted, err := strconv.ParseBool(value)
+		if err == nil {
+			return trusted
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarTrustedGateway, value)
+	}
+	return false
+}
+
+// GetTrustedProxies - return the addresses, single ips or CIDR ranges, of the proxies in front of nxfs whose
+// X-Forwarded-For header is honoured. none is returned if the header must be ignored
+func GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv(envVarTrustedProxies), ",") {
+		if proxy = strings.TrimSpace(proxy); "" != proxy {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// GetTenantsReloadInterval - return how often the tenants file is checked for changes
+func GetTenantsReloadInterval() time.Duration {
+	if value := os.Getenv(envVarTenantsReloadInterval); "" != value {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval > 0 {
+			return interval
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarTenantsReloadInterval, value)
+	}
+	return defaultTenantsReloadInterval
+}
+
+// GetMaxBodySize - return the maximum size of the JSON request bodies
+func GetMaxBodySize() int64 {
+	return getSize(envVarMaxBodySize, defaultMaxBodySize)
+}
+
+// GetMaxChunkSize - return the maximum size of the chunks of the resumable uploads
+func GetMaxChunkSize() int64 {
+	return getSize(envVarMaxChunkSize, defaultMaxChunkSize)
+}
+
+// GetMaxArchiveSize - return the maximum size of the archives to import
+func GetMaxArchiveSize() int64 {
+	return getSize(envVarMaxArchiveSize, defaultMaxArchiveSize)
+}
+
+// GetMaxFileSize - return the maximum size of a file written to the browsable fs, zero for no limit
+func GetMaxFileSize() int64 {
+	return getSize(envVarMaxFileSize, 0)
+}
+
+// GetQuotas - return the quotas of the folders of the browsable fs, none by default
+func GetQuotas() []Quota {
+	quotas, err := ParseQuotas(os.Getenv(envVarQuotas))
+	if err != nil {
+		log.Printf("Ignoring invalid %s value: %s", envVarQuotas, err.Error())
+		return nil
+	}
+	return quotas
+}
+
+// GetTypeRules - return the rules restricting the types of the files of the folders of the browsable fs, none by default
+func GetTypeRules() []TypeRule {
+	rules, err := ParseTypeRules(os.Getenv(envVarTypeRules))
+	if err != nil {
+		log.Printf("Ignoring invalid %s value: %s", envVarTypeRules, err.Error())
+		return nil
+	}
+	return rules
+}
+
+// GetScanner - return the scanner checking the files before they're written, none by default. an unknown scanner is returned
+// as is, so that nxfs refuses to start instead of writing files without scanning them
+func GetScanner() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv(envVarScanner)))
+}
+
+// GetClamdAddress - return the address of the clamd daemon: unix:/path for a unix socket, tcp:host:port or host:port
+func GetClamdAddress() string {
+	if address := strings.TrimSpace(os.Getenv(envVarClamdAddress)); "" != address {
+		return address
+	}
+	return defaultClamdAddress
+}
+
+// GetScanCommand - return the command, with its arguments, run by the exec scanner
+func GetScanCommand() []string {
+	return strings.Fields(os.Getenv(envVarScanCommand))
+}
+
+// GetScanTimeout - return how long the scan of a file can take before failing
+func GetScanTimeout() time.Duration {
+	if value := os.Getenv(envVarScanTimeout); "" != value {
+		timeout, err := time.ParseDuration(value)
+		if err == nil && timeout > 0 {
+			return timeout
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarScanTimeout, value)
+	}
+	return defaultScanTimeout
+}
+
+// GetRenditionMaxAge - return for how long the clients can cache the raw files and their renditions without revalidating them
+func GetRenditionMaxAge() time.Duration {
+	if value := os.Getenv(envVarRenditionMaxAge); "" != value {
+		maxAge, err := time.ParseDuration(value)
+		if err == nil && maxAge >= 0 {
+			return maxAge
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarRenditionMaxAge, value)
+	}
+	return defaultRenditionMaxAge
+}
+
+// GetUploadExpiration - return for how long a resumable upload is kept without receiving chunks
+func GetUploadExpiration() time.Duration {
+	if value := os.Getenv(envVarUploadExpiration); "" != value {
+		expiration, err := time.ParseDuration(value)
+		if err == nil && expiration > 0 {
+			return expiration
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarUploadExpiration, value)
+	}
+	return defaultUploadExpiration
+}
+
+// GetCompressionLevel - return the gzip level, also the brotli quality, of the compressed responses, from 1 for the fastest to 9 for the smallest,
+// 0 to turn the compression off
+func GetCompressionLevel() int {
+	if value := os.Getenv(envVarCompressionLevel); "" != value {
+		level, err := strconv.Atoi(value)
+		if err == nil && level >= 0 && level <= 9 {
+			return level
+		}
+		log.Printf("Ignoring invalid %s value %q", envVarCompressionLevel, value)
+	}
+	return defaultCompressionLevel
+}
+
+// GetCompressionMinSize - return the size under which the responses are sent uncompressed
+func GetCompressionMinSize() int64 {
+	return getSize(envVarCompressionMinSize, defaultCompressionMinSize)
+}
+
+// getSize - return the size set by the received environment variable, the default one if it's missing or invalid
+func getSize(envVar string, defaultSize int64) int64 {
+	if value := os.Getenv(envVar); "" != value {
+		size, err := ParseSize(value)
+		if err == nil {
+			return size
+		}
+		log.Printf("Ignoring invalid %s value %q", envVar, value)
+	}
+	return defaultSize
 }
@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"fmt"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Quota - the maximum bytes and files a folder of a browsable fs can hold, zero for no limit
+type Quota struct {
+	// Path - the folder, relative to the root of the browsable fs, "." for the whole fs
+	Path     string
+	MaxBytes int64
+	MaxFiles int64
+}
+
+// sizeUnits - the multipliers of the size suffixes, powers of 1024
+var sizeUnits = map[string]int64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+
+// ParseSize - parse a size in bytes, optionally followed by a K, M, G or T binary multiplier (an ending B or iB is ignored), e.g. 512K or 10MiB
+func ParseSize(value string) (int64, error) {
+	size := strings.ToUpper(strings.TrimSpace(value))
+	size = strings.TrimSuffix(strings.TrimSuffix(size, "B"), "I")
+	digits := strings.TrimRight(size, "KMGT")
+	unit, ok := sizeUnits[size[len(digits):]]
+	number, err := strconv.ParseInt(strings.TrimSpace(digits), 10, 64)
+	if !ok || err != nil || number < 0 || number > (1<<62)/unit {
+		return 0, fmt.Errorf("%q is not a valid size", value)
+	}
+	return number * unit, nil
+}
+
+// ParseQuotas - parse a comma separated list of quotas, each one written as folder=maxBytes:maxFiles where the sizes follow
+// ParseSize and any limit can be empty, e.g. /=1G:10000,draft_pages=100M
+func ParseQuotas(value string) ([]Quota, error) {
+	var quotas []Quota
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); "" == entry {
+			continue
+		}
+		equal := strings.LastIndex(entry, "=")
+		if equal < 0 {
+			return nil, fmt.Errorf("the quota %q must be written as folder=maxBytes:maxFiles", entry)
+		}
+		quota := Quota{Path: entry[:equal]}
+		limits := strings.SplitN(entry[equal+1:], ":", 2)
+		if maxBytes := strings.TrimSpace(limits[0]); "" != maxBytes {
+			size, err := ParseSize(maxBytes)
+			if err != nil {
+				return nil, fmt.Errorf("the quota %q has an invalid maximum size: %s", entry, err.Error())
+			}
+			quota.MaxBytes = size
+		}
+		if len(limits) > 1 && "" != strings.TrimSpace(limits[1]) {
+			maxFiles, err := strconv.ParseInt(strings.TrimSpace(limits[1]), 10, 64)
+			if err != nil || maxFiles < 0 {
+				return nil, fmt.Errorf("the quota %q has an invalid maximum number of files", entry)
+			}
+			quota.MaxFiles = maxFiles
+		}
+		quotas = append(quotas, quota)
+	}
+	return CleanQuotas(quotas)
+}
+
+// CleanQuotas - return the received quotas with their folders cleaned, an error if a folder escapes the root or has two quotas
+func CleanQuotas(quotas []Quota) ([]Quota, error) {
+	cleaned := make([]Quota, 0, len(quotas))
+	folders := map[string]bool{}
+	for _, quota := range quotas {
+		folder, err := cleanFolder(quota.Path)
+		if err != nil {
+			return nil, err
+		}
+		if folders[folder] {
+			return nil, fmt.Errorf("the folder %q has more than a quota", folder)
+		}
+		if quota.MaxBytes < 0 || quota.MaxFiles < 0 {
+			return nil, fmt.Errorf("the quota of %q has negative limits", folder)
+		}
+		folders[folder] = true
+		quota.Path = folder
+		cleaned = append(cleaned, quota)
+	}
+	return cleaned, nil
+}
+
+// cleanFolder - return the received folder as a clean slash separated path relative to the browsable fs root, "." for the root itself
+func cleanFolder(folder string) (string, error) {
+	if strings.Contains("/"+filepath.ToSlash(strings.TrimSpace(folder))+"/", "/../") {
+		return "", fmt.Errorf("the folder %q is not valid", folder)
+	}
+	if cleaned := path.Clean("/" + strings.TrimSpace(folder))[1:]; "" != cleaned {
+		return cleaned, nil
+	}
+	return ".", nil
+}
+
+// limitedBody - a request body failing with a body_too_large error once more than limit bytes are read
+type limitedBody struct {
+	body      io.Reader
+	remaining int64
+	limit     int64
+}
+
+// LimitBody - return a reader of the received request body failing with a body_too_large error if it's longer than limit bytes,
+// the body itself if limit is zero
+func LimitBody(body io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return body
+	}
+	return &limitedBody{body: body, remaining: limit, limit: limit}
+}
+
+// Read - implement io.Reader, reading at most one byte more than the limit to detect the longer bodies
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, BodyTooLargeError(l.limit)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.body.Read(p)
+	if l.remaining -= int64(n); l.remaining < 0 {
+		return n + int(l.remaining), BodyTooLargeError(l.limit)
+	}
+	return n, err
+}
+
+// BodyTooLargeError - return the error of a request whose body is longer than limit bytes
+func BodyTooLargeError(limit int64) error {
+	return nxfserrors.New(nxfserrors.ErrTooLarge, "body_too_large", fmt.Sprintf("The request body is larger than %d bytes", limit))
+}
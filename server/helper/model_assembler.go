@@ -4,12 +4,11 @@ import (
 	"fmt"
 	"github.com/entando/entando-nxfs/server/model"
 	"os"
-	"path"
 	"path/filepath"
 )
 
-// ToDirectoryObject - create and return a DirectoryObject starting by the received path and FileInfo
-func ToDirectoryObject(path string, fileInfo os.FileInfo) model.DirectoryObject {
+// ToDirectoryObject - create and return a DirectoryObject starting by the received directory, relative to the browsable fs root, and FileInfo
+func ToDirectoryObject(dir string, fileInfo os.FileInfo) model.DirectoryObject {
 
 	if fileInfo == nil {
 		return model.DirectoryObject{}
@@ -20,14 +19,9 @@ func ToDirectoryObject(path string, fileInfo os.FileInfo) model.DirectoryObject
 		objectType = model.D
 	}
 
-	rel, err := filepath.Rel(GetBrowsableFsRootPath(), path)
-	if err != nil {
-		panic("Error during relativization of the file " + fileInfo.Name())
-	}
-
 	return model.DirectoryObject{
 		Name:    fileInfo.Name(),
-		Path:    rel,
+		Path:    filepath.Clean(dir),
 		Size:    fileInfo.Size(),
 		Type:    objectType,
 		Created: model.ActionLog{At: fileInfo.ModTime()},
@@ -35,36 +29,16 @@ func ToDirectoryObject(path string, fileInfo os.FileInfo) model.DirectoryObject
 	}
 }
 
-// ToDirectoryObjectFromFilePath - create and return a DirectoryObject starting by the received path
-func ToDirectoryObjectFromFilePath(filePath string) model.DirectoryObject {
-
-	var fileInfo os.FileInfo
-	var err error
-
-	if fileInfo, err = os.Stat(filePath); os.IsNotExist(err) {
-		panic(err.Error())
-	}
-
-	fullPath := path.Dir(filePath)
-
-	return ToDirectoryObject(fullPath, fileInfo)
-}
-
-// ToFileObject - create and return a FileObject starting by the received path and FileInfo
-func ToFileObject(path string, fileInfo os.FileInfo, fileContentString string) model.FileObject {
+// ToFileObject - create and return a FileObject starting by the received directory, relative to the browsable fs root, and FileInfo
+func ToFileObject(dir string, fileInfo os.FileInfo, fileContentString string) model.FileObject {
 
 	if fileInfo == nil {
 		return model.FileObject{}
 	}
 
-	rel, err := filepath.Rel(GetBrowsableFsRootPath(), path)
-	if err != nil {
-		panic("Error during relativization of the file " + fileInfo.Name())
-	}
-
 	return model.FileObject{
 		Name:    fileInfo.Name(),
-		Path:    rel,
+		Path:    filepath.Clean(dir),
 		Size:    fileInfo.Size(),
 		Type:    model.F,
 		Created: model.ActionLog{At: fileInfo.ModTime()},
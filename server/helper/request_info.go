@@ -0,0 +1,177 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const anonymousUser = "anonymous"
+
+type requestInfoKey struct{}
+
+type claimsKey struct{}
+
+// RequestInfo - information about the caller of the current request
+type RequestInfo struct {
+	User     string
+	ClientIp string
+	Roles    []string
+}
+
+// WithRequestInfo - wrap the received handler adding the RequestInfo of the caller to the request context
+func WithRequestInfo(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, roles := userFromClaims(r.Context())
+		info := RequestInfo{
+			User:     user,
+			ClientIp: clientIp(r),
+			Roles:    roles,
+		}
+		inner.ServeHTTP(w, r.WithContext(ContextWithRequestInfo(r.Context(), info)))
+	})
+}
+
+// ContextWithClaims - return a copy of the received context carrying the decoded claims of the authenticated bearer JWT
+// of the caller
+func ContextWithClaims(ctx context.Context, claims []byte) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ContextWithRequestInfo - return a copy of the received context carrying the received RequestInfo, used by the operations not started by a request
+func ContextWithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// GetRequestInfo - return the RequestInfo stored in the received context, an anonymous one if none is present
+func GetRequestInfo(ctx context.Context) RequestInfo {
+	if info, ok := ctx.Value(requestInfoKey{}).(RequestInfo); ok {
+		return info
+	}
+	return RequestInfo{User: anonymousUser}
+}
+
+// IsAdmin - return true if the caller has the nxfs administrator role
+func (info RequestInfo) IsAdmin() bool {
+	return info.HasRole(GetAdminRole())
+}
+
+// HasRole - return true if the caller has the received realm role
+func (info RequestInfo) HasRole(role string) bool {
+	for _, callerRole := range info.Roles {
+		if callerRole == role {
+			return true
+		}
+	}
+	return false
+}
+
+// userFromClaims - extract the user name and the realm roles from the claims of the authenticated bearer JWT of the
+// caller, stored in the received context
+func userFromClaims(ctx context.Context) (string, []string) {
+	payload, ok := ctx.Value(claimsKey{}).([]byte)
+	if !ok {
+		return anonymousUser, nil
+	}
+
+	var claims struct {
+		PreferredUsername string `json:"preferred_username"`
+		Subject           string `json:"sub"`
+		RealmAccess       struct {
+			Roles []string `json:"roles"`
+		} `json:"realm_access"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return anonymousUser, nil
+	}
+
+	if "" != claims.PreferredUsername {
+		return claims.PreferredUsername, claims.RealmAccess.Roles
+	} else if "" != claims.Subject {
+		return claims.Subject, claims.RealmAccess.Roles
+	}
+	return anonymousUser, nil
+}
+
+// GetClaim - return the received string claim of the authenticated bearer JWT of the caller, stored in the received
+// context, an empty string if the token or the claim is missing
+func GetClaim(ctx context.Context, claim string) string {
+	payload, ok := ctx.Value(claimsKey{}).([]byte)
+	if !ok {
+		return ""
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	value, _ := claims[claim].(string)
+	return value
+}
+
+// clientIp - return the ip of the client. the X-Forwarded-For header is honoured only when the request comes from a
+// trusted proxy: its hops are walked from the right, the client being the first one that isn't a trusted proxy
+func clientIp(r *http.Request) string {
+	remoteIp, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIp = r.RemoteAddr
+	}
+
+	proxies := trustedProxies()
+	if !isTrustedProxy(remoteIp, proxies) {
+		return remoteIp
+	}
+
+	clientIp := remoteIp
+	hops := strings.Split(strings.Join(r.Header[http.CanonicalHeaderKey("X-Forwarded-For")], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if nil == net.ParseIP(hop) {
+			// a malformed hop isn't added by a trusted proxy, the last one walked is the client
+			break
+		}
+		clientIp = hop
+		if !isTrustedProxy(hop, proxies) {
+			break
+		}
+	}
+	return clientIp
+}
+
+// trustedProxies - return the networks of the configured trusted proxies, ignoring the invalid ones
+func trustedProxies() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, proxy := range GetTrustedProxies() {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted proxy %q", proxy)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isTrustedProxy - return true if the received ip belongs to one of the trusted proxies networks
+func isTrustedProxy(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package helper
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestClientIp(t *testing.T) {
+	cases := []struct {
+		name       string
+		proxies    string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"no proxy", "", "203.0.113.9:4000", nil, "203.0.113.9"},
+		{"forwarded by an untrusted caller", "", "203.0.113.9:4000", []string{"10.1.1.1"}, "203.0.113.9"},
+		{"forwarded by a caller that isn't a trusted proxy", "10.0.0.1", "203.0.113.9:4000", []string{"10.1.1.1"}, "203.0.113.9"},
+		{"forwarded by a trusted proxy", "10.0.0.1", "10.0.0.1:4000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"spoofed hops before the client", "10.0.0.0/8", "10.0.0.1:4000", []string{"1.2.3.4, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"several headers", "10.0.0.0/8", "10.0.0.1:4000", []string{"1.2.3.4", "198.51.100.7"}, "198.51.100.7"},
+		{"only trusted hops", "10.0.0.0/8", "10.0.0.1:4000", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"malformed hop", "10.0.0.0/8", "10.0.0.1:4000", []string{"198.51.100.7, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"trusted proxy without the header", "10.0.0.1", "10.0.0.1:4000", nil, "10.0.0.1"},
+		{"ipv6 proxy", "fd00::/8, invalid", "[fd00::1]:4000", []string{"2001:db8::7"}, "2001:db8::7"},
+	}
+
+	defer os.Unsetenv(envVarTrustedProxies)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(envVarTrustedProxies, c.proxies)
+			r := httptest.NewRequest("GET", "/api/nxfs/browse", nil)
+			r.RemoteAddr = c.remoteAddr
+			for _, forwarded := range c.forwarded {
+				r.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if ip := clientIp(r); ip != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, ip)
+			}
+		})
+	}
+}
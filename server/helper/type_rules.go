@@ -0,0 +1,49 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TypeRule - the types of files a folder of a browsable fs and its subfolders accept. the types are written as extensions
+// like .page, as MIME types like image/png or as kinds of MIME types like image/*
+type TypeRule struct {
+	// Path - the folder, relative to the root of the browsable fs, "." or "/" for the whole fs
+	Path string `json:"path"`
+	// Allow - the only types accepted, any type if empty
+	Allow []string `json:"allow,omitempty"`
+	// Deny - the types rejected, even if allowed
+	Deny []string `json:"deny,omitempty"`
+}
+
+// ParseTypeRules - parse a JSON array of type rules
+func ParseTypeRules(value string) ([]TypeRule, error) {
+	if "" == strings.TrimSpace(value) {
+		return nil, nil
+	}
+	var rules []TypeRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, err
+	}
+	return CleanTypeRules(rules)
+}
+
+// CleanTypeRules - return the received rules with their folders cleaned, an error if a folder escapes the root or a type is not valid
+func CleanTypeRules(rules []TypeRule) ([]TypeRule, error) {
+	cleaned := make([]TypeRule, 0, len(rules))
+	for _, rule := range rules {
+		folder, err := cleanFolder(rule.Path)
+		if err != nil {
+			return nil, err
+		}
+		rule.Path = folder
+		for _, mimeType := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			if mimeType = strings.TrimSpace(mimeType); !strings.HasPrefix(mimeType, ".") && strings.Count(mimeType, "/") != 1 {
+				return nil, fmt.Errorf("the type %q of the rule of %s is neither an extension nor a MIME type", mimeType, folder)
+			}
+		}
+		cleaned = append(cleaned, rule)
+	}
+	return cleaned, nil
+}
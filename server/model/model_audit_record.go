@@ -0,0 +1,40 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type AuditRecord struct {
+	Seq int64 `json:"seq"`
+
+	At time.Time `json:"at"`
+
+	User string `json:"user"`
+
+	ClientIp string `json:"clientIp,omitempty"`
+
+	Path string `json:"path"`
+
+	Operation string `json:"operation"`
+
+	BeforeHash string `json:"beforeHash,omitempty"`
+
+	AfterHash string `json:"afterHash,omitempty"`
+
+	Outcome string `json:"outcome"`
+
+	Status int `json:"status"`
+
+	PrevHash string `json:"prevHash"`
+
+	Hash string `json:"hash"`
+}
@@ -0,0 +1,14 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type AuditRecordList struct {
+	List []AuditRecord `json:"list"`
+}
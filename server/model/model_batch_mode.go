@@ -0,0 +1,19 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// BatchMode : How a batch handles a failed operation: - atomic - best-effort
+type BatchMode string
+
+// List of BatchMode
+const (
+	BatchAtomic     BatchMode = "atomic"
+	BatchBestEffort BatchMode = "best-effort"
+)
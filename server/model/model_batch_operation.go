@@ -0,0 +1,29 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type BatchOperation struct {
+	Op BatchOperationType `json:"op"`
+
+	// object path, relative to the browsable fs root, or page path, relative to the pages folders, for publish and unpublish
+	Path string `json:"path"`
+
+	// destination path of a move or a copy, relative to the browsable fs root
+	To string `json:"to,omitempty"`
+
+	// object to write with a put
+	Object *FileObject `json:"object,omitempty"`
+
+	// RFC 3339 time a publish or an unpublish is scheduled at, immediate when missing
+	At string `json:"at,omitempty"`
+
+	// publish also the referenced assets, see PublishPage
+	WithAssets bool `json:"withAssets,omitempty"`
+}
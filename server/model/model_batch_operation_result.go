@@ -0,0 +1,25 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type BatchOperationResult struct {
+	Op BatchOperationType `json:"op"`
+
+	Path string `json:"path"`
+
+	// http status the operation would have had as a single request
+	Status int `json:"status"`
+
+	// body of a successful operation, e.g. the written object or the schedule
+	Result interface{} `json:"result,omitempty"`
+
+	// error of a failed operation
+	Error *Result `json:"error,omitempty"`
+}
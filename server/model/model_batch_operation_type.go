@@ -0,0 +1,23 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// BatchOperationType : Operation of a batch: - put - delete - move - copy - publish - unpublish
+type BatchOperationType string
+
+// List of BatchOperationType
+const (
+	BatchPut       BatchOperationType = "put"
+	BatchDelete    BatchOperationType = "delete"
+	BatchMove      BatchOperationType = "move"
+	BatchCopy      BatchOperationType = "copy"
+	BatchPublish   BatchOperationType = "publish"
+	BatchUnpublish BatchOperationType = "unpublish"
+)
@@ -0,0 +1,18 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type BatchRequest struct {
+	// atomic by default
+	Mode BatchMode `json:"mode,omitempty"`
+
+	// operations executed in order
+	Operations []BatchOperation `json:"operations"`
+}
@@ -0,0 +1,17 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type BatchResult struct {
+	Mode BatchMode `json:"mode"`
+
+	// results of the operations, in order
+	List []BatchOperationResult `json:"list"`
+}
@@ -0,0 +1,30 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type Commit struct {
+	Id string `json:"id"`
+
+	Author string `json:"author"`
+
+	Date time.Time `json:"date"`
+
+	Message string `json:"message"`
+
+	// paths, relative to the browsable fs root, changed by the commit
+	Paths []string `json:"paths"`
+
+	// unified diff of the changes, only when a single commit is requested
+	Diff string `json:"diff,omitempty"`
+}
@@ -20,7 +20,13 @@ type DirectoryObject struct {
 
 	Type ObjectType `json:"type,omitempty"`
 
+	// MIME type of a file
+	MimeType string `json:"mimeType,omitempty"`
+
 	Created ActionLog `json:"_created,omitempty"`
 
 	Updated ActionLog `json:"_updated,omitempty"`
+
+	// result of the scan of a file, missing if it hasn't been scanned
+	Scan *ScanResult `json:"_scan,omitempty"`
 }
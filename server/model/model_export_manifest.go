@@ -0,0 +1,43 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type ExportManifest struct {
+	Version int `json:"version"`
+
+	// path of the exported object, relative to the browsable fs root
+	Root string `json:"root"`
+
+	ExportedBy string `json:"exportedBy"`
+
+	ExportedAt time.Time `json:"exportedAt"`
+
+	Entries []ExportEntry `json:"entries"`
+}
+
+type ExportEntry struct {
+	// path of the entry in the archive
+	Path string `json:"path"`
+
+	Type ObjectType `json:"type"`
+
+	Size int64 `json:"size,omitempty"`
+
+	ModTime time.Time `json:"modTime"`
+
+	Hash string `json:"hash,omitempty"`
+
+	// how a draft page differs from its published copy, or a published page from its draft
+	PageStatus PageDiffStatus `json:"pageStatus,omitempty"`
+}
@@ -0,0 +1,21 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type GitSyncResult struct {
+	// branch of the remote repository pushed to or pulled from
+	Branch string `json:"branch"`
+
+	// id of the commit at the head of the working tree after the operation
+	Head string `json:"head"`
+
+	// paths, relative to the browsable fs root, changed in the working tree by a pull
+	Changed []string `json:"changed"`
+}
@@ -0,0 +1,22 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// ImportAction : What an import does, or would do in a dry run, with an entry of the archive: - created - overwritten - skipped - unchanged - conflict
+type ImportAction string
+
+// List of ImportAction
+const (
+	EntryCreated     ImportAction = "created"
+	EntryOverwritten ImportAction = "overwritten"
+	EntrySkipped     ImportAction = "skipped"
+	EntryUnchanged   ImportAction = "unchanged"
+	EntryConflict    ImportAction = "conflict"
+)
@@ -0,0 +1,20 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// ImportPolicy : What an import does with the files that already exist with a different content: - overwrite - skip - fail
+type ImportPolicy string
+
+// List of ImportPolicy
+const (
+	ImportOverwrite ImportPolicy = "overwrite"
+	ImportSkip      ImportPolicy = "skip"
+	ImportFail      ImportPolicy = "fail"
+)
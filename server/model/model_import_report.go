@@ -0,0 +1,36 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type ImportReport struct {
+	// path of the directory the archive has been imported into, relative to the browsable fs root
+	Target string `json:"target"`
+
+	Policy ImportPolicy `json:"policy"`
+
+	DryRun bool `json:"dryRun"`
+
+	Entries []ImportEntry `json:"entries"`
+}
+
+type ImportEntry struct {
+	// path of the entry, relative to the target directory
+	Path string `json:"path"`
+
+	Type ObjectType `json:"type"`
+
+	Action ImportAction `json:"action"`
+
+	// size of a file entry in bytes
+	Size int64 `json:"size,omitempty"`
+
+	// MIME type of a file entry
+	MimeType string `json:"mimeType,omitempty"`
+}
@@ -0,0 +1,15 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type LockRequest struct {
+	// lock duration in seconds, 0 for the default one
+	Ttl int64 `json:"ttl,omitempty"`
+}
@@ -0,0 +1,24 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type ObjectLock struct {
+	Path string `json:"path"`
+
+	Owner string `json:"owner"`
+
+	AcquiredAt time.Time `json:"acquiredAt"`
+
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,27 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type PageDiff struct {
+	// path of the page, relative to the pages folders
+	Path string `json:"path"`
+
+	Status PageDiffStatus `json:"status"`
+
+	// unified diff from the published page to the draft one
+	Unified string `json:"unified,omitempty"`
+
+	// changes of the page document, present when both sides are valid page documents
+	Semantic *PageSemanticDiff `json:"semantic,omitempty"`
+}
+
+type PageDiffList struct {
+	List []PageDiff `json:"list"`
+}
@@ -0,0 +1,21 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// PageDiffStatus : How the draft page differs from the published one: - added - removed - modified - unchanged
+type PageDiffStatus string
+
+// List of PageDiffStatus
+const (
+	PageAdded     PageDiffStatus = "added"
+	PageRemoved   PageDiffStatus = "removed"
+	PageModified  PageDiffStatus = "modified"
+	PageUnchanged PageDiffStatus = "unchanged"
+)
@@ -0,0 +1,36 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type PageDocument struct {
+	SchemaVersion int `json:"schemaVersion"`
+
+	Title string `json:"title"`
+
+	Description string `json:"description,omitempty"`
+
+	Template string `json:"template"`
+
+	Frames []PageFrame `json:"frames"`
+}
+
+type PageFrame struct {
+	Pos int `json:"pos"`
+
+	Name string `json:"name"`
+
+	Widget *PageWidget `json:"widget,omitempty"`
+}
+
+type PageWidget struct {
+	Code string `json:"code"`
+
+	Config map[string]interface{} `json:"config,omitempty"`
+}
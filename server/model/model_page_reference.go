@@ -0,0 +1,25 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type PageReference struct {
+	// path of the referencing page, relative to the browsable fs root
+	Page string `json:"page"`
+
+	// field of the page document holding the reference
+	Field string `json:"field"`
+
+	// referenced path, relative to the browsable fs root
+	Reference string `json:"reference"`
+}
+
+type PageReferenceList struct {
+	List []PageReference `json:"list"`
+}
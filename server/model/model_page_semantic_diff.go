@@ -0,0 +1,38 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type PageSemanticDiff struct {
+	Properties []PagePropertyChange `json:"properties"`
+
+	Frames []PageFrameChange `json:"frames"`
+}
+
+type PagePropertyChange struct {
+	Field string `json:"field"`
+
+	Before string `json:"before"`
+
+	After string `json:"after"`
+}
+
+type PageFrameChange struct {
+	Pos int `json:"pos"`
+
+	// added, removed or changed
+	Change string `json:"change"`
+
+	// added, removed, replaced or reconfigured, when the widget of the frame changed
+	Widget string `json:"widget,omitempty"`
+
+	Before *PageFrame `json:"before,omitempty"`
+
+	After *PageFrame `json:"after,omitempty"`
+}
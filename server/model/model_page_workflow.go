@@ -0,0 +1,34 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type PageWorkflow struct {
+	// path of the page, relative to the pages folders
+	Path string `json:"path"`
+
+	State WorkflowState `json:"state"`
+
+	// hash of the draft approved by the reviewer, only the approved content can be published
+	ApprovedHash string `json:"approvedHash,omitempty"`
+
+	UpdatedBy string `json:"updatedBy,omitempty"`
+
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+
+	History []WorkflowEvent `json:"history"`
+}
+
+type PageWorkflowList struct {
+	List []PageWorkflow `json:"list"`
+}
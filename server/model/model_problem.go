@@ -0,0 +1,27 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// Problem - RFC 7807 problem details, returned instead of a Result to clients accepting application/problem+json
+type Problem struct {
+	Type string `json:"type"`
+
+	Title string `json:"title"`
+
+	Status int `json:"status"`
+
+	Detail string `json:"detail,omitempty"`
+
+	Instance string `json:"instance,omitempty"`
+
+	Code string `json:"code"`
+
+	Details []ResultDetail `json:"details,omitempty"`
+}
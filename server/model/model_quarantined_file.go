@@ -0,0 +1,30 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type QuarantinedFile struct {
+	Id string `json:"id"`
+
+	// path the file was written to, relative to the browsable fs root
+	Path string `json:"path"`
+
+	Size int64 `json:"size"`
+
+	// user who wrote the file
+	User string `json:"user,omitempty"`
+
+	At time.Time `json:"at"`
+
+	Scan ScanResult `json:"scan"`
+}
@@ -0,0 +1,29 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type QuotaUsage struct {
+	// path of the folder, relative to the browsable fs root, "." for the whole fs
+	Path string `json:"path"`
+
+	Bytes int64 `json:"bytes"`
+
+	Files int64 `json:"files"`
+
+	// maximum bytes the folder can hold, missing if unlimited
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+
+	// maximum files the folder can hold, missing if unlimited
+	MaxFiles int64 `json:"maxFiles,omitempty"`
+}
+
+type QuotaUsageList struct {
+	List []QuotaUsage `json:"list"`
+}
@@ -0,0 +1,32 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type Release struct {
+	Id string `json:"id"`
+
+	Status ReleaseStatus `json:"status"`
+
+	// id of the release undone by this one, for the rollback releases
+	RollbackOf string `json:"rollbackOf,omitempty"`
+
+	// id of the release that undid this one
+	RolledBackBy string `json:"rolledBackBy,omitempty"`
+
+	CreatedBy string `json:"createdBy"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	Pages []ReleasePage `json:"pages"`
+}
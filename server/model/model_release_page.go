@@ -0,0 +1,21 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type ReleasePage struct {
+	// path of the page, relative to the pages folders
+	Path string `json:"path"`
+
+	// hash of the published page before the release, empty if it wasn't published
+	BeforeHash string `json:"beforeHash"`
+
+	// hash of the published page after the release, empty if the release unpublished it
+	AfterHash string `json:"afterHash"`
+}
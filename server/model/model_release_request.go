@@ -0,0 +1,18 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type ReleaseRequest struct {
+	// directory, relative to the draft pages folder, whose pages are released recursively
+	Directory string `json:"directory,omitempty"`
+
+	// draft pages to release, relative to the draft pages folder
+	Pages []string `json:"pages,omitempty"`
+}
@@ -0,0 +1,21 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// ReleaseStatus : Status of a release: - pending - applied - rolled_back - failed
+type ReleaseStatus string
+
+// List of ReleaseStatus
+const (
+	ReleasePending    ReleaseStatus = "pending"
+	ReleaseApplied    ReleaseStatus = "applied"
+	ReleaseRolledBack ReleaseStatus = "rolled_back"
+	ReleaseFailed     ReleaseStatus = "failed"
+)
@@ -13,4 +13,6 @@ type Result struct {
 	Code string `json:"code"`
 
 	Message string `json:"message"`
+
+	Details []ResultDetail `json:"details,omitempty"`
 }
@@ -0,0 +1,16 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type ResultDetail struct {
+	Field string `json:"field"`
+
+	Message string `json:"message"`
+}
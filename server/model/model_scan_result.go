@@ -0,0 +1,32 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type ScanResult struct {
+	Status ScanStatus `json:"status"`
+
+	// name of the scanner
+	Scanner string `json:"scanner"`
+
+	// malware found in an infected file
+	Signature string `json:"signature,omitempty"`
+
+	// why the file couldn't be scanned
+	Error string `json:"error,omitempty"`
+
+	// SHA-256 of the scanned content
+	Hash string `json:"hash"`
+
+	At time.Time `json:"at"`
+}
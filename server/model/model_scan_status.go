@@ -0,0 +1,20 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// ScanStatus : Result of the scan of a file: - clean - infected - failed
+type ScanStatus string
+
+// List of ScanStatus
+const (
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+	ScanFailed   ScanStatus = "failed"
+)
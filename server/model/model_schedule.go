@@ -0,0 +1,38 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type Schedule struct {
+	Id string `json:"id"`
+
+	Operation ScheduleOperation `json:"operation"`
+
+	Path string `json:"path"`
+
+	At time.Time `json:"at"`
+
+	// publish also the referenced assets, see PublishPage
+	WithAssets bool `json:"withAssets,omitempty"`
+
+	CreatedBy string `json:"createdBy"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	Status ScheduleStatus `json:"status"`
+
+	// why the execution failed
+	Error string `json:"error,omitempty"`
+
+	ExecutedAt *time.Time `json:"executedAt,omitempty"`
+}
@@ -0,0 +1,19 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// ScheduleOperation : Operation executed by a schedule: - publish - unpublish
+type ScheduleOperation string
+
+// List of ScheduleOperation
+const (
+	SchedulePublish   ScheduleOperation = "publish"
+	ScheduleUnpublish ScheduleOperation = "unpublish"
+)
@@ -0,0 +1,20 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// ScheduleStatus : Status of a schedule: - pending - running - failed
+type ScheduleStatus string
+
+// List of ScheduleStatus
+const (
+	SchedulePending ScheduleStatus = "pending"
+	ScheduleRunning ScheduleStatus = "running"
+	ScheduleFailed  ScheduleStatus = "failed"
+)
@@ -0,0 +1,37 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type Upload struct {
+	Id string `json:"id"`
+
+	// path the file is written to once the upload is finalized, relative to the browsable fs root
+	Path string `json:"path"`
+
+	// size of the whole file
+	Length int64 `json:"length"`
+
+	// bytes received so far, the next chunk starts here
+	Offset int64 `json:"offset"`
+
+	// expected SHA-256 of the whole file, hex encoded
+	Checksum string `json:"checksum,omitempty"`
+
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	// the upload is discarded if no chunk is received before this time
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,21 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type UploadRequest struct {
+	// path the file is written to once the upload is finalized, relative to the browsable fs root
+	Path string `json:"path"`
+
+	// size of the whole file
+	Length int64 `json:"length"`
+
+	// expected SHA-256 of the whole file, hex encoded, checked when the upload is finalized
+	Checksum string `json:"checksum,omitempty"`
+}
@@ -0,0 +1,23 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// WorkflowAction : Action moving a page between the workflow states: - submit - approve - reject - publish - edit - unpublish
+type WorkflowAction string
+
+// List of WorkflowAction
+const (
+	WorkflowSubmit    WorkflowAction = "submit"
+	WorkflowApprove   WorkflowAction = "approve"
+	WorkflowReject    WorkflowAction = "reject"
+	WorkflowPublish   WorkflowAction = "publish"
+	WorkflowEdit      WorkflowAction = "edit"
+	WorkflowUnpublish WorkflowAction = "unpublish"
+)
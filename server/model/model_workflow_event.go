@@ -0,0 +1,28 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+import (
+	"time"
+)
+
+type WorkflowEvent struct {
+	Action WorkflowAction `json:"action"`
+
+	From WorkflowState `json:"from"`
+
+	To WorkflowState `json:"to"`
+
+	User string `json:"user"`
+
+	Comment string `json:"comment,omitempty"`
+
+	At time.Time `json:"at"`
+}
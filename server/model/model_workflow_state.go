@@ -0,0 +1,21 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+// WorkflowState : State of a draft page in the review workflow: - draft - in_review - approved - published
+type WorkflowState string
+
+// List of WorkflowState
+const (
+	WorkflowDraft     WorkflowState = "draft"
+	WorkflowInReview  WorkflowState = "in_review"
+	WorkflowApproved  WorkflowState = "approved"
+	WorkflowPublished WorkflowState = "published"
+)
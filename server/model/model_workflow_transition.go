@@ -0,0 +1,18 @@
+/*
+ * NxFs
+ *
+ * Simple file access APIs for the Entando Nx subsystem
+ *
+ * API version: 0.0.1
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package model
+
+type WorkflowTransition struct {
+	// submit, approve or reject
+	Action WorkflowAction `json:"action"`
+
+	// reviewer comment, required to reject
+	Comment string `json:"comment,omitempty"`
+}
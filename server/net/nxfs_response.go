@@ -9,8 +9,47 @@
 
 package net
 
+import (
+	"io"
+	"time"
+)
+
 // NxfsResponse - NxfsResponse defines an error code with the associated body
 type NxfsResponse struct {
 	Code int
 	Body interface{}
 }
+
+// NxfsStream - NxfsStream is a response body streamed to the client as is, instead of being encoded as JSON
+type NxfsStream struct {
+	ContentType string
+	// FileName - when not empty the body is sent as an attachment with this name
+	FileName string
+	// Write - write the body, always called exactly once
+	Write func(w io.Writer) error
+}
+
+// NxfsContent - NxfsContent is a response body served as is with its validators, answering the conditional and range requests
+type NxfsContent struct {
+	ContentType string
+	ModTime     time.Time
+	// ETag - the entity tag of the content, without quotes
+	ETag         string
+	CacheControl string
+	// Content - the content, closed once served if it's an io.Closer
+	Content io.ReadSeeker
+	// FileName - the name of the file the content is saved as, if it's served as an attachment
+	FileName string
+	// Attachment - the content must be saved rather than displayed by the browsers
+	Attachment bool
+}
+
+// NxfsCacheable - NxfsCacheable is a response body encoded as JSON with its validators, answering the conditional requests.
+// its ETag is the hash of the encoded body
+type NxfsCacheable struct {
+	Body interface{}
+	// ModTime - the time of the last change of the body, zero if it's unknown
+	ModTime time.Time
+	// CacheControl - when empty the clients can cache the body only for themselves, revalidating it before every use
+	CacheControl string
+}
@@ -0,0 +1,241 @@
+package nxfsarchive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io"
+	"os"
+	"time"
+)
+
+// Format - the format of an archive
+type Format string
+
+// List of Format
+const (
+	TarGz Format = "tar.gz"
+	Zip   Format = "zip"
+)
+
+// ManifestName - name of the archive entry describing the exported objects, written before any other entry
+const ManifestName = ".nxfs-export.json"
+
+// EntryType - the kind of an archive entry
+type EntryType int
+
+// List of EntryType
+const (
+	FileEntry EntryType = iota
+	DirEntry
+	// OtherEntry - links, devices and any other entry that can't be imported
+	OtherEntry
+)
+
+// Entry - an entry read from an archive
+type Entry struct {
+	// Name - the name of the entry as found in the archive, not to be trusted
+	Name    string
+	Type    EntryType
+	ModTime time.Time
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zipMagic = []byte("PK\x03\x04")
+
+// ParseFormat - return the archive format identified by the received value, tar.gz if it's empty
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", TarGz, "tgz":
+		return TarGz, nil
+	case Zip:
+		return Zip, nil
+	}
+	return "", nxfserrors.New(nxfserrors.ErrInvalid, "invalid_format", fmt.Sprintf("Unsupported archive format %q, use tar.gz or zip", value))
+}
+
+// ContentType - return the media type of the archives of the received format
+func (f Format) ContentType() string {
+	if f == Zip {
+		return "application/zip"
+	}
+	return "application/gzip"
+}
+
+// Walk - call fn for every entry of the received tar.gz or zip archive, detected by its content, with a reader of the
+// entry content for the files. fn must not retain the reader after returning
+func Walk(archive *os.File, fn func(entry Entry, content io.Reader) error) error {
+	magic := make([]byte, len(zipMagic))
+	n, err := archive.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nxfserrors.FromOS(err, "archive_read_error", "An error occurred during the reading of the archive")
+	}
+
+	switch {
+	case bytes.HasPrefix(magic[:n], zipMagic):
+		return walkZip(archive, fn)
+	case bytes.HasPrefix(magic[:n], gzipMagic):
+		return walkTarGz(archive, fn)
+	}
+	return nxfserrors.New(nxfserrors.ErrInvalid, "invalid_archive", "The archive is neither a tar.gz nor a zip")
+}
+
+// walkTarGz - walk the entries of a tar.gz archive
+func walkTarGz(archive *os.File, fn func(entry Entry, content io.Reader) error) error {
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return nxfserrors.FromOS(err, "archive_read_error", "An error occurred during the reading of the archive")
+	}
+	gzipReader, err := gzip.NewReader(bufio.NewReader(archive))
+	if err != nil {
+		return corruptedError(err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return corruptedError(err)
+		}
+
+		entry := Entry{Name: header.Name, Type: OtherEntry, ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			entry.Type = FileEntry
+		case tar.TypeDir:
+			entry.Type = DirEntry
+		case tar.TypeXGlobalHeader:
+			continue
+		}
+
+		var content io.Reader
+		if entry.Type == FileEntry {
+			content = tarReader
+		}
+		if err = fn(entry, content); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip - walk the entries of a zip archive
+func walkZip(archive *os.File, fn func(entry Entry, content io.Reader) error) error {
+	fileInfo, err := archive.Stat()
+	if err != nil {
+		return nxfserrors.FromOS(err, "archive_read_error", "An error occurred during the reading of the archive")
+	}
+	zipReader, err := zip.NewReader(archive, fileInfo.Size())
+	if err != nil {
+		return corruptedError(err)
+	}
+
+	for _, file := range zipReader.File {
+		entry := Entry{Name: file.Name, Type: OtherEntry, ModTime: file.Modified}
+		switch mode := file.Mode(); {
+		case mode.IsDir():
+			entry.Type = DirEntry
+		case mode.IsRegular():
+			entry.Type = FileEntry
+		}
+
+		if entry.Type != FileEntry {
+			if err = fn(entry, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return corruptedError(err)
+		}
+		err = fn(entry, content)
+		if closeErr := content.Close(); err == nil && closeErr != nil {
+			// the checksum of the entry is verified when it's closed
+			err = corruptedError(closeErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveWriter - writes the entries of an archive
+type archiveWriter interface {
+	writeDir(name string, modTime time.Time) error
+	writeFile(name string, size int64, modTime time.Time, content io.Reader) error
+	Close() error
+}
+
+// newArchiveWriter - return a writer of an archive of the received format
+func newArchiveWriter(w io.Writer, format Format) archiveWriter {
+	if format == Zip {
+		return &zipWriter{zip.NewWriter(w)}
+	}
+	gzipWriter := gzip.NewWriter(w)
+	return &tarGzWriter{gzip: gzipWriter, tar: tar.NewWriter(gzipWriter)}
+}
+
+// tarGzWriter - an archiveWriter of tar.gz archives
+type tarGzWriter struct {
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func (w *tarGzWriter) writeDir(name string, modTime time.Time) error {
+	return w.tar.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755, ModTime: modTime})
+}
+
+func (w *tarGzWriter) writeFile(name string, size int64, modTime time.Time, content io.Reader) error {
+	if err := w.tar.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: size, ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w.tar, content, size)
+	return err
+}
+
+func (w *tarGzWriter) Close() error {
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+	return w.gzip.Close()
+}
+
+// zipWriter - an archiveWriter of zip archives
+type zipWriter struct {
+	zip *zip.Writer
+}
+
+func (w *zipWriter) writeDir(name string, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name + "/", Modified: modTime}
+	header.SetMode(os.ModeDir | 0755)
+	_, err := w.zip.CreateHeader(header)
+	return err
+}
+
+func (w *zipWriter) writeFile(name string, size int64, modTime time.Time, content io.Reader) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	header.SetMode(0644)
+	entryWriter, err := w.zip.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(entryWriter, content, size)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zip.Close()
+}
+
+// corruptedError - return the error describing an archive that can't be read
+func corruptedError(err error) error {
+	return nxfserrors.New(nxfserrors.ErrInvalid, "invalid_archive", "The archive is corrupted: "+err.Error())
+}
@@ -0,0 +1,295 @@
+package nxfsarchive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var browsableFs string
+
+func TestMain(m *testing.M) {
+	var err error
+	if browsableFs, err = ioutil.TempDir("", "nxfs-archive-test"); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(browsableFs)
+	os.Exit(code)
+}
+
+const validPage = `{"schemaVersion":1,"title":"Home","template":"layouts/default.html","frames":[]}`
+
+func writeFile(t *testing.T, relPath string, content string) {
+	fullPath := filepath.Join(browsableFs, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertContent(t *testing.T, relPath string, expected string) {
+	content, err := ioutil.ReadFile(filepath.Join(browsableFs, relPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Fatalf("%s: expected %q, got %q", relPath, expected, content)
+	}
+}
+
+// archiveFile - write the received archive content to a temporary file, as the service spools the uploads
+func archiveFile(t *testing.T, content []byte) *os.File {
+	file, err := ioutil.TempFile("", "nxfs-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func export(t *testing.T, relPath string, format Format) *os.File {
+	manifest, err := Manifest(browsableFs, relPath, "exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err = Export(&archive, browsableFs, manifest, format); err != nil {
+		t.Fatal(err)
+	}
+	return archiveFile(t, archive.Bytes())
+}
+
+func importArchive(archive *os.File, target string, policy model.ImportPolicy, dryRun bool) (model.ImportReport, []string, error) {
+	var written []string
+	report, err := Import(archive, browsableFs, target, policy, dryRun, func(entries []model.ImportEntry) error {
+		return nil
+	}, nil, func(relPath string, beforeHash string, afterHash string) {
+		written = append(written, relPath)
+	})
+	return report, written, err
+}
+
+func actions(report model.ImportReport) map[string]model.ImportAction {
+	result := map[string]model.ImportAction{}
+	for _, entry := range report.Entries {
+		result[entry.Path] = entry.Action
+	}
+	return result
+}
+
+func TestExportAndImportRoundTrip(t *testing.T) {
+	writeFile(t, "source/draft_pages/home.page", validPage)
+	writeFile(t, "source/pages/home.page", validPage)
+	writeFile(t, "source/assets/logo.png", "logo")
+	if err := os.MkdirAll(filepath.Join(browsableFs, "source", "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{TarGz, Zip} {
+		archive := export(t, "source", format)
+		defer os.Remove(archive.Name())
+
+		target := "copy-" + string(format)
+		report, written, err := importArchive(archive, target, model.ImportFail, false)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", format, err)
+		}
+		if len(written) != 3 {
+			t.Fatalf("%s: expected 3 written files, got %v", format, written)
+		}
+		if action := actions(report)["empty"]; action != model.EntryCreated {
+			t.Fatalf("%s: expected the empty directory to be created, got %q", format, action)
+		}
+		assertContent(t, filepath.Join(target, "assets", "logo.png"), "logo")
+		assertContent(t, filepath.Join(target, "draft_pages", "home.page"), validPage)
+		if _, err = os.Stat(filepath.Join(browsableFs, target, ManifestName)); !os.IsNotExist(err) {
+			t.Fatalf("%s: the manifest has been imported", format)
+		}
+
+		// importing the same archive again changes nothing
+		report, written, err = importArchive(archive, target, model.ImportFail, false)
+		if err != nil || len(written) != 0 || actions(report)["assets/logo.png"] != model.EntryUnchanged {
+			t.Fatalf("%s: expected an unchanged import, got %v %v %v", format, err, written, report)
+		}
+	}
+}
+
+func TestManifestRecordsThePageStatus(t *testing.T) {
+	writeFile(t, "draft_pages/same.page", validPage)
+	writeFile(t, "pages/same.page", validPage)
+	writeFile(t, "draft_pages/changed.page", validPage)
+	writeFile(t, "pages/changed.page", `{"schemaVersion":1,"title":"Old","template":"layouts/default.html","frames":[]}`)
+	writeFile(t, "draft_pages/new.page", validPage)
+	defer os.RemoveAll(filepath.Join(browsableFs, "draft_pages"))
+	defer os.RemoveAll(filepath.Join(browsableFs, "pages"))
+
+	manifest, err := Manifest(browsableFs, "draft_pages", "exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]model.PageDiffStatus{"same.page": model.PageUnchanged, "changed.page": model.PageModified, "new.page": model.PageAdded}
+	for _, entry := range manifest.Entries {
+		if entry.PageStatus != expected[entry.Path] {
+			t.Errorf("%s: expected status %q, got %q", entry.Path, expected[entry.Path], entry.PageStatus)
+		}
+	}
+}
+
+func TestImportPolicies(t *testing.T) {
+	writeFile(t, "policies/source/a.txt", "new a")
+	writeFile(t, "policies/source/b.txt", "new b")
+	archive := export(t, "policies/source", TarGz)
+	defer os.Remove(archive.Name())
+
+	reset := func() {
+		writeFile(t, "policies/target/a.txt", "old a")
+		os.Remove(filepath.Join(browsableFs, "policies", "target", "b.txt"))
+	}
+
+	reset()
+	report, written, err := importArchive(archive, "policies/target", model.ImportFail, true)
+	if err != nil || len(written) != 0 {
+		t.Fatalf("dry run: unexpected %v %v", err, written)
+	}
+	if got := actions(report); got["a.txt"] != model.EntryConflict || got["b.txt"] != model.EntryCreated {
+		t.Fatalf("dry run: unexpected actions %v", got)
+	}
+
+	if _, _, err = importArchive(archive, "policies/target", model.ImportFail, false); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("fail policy: expected a conflict, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(browsableFs, "policies", "target", "b.txt")); !os.IsNotExist(err) {
+		t.Fatal("fail policy: a file has been written")
+	}
+
+	if _, _, err = importArchive(archive, "policies/target", model.ImportSkip, false); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, "policies/target/a.txt", "old a")
+	assertContent(t, "policies/target/b.txt", "new b")
+
+	reset()
+	if _, _, err = importArchive(archive, "policies/target", model.ImportOverwrite, false); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, "policies/target/a.txt", "new a")
+}
+
+func TestImportIsCheckedBeforeWriting(t *testing.T) {
+	writeFile(t, "checked/source/a.txt", "12345")
+	writeFile(t, "checked/source/dir/b.txt", "123")
+	archive := export(t, "checked/source", Zip)
+	defer os.Remove(archive.Name())
+
+	var checked []model.ImportEntry
+	_, err := Import(archive, browsableFs, "checked/target", model.ImportFail, false, func(entries []model.ImportEntry) error {
+		checked = entries
+		return nxfserrors.New(nxfserrors.ErrNoSpace, "quota_exceeded", "too much")
+	}, nil, func(relPath string, beforeHash string, afterHash string) {
+		t.Fatalf("%s has been written", relPath)
+	})
+	if !errors.Is(err, nxfserrors.ErrNoSpace) {
+		t.Fatalf("expected the check error, got %v", err)
+	}
+	sizes := map[string]int64{}
+	for _, entry := range checked {
+		sizes[entry.Path] = entry.Size
+	}
+	if sizes["a.txt"] != 5 || sizes["dir/b.txt"] != 3 || sizes["dir"] != 0 {
+		t.Fatalf("unexpected checked entries %v", checked)
+	}
+	if _, err = os.Stat(filepath.Join(browsableFs, "checked", "target")); !os.IsNotExist(err) {
+		t.Fatal("something has been written")
+	}
+}
+
+func TestImportIsScannedBeforeWriting(t *testing.T) {
+	writeFile(t, "scanned/source/a.txt", "clean")
+	writeFile(t, "scanned/source/dir/b.txt", "infected")
+	writeFile(t, "scanned/target/a.txt", "clean")
+	archive := export(t, "scanned/source", TarGz)
+	defer os.Remove(archive.Name())
+
+	scanned := map[string]string{}
+	scan := func(relPath string, content io.Reader) error {
+		data, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		scanned[relPath] = string(data)
+		if "infected" == string(data) {
+			return nxfserrors.New(nxfserrors.ErrUnprocessable, "infected", relPath+" is infected")
+		}
+		return nil
+	}
+	noCheck := func(entries []model.ImportEntry) error { return nil }
+	noWrite := func(relPath string, beforeHash string, afterHash string) { t.Fatalf("%s has been written", relPath) }
+
+	if _, err := Import(archive, browsableFs, "scanned/target", model.ImportFail, true, noCheck, scan, noWrite); err != nil || len(scanned) != 0 {
+		t.Fatalf("expected a dry run not to scan, got %v %v", scanned, err)
+	}
+
+	_, err := Import(archive, browsableFs, "scanned/target", model.ImportFail, false, noCheck, scan, noWrite)
+	if !errors.Is(err, nxfserrors.ErrUnprocessable) {
+		t.Fatalf("expected the scan error, got %v", err)
+	}
+	// the unchanged file isn't scanned again
+	if len(scanned) != 1 || scanned["scanned/target/dir/b.txt"] != "infected" {
+		t.Fatalf("unexpected scanned files %v", scanned)
+	}
+	if _, err = os.Stat(filepath.Join(browsableFs, "scanned", "target", "dir")); !os.IsNotExist(err) {
+		t.Fatal("something has been written")
+	}
+}
+
+func TestImportRejectsEntriesEscapingTheTarget(t *testing.T) {
+	var tarGz bytes.Buffer
+	gzipWriter := gzip.NewWriter(&tarGz)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range []string{"ok.txt", "../escaped.txt", "/absolute.txt", "nested/../../escaped.txt"} {
+		tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: 2})
+		tarWriter.Write([]byte("hi"))
+	}
+	tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "/etc/passwd"})
+	tarWriter.Close()
+	gzipWriter.Close()
+
+	var zipped bytes.Buffer
+	zipWriter := zip.NewWriter(&zipped)
+	for _, name := range []string{"ok.txt", "..\\escaped.txt"} {
+		entryWriter, _ := zipWriter.Create(name)
+		entryWriter.Write([]byte("hi"))
+	}
+	zipWriter.Close()
+
+	for format, content := range map[string][]byte{"tar.gz": tarGz.Bytes(), "zip": zipped.Bytes()} {
+		archive := archiveFile(t, content)
+		defer os.Remove(archive.Name())
+
+		_, written, err := importArchive(archive, "slip/target", model.ImportOverwrite, false)
+		if !errors.Is(err, nxfserrors.ErrUnprocessable) || len(written) != 0 {
+			t.Fatalf("%s: expected the archive to be rejected, got %v %v", format, err, written)
+		}
+		if details := err.(*nxfserrors.Error).Details; len(details) == 0 || details[0].Field == "ok.txt" {
+			t.Fatalf("%s: unexpected details %v", format, details)
+		}
+		if _, err = os.Stat(filepath.Join(browsableFs, "slip")); !os.IsNotExist(err) {
+			t.Fatalf("%s: something has been written", format)
+		}
+	}
+}
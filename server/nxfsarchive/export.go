@@ -0,0 +1,138 @@
+package nxfsarchive
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// manifestVersion - version of the format of the export manifest
+const manifestVersion = 1
+
+// Manifest - return the manifest of the export of the object identified by the received path, relative to the received browsable
+// fs root: the object itself if it's a file, everything under it if it's a directory
+func Manifest(root string, relPath string, user string) (model.ExportManifest, error) {
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	manifest := model.ExportManifest{Version: manifestVersion, Root: relPath, ExportedBy: user, ExportedAt: time.Now().UTC(), Entries: []model.ExportEntry{}}
+
+	rootPath := filepath.Join(root, filepath.FromSlash(relPath))
+	rootInfo, err := os.Stat(rootPath)
+	if err != nil {
+		return model.ExportManifest{}, nxfserrors.FromOS(err, "export_error", "An error occurred during the reading of the object to export")
+	}
+	if !rootInfo.IsDir() {
+		manifest.Entries = append(manifest.Entries, fileEntry(root, path.Base(relPath), relPath, rootPath, rootInfo))
+		return manifest, nil
+	}
+
+	err = filepath.Walk(rootPath, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == rootPath || nxfsfiles.IsTempFile(fileInfo.Name()) {
+			return nil
+		}
+
+		name, _ := filepath.Rel(rootPath, filePath)
+		name = filepath.ToSlash(name)
+		if fileInfo.IsDir() {
+			manifest.Entries = append(manifest.Entries, model.ExportEntry{Path: name, Type: model.D, ModTime: fileInfo.ModTime().UTC()})
+		} else if fileInfo.Mode().IsRegular() {
+			manifest.Entries = append(manifest.Entries, fileEntry(root, name, path.Join(relPath, name), filePath, fileInfo))
+		}
+		return nil
+	})
+	if err != nil {
+		return model.ExportManifest{}, nxfserrors.FromOS(err, "export_error", "An error occurred during the listing of the objects to export")
+	}
+	return manifest, nil
+}
+
+// Export - write to w the archive of the objects listed by the received manifest, starting with the manifest itself
+func Export(w io.Writer, root string, manifest model.ExportManifest, format Format) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	archive := newArchiveWriter(w, format)
+	if err = archive.writeFile(ManifestName, int64(len(content)), manifest.ExportedAt, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	rootPath := filepath.Join(root, filepath.FromSlash(manifest.Root))
+	rootInfo, err := os.Stat(rootPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range manifest.Entries {
+		if entry.Type == model.D {
+			err = archive.writeDir(entry.Path, entry.ModTime)
+		} else if rootInfo.IsDir() {
+			err = exportFile(archive, entry, filepath.Join(rootPath, filepath.FromSlash(entry.Path)))
+		} else {
+			err = exportFile(archive, entry, rootPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// exportFile - write a file to the archive with the size recorded by the manifest
+func exportFile(archive archiveWriter, entry model.ExportEntry, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return archive.writeFile(entry.Path, entry.Size, entry.ModTime, file)
+}
+
+// fileEntry - return the manifest entry of a file, whose path relative to the browsable fs root is relPath
+func fileEntry(root string, name string, relPath string, filePath string, fileInfo os.FileInfo) model.ExportEntry {
+	entry := model.ExportEntry{Path: name, Type: model.F, Size: fileInfo.Size(), ModTime: fileInfo.ModTime().UTC(), Hash: nxfsfiles.HashFile(filePath)}
+	if nxfspages.IsPage(relPath) {
+		entry.PageStatus = pageStatus(root, relPath, entry.Hash)
+	}
+	return entry
+}
+
+// pageStatus - return how the page identified by the received path, relative to the browsable fs root, differs from
+// its counterpart: the published copy for a draft, the draft for a published page. empty if it's not in a pages folder
+func pageStatus(root string, relPath string, hash string) model.PageDiffStatus {
+	draftPrefix := helper.GetDraftPagesRelativePath() + "/"
+	publishedPrefix := helper.GetPublishedPagesRelativePath() + "/"
+
+	var counterpart string
+	missing := model.PageAdded
+	switch {
+	case strings.HasPrefix(relPath, draftPrefix):
+		counterpart = publishedPrefix + strings.TrimPrefix(relPath, draftPrefix)
+	case strings.HasPrefix(relPath, publishedPrefix):
+		counterpart = draftPrefix + strings.TrimPrefix(relPath, publishedPrefix)
+		missing = model.PageRemoved
+	default:
+		return ""
+	}
+
+	switch counterpartHash := nxfsfiles.HashFile(filepath.Join(root, filepath.FromSlash(counterpart))); counterpartHash {
+	case "":
+		return missing
+	case hash:
+		return model.PageUnchanged
+	}
+	return model.PageModified
+}
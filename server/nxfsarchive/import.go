@@ -0,0 +1,295 @@
+package nxfsarchive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxManifestSize - the manifests larger than this are not read
+const maxManifestSize = 64 << 20
+
+// plannedEntry - an entry of the archive being imported with what the import does with it
+type plannedEntry struct {
+	entry model.ImportEntry
+	hash  string
+}
+
+// importPlan - what an import does with every entry of the archive, and the problems preventing it
+type importPlan struct {
+	root     string
+	target   string
+	policy   model.ImportPolicy
+	entries  map[string]*plannedEntry
+	order    []string
+	manifest *model.ExportManifest
+	details  []model.ResultDetail
+}
+
+// Import - import into the target directory, relative to the received browsable fs root, the tar.gz or zip archive read from the received file.
+// every entry is checked before writing anything: entries escaping the target directory, of unsupported types, clashing with an
+// object of the other type, not matching the manifest or that are invalid draft pages make the import fail with an invalid_archive
+// error listing them. files existing with a different content are overwritten or skipped according to the policy; with the fail
+// policy any of them makes the import fail with an import_conflict error. a dry run only reports what the import would do.
+// check is called with the planned entries before writing anything, even on a dry run, its error makes the import fail.
+// scan, unless nil or on a dry run, is then called with the path relative to the browsable fs root and the content of every
+// file to write, before writing anything: its first error makes the import fail. written is called for every written file with its path relative to the browsable fs root and its hash before and after
+func Import(archive *os.File, root string, target string, policy model.ImportPolicy, dryRun bool, check func(entries []model.ImportEntry) error, scan func(relPath string, content io.Reader) error, written func(relPath string, beforeHash string, afterHash string)) (model.ImportReport, error) {
+	plan := &importPlan{
+		root:    filepath.Join(root, filepath.FromSlash(target)),
+		target:  filepath.ToSlash(filepath.Clean(target)),
+		policy:  policy,
+		entries: map[string]*plannedEntry{},
+	}
+
+	if targetInfo, err := os.Stat(plan.root); err == nil && !targetInfo.IsDir() {
+		return model.ImportReport{}, nxfserrors.New(nxfserrors.ErrConflict, "path_conflict", "The import target is not a directory")
+	}
+	if err := Walk(archive, plan.add); err != nil {
+		return model.ImportReport{}, err
+	}
+	plan.checkManifest()
+
+	report := model.ImportReport{Target: plan.target, Policy: policy, DryRun: dryRun, Entries: make([]model.ImportEntry, 0, len(plan.order))}
+	var conflicts []model.ResultDetail
+	for _, name := range plan.order {
+		report.Entries = append(report.Entries, plan.entries[name].entry)
+		if plan.entries[name].entry.Action == model.EntryConflict {
+			conflicts = append(conflicts, model.ResultDetail{Field: name, Message: "exists with a different content"})
+		}
+	}
+
+	if len(plan.details) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrUnprocessable, "invalid_archive", "Some entries of the archive can't be imported, nothing has been written")
+		err.Details = plan.details
+		return model.ImportReport{}, err
+	}
+	if err := check(report.Entries); err != nil {
+		return model.ImportReport{}, err
+	}
+	if dryRun {
+		return report, nil
+	}
+	if len(conflicts) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrConflict, "import_conflict", "Some files of the archive already exist with a different content, nothing has been written")
+		err.Details = conflicts
+		return model.ImportReport{}, err
+	}
+	if scan != nil {
+		if err := Walk(archive, func(entry Entry, content io.Reader) error {
+			if name, planned, ok := plan.toWrite(entry); ok && planned.entry.Type == model.F {
+				return scan(path.Join(plan.target, name), content)
+			}
+			return nil
+		}); err != nil {
+			return model.ImportReport{}, err
+		}
+	}
+
+	if err := os.MkdirAll(plan.root, 0755); err != nil {
+		return model.ImportReport{}, nxfserrors.FromOS(err, "import_write_error", "An error occurred during the creation of the import target")
+	}
+	if err := Walk(archive, func(entry Entry, content io.Reader) error {
+		return plan.apply(entry, content, written)
+	}); err != nil {
+		return model.ImportReport{}, err
+	}
+	return report, nil
+}
+
+// add - plan what the import does with an entry of the archive, recording the reasons why it can't be imported
+func (p *importPlan) add(entry Entry, content io.Reader) error {
+	if path.Clean(entry.Name) == ManifestName && entry.Type == FileEntry {
+		return p.readManifest(content)
+	}
+
+	name, ok := cleanEntryName(entry.Name)
+	if !ok {
+		p.details = append(p.details, model.ResultDetail{Field: entry.Name, Message: "escapes the target directory"})
+		return nil
+	}
+	if _, duplicated := p.entries[name]; duplicated {
+		p.details = append(p.details, model.ResultDetail{Field: name, Message: "duplicated entry"})
+		return nil
+	}
+	if entry.Type == OtherEntry {
+		p.details = append(p.details, model.ResultDetail{Field: name, Message: "only files and directories can be imported"})
+		return nil
+	}
+
+	planned := &plannedEntry{entry: model.ImportEntry{Path: name, Type: model.F, Action: model.EntryCreated}}
+	if entry.Type == DirEntry {
+		planned.entry.Type = model.D
+	}
+	p.entries[name] = planned
+	p.order = append(p.order, name)
+
+	fullPath := filepath.Join(p.root, filepath.FromSlash(name))
+	if ancestor, ok := p.fileAncestor(name); !ok {
+		p.details = append(p.details, model.ResultDetail{Field: name, Message: fmt.Sprintf("the file %s exists where a directory is needed", ancestor)})
+		return nil
+	}
+
+	if entry.Type == FileEntry {
+		if err := p.hashAndValidate(planned, content); err != nil {
+			return err
+		}
+	}
+
+	existing, err := os.Stat(fullPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nxfserrors.FromOS(err, "import_read_error", fmt.Sprintf("An error occurred during the reading of %s", name))
+	case existing.IsDir() != (entry.Type == DirEntry):
+		p.details = append(p.details, model.ResultDetail{Field: name, Message: "an object of another type exists at this path"})
+	case entry.Type == DirEntry || nxfsfiles.HashFile(fullPath) == planned.hash:
+		planned.entry.Action = model.EntryUnchanged
+	case p.policy == model.ImportOverwrite:
+		planned.entry.Action = model.EntryOverwritten
+	case p.policy == model.ImportSkip:
+		planned.entry.Action = model.EntrySkipped
+	default:
+		planned.entry.Action = model.EntryConflict
+	}
+	return nil
+}
+
+// hashAndValidate - record the hash, the size and the type of the content of a file entry, validating it if it's imported as a draft page
+func (p *importPlan) hashAndValidate(planned *plannedEntry, content io.Reader) error {
+	name := planned.entry.Path
+	if !nxfspages.IsDraftPage(path.Join(p.target, name)) {
+		hash := sha256.New()
+		sniffer := &nxfsmime.Sniffer{}
+		size, err := io.Copy(io.MultiWriter(hash, sniffer), content)
+		if err != nil {
+			return corruptedError(err)
+		}
+		planned.hash, planned.entry.Size, planned.entry.MimeType = hex.EncodeToString(hash.Sum(nil)), size, sniffer.Detect(name)
+		return nil
+	}
+
+	page, err := ioutil.ReadAll(content)
+	if err != nil {
+		return corruptedError(err)
+	}
+	if err = nxfspages.ValidatePage(page); err != nil {
+		if pageErr, ok := err.(*nxfserrors.Error); ok && len(pageErr.Details) > 0 {
+			for _, detail := range pageErr.Details {
+				p.details = append(p.details, model.ResultDetail{Field: name + ":" + detail.Field, Message: detail.Message})
+			}
+		} else {
+			p.details = append(p.details, model.ResultDetail{Field: name, Message: nxfserrors.Wrap(err, "").Message})
+		}
+	}
+	planned.hash, planned.entry.Size, planned.entry.MimeType = nxfsfiles.HashContent(page), int64(len(page)), nxfsmime.Detect(name, page)
+	return nil
+}
+
+// fileAncestor - return false, with its path, if a directory containing the entry, under the target, exists as a file
+func (p *importPlan) fileAncestor(name string) (string, bool) {
+	for ancestor := path.Dir(name); "." != ancestor; ancestor = path.Dir(ancestor) {
+		if fileInfo, err := os.Stat(filepath.Join(p.root, filepath.FromSlash(ancestor))); err == nil && !fileInfo.IsDir() {
+			return ancestor, false
+		}
+	}
+	return "", true
+}
+
+// readManifest - read the manifest of the archive, used to check that the archive content is the exported one
+func (p *importPlan) readManifest(content io.Reader) error {
+	var manifest model.ExportManifest
+	if err := json.NewDecoder(io.LimitReader(content, maxManifestSize)).Decode(&manifest); err != nil {
+		p.details = append(p.details, model.ResultDetail{Field: ManifestName, Message: "not a valid export manifest: " + err.Error()})
+		return nil
+	}
+	p.manifest = &manifest
+	return nil
+}
+
+// checkManifest - record the entries whose content doesn't match the manifest, if the archive has one
+func (p *importPlan) checkManifest() {
+	if p.manifest == nil {
+		return
+	}
+	for _, manifestEntry := range p.manifest.Entries {
+		planned, ok := p.entries[manifestEntry.Path]
+		if !ok {
+			p.details = append(p.details, model.ResultDetail{Field: manifestEntry.Path, Message: "listed in the manifest but missing from the archive"})
+		} else if "" != manifestEntry.Hash && manifestEntry.Hash != planned.hash {
+			p.details = append(p.details, model.ResultDetail{Field: manifestEntry.Path, Message: "the content doesn't match the hash in the manifest"})
+		}
+	}
+}
+
+// toWrite - return the clean name and the plan of an entry of the archive, false if the import doesn't write it
+func (p *importPlan) toWrite(entry Entry) (string, *plannedEntry, bool) {
+	name, _ := cleanEntryName(entry.Name)
+	planned, ok := p.entries[name]
+	if !ok || (planned.entry.Action != model.EntryCreated && planned.entry.Action != model.EntryOverwritten) {
+		return "", nil, false
+	}
+	return name, planned, true
+}
+
+// apply - write an entry of the archive as planned
+func (p *importPlan) apply(entry Entry, content io.Reader, written func(relPath string, beforeHash string, afterHash string)) error {
+	name, planned, ok := p.toWrite(entry)
+	if !ok {
+		return nil
+	}
+
+	fullPath := filepath.Join(p.root, filepath.FromSlash(name))
+	if planned.entry.Type == model.D {
+		if err := os.MkdirAll(fullPath, 0755); err != nil {
+			return nxfserrors.FromOS(err, "import_write_error", fmt.Sprintf("An error occurred during the creation of %s", name))
+		}
+		return nil
+	}
+
+	beforeHash := nxfsfiles.HashFile(fullPath)
+	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err == nil {
+		err = nxfsfiles.WriteFileAtomic(fullPath, content, 0644)
+	}
+	if err != nil {
+		return nxfserrors.FromOS(err, "import_write_error", fmt.Sprintf("An error occurred during the writing of %s, the entries before it have been imported", name))
+	}
+	if !entry.ModTime.IsZero() {
+		// keeping the exported modification time is best effort, the content has been imported anyway
+		os.Chtimes(fullPath, entry.ModTime, entry.ModTime)
+	}
+
+	written(path.Join(p.target, name), beforeHash, planned.hash)
+	return nil
+}
+
+// cleanEntryName - return the name of an archive entry as a clean slash separated path, false if it would escape the target directory
+func cleanEntryName(name string) (string, bool) {
+	if strings.ContainsAny(name, "\\\x00") || path.IsAbs(name) || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+
+	cleaned := path.Clean(name)
+	if "." == cleaned || ".." == cleaned || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	for _, segment := range strings.Split(cleaned, "/") {
+		if nxfsfiles.IsTempFile(segment) {
+			return "", false
+		}
+	}
+	return cleaned, true
+}
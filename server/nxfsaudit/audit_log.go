@@ -0,0 +1,263 @@
+package nxfsaudit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	pkgErr "github.com/pkg/errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// audited operations
+const (
+	OpPut       = "put"
+	OpDelete    = "delete"
+	OpPublish   = "publish"
+	OpUnpublish = "unpublish"
+	OpRelease   = "release"
+	OpRestore   = "restore"
+	OpImport    = "import"
+	OpRevert    = "revert"
+	OpPull      = "pull"
+	OpUpload    = "upload"
+)
+
+// OutcomeSuccess - outcome of an operation that completed without errors
+const OutcomeSuccess = "success"
+
+// genesisHash - the previous hash of the first record of the chain
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// logFile - the file of a Log, replaced in the tests to simulate the failed writes
+type logFile interface {
+	io.WriteCloser
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// Log - an append only, hash chained, audit log persisted as a JSON line file
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	file     logFile
+	lastSeq  int64
+	lastHash string
+}
+
+// Filter - criteria used to query the audit log, zero values match everything
+type Filter struct {
+	Path string
+	User string
+	From time.Time
+	To   time.Time
+}
+
+// NewLog - create a Log backed by the file identified by the received path. the file is opened on the first append
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Append - append a record describing the received operation to the log, chaining it to the previous one
+func (l *Log) Append(ctx context.Context, operation string, path string, beforeHash string, afterHash string, outcome string, status int) (model.AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.open(); err != nil {
+		return model.AuditRecord{}, err
+	}
+
+	requestInfo := helper.GetRequestInfo(ctx)
+	record := model.AuditRecord{
+		Seq:        l.lastSeq + 1,
+		At:         time.Now().UTC(),
+		User:       requestInfo.User,
+		ClientIp:   requestInfo.ClientIp,
+		Path:       path,
+		Operation:  operation,
+		BeforeHash: beforeHash,
+		AfterHash:  afterHash,
+		Outcome:    outcome,
+		Status:     status,
+		PrevHash:   l.lastHash,
+	}
+
+	hash, err := computeHash(record)
+	if err != nil {
+		return model.AuditRecord{}, err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return model.AuditRecord{}, pkgErr.Wrap(err, "can't encode audit record")
+	}
+	fileInfo, err := l.file.Stat()
+	if err != nil {
+		return model.AuditRecord{}, pkgErr.Wrap(err, "can't stat the audit log")
+	}
+	line = append(line, '\n')
+	written, err := l.file.Write(line)
+	if err == nil && written < len(line) {
+		err = io.ErrShortWrite
+	}
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		// a record not entirely written isn't part of the chain
+		l.discard(fileInfo.Size())
+		return model.AuditRecord{}, pkgErr.Wrap(err, "can't write audit record")
+	}
+
+	l.lastSeq = record.Seq
+	l.lastHash = record.Hash
+	return record, nil
+}
+
+// Query - return the records matching the received filter, in chain order
+func (l *Log) Query(filter Filter) ([]model.AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := []model.AuditRecord{}
+	err := readRecords(l.path, func(record model.AuditRecord) error {
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Verify - read the whole log file identified by the received path checking the hash chain. return the number of verified records
+func Verify(path string) (int64, error) {
+	var verified int64
+	prevHash := genesisHash
+
+	err := readRecords(path, func(record model.AuditRecord) error {
+		if record.Seq != verified+1 {
+			return fmt.Errorf("record %d: expected sequence number %d", record.Seq, verified+1)
+		}
+		if record.PrevHash != prevHash {
+			return fmt.Errorf("record %d: chain broken, previous hash does not match", record.Seq)
+		}
+		hash, err := computeHash(record)
+		if err != nil {
+			return err
+		}
+		if record.Hash != hash {
+			return fmt.Errorf("record %d: content does not match its hash", record.Seq)
+		}
+
+		verified++
+		prevHash = record.Hash
+		return nil
+	})
+
+	return verified, err
+}
+
+// open - open the log file if needed, recovering the head of the chain from the existing records
+func (l *Log) open() error {
+	if l.file != nil {
+		return nil
+	}
+
+	l.lastSeq = 0
+	l.lastHash = genesisHash
+	err := readRecords(l.path, func(record model.AuditRecord) error {
+		l.lastSeq = record.Seq
+		l.lastHash = record.Hash
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return pkgErr.Wrap(err, "can't create the audit log directory")
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return pkgErr.Wrap(err, "can't open the audit log")
+	}
+
+	l.file = file
+	return nil
+}
+
+// discard - truncate the log file to the received size, dropping a partially written record. a file that can't be
+// truncated is closed, so that the next append reads it again and fails on the partial record instead of chaining to it
+func (l *Log) discard(size int64) {
+	if err := l.file.Truncate(size); err != nil {
+		log.Printf("Truncation of the audit log %s failed, the last record is partially written: %s", l.path, err.Error())
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// matches - return true if the received record satisfies the filter
+func (f Filter) matches(record model.AuditRecord) bool {
+	if "" != f.Path && record.Path != f.Path && !strings.HasPrefix(record.Path, strings.TrimSuffix(f.Path, "/")+"/") {
+		return false
+	}
+	if "" != f.User && record.User != f.User {
+		return false
+	}
+	if !f.From.IsZero() && record.At.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && record.At.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// readRecords - decode every record of the log file calling fn for each one. a missing file contains no records
+func readRecords(path string, fn func(model.AuditRecord) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return pkgErr.Wrap(err, "can't open the audit log")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record model.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return pkgErr.Wrap(err, fmt.Sprintf("line %d: malformed audit record", line))
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// computeHash - return the hex encoded sha256 of the received record, computed without its own hash
+func computeHash(record model.AuditRecord) (string, error) {
+	record.Hash = ""
+	content, err := json.Marshal(record)
+	if err != nil {
+		return "", pkgErr.Wrap(err, "can't encode audit record")
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
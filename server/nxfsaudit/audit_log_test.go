@@ -0,0 +1,168 @@
+package nxfsaudit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestLog - return a Log backed by a file in a new temporary directory, removed by the returned function
+func newTestLog(t *testing.T) (*Log, string, func()) {
+	dir, err := ioutil.TempDir("", "nxfs-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "data", "audit.log")
+	return NewLog(logPath), logPath, func() { os.RemoveAll(dir) }
+}
+
+// appendRecords - append a put of the received paths by alice
+func appendRecords(t *testing.T, auditLog *Log, paths ...string) {
+	t.Helper()
+	ctx := helper.ContextWithRequestInfo(context.Background(), helper.RequestInfo{User: "alice", ClientIp: "10.0.0.1"})
+	for _, path := range paths {
+		if _, err := auditLog.Append(ctx, OpPut, path, "", "hash-"+path, OutcomeSuccess, 201); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readLines - return the lines of the log file
+func readLines(t *testing.T, logPath string) []string {
+	t.Helper()
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// rewrite - replace the log file with the received lines, the records changed by change re-encoded
+func rewrite(t *testing.T, logPath string, lines []string, change func(i int, record *model.AuditRecord)) {
+	t.Helper()
+	var content []byte
+	for i, line := range lines {
+		if change != nil {
+			var record model.AuditRecord
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatal(err)
+			}
+			change(i, &record)
+			encoded, _ := json.Marshal(record)
+			line = string(encoded)
+		}
+		content = append(content, strings.TrimSuffix(line, "\n")+"\n"...)
+	}
+	if err := ioutil.WriteFile(logPath, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	auditLog, logPath, cleanup := newTestLog(t)
+	defer cleanup()
+
+	if verified, err := Verify(logPath); verified != 0 || err != nil {
+		t.Fatalf("expected the missing log to be empty, got %d %v", verified, err)
+	}
+	appendRecords(t, auditLog, "a.txt", "b.txt", "c.txt")
+	if verified, err := Verify(logPath); verified != 3 || err != nil {
+		t.Fatalf("expected three verified records, got %d %v", verified, err)
+	}
+	lines := readLines(t, logPath)
+
+	cases := []struct {
+		name     string
+		lines    []string
+		change   func(i int, record *model.AuditRecord)
+		verified int64
+		expected string
+	}{
+		{"tampered field", lines, func(i int, record *model.AuditRecord) {
+			if i == 1 {
+				record.User = "mallory"
+			}
+		}, 1, "record 2: content does not match its hash"},
+		{"reordered records", []string{lines[0], lines[2], lines[1]}, nil, 1, "record 3: expected sequence number 2"},
+		{"removed record", []string{lines[0], lines[2]}, nil, 1, "record 3: expected sequence number 2"},
+		// the record is rehashed, only the link to the previous one is broken
+		{"broken previous hash", lines, func(i int, record *model.AuditRecord) {
+			if i == 2 {
+				record.PrevHash = genesisHash
+				record.Hash, _ = computeHash(*record)
+			}
+		}, 2, "record 3: chain broken, previous hash does not match"},
+		{"malformed record", []string{lines[0], "{\"seq\":2,\"path\"\n"}, nil, 1, "line 2: malformed audit record"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rewrite(t, logPath, c.lines, c.change)
+			verified, err := Verify(logPath)
+			if err == nil || !strings.Contains(err.Error(), c.expected) || verified != c.verified {
+				t.Fatalf("expected %q after %d records, got %d %v", c.expected, c.verified, verified, err)
+			}
+		})
+	}
+}
+
+func TestReopenAndContinue(t *testing.T) {
+	auditLog, logPath, cleanup := newTestLog(t)
+	defer cleanup()
+
+	appendRecords(t, auditLog, "a.txt", "b.txt")
+	// a new Log on the same file continues the chain
+	reopened := NewLog(logPath)
+	appendRecords(t, reopened, "c.txt")
+	if verified, err := Verify(logPath); verified != 3 || err != nil {
+		t.Fatalf("expected the continued chain to be verified, got %d %v", verified, err)
+	}
+
+	records, err := reopened.Query(Filter{Path: "c.txt"})
+	if err != nil || len(records) != 1 || records[0].Seq != 3 || records[0].User != "alice" || records[0].ClientIp != "10.0.0.1" || records[0].AfterHash != "hash-c.txt" {
+		t.Fatalf("unexpected records %+v %v", records, err)
+	}
+	if records, _ = reopened.Query(Filter{User: "bob"}); len(records) != 0 {
+		t.Fatalf("expected no records of bob, got %+v", records)
+	}
+}
+
+// failingFile - a log file writing only the first size bytes of a record, then failing
+type failingFile struct {
+	logFile
+	size int
+}
+
+func (f failingFile) Write(content []byte) (int, error) {
+	written, _ := f.logFile.Write(content[:f.size])
+	return written, errors.New("no space left on device")
+}
+
+func TestFailedAppend(t *testing.T) {
+	auditLog, logPath, cleanup := newTestLog(t)
+	defer cleanup()
+
+	appendRecords(t, auditLog, "a.txt")
+	file := auditLog.file
+	auditLog.file = failingFile{logFile: file, size: 10}
+	if _, err := auditLog.Append(context.Background(), OpDelete, "b.txt", "", "", OutcomeSuccess, 204); err == nil {
+		t.Fatal("expected the failed write to be reported")
+	}
+	if lines := readLines(t, logPath); len(lines) != 1 {
+		t.Fatalf("expected the partial record to be dropped, got %q", lines)
+	}
+
+	// the chain continues from the last record entirely written
+	auditLog.file = file
+	appendRecords(t, auditLog, "c.txt")
+	if verified, err := Verify(logPath); verified != 2 || err != nil {
+		t.Fatalf("expected the chain to be intact, got %d %v", verified, err)
+	}
+}
@@ -0,0 +1,42 @@
+package nxfsauth
+
+import (
+	nxsiteman "github.com/entando/entando-nxfs/server"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// Handler - authenticate the bearer JWT of the requests, storing its claims in the request context where the request
+// info and the tenant selection read them. the tokens are verified by the received verifier, a nil one trusts them as
+// verified by a gateway in front of nxfs. a request whose token fails the verification is rejected with a 401
+func Handler(inner http.Handler, verifier *Verifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, bearerPrefix) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		token := strings.TrimPrefix(authorization, bearerPrefix)
+
+		if verifier == nil {
+			// a malformed token identifies no caller, as a missing one
+			if claims, err := DecodeClaims(token); err == nil {
+				r = r.WithContext(helper.ContextWithClaims(r.Context(), claims))
+			}
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			_ = nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrUnauthenticated, "invalid_token", "The bearer token is not valid: "+err.Error()), w, r)
+			return
+		}
+		inner.ServeHTTP(w, r.WithContext(helper.ContextWithClaims(r.Context(), claims)))
+	})
+}
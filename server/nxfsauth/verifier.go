@@ -0,0 +1,275 @@
+package nxfsauth
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leeway - the clock skew tolerated checking the expiration and the start of validity of a token
+const leeway = 30 * time.Second
+
+// jwksRefreshInterval - the minimum interval between two fetches of the JWKS, a token signed by an unknown key
+// triggers a fetch no more often than this
+const jwksRefreshInterval = time.Minute
+
+// Config - the keys verifying the signature of the tokens, at least one of Secret, PublicKeyFile and JWKSURL must be set
+type Config struct {
+	// Secret - the shared key of the HS256, HS384 and HS512 tokens
+	Secret string
+	// PublicKeyFile - a PEM file with the public keys, or certificates, of the RS, PS and ES tokens
+	PublicKeyFile string
+	// JWKSURL - the url of the JSON Web Key Set of the identity provider, e.g. the certs endpoint of a Keycloak realm
+	JWKSURL string
+	// Issuer - the iss claim the tokens must have, any issuer if empty
+	Issuer string
+}
+
+// Configured - return true if the configuration has a key to verify the tokens with
+func (c Config) Configured() bool {
+	return "" != c.Secret || "" != c.PublicKeyFile || "" != c.JWKSURL
+}
+
+// Verifier - verifies the signature and the validity of the bearer JWTs with go-jose, choosing the keys to try
+type Verifier struct {
+	secret  []byte
+	keys    []jose.JSONWebKey
+	jwksURL string
+	issuer  string
+	client  *http.Client
+	// refreshInterval - the minimum interval between two fetches of the JWKS
+	refreshInterval time.Duration
+
+	mu          sync.Mutex
+	jwksKeys    []jose.JSONWebKey
+	jwksFetched time.Time
+}
+
+// publicAlgorithms - the supported signature algorithms verified with the public keys
+var publicAlgorithms = map[jose.SignatureAlgorithm]bool{
+	jose.RS256: true, jose.RS384: true, jose.RS512: true,
+	jose.PS256: true, jose.PS384: true, jose.PS512: true,
+	jose.ES256: true, jose.ES384: true, jose.ES512: true,
+}
+
+// hmacAlgorithms - the supported signature algorithms verified with the shared secret
+var hmacAlgorithms = map[jose.SignatureAlgorithm]bool{
+	jose.HS256: true, jose.HS384: true, jose.HS512: true,
+}
+
+// NewVerifier - create a Verifier of the tokens signed by the configured keys, nil if none is configured. the JWKS is
+// fetched when the first token is verified
+func NewVerifier(config Config) (*Verifier, error) {
+	if !config.Configured() {
+		return nil, nil
+	}
+	v := &Verifier{secret: []byte(config.Secret), jwksURL: config.JWKSURL, issuer: config.Issuer, client: &http.Client{Timeout: 10 * time.Second}, refreshInterval: jwksRefreshInterval}
+	if "" != config.PublicKeyFile {
+		content, err := ioutil.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if v.keys, err = parsePEMKeys(content); err != nil {
+			return nil, fmt.Errorf("invalid public key file %s: %s", config.PublicKeyFile, err.Error())
+		}
+	}
+	return v, nil
+}
+
+// Verify - check the signature, the expiration and the issuer of the received token, returning its decoded claims
+func (v *Verifier) Verify(token string) ([]byte, error) {
+	signed, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("the token is not a JWT: %s", err.Error())
+	}
+	header := signed.Signatures[0].Header
+
+	payload, err := v.verifySignature(signed, jose.SignatureAlgorithm(header.Algorithm), header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims jwt.Claims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %s", err.Error())
+	}
+	if err = claims.ValidateWithLeeway(jwt.Expected{Issuer: v.issuer, Time: time.Now()}, leeway); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %s", err.Error())
+	}
+	return payload, nil
+}
+
+// verifySignature - check the signature of the received token with the configured keys, returning its payload. the
+// algorithm must match the kind of the key, so a public key can't be used as an HMAC secret
+func (v *Verifier) verifySignature(signed *jose.JSONWebSignature, alg jose.SignatureAlgorithm, kid string) ([]byte, error) {
+	if hmacAlgorithms[alg] {
+		if len(v.secret) == 0 {
+			return nil, fmt.Errorf("the %s tokens are not accepted", alg)
+		}
+		payload, err := signed.Verify(v.secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token signature")
+		}
+		return payload, nil
+	}
+	if !publicAlgorithms[alg] {
+		return nil, fmt.Errorf("the %q algorithm is not supported", alg)
+	}
+
+	keys := v.candidateKeys(kid, false)
+	for _, key := range keys {
+		if payload, err := signed.Verify(key.Key); err == nil {
+			return payload, nil
+		}
+	}
+	if "" != v.jwksURL && !hasKid(keys, kid) {
+		// the identity provider may have rotated its keys
+		for _, key := range v.candidateKeys(kid, true) {
+			if payload, err := signed.Verify(key.Key); err == nil {
+				return payload, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid token signature")
+}
+
+// candidateKeys - return the keys that may have signed a token with the received kid: the one with the kid if known,
+// every key otherwise. the JWKS is fetched if it never was, or if refresh is true and it wasn't fetched recently
+func (v *Verifier) candidateKeys(kid string, refresh bool) []jose.JSONWebKey {
+	keys := v.keys
+	if "" != v.jwksURL {
+		keys = append(append([]jose.JSONWebKey(nil), keys...), v.jwks(refresh)...)
+	}
+	if "" != kid && hasKid(keys, kid) {
+		var matching []jose.JSONWebKey
+		for _, key := range keys {
+			if key.KeyID == kid {
+				matching = append(matching, key)
+			}
+		}
+		return matching
+	}
+	return keys
+}
+
+// jwks - return the keys of the JWKS, fetching it as requested by candidateKeys. a failed fetch is logged and keeps the
+// current keys
+func (v *Verifier) jwks(refresh bool) []jose.JSONWebKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.jwksFetched.IsZero() || refresh && time.Since(v.jwksFetched) >= v.refreshInterval {
+		v.jwksFetched = time.Now()
+		keys, err := v.fetchJWKS()
+		if err != nil {
+			log.Printf("Fetch of the JWKS %s failed: %s", v.jwksURL, err.Error())
+		} else {
+			v.jwksKeys = keys
+		}
+	}
+	return v.jwksKeys
+}
+
+// fetchJWKS - download and parse the JWKS
+func (v *Verifier) fetchJWKS() ([]jose.JSONWebKey, error) {
+	response, err := v.client.Get(v.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(content)
+}
+
+// parseJWKS - return the public signature keys of a JSON Web Key Set
+func parseJWKS(content []byte) ([]jose.JSONWebKey, error) {
+	// the keys are parsed one by one, so that a key of an unknown type doesn't discard the others
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []jose.JSONWebKey
+	for _, raw := range set.Keys {
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(raw); err != nil {
+			continue
+		}
+		if ("" == key.Use || "sig" == key.Use) && key.IsPublic() {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// parsePEMKeys - return the public keys of the PUBLIC KEY, RSA PUBLIC KEY and CERTIFICATE blocks of a PEM file
+func parsePEMKeys(content []byte) ([]jose.JSONWebKey, error) {
+	var keys []jose.JSONWebKey
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		var key crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var certificate *x509.Certificate
+			if certificate, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = certificate.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jose.JSONWebKey{Key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	return keys, nil
+}
+
+// DecodeClaims - return the decoded claims of a JWT without verifying it, for the tokens verified by a trusted gateway.
+// the token is only split, its signature is never looked at
+func DecodeClaims(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("the token is not a JWT")
+	}
+	return base64.RawURLEncoding.DecodeString(parts[1])
+}
+
+func hasKid(keys []jose.JSONWebKey, kid string) bool {
+	for _, key := range keys {
+		if "" != kid && key.KeyID == kid {
+			return true
+		}
+	}
+	return false
+}
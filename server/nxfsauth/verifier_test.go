@@ -0,0 +1,192 @@
+package nxfsauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encodeSegment(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign - return a JWT of the received header and claims, signed by the received function
+func sign(header map[string]string, claims map[string]interface{}, signature func(signed []byte) []byte) string {
+	signed := encodeSegment(header) + "." + encodeSegment(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func rs256(key *rsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		return signature
+	}
+}
+
+func es256(key *ecdsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+		return append(fixedSize(r.Bytes(), 32), fixedSize(s.Bytes(), 32)...)
+	}
+}
+
+// fixedSize - left pad a big endian integer to the received size
+func fixedSize(value []byte, size int) []byte {
+	return append(make([]byte, size-len(value)), value...)
+}
+
+func TestVerifierSecret(t *testing.T) {
+	verifier, err := NewVerifier(Config{Secret: "secret", Issuer: "https://sso/realms/entando"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"sub": "alice", "iss": "https://sso/realms/entando", "exp": now + 60}
+	hs := map[string]string{"alg": "HS256"}
+
+	claims, err := verifier.Verify(sign(hs, valid, hs256("secret")))
+	if err != nil || !strings.Contains(string(claims), `"sub":"alice"`) {
+		t.Fatalf("expected the claims of the valid token, got %s %v", claims, err)
+	}
+
+	for name, token := range map[string]string{
+		"other secret":  sign(hs, valid, hs256("other")),
+		"unsigned":      sign(map[string]string{"alg": "none"}, valid, func([]byte) []byte { return nil }),
+		"unknown alg":   sign(map[string]string{"alg": "HS1"}, valid, hs256("secret")),
+		"no public key": sign(map[string]string{"alg": "RS256"}, valid, hs256("secret")),
+		"expired":       sign(hs, map[string]interface{}{"iss": "https://sso/realms/entando", "exp": now - 120}, hs256("secret")),
+		"not yet valid": sign(hs, map[string]interface{}{"iss": "https://sso/realms/entando", "nbf": now + 120}, hs256("secret")),
+		"other issuer":  sign(hs, map[string]interface{}{"iss": "https://evil"}, hs256("secret")),
+		"not a jwt":     "abc",
+		// the claims are changed after the signature
+		"tampered": strings.Replace(sign(hs, valid, hs256("secret")), encodeSegment(valid), encodeSegment(map[string]interface{}{"sub": "bob"}), 1),
+	} {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+
+	if verifier, err = NewVerifier(Config{}); verifier != nil || err != nil {
+		t.Fatalf("expected no verifier without keys, got %v %v", verifier, err)
+	}
+}
+
+func TestVerifierPublicKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	keyFile := filepath.Join(dir, "key.pem")
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(Config{PublicKeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{"sub": "alice"}
+	if _, err = verifier.Verify(sign(map[string]string{"alg": "RS256"}, claims, rs256(key))); err != nil {
+		t.Fatalf("expected the RS256 token to be verified, got %v", err)
+	}
+	// the public key, known to everyone, can't be used as an HMAC secret
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if _, err = verifier.Verify(sign(map[string]string{"alg": "HS256"}, claims, hs256(pemKey))); err == nil {
+		t.Fatal("expected the HS256 token to be rejected")
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err = verifier.Verify(sign(map[string]string{"alg": "RS256"}, claims, rs256(other))); err == nil {
+		t.Fatal("expected the token signed by another key to be rejected")
+	}
+
+	if err = ioutil.WriteFile(keyFile, []byte("no key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewVerifier(Config{PublicKeyFile: keyFile}); err == nil {
+		t.Fatal("expected the file without keys to be rejected")
+	}
+}
+
+func TestVerifierJWKS(t *testing.T) {
+	first, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	second, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwk := func(kid string, key *ecdsa.PrivateKey) string {
+		return fmt.Sprintf(`{"kty":"EC","kid":%q,"use":"sig","crv":"P-256","x":%q,"y":%q}`, kid,
+			base64.RawURLEncoding.EncodeToString(fixedSize(key.X.Bytes(), 32)), base64.RawURLEncoding.EncodeToString(fixedSize(key.Y.Bytes(), 32)))
+	}
+
+	var fetches int32
+	var keys atomic.Value
+	keys.Store(jwk("first", first))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		fmt.Fprintf(w, `{"keys":[%s,{"kty":"oct","kid":"ignored"}]}`, keys.Load())
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(Config{JWKSURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{"sub": "alice"}
+	for i := 0; i < 2; i++ {
+		if _, err = verifier.Verify(sign(map[string]string{"alg": "ES256", "kid": "first"}, claims, es256(first))); err != nil {
+			t.Fatalf("expected the ES256 token to be verified, got %v", err)
+		}
+	}
+	if atomic.LoadInt32(&fetches) != 1 {
+		t.Fatalf("expected the JWKS to be fetched once, got %d", fetches)
+	}
+
+	// a rotated key is fetched when a token signed by it is received
+	verifier.refreshInterval = 0
+	keys.Store(jwk("first", first) + "," + jwk("second", second))
+	if _, err = verifier.Verify(sign(map[string]string{"alg": "ES256", "kid": "second"}, claims, es256(second))); err != nil {
+		t.Fatalf("expected the token signed by the rotated key to be verified, got %v", err)
+	}
+	// but no more than once per refresh interval
+	verifier.refreshInterval = time.Minute
+	third, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err = verifier.Verify(sign(map[string]string{"alg": "ES256", "kid": "third"}, claims, es256(third))); err == nil || atomic.LoadInt32(&fetches) != 2 {
+		t.Fatalf("expected the unknown key to be rejected without fetching the JWKS again, got %v after %d fetches", err, fetches)
+	}
+	if _, err = verifier.Verify(sign(map[string]string{"alg": "ES256", "kid": "first"}, claims, es256(second))); err == nil {
+		t.Fatal("expected the token signed by a key other than its kid to be rejected")
+	}
+}
@@ -0,0 +1,186 @@
+package nxfsblob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// blobPerm - the blobs are shared by all the files linking them, so they are never written in place
+const blobPerm = 0444
+
+// DefaultGCMinAge - blobs younger than this are never collected, so that a blob just stored is not removed before being linked
+const DefaultGCMinAge = 10 * time.Minute
+
+// Store - a content addressed store keeping every distinct content once, in a file named by its sha256. the files sharing
+// a content are hard links to its blob, so the link count of a blob is its reference count. the linked files are
+// copy-on-write: they're only ever replaced by renaming a staged file over them, which drops their link to the blob
+type Store struct {
+	dir          string
+	fallbackOnce sync.Once
+}
+
+// GCResult - the outcome of a garbage collection
+type GCResult struct {
+	Blobs   int
+	Removed int
+	Freed   int64
+}
+
+// NewStore - create a Store keeping the blobs in the received directory
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Put - store the content read from the received reader, returning its hash. a content already stored is not written again
+func (s *Store) Put(content io.Reader) (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	stagedPath, err := nxfsfiles.StageFile(filepath.Join(s.dir, "blob"), io.TeeReader(content, hash), blobPerm)
+	if err != nil {
+		return "", err
+	}
+	defer nxfsfiles.DiscardStagedFile(stagedPath)
+
+	contentHash := hex.EncodeToString(hash.Sum(nil))
+	blobPath := s.Path(contentHash)
+	if err = os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		return "", err
+	}
+
+	// linking, unlike renaming, never replaces a blob already stored, and the files linking it
+	if err = os.Link(stagedPath, blobPath); os.IsExist(err) {
+		// refresh the blob age so that it survives the garbage collections running before it gets linked again
+		now := time.Now()
+		err = os.Chtimes(blobPath, now, now)
+	}
+	if err != nil {
+		return "", err
+	}
+	return contentHash, nil
+}
+
+// PutFile - store the content of the received file, returning its hash
+func (s *Store) PutFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return s.Put(file)
+}
+
+// Stage - link the received blob to a temporary file next to filePath, returning its path. the staged file replaces filePath
+// when committed with nxfsfiles.CommitStagedFile. where hard links are not available the blob content is copied instead
+func (s *Store) Stage(hash string, filePath string) (string, error) {
+	stagedPath := nxfsfiles.TempPathFor(filePath)
+	err := os.Link(s.Path(hash), stagedPath)
+	if err == nil {
+		return stagedPath, nil
+	}
+	if os.IsNotExist(err) {
+		return "", err
+	}
+
+	// the browsable fs and the data dir are probably on different devices
+	s.fallbackOnce.Do(func() {
+		log.Printf("Blobs can't be linked to %s, their content will be copied: %s", filepath.Dir(filePath), err.Error())
+	})
+	blob, err := os.Open(s.Path(hash))
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	return nxfsfiles.StageFile(filePath, blob, 0644)
+}
+
+// Copy - make dstPath share the content of srcPath through the store, returning the hash of the content
+func (s *Store) Copy(srcPath string, dstPath string) (string, error) {
+	hash, err := s.PutFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+	return hash, s.Write(hash, dstPath)
+}
+
+// Write - atomically replace filePath with a link to the received blob
+func (s *Store) Write(hash string, filePath string) error {
+	stagedPath, err := s.Stage(hash, filePath)
+	if err != nil {
+		return err
+	}
+	if err = nxfsfiles.CommitStagedFile(stagedPath, filePath); err != nil {
+		nxfsfiles.DiscardStagedFile(stagedPath)
+		return err
+	}
+	return nil
+}
+
+// RefCount - return the number of files sharing the received blob, false if the platform doesn't expose the link counts
+func (s *Store) RefCount(hash string) (int, bool, error) {
+	fileInfo, err := os.Stat(s.Path(hash))
+	if err != nil {
+		return 0, false, err
+	}
+	links, ok := linkCount(fileInfo)
+	if !ok {
+		return 0, false, nil
+	}
+	return int(links) - 1, true, nil
+}
+
+// GC - remove the blobs no file links anymore, sparing the ones younger than minAge
+func (s *Store) GC(minAge time.Duration) (GCResult, error) {
+	var result GCResult
+	threshold := time.Now().Add(-minAge)
+
+	err := filepath.Walk(s.dir, func(blobPath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fileInfo.IsDir() || !isHash(fileInfo.Name()) {
+			return nil
+		}
+
+		result.Blobs++
+		links, ok := linkCount(fileInfo)
+		if !ok || links > 1 || fileInfo.ModTime().After(threshold) {
+			return nil
+		}
+		if err = os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		result.Removed++
+		result.Freed += fileInfo.Size()
+		return nil
+	})
+	return result, err
+}
+
+// Path - return the path of the blob identified by the received hash
+func (s *Store) Path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// isHash - return true if the received file name is the hex encoded sha256 of a blob
+func isHash(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
@@ -0,0 +1,135 @@
+package nxfsblob
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+	dir, err := ioutil.TempDir("", "nxfs-blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(filepath.Join(dir, "blobs")), dir
+}
+
+func writeTestFile(t *testing.T, filePath string, content string) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertRefCount(t *testing.T, store *Store, hash string, expected int) {
+	refCount, ok, err := store.RefCount(hash)
+	if err != nil || !ok {
+		t.Fatalf("can't read the reference count: %v %v", ok, err)
+	}
+	if refCount != expected {
+		t.Fatalf("expected %d references, got %d", expected, refCount)
+	}
+}
+
+func TestPutStoresIdenticalContentOnce(t *testing.T) {
+	store, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	first, err := store.Put(strings.NewReader("same content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Put(strings.NewReader("same content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("identical contents have different hashes %s and %s", first, second)
+	}
+
+	result, err := store.GC(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Blobs != 1 || result.Removed != 0 {
+		t.Fatalf("expected a single young blob, got %+v", result)
+	}
+	assertRefCount(t, store, first, 0)
+}
+
+func TestCopyLinksTheFilesToTheSameBlob(t *testing.T) {
+	store, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "fs", "draft_pages", "home.page")
+	writeTestFile(t, source, "page content")
+
+	destinations := []string{filepath.Join(dir, "fs", "pages", "home.page"), filepath.Join(dir, "releases", "before", "home.page")}
+	var hash string
+	for _, destination := range destinations {
+		if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if hash, err = store.Copy(source, destination); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertRefCount(t, store, hash, 2)
+	blobInfo, _ := os.Stat(store.Path(hash))
+	for _, destination := range destinations {
+		fileInfo, err := os.Stat(destination)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(blobInfo, fileInfo) {
+			t.Fatalf("%s is not a link to the blob", destination)
+		}
+	}
+
+	// replacing a linked file doesn't change the blob
+	writeTestFile(t, source, "new content")
+	if _, err := store.Copy(source, destinations[0]); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(destinations[1]); string(content) != "page content" {
+		t.Fatalf("the other link changed to %q", content)
+	}
+	assertRefCount(t, store, hash, 1)
+}
+
+func TestGCRemovesOnlyTheUnreferencedBlobs(t *testing.T) {
+	store, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source.txt")
+	writeTestFile(t, source, "referenced")
+	referenced, err := store.Copy(source, filepath.Join(dir, "published.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreferenced, err := store.Put(strings.NewReader("unreferenced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := store.GC(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Blobs != 2 || result.Removed != 1 || result.Freed != int64(len("unreferenced")) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if _, err = os.Stat(store.Path(unreferenced)); !os.IsNotExist(err) {
+		t.Fatalf("the unreferenced blob has not been removed: %v", err)
+	}
+	if _, err = os.Stat(store.Path(referenced)); err != nil {
+		t.Fatalf("the referenced blob has been removed: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package nxfsblob
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount - return the number of hard links to the received file
+func linkCount(fileInfo os.FileInfo) (uint64, bool) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package nxfsblob
+
+import "os"
+
+// linkCount - the link counts are not exposed by os.FileInfo on windows, so the blobs are never collected there
+func linkCount(fileInfo os.FileInfo) (uint64, bool) {
+	return 0, false
+}
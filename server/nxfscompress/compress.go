@@ -0,0 +1,276 @@
+package nxfscompress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DisabledLevel - the compression level turning the compression off
+const DisabledLevel = 0
+
+// encoder - a content coding the responses can be compressed with
+type encoder struct {
+	coding    string
+	newWriter func(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// encoders - the supported content codings, in order of preference when the client accepts several of them equally. the
+// gzip level is used as the brotli quality, both going from the fastest to the smallest
+var encoders = []encoder{
+	{coding: "br", newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+		if level == gzip.DefaultCompression {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	}},
+	{coding: "gzip", newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	}},
+}
+
+// compressibleTypes - the types of the contents worth compressing, besides the text ones and the JSON and XML based ones
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+}
+
+// Handler - compress the responses of the inner handler with the content coding negotiated from the Accept-Encoding
+// of the request, at the received level. the bodies shorter than minSize, the ones whose type isn't worth compressing,
+// and the responses to range requests are sent as they are. compression is off at DisabledLevel
+func Handler(inner http.Handler, level int, minSize int64) http.Handler {
+	if level == DisabledLevel {
+		return inner
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{ResponseWriter: w, level: level, minSize: minSize}
+		if r.Method != http.MethodHead && "" == r.Header.Get("Range") {
+			cw.encoder = negotiate(r.Header.Get("Accept-Encoding"))
+		}
+		defer cw.Close()
+		inner.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate - return the encoder of the supported content coding most preferred by the received Accept-Encoding, nil if
+// the client accepts none of them
+func negotiate(acceptEncoding string) *encoder {
+	qualities := map[string]float64{}
+	for _, accepted := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(accepted, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if "" == coding {
+			continue
+		}
+		quality := 1.0
+		for _, param := range params[1:] {
+			if name, value := splitParam(param); "q" == name {
+				var err error
+				if quality, err = strconv.ParseFloat(value, 64); err != nil {
+					quality = 0
+				}
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	var negotiated *encoder
+	best := 0.0
+	for i := range encoders {
+		quality, ok := qualities[encoders[i].coding]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > best {
+			negotiated, best = &encoders[i], quality
+		}
+	}
+	return negotiated
+}
+
+// splitParam - split a name=value parameter of a header
+func splitParam(param string) (string, string) {
+	parts := strings.SplitN(param, "=", 2)
+	if len(parts) < 2 {
+		return strings.ToLower(strings.TrimSpace(parts[0])), ""
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+}
+
+// Compressible - return true if contents of the received type are worth compressing
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || compressibleTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// compressWriter - a response writer that holds back the beginning of a body of unknown length until it knows whether
+// it's long enough to be worth compressing, then streams it compressed or as is
+type compressWriter struct {
+	http.ResponseWriter
+	// encoder - the negotiated content coding, nil if the client accepts none
+	encoder *encoder
+	level   int
+	minSize int64
+	status  int
+	// decided - the body is being streamed, through writer when it's compressed
+	decided  bool
+	buffered []byte
+	writer   io.WriteCloser
+}
+
+// WriteHeader - record the status, deciding at once whether the body can be compressed when it's known from the headers
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+
+	header := cw.Header()
+	if Compressible(header.Get("Content-Type")) {
+		addVary(header)
+	}
+	contentLength, lengthErr := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	switch {
+	case cw.encoder == nil || status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified:
+		if status == http.StatusNotModified && cw.encoder != nil {
+			// the representation validated by the client is the compressed one
+			weakenETag(header)
+		}
+		cw.decide(false)
+	case "" != header.Get("Content-Encoding") || "" != header.Get("Content-Type") && !Compressible(header.Get("Content-Type")):
+		cw.decide(false)
+	case lengthErr == nil:
+		cw.decide(contentLength >= cw.minSize)
+	}
+}
+
+// Write - stream the body, holding it back while it's too short to know if it's worth compressing
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.writer != nil {
+			return cw.writer.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buffered = append(cw.buffered, p...)
+	if int64(len(cw.buffered)) >= cw.minSize {
+		if err := cw.decideBuffered(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush - send what's been written so far, a body flushed before it's known to be short is compressed
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		_ = cw.decideBuffered(true)
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack - let the inner handler take over the connection
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// Close - complete the response, sending as is a body that turned out to be too short
+func (cw *compressWriter) Close() error {
+	if cw.status == 0 {
+		return nil
+	}
+	if !cw.decided {
+		if err := cw.decideBuffered(false); err != nil {
+			return err
+		}
+	}
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+// decideBuffered - decide whether the body held back is compressed, then send it
+func (cw *compressWriter) decideBuffered(compress bool) error {
+	header := cw.Header()
+	if "" == header.Get("Content-Type") {
+		header.Set("Content-Type", http.DetectContentType(cw.buffered))
+		if Compressible(header.Get("Content-Type")) {
+			addVary(header)
+		} else {
+			compress = false
+		}
+	}
+	cw.decide(compress)
+
+	buffered := cw.buffered
+	cw.buffered = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	_, err := cw.Write(buffered)
+	return err
+}
+
+// decide - send the headers, starting the compression of the body if requested
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	if compress {
+		writer, err := cw.encoder.newWriter(cw.ResponseWriter, cw.level)
+		if err == nil {
+			header := cw.Header()
+			header.Set("Content-Encoding", cw.encoder.coding)
+			header.Del("Content-Length")
+			weakenETag(header)
+			cw.writer = writer
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// addVary - declare that the response depends on the Accept-Encoding of the request
+func addVary(header http.Header) {
+	for _, vary := range header["Vary"] {
+		for _, name := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	header.Add("Vary", "Accept-Encoding")
+}
+
+// weakenETag - turn a strong entity tag into a weak one, the compressed content isn't byte for byte the identified one
+func weakenETag(header http.Header) {
+	if etag := header.Get("ETag"); "" != etag && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
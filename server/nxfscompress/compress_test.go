@@ -0,0 +1,209 @@
+package nxfscompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		coding         string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, GZIP;q=0.5", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.3, gzip;q=0", "br"},
+		{"*;q=0.3, br;q=0, gzip;q=0", ""},
+		{"br", "br"},
+		{"br, gzip;q=0.8", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		// brotli is preferred when both are accepted equally
+		{"gzip, br", "br"},
+		{"identity", ""},
+	}
+	for _, test := range tests {
+		coding := ""
+		if negotiated := negotiate(test.acceptEncoding); negotiated != nil {
+			coding = negotiated.coding
+		}
+		if coding != test.coding {
+			t.Errorf("%q: expected %q, got %q", test.acceptEncoding, test.coding, coding)
+		}
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"application/json; charset=UTF-8": true,
+		"application/problem+json":        true,
+		"text/html":                       true,
+		"image/svg+xml":                   true,
+		"image/png":                       false,
+		"application/zip":                 false,
+		"":                                false,
+	} {
+		if Compressible(contentType) != expected {
+			t.Errorf("%q: expected %v", contentType, expected)
+		}
+	}
+}
+
+// serve - send a request with the received headers to a handler writing the received body, returning the response
+func serve(t *testing.T, inner http.HandlerFunc, requestHeaders map[string]string) *http.Response {
+	t.Helper()
+	request := httptest.NewRequest("GET", "/", nil)
+	for name, value := range requestHeaders {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	Handler(inner, gzip.DefaultCompression, 100).ServeHTTP(recorder, request)
+	return recorder.Result()
+}
+
+func writeJSON(body string, headers map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		// written in small pieces, like an encoder streaming it
+		for i := 0; i < len(body); i += 10 {
+			end := i + 10
+			if end > len(body) {
+				end = len(body)
+			}
+			_, _ = w.Write([]byte(body[i:end]))
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	long := `{"list":[` + strings.Repeat(`{"name":"a.txt"},`, 50) + `{}]}`
+	acceptGzip := map[string]string{"Accept-Encoding": "gzip"}
+
+	response := serve(t, writeJSON(long, map[string]string{"ETag": `"abc"`}), acceptGzip)
+	if response.Header.Get("Content-Encoding") != "gzip" || response.Header.Get("Vary") != "Accept-Encoding" || response.Header.Get("ETag") != `W/"abc"` {
+		t.Fatalf("expected a compressed response, got %v", response.Header)
+	}
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decompressed, err := ioutil.ReadAll(reader); err != nil || string(decompressed) != long {
+		t.Fatalf("unexpected decompressed body %q %v", decompressed, err)
+	}
+
+	response = serve(t, writeJSON(long, nil), map[string]string{"Accept-Encoding": "gzip, br"})
+	if response.Header.Get("Content-Encoding") != "br" {
+		t.Fatalf("expected a brotli compressed response, got %v", response.Header)
+	}
+	if decompressed, err := ioutil.ReadAll(brotli.NewReader(response.Body)); err != nil || string(decompressed) != long {
+		t.Fatalf("unexpected decompressed body %q %v", decompressed, err)
+	}
+
+	// the responses sent as they are
+	short := `{"list":[]}`
+	binary := string(bytes.Repeat([]byte{0}, 200))
+	for name, test := range map[string]struct {
+		inner   http.HandlerFunc
+		headers map[string]string
+		body    string
+		vary    bool
+	}{
+		"short":               {writeJSON(short, nil), acceptGzip, short, true},
+		"not accepted":        {writeJSON(long, nil), nil, long, true},
+		"only identity":       {writeJSON(long, nil), map[string]string{"Accept-Encoding": "identity"}, long, true},
+		"range":               {writeJSON(long, nil), map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"}, long, true},
+		"declared short":      {writeJSON(short, map[string]string{"Content-Length": "11"}), acceptGzip, short, true},
+		"not compressible":    {writeJSON(long, map[string]string{"Content-Type": "image/png"}), acceptGzip, long, false},
+		"already compressed":  {writeJSON(long, map[string]string{"Content-Encoding": "gzip"}), acceptGzip, long, true},
+		"sniffed as a binary": {func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(binary)) }, acceptGzip, binary, false},
+	} {
+		response := serve(t, test.inner, test.headers)
+		if body, _ := ioutil.ReadAll(response.Body); string(body) != test.body {
+			t.Errorf("%s: expected the body as is, got %v %q", name, response.Header, body)
+		}
+		if ("" != response.Header.Get("Vary")) != test.vary {
+			t.Errorf("%s: unexpected Vary %q", name, response.Header.Get("Vary"))
+		}
+	}
+
+	// a declared length is enough to decide, the body isn't held back
+	response = serve(t, writeJSON(long, map[string]string{"Content-Length": strconv.Itoa(len(long))}), acceptGzip)
+	if response.Header.Get("Content-Encoding") != "gzip" || "" != response.Header.Get("Content-Length") {
+		t.Fatalf("expected a compressed response without length, got %v", response.Header)
+	}
+
+	response = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusNotModified)
+	}, acceptGzip)
+	if response.StatusCode != http.StatusNotModified || response.Header.Get("ETag") != `W/"abc"` {
+		t.Fatalf("expected the etag of the compressed content, got %d %v", response.StatusCode, response.Header)
+	}
+}
+
+func TestHandlerStreaming(t *testing.T) {
+	for coding, newReader := range map[string]func(r io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	} {
+		t.Run(coding, func(t *testing.T) {
+			flushed := make(chan bool)
+			server := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = w.Write([]byte("first"))
+				w.(http.Flusher).Flush()
+				<-flushed
+				_, _ = w.Write([]byte(" second"))
+			}), gzip.DefaultCompression, 1024))
+			defer server.Close()
+
+			request, _ := http.NewRequest("GET", server.URL, nil)
+			request.Header.Set("Accept-Encoding", coding)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			if response.Header.Get("Content-Encoding") != coding {
+				t.Fatalf("expected a %s response, got %v", coding, response.Header)
+			}
+
+			// the flushed part of a short body is received compressed before the handler completes
+			reader, err := newReader(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := make([]byte, len("first"))
+			done := make(chan error)
+			go func() {
+				_, err := io.ReadFull(reader, first)
+				done <- err
+			}()
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				close(flushed)
+				t.Fatal("expected the flushed part to be received")
+			}
+			close(flushed)
+			rest, _ := ioutil.ReadAll(reader)
+			if err != nil || "first" != string(first) || " second" != string(rest) {
+				t.Fatalf("unexpected body %q %q %v", first, rest, err)
+			}
+		})
+	}
+}
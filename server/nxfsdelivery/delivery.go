@@ -0,0 +1,224 @@
+package nxfsdelivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexPage - the page served for the requests of a directory
+const indexPage = "index.page"
+
+// PageView - the data a layout template is executed with: the page document and its path relative to the pages folder
+type PageView struct {
+	model.PageDocument
+	Path string
+}
+
+// Handler - serves read only the published pages folder, rendering the page documents through their layout templates
+type Handler struct {
+	prefix    string
+	root      string
+	maxAge    time.Duration
+	mu        sync.Mutex
+	templates map[string]cachedTemplate
+}
+
+// cachedTemplate - a parsed layout template with the stat of the file it has been parsed from
+type cachedTemplate struct {
+	modTime  time.Time
+	size     int64
+	template *template.Template
+}
+
+// NewHandler - return a Handler serving under the received url prefix the published pages of the browsable fs whose root is
+// the received directory, letting the clients cache them for maxAge
+func NewHandler(prefix string, root string, maxAge time.Duration) *Handler {
+	return &Handler{prefix: strings.TrimRight(prefix, "/"), root: root, maxAge: maxAge, templates: map[string]cachedTemplate{}}
+}
+
+// ServeHTTP - serve the published page or file identified by the request path: /about is the file pages/about if it exists,
+// otherwise the page pages/about.page, otherwise the page pages/about/index.page. pages are rendered, the other files served as is
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	relPath, ok := h.resolve(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	fullPath := filepath.Join(h.root, helper.GetPublishedPagesRelativePath(), filepath.FromSlash(relPath))
+	if nxfspages.IsPage(relPath) {
+		h.servePage(w, r, relPath, fullPath)
+	} else {
+		h.serveFile(w, r, fullPath)
+	}
+}
+
+// resolve - return the path, relative to the published pages folder, of the page or file identified by the request path
+func (h *Handler) resolve(requestPath string) (string, bool) {
+	rest := strings.TrimPrefix(requestPath, h.prefix)
+	if "" != rest && !strings.HasPrefix(rest, "/") {
+		// the prefix matched only a part of the first path segment
+		return "", false
+	}
+
+	relPath := path.Clean("/" + rest)[1:]
+	for _, segment := range strings.Split(relPath, "/") {
+		if strings.HasPrefix(segment, ".") {
+			// hidden files, like the temporary ones of the atomic writes, are never served
+			return "", false
+		}
+	}
+
+	candidates := []string{relPath}
+	if "" != relPath && !nxfspages.IsPage(relPath) {
+		candidates = append(candidates, nxfspages.AddPageSuffix(relPath))
+	}
+	candidates = append(candidates, path.Join(relPath, indexPage))
+
+	for _, candidate := range candidates {
+		fileInfo, err := os.Stat(filepath.Join(h.root, helper.GetPublishedPagesRelativePath(), filepath.FromSlash(candidate)))
+		if err == nil && !fileInfo.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// servePage - render the page document through its layout template and serve the result
+func (h *Handler) servePage(w http.ResponseWriter, r *http.Request, relPath string, fullPath string) {
+	pageInfo, err := os.Stat(fullPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	content, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		h.serveError(w, fmt.Sprintf("can't read the page %s: %s", relPath, err.Error()))
+		return
+	}
+
+	var page model.PageDocument
+	if err = json.Unmarshal(content, &page); err != nil {
+		h.serveError(w, fmt.Sprintf("the page %s is not a valid page document: %s", relPath, err.Error()))
+		return
+	}
+
+	layout, layoutModTime, err := h.layout(page.Template)
+	if err != nil {
+		h.serveError(w, fmt.Sprintf("can't load the layout of the page %s: %s", relPath, err.Error()))
+		return
+	}
+
+	var rendered bytes.Buffer
+	if err = layout.Execute(&rendered, PageView{PageDocument: page, Path: relPath}); err != nil {
+		h.serveError(w, fmt.Sprintf("can't render the page %s: %s", relPath, err.Error()))
+		return
+	}
+
+	modTime := pageInfo.ModTime()
+	if layoutModTime.After(modTime) {
+		modTime = layoutModTime
+	}
+	h.setCacheHeaders(w, nxfsfiles.HashContent(rendered.Bytes()))
+	http.ServeContent(w, r, "index.html", modTime, bytes.NewReader(rendered.Bytes()))
+}
+
+// serveFile - serve a file of the published pages folder as is
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, fullPath string) {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.serveError(w, fmt.Sprintf("can't stat %s: %s", fullPath, err.Error()))
+		return
+	}
+
+	// the files are uploaded by the users, they're served without letting them run scripts on the site origin
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	h.setCacheHeaders(w, nxfsfiles.HashFile(fullPath))
+	http.ServeContent(w, r, fileInfo.Name(), fileInfo.ModTime(), file)
+}
+
+// layout - return the parsed layout template identified by the received path relative to the browsable fs root, with its modification time.
+// only the templates of the layouts folder are loaded, so that a page can't expose the drafts or other unpublished files.
+// the parsed templates are cached until their file changes
+func (h *Handler) layout(templatePath string) (*template.Template, time.Time, error) {
+	if "" == templatePath {
+		return nil, time.Time{}, fmt.Errorf("the page has no template")
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(templatePath), "/") {
+		if ".." == segment {
+			return nil, time.Time{}, fmt.Errorf("the template %s climbs out of its folder", templatePath)
+		}
+	}
+	templatePath = path.Clean("/" + templatePath)[1:]
+	if !strings.HasPrefix(templatePath, helper.GetLayoutsRelativePath()+"/") {
+		return nil, time.Time{}, fmt.Errorf("the template %s is not in the %s folder", templatePath, helper.GetLayoutsRelativePath())
+	}
+	fullPath := filepath.Join(h.root, filepath.FromSlash(templatePath))
+
+	fileInfo, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cached, ok := h.templates[templatePath]; ok && cached.modTime.Equal(fileInfo.ModTime()) && cached.size == fileInfo.Size() {
+		return cached.template, cached.modTime, nil
+	}
+
+	content, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	parsed, err := template.New(templatePath).Parse(string(content))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	h.templates[templatePath] = cachedTemplate{modTime: fileInfo.ModTime(), size: fileInfo.Size(), template: parsed}
+	return parsed, fileInfo.ModTime(), nil
+}
+
+// setCacheHeaders - set the headers letting the clients cache the response and revalidate it by its hash
+func (h *Handler) setCacheHeaders(w http.ResponseWriter, hash string) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(h.maxAge/time.Second)))
+	if "" != hash {
+		w.Header().Set("ETag", `"`+hash+`"`)
+	}
+}
+
+// serveError - log the cause of a delivery failure and answer with a generic error, not to expose the internals to the visitors
+func (h *Handler) serveError(w http.ResponseWriter, cause string) {
+	log.Printf("Delivery failed: %s", cause)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
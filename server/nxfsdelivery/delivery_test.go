@@ -0,0 +1,156 @@
+package nxfsdelivery
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var browsableFs string
+
+func TestMain(m *testing.M) {
+	var err error
+	if browsableFs, err = ioutil.TempDir("", "nxfs-delivery-test"); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(browsableFs)
+	os.Exit(code)
+}
+
+func writeFile(t *testing.T, relPath string, content string) {
+	fullPath := filepath.Join(browsableFs, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(handler http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestServeRendersPagesThroughTheirLayout(t *testing.T) {
+	writeFile(t, "layouts/simple.html", `<title>{{.Title}}</title>{{range .Frames}}[{{.Name}}{{if .Widget}}:{{.Widget.Code}}{{end}}]{{end}}`)
+	writeFile(t, "pages/about.page", `{"schemaVersion":1,"title":"About <us>","template":"layouts/simple.html","frames":[{"pos":0,"name":"main","widget":{"code":"text"}}]}`)
+	writeFile(t, "pages/news/index.page", `{"schemaVersion":1,"title":"News","template":"/layouts/simple.html","frames":[]}`)
+	writeFile(t, "pages/assets/logo.txt", "logo")
+	writeFile(t, "pages/.nxfs-tmp-123", "staged")
+
+	handler := NewHandler("/site", browsableFs, time.Minute)
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/site/about", http.StatusOK, "<title>About &lt;us&gt;</title>[main:text]"},
+		{"/site/about.page", http.StatusOK, "<title>About &lt;us&gt;</title>[main:text]"},
+		{"/site/news", http.StatusOK, "<title>News</title>"},
+		{"/site/news/", http.StatusOK, "<title>News</title>"},
+		{"/site/assets/logo.txt", http.StatusOK, "logo"},
+		{"/site/../layouts/simple.html", http.StatusNotFound, ""},
+		{"/site/.nxfs-tmp-123", http.StatusNotFound, ""},
+		{"/siteabout", http.StatusNotFound, ""},
+		{"/site/missing", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		response := get(handler, test.target, nil)
+		if response.Code != test.status {
+			t.Errorf("GET %s: expected status %d, got %d", test.target, test.status, response.Code)
+			continue
+		}
+		if "" != test.body && response.Body.String() != test.body {
+			t.Errorf("GET %s: expected body %q, got %q", test.target, test.body, response.Body.String())
+		}
+	}
+}
+
+func TestServeLoadsTheLayoutsFolderOnly(t *testing.T) {
+	writeFile(t, "layouts/simple.html", `<title>{{.Title}}</title>`)
+	writeFile(t, "draft_pages/secret.page", `{"schemaVersion":1,"title":"Secret draft","frames":[]}`)
+	writeFile(t, "pages/draft.page", `{"schemaVersion":1,"title":"Draft","template":"draft_pages/secret.page","frames":[]}`)
+	writeFile(t, "pages/climbing.page", `{"schemaVersion":1,"title":"Climbing","template":"layouts/../draft_pages/secret.page","frames":[]}`)
+	writeFile(t, "pages/outside.page", `{"schemaVersion":1,"title":"Outside","template":"../layouts/simple.html","frames":[]}`)
+	writeFile(t, "pages/folder.page", `{"schemaVersion":1,"title":"Folder","template":"layouts","frames":[]}`)
+
+	handler := NewHandler("/site", browsableFs, time.Minute)
+	for _, target := range []string{"/site/draft", "/site/climbing", "/site/outside", "/site/folder"} {
+		response := get(handler, target, nil)
+		if response.Code != http.StatusInternalServerError || strings.Contains(response.Body.String(), "Secret") {
+			t.Errorf("GET %s: expected the template to be refused, got %d %q", target, response.Code, response.Body.String())
+		}
+	}
+}
+
+func TestServeFilesWithoutRunningThem(t *testing.T) {
+	writeFile(t, "pages/assets/page.html", "<script>alert(1)</script>")
+
+	response := get(NewHandler("/site", browsableFs, time.Minute), "/site/assets/page.html", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", response.Code)
+	}
+	if nosniff := response.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+		t.Errorf("unexpected X-Content-Type-Options %q", nosniff)
+	}
+	if csp := response.Header().Get("Content-Security-Policy"); csp != "sandbox" {
+		t.Errorf("unexpected Content-Security-Policy %q", csp)
+	}
+}
+
+func TestServeSetsCachingHeaders(t *testing.T) {
+	writeFile(t, "layouts/simple.html", `<title>{{.Title}}</title>`)
+	writeFile(t, "pages/cached.page", `{"schemaVersion":1,"title":"Cached","template":"layouts/simple.html","frames":[]}`)
+
+	handler := NewHandler("/", browsableFs, 90*time.Second)
+
+	response := get(handler, "/cached", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", response.Code)
+	}
+	if cacheControl := response.Header().Get("Cache-Control"); cacheControl != "public, max-age=90" {
+		t.Errorf("unexpected Cache-Control %q", cacheControl)
+	}
+	if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("unexpected Content-Type %q", contentType)
+	}
+	etag := response.Header().Get("ETag")
+	if "" == etag || "" == response.Header().Get("Last-Modified") {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, response.Header().Get("Last-Modified"))
+	}
+
+	if response = get(handler, "/cached", http.Header{"If-None-Match": {etag}}); response.Code != http.StatusNotModified {
+		t.Errorf("expected status 304 for a matching ETag, got %d", response.Code)
+	}
+
+	// a changed layout changes the rendered page, so the old ETag doesn't match anymore
+	writeFile(t, "layouts/simple.html", `<h1>{{.Title}}</h1>`)
+	response = get(handler, "/cached", http.Header{"If-None-Match": {etag}})
+	if response.Code != http.StatusOK || response.Body.String() != "<h1>Cached</h1>" {
+		t.Errorf("expected the page rendered with the new layout, got %d %q", response.Code, response.Body.String())
+	}
+}
+
+func TestServeIsReadOnly(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/site/about", nil)
+	recorder := httptest.NewRecorder()
+	NewHandler("/site", browsableFs, time.Minute).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("expected 405 with the allowed methods, got %d %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,251 @@
+package nxfsdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext - number of unchanged lines shown around every change
+const DefaultContext = 3
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit - a line of the edit script transforming the old text into the new one
+type edit struct {
+	kind editKind
+	line string
+	// 0 based indexes of the line in the old and in the new text, valid for the kinds containing it
+	oldIndex int
+	newIndex int
+}
+
+// Unified - return the unified diff transforming oldText, named oldName, into newText, named newName,
+// with context unchanged lines around every change. identical texts produce an empty string
+func Unified(oldName string, newName string, oldText string, newText string, context int) string {
+	edits := diffLines(splitLines(oldText), splitLines(newText))
+
+	var builder strings.Builder
+	for _, hunk := range hunks(edits, context) {
+		if builder.Len() == 0 {
+			fmt.Fprintf(&builder, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		writeHunk(&builder, edits[hunk[0]:hunk[1]])
+	}
+	return builder.String()
+}
+
+// splitLines - split a text in lines keeping their line terminators, so that a missing final newline is a difference
+func splitLines(text string) []string {
+	if "" == text {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if "" == lines[len(lines)-1] {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxEditDistance - number of inserted and deleted lines above which the changed lines are diffed as a whole replacement,
+// bounding the memory of the trace kept by the Myers algorithm to about maxEditDistance^2 ints
+const maxEditDistance = 1024
+
+// diffLines - return the shortest edit script transforming a into b, computed with the Myers algorithm on the lines
+// between the common prefix and suffix
+func diffLines(a []string, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{kind: editEqual, line: a[i], oldIndex: i, newIndex: i})
+	}
+	middle := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], maxEditDistance)
+	if middle == nil {
+		middle = replace(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	}
+	for _, e := range middle {
+		e.oldIndex += prefix
+		e.newIndex += prefix
+		edits = append(edits, e)
+	}
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, edit{kind: editEqual, line: a[len(a)-i], oldIndex: len(a) - i, newIndex: len(b) - i})
+	}
+	return edits
+}
+
+// myers - return the shortest edit script transforming a into b, nil if it has more than maxDistance inserted
+// and deleted lines
+func myers(a []string, b []string, maxDistance int) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDistance {
+		max = maxDistance
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// trace[d] is the v[-d..d] window of the state of v before step d, the only one read when walking the edit path back
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack - rebuild the edit script from the trace of the Myers algorithm
+func backtrack(trace [][]int, a []string, b []string) []edit {
+	var reversed []edit
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// the window of step d starts at diagonal -d
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[d+prevK]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, edit{kind: editEqual, line: a[x], oldIndex: x, newIndex: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				reversed = append(reversed, edit{kind: editInsert, line: b[y], oldIndex: x, newIndex: y})
+			} else {
+				x--
+				reversed = append(reversed, edit{kind: editDelete, line: a[x], oldIndex: x, newIndex: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
+
+// replace - return the edit script deleting all the lines of a and inserting all the lines of b
+func replace(a []string, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for i, line := range a {
+		edits = append(edits, edit{kind: editDelete, line: line, oldIndex: i, newIndex: 0})
+	}
+	for i, line := range b {
+		edits = append(edits, edit{kind: editInsert, line: line, oldIndex: len(a), newIndex: i})
+	}
+	return edits
+}
+
+// hunks - group the changes of the edit script in hunks, returned as [start, end) ranges of edits including the context lines.
+// changes separated by no more than 2*context unchanged lines belong to the same hunk
+func hunks(edits []edit, context int) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(edits); i++ {
+		if edits[i].kind == editEqual {
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		if len(ranges) > 0 && start <= ranges[len(ranges)-1][1] {
+			start = ranges[len(ranges)-1][0]
+			ranges = ranges[:len(ranges)-1]
+		}
+
+		// extend to the last change of the run, then add the trailing context
+		for i+1 < len(edits) && edits[i+1].kind != editEqual {
+			i++
+		}
+		end := i + 1 + context
+		if end > len(edits) {
+			end = len(edits)
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// writeHunk - write a hunk header followed by its lines
+func writeHunk(builder *strings.Builder, edits []edit) {
+	oldStart, newStart := edits[0].oldIndex, edits[0].newIndex
+	oldLen, newLen := 0, 0
+	for _, e := range edits {
+		if e.kind != editInsert {
+			oldLen++
+		}
+		if e.kind != editDelete {
+			newLen++
+		}
+	}
+
+	fmt.Fprintf(builder, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLen), hunkRange(newStart, newLen))
+	for _, e := range edits {
+		prefix := " "
+		if e.kind == editDelete {
+			prefix = "-"
+		} else if e.kind == editInsert {
+			prefix = "+"
+		}
+		builder.WriteString(prefix + e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			builder.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange - format the range of a hunk: 1 based start line and length, the line before the hunk if it's empty
+func hunkRange(start int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	} else if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
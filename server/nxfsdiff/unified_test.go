@@ -0,0 +1,86 @@
+package nxfsdiff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	cases := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"both empty", "", "", ""},
+		{"added file", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"removed file", "a\n", "", "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n"},
+		{"changed line", "a\nb\nc\n", "a\nB\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"missing final newline", "a\n", "a", "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		{
+			"distant changes in separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+		{
+			"close changes merged in one hunk",
+			"1\n2\n3\n4\n5\n",
+			"x\n2\n3\n4\ny\n",
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n 4\n-5\n+y\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := Unified("old", "new", c.old, c.new, DefaultContext); actual != c.expected {
+				t.Fatalf("expected\n%q\ngot\n%q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUnifiedLargeInput(t *testing.T) {
+	lines := func(count int, line func(i int) string) string {
+		var builder strings.Builder
+		for i := 0; i < count; i++ {
+			builder.WriteString(line(i) + "\n")
+		}
+		return builder.String()
+	}
+	old := lines(50000, func(i int) string { return strconv.Itoa(i) })
+
+	// scattered changes are diffed line by line
+	scattered := lines(50000, func(i int) string {
+		if i%1000 == 500 {
+			return "changed " + strconv.Itoa(i)
+		}
+		return strconv.Itoa(i)
+	})
+	diff := Unified("old", "new", old, scattered, DefaultContext)
+	if hunks := strings.Count(diff, "\n@@ "); hunks != 50 {
+		t.Fatalf("expected a hunk for every changed line, got %d", hunks)
+	}
+	if !strings.HasPrefix(diff, "--- old\n+++ new\n@@ -498,7 +498,7 @@\n 497\n 498\n 499\n-500\n+changed 500\n 501\n") {
+		t.Fatalf("unexpected first hunk in\n%s", diff[:200])
+	}
+
+	// files differing everywhere but their first and last lines are diffed as a replacement of the lines in between
+	replaced := lines(50000, func(i int) string {
+		if i == 0 || i == 49999 {
+			return strconv.Itoa(i)
+		}
+		return "other " + strconv.Itoa(i)
+	})
+	diff = Unified("old", "new", old, replaced, DefaultContext)
+	if !strings.HasPrefix(diff, "--- old\n+++ new\n@@ -1,50000 +1,50000 @@\n 0\n-1\n-2\n") ||
+		!strings.HasSuffix(diff, "+other 49998\n 49999\n") {
+		t.Fatalf("expected a replacement of the changed lines, got\n%s...%s", diff[:200], diff[len(diff)-200:])
+	}
+	// the +++ header line is counted with the inserted lines
+	if strings.Count(diff, "\n-") != 49998 || strings.Count(diff, "\n+") != 49999 {
+		t.Fatal("expected all the changed lines to be deleted and inserted")
+	}
+}
@@ -0,0 +1,149 @@
+package nxfserrors
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// error kinds, every nxfs error belongs to one of them
+var (
+	ErrInvalid         = errors.New("invalid request")
+	ErrNotFound        = errors.New("not found")
+	ErrExists          = errors.New("already exists")
+	ErrNotEmpty        = errors.New("directory not empty")
+	ErrConflict        = errors.New("conflict")
+	ErrPermission      = errors.New("permission denied")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrUnprocessable   = errors.New("unprocessable")
+	ErrNoSpace         = errors.New("no space left")
+	ErrTooLarge        = errors.New("too large")
+	ErrReadOnly        = errors.New("read-only file system")
+	ErrLocked          = errors.New("locked")
+	ErrInternal        = errors.New("internal error")
+
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrNotImplemented   = errors.New("not implemented")
+	ErrUnsupportedType  = errors.New("unsupported type")
+	ErrUnavailable      = errors.New("unavailable")
+)
+
+// kindStatuses - the http status corresponding to each error kind
+var kindStatuses = map[error]int{
+	ErrInvalid:         http.StatusBadRequest,
+	ErrNotFound:        http.StatusNotFound,
+	ErrExists:          http.StatusConflict,
+	ErrNotEmpty:        http.StatusUnprocessableEntity,
+	ErrConflict:        http.StatusConflict,
+	ErrPermission:      http.StatusForbidden,
+	ErrUnauthenticated: http.StatusUnauthorized,
+	ErrUnprocessable:   http.StatusUnprocessableEntity,
+	ErrNoSpace:         http.StatusInsufficientStorage,
+	ErrTooLarge:        http.StatusRequestEntityTooLarge,
+	ErrReadOnly:        http.StatusServiceUnavailable,
+	ErrLocked:          http.StatusLocked,
+	ErrInternal:        http.StatusInternalServerError,
+
+	ErrMethodNotAllowed: http.StatusMethodNotAllowed,
+	ErrNotImplemented:   http.StatusNotImplemented,
+	ErrUnsupportedType:  http.StatusUnsupportedMediaType,
+	ErrUnavailable:      http.StatusServiceUnavailable,
+}
+
+// kindCodes - the stable Result code used for the errors of a kind mapped from an os error
+var kindCodes = map[error]string{
+	ErrNotFound:   "path_not_found",
+	ErrExists:     "already_exists",
+	ErrNotEmpty:   "dir_not_empty",
+	ErrConflict:   "path_conflict",
+	ErrPermission: "permission_denied",
+	ErrNoSpace:    "no_space_left",
+	ErrReadOnly:   "read_only_fs",
+}
+
+// Error - an nxfs error: its kind, the stable code, the message and the details exposed in the Result and the underlying cause
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Details []model.ResultDetail
+	Err     error
+}
+
+// New - create an Error of the received kind
+func New(kind error, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// FromOS - create an Error classifying the received os error. known causes get the stable code of their kind,
+// the others are internal errors identified by the received code
+func FromOS(err error, code string, message string) *Error {
+	kind := classify(err)
+	if kindCode, ok := kindCodes[kind]; ok {
+		code = kindCode
+	}
+	return &Error{Kind: kind, Code: code, Message: message + ": " + err.Error(), Err: err}
+}
+
+// Wrap - convert any error to an Error, classifying it if it isn't one already
+func Wrap(err error, code string) *Error {
+	var nxfsErr *Error
+	if errors.As(err, &nxfsErr) {
+		return nxfsErr
+	}
+	kind := classify(err)
+	if kindCode, ok := kindCodes[kind]; ok {
+		code = kindCode
+	}
+	return &Error{Kind: kind, Code: code, Message: err.Error(), Err: err}
+}
+
+// Error - implement the error interface
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap - return the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is - an Error matches its own kind
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Status - return the http status corresponding to the kind of the error
+func (e *Error) Status() int {
+	if status, ok := kindStatuses[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// classify - return the kind corresponding to the received os error, even if wrapped. ENOTEMPTY is checked first as it
+// also matches os.ErrExist
+func classify(err error) error {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return ErrNotEmpty
+	case errors.Is(err, os.ErrExist):
+		return ErrExists
+	case errors.Is(err, os.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return ErrNoSpace
+	case errors.Is(err, syscall.EROFS):
+		return ErrReadOnly
+	case errors.Is(err, syscall.ENOTDIR), errors.Is(err, syscall.EISDIR):
+		return ErrConflict
+	case errors.Is(err, syscall.ENAMETOOLONG), errors.Is(err, syscall.EINVAL):
+		return ErrInvalid
+	default:
+		return ErrInternal
+	}
+}
@@ -0,0 +1,96 @@
+package nxfserrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestFromOS(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		kind   error
+		code   string
+		status int
+	}{
+		{"not found", os.ErrNotExist, ErrNotFound, "path_not_found", http.StatusNotFound},
+		{"already exists", syscall.EEXIST, ErrExists, "already_exists", http.StatusConflict},
+		{"directory not empty", syscall.ENOTEMPTY, ErrNotEmpty, "dir_not_empty", http.StatusUnprocessableEntity},
+		{"permission denied", syscall.EACCES, ErrPermission, "permission_denied", http.StatusForbidden},
+		{"no space left", syscall.ENOSPC, ErrNoSpace, "no_space_left", http.StatusInsufficientStorage},
+		{"quota exceeded", syscall.EDQUOT, ErrNoSpace, "no_space_left", http.StatusInsufficientStorage},
+		{"read-only file system", syscall.EROFS, ErrReadOnly, "read_only_fs", http.StatusServiceUnavailable},
+		{"not a directory", syscall.ENOTDIR, ErrConflict, "path_conflict", http.StatusConflict},
+		{"is a directory", syscall.EISDIR, ErrConflict, "path_conflict", http.StatusConflict},
+		// the invalid and the internal errors keep the code of the failed operation
+		{"name too long", syscall.ENAMETOOLONG, ErrInvalid, "write_error", http.StatusBadRequest},
+		{"invalid argument", syscall.EINVAL, ErrInvalid, "write_error", http.StatusBadRequest},
+		{"other error", syscall.EIO, ErrInternal, "write_error", http.StatusInternalServerError},
+		{"not an os error", errors.New("unexpected"), ErrInternal, "write_error", http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cause := &os.PathError{Op: "write", Path: "docs/a.txt", Err: c.err}
+			err := FromOS(cause, "write_error", "An error occurred during the writing")
+			if err.Kind != c.kind || err.Code != c.code || err.Status() != c.status {
+				t.Fatalf("expected %v %s %d, got %v %s %d", c.kind, c.code, c.status, err.Kind, err.Code, err.Status())
+			}
+			if !errors.Is(err, c.kind) || !errors.Is(err, c.err) {
+				t.Fatalf("expected %v to match its kind and its cause", err)
+			}
+			if expected := "An error occurred during the writing: " + cause.Error(); err.Message != expected {
+				t.Fatalf("expected the message %q, got %q", expected, err.Message)
+			}
+
+			// wrapping classifies the same way
+			if wrapped := Wrap(fmt.Errorf("copying: %w", cause), "write_error"); wrapped.Kind != c.kind || wrapped.Code != c.code {
+				t.Fatalf("expected the wrapped error to be %v %s, got %v %s", c.kind, c.code, wrapped.Kind, wrapped.Code)
+			}
+		})
+	}
+}
+
+func TestWrapKeepsTheErrors(t *testing.T) {
+	original := New(ErrLocked, "client_locked", "The object is locked")
+	if wrapped := Wrap(fmt.Errorf("deleting: %w", original), "deletion_error"); wrapped != original {
+		t.Fatalf("expected the nxfs error to be kept, got %v", wrapped)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	cases := []struct {
+		kind   error
+		status int
+	}{
+		{ErrInvalid, http.StatusBadRequest},
+		{ErrNotFound, http.StatusNotFound},
+		{ErrExists, http.StatusConflict},
+		{ErrNotEmpty, http.StatusUnprocessableEntity},
+		{ErrConflict, http.StatusConflict},
+		{ErrPermission, http.StatusForbidden},
+		{ErrUnauthenticated, http.StatusUnauthorized},
+		{ErrUnprocessable, http.StatusUnprocessableEntity},
+		{ErrNoSpace, http.StatusInsufficientStorage},
+		{ErrTooLarge, http.StatusRequestEntityTooLarge},
+		{ErrReadOnly, http.StatusServiceUnavailable},
+		{ErrLocked, http.StatusLocked},
+		{ErrInternal, http.StatusInternalServerError},
+		{ErrMethodNotAllowed, http.StatusMethodNotAllowed},
+		{ErrNotImplemented, http.StatusNotImplemented},
+		{ErrUnsupportedType, http.StatusUnsupportedMediaType},
+		{ErrUnavailable, http.StatusServiceUnavailable},
+		// an unknown kind is an internal error
+		{errors.New("unknown"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		if status := New(c.kind, "code", "message").Status(); status != c.status {
+			t.Errorf("expected %v to be a %d, got %d", c.kind, c.status, status)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package nxfsfiles
+
+import (
+	"github.com/entando/entando-nxfs/server/helper"
+	pkgErr "github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// tempFilePrefix - prefix of the temporary files used by the atomic writes
+const tempFilePrefix = ".nxfs-tmp-"
+
+// file system operations used by WriteFileAtomic, replaced by the tests to simulate failures
+var (
+	syncFile   = func(file *os.File) error { return file.Sync() }
+	renameFile = os.Rename
+	syncDir    = syncDirectory
+)
+
+// WriteFileAtomic - write the content read from the received reader to the file identified by filePath.
+// the content is written to a temporary file in the same directory that is synced and then renamed in place,
+// so filePath always contains either its previous content or the whole new one
+func WriteFileAtomic(filePath string, content io.Reader, perm os.FileMode) error {
+	stagedPath, err := StageFile(filePath, content, perm)
+	if err != nil {
+		return err
+	}
+	if err = CommitStagedFile(stagedPath, filePath); err != nil {
+		DiscardStagedFile(stagedPath)
+		return err
+	}
+	return nil
+}
+
+// StageFile - write the content read from the received reader to a synced temporary file in the directory of filePath,
+// returning its path. the staged file replaces filePath only when committed with CommitStagedFile
+func StageFile(filePath string, content io.Reader, perm os.FileMode) (stagedPath string, err error) {
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(filePath), tempFilePrefix)
+	if err != nil {
+		return "", err
+	}
+
+	// on any failure remove the temporary file, the target is left untouched
+	defer func() {
+		if err != nil {
+			tempFile.Close()
+			os.Remove(tempFile.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tempFile, content); err != nil {
+		return "", err
+	}
+	if err = tempFile.Chmod(perm); err != nil {
+		return "", err
+	}
+	if err = syncFile(tempFile); err != nil {
+		return "", err
+	}
+	if err = tempFile.Close(); err != nil {
+		return "", err
+	}
+	return tempFile.Name(), nil
+}
+
+// TempPathFor - return an unused temporary path in the directory of filePath, where a file can be staged by other means
+// than StageFile. a file left there is removed by SweepTempFiles
+func TempPathFor(filePath string) string {
+	return filepath.Join(filepath.Dir(filePath), tempFilePrefix+helper.NewRandomId())
+}
+
+// CommitStagedFile - atomically replace filePath with the file staged by StageFile
+func CommitStagedFile(stagedPath string, filePath string) error {
+	if err := renameFile(stagedPath, filePath); err != nil {
+		return err
+	}
+
+	if syncErr := syncDir(filepath.Dir(filePath)); syncErr != nil {
+		return pkgErr.Wrap(syncErr, "file written but its directory can't be synced")
+	}
+	return nil
+}
+
+// DiscardStagedFile - remove a staged file that won't be committed
+func DiscardStagedFile(stagedPath string) {
+	os.Remove(stagedPath)
+}
+
+// SweepTempFiles - remove the temporary files left under the received root by interrupted atomic writes. return the number of removed files
+func SweepTempFiles(root string) (int, error) {
+	removed := 0
+	err := filepath.Walk(root, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fileInfo.IsDir() && IsTempFile(fileInfo.Name()) {
+			if err := os.Remove(filePath); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// IsTempFile - return true if the received file name belongs to a temporary file of an atomic write
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix)
+}
+
+// syncDirectory - sync the received directory so that a rename inside it is durable
+func syncDirectory(dir string) error {
+	if runtime.GOOS == "windows" {
+		// directories can't be opened for sync on windows
+		return nil
+	}
+
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer dirFile.Close()
+
+	return dirFile.Sync()
+}
@@ -0,0 +1,163 @@
+package nxfsfiles
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errSimulated = errors.New("simulated failure")
+
+// failingReader - a reader returning some content and then an error, like a client disconnecting mid upload
+type failingReader struct {
+	content io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.content.Read(p)
+	if err == io.EOF {
+		return n, errSimulated
+	}
+	return n, err
+}
+
+func TestWriteFileAtomicReplacesContent(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "page.page")
+	writeTestFile(t, target, "old")
+
+	if err := WriteFileAtomic(target, strings.NewReader("new content"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertContent(t, target, "new content")
+	assertNoTempFiles(t, dir)
+}
+
+func TestWriteFileAtomicFailuresKeepPreviousContent(t *testing.T) {
+	failures := []struct {
+		name    string
+		content func() io.Reader
+		setup   func() (restore func())
+	}{
+		{
+			name:    "reader fails part-way",
+			content: func() io.Reader { return &failingReader{content: strings.NewReader("half written")} },
+			setup:   func() func() { return func() {} },
+		},
+		{
+			name:    "sync fails",
+			content: func() io.Reader { return strings.NewReader("never synced") },
+			setup: func() func() {
+				syncFile = func(*os.File) error { return errSimulated }
+				return func() { syncFile = func(file *os.File) error { return file.Sync() } }
+			},
+		},
+		{
+			name:    "rename fails",
+			content: func() io.Reader { return strings.NewReader("never renamed") },
+			setup: func() func() {
+				renameFile = func(string, string) error { return errSimulated }
+				return func() { renameFile = os.Rename }
+			},
+		},
+	}
+
+	for _, failure := range failures {
+		t.Run(failure.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+			target := filepath.Join(dir, "page.page")
+			writeTestFile(t, target, "previous content")
+
+			restore := failure.setup()
+			defer restore()
+
+			if err := WriteFileAtomic(target, failure.content(), 0644); err != errSimulated {
+				t.Fatalf("expected the simulated failure, got %v", err)
+			}
+
+			assertContent(t, target, "previous content")
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+func TestWriteFileAtomicFailureOnNewFileLeavesNothing(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "new.page")
+
+	if err := WriteFileAtomic(target, &failingReader{content: strings.NewReader("half")}, 0644); err != errSimulated {
+		t.Fatalf("expected the simulated failure, got %v", err)
+	}
+
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected no target file, stat returned %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestSweepTempFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, tempFilePrefix+"1"), "orphan")
+	writeTestFile(t, filepath.Join(dir, "nested", tempFilePrefix+"2"), "orphan")
+	writeTestFile(t, filepath.Join(dir, "nested", "kept.txt"), "kept")
+
+	removed, err := SweepTempFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 removed files, got %d", removed)
+	}
+
+	assertNoTempFiles(t, dir)
+	assertNoTempFiles(t, filepath.Join(dir, "nested"))
+	assertContent(t, filepath.Join(dir, "nested", "kept.txt"), "kept")
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "nxfsfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, filePath string, content string) {
+	if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertContent(t *testing.T, filePath string, expected string) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("can't read %s: %v", filePath, err)
+	}
+	if string(content) != expected {
+		t.Fatalf("expected content %q, got %q", expected, string(content))
+	}
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if IsTempFile(file.Name()) {
+			t.Fatalf("temporary file %s left in %s", file.Name(), dir)
+		}
+	}
+}
@@ -1,192 +1,57 @@
 package nxfsfiles
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/entando/entando-nxfs/server/helper"
-	"github.com/entando/entando-nxfs/server/model"
 	"github.com/entando/entando-nxfs/server/net"
-	pkgErr "github.com/pkg/errors"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
 	"io"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"os"
-	"path"
-	"path/filepath"
 )
 
-// IsDirWithChildren - return true if path is a dir and has childre, false otherwise
-func IsDirWithChildren(absPathFile string, fileToDelete os.FileInfo) bool {
-	if children, _ := ioutil.ReadDir(absPathFile); fileToDelete.IsDir() && len(children) > 0 {
-		return true
-	} else {
-		return false
-	}
-}
-
 // GetFileInfoIfPathExistOrErrorResponse - if the received path exists return the corresponding os.FileInfo, otherwise return an error NxfsResponse
 func GetFileInfoIfPathExistOrErrorResponse(pathToCheck string) (os.FileInfo, *net.NxfsResponse) {
-	if fileInfo, err := os.Stat(pathToCheck); os.IsNotExist(err) {
-		return nil, helper.ErrorResponse(http.StatusNotFound, "path_not_found", err.Error())
+	if fileInfo, err := os.Stat(pathToCheck); err != nil {
+		return nil, helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
 	} else {
 		return fileInfo, nil
 	}
 }
 
-// GetDraftPageInfoIfExistOrErrorResponse - if the received path exists as relative path in the draft pages folder return the corresponding os.FileInfo, otherwise return an error NxfsResponse
-func GetDraftPageInfoIfExistOrErrorResponse(pathToCheck string) (os.FileInfo, *net.NxfsResponse) {
-	return GetFileInfoIfPathExistOrErrorResponse(path.Join(helper.GetDraftPagesPath(), pathToCheck))
-}
-
-// getPublishedPageInfoIfExistOrErrorResponse - if the received path exists as relative path in the published pages folder return the corresponding os.FileInfo, otherwise return an error NxfsResponse
-func getPublishedPageInfoIfExistOrErrorResponse(pathToCheck string) (os.FileInfo, *net.NxfsResponse) {
-	return GetFileInfoIfPathExistOrErrorResponse(path.Join(helper.GetPublishedPagesPath(), pathToCheck))
-}
-
-// RelativizeToDraftPageFolder - receive a path and relativize it to the draft pages folder
-func RelativizeToDraftPageFolder(pathToRelativize string) string {
-	return path.Join(helper.GetDraftPagesPath(), pathToRelativize)
-}
-
-// RelativizeToPublishedPageFolder - receive a path and relativize it to the draft pages folder
-func RelativizeToPublishedPageFolder(pathToRelativize string) string {
-	return path.Join(helper.GetPublishedPagesPath(), pathToRelativize)
-}
-
-// CreateFile - create a file in the received path containing the received content return an error NxfsResponse if an error occurs, nil otherwise
-func CreateFile(path string, fileObject model.FileObject) (errorResp *net.NxfsResponse) {
-
-	data := []byte(fileObject.Content)
-	err := ioutil.WriteFile(path, data, 0755)
-	if err != nil {
-		errorResp = helper.ErrorResponse(http.StatusBadRequest, "write_error", err.Error())
-	}
-
-	return errorResp
-}
-
-// CreateDirectory - create a directory in the received path. return an error NxfsResponse if an error occurs, nil otherwise
-func CreateDirectory(dirPath string) (errorResp *net.NxfsResponse) {
-
-	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		err := os.Mkdir(dirPath, 0755)
-		if err != nil {
-			errorResp = helper.ErrorResponse(http.StatusBadRequest, "dir_write_error", err.Error())
-		}
-	}
-
-	return errorResp
-}
-
 // DecodePath - receives an url encoded path, decodes and returns it. if a decode error occurs, it will return an error NxfsResponse
 func DecodePath(encodedPath string) (string, *net.NxfsResponse) {
 
 	decodedPath, err := url.PathUnescape(encodedPath)
 	if err != nil {
-		return "", helper.ErrorResponse(http.StatusBadRequest, "error_decoding_path", err.Error())
+		return "", helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "error_decoding_path", err.Error()))
 	}
 
 	return decodedPath, nil
 }
 
-// DeleteFile - delete a file or folder, return an error NxfsResponse if an error occur, nil otherwise
-func DeleteFile(filePath string) *net.NxfsResponse {
-	err := os.Remove(filePath)
-	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "deletion_error", "An error occurred during the deletion")
-	} else {
-		return nil
-	}
-}
-
-// BrowseFileTree - traverse recursively the path represented by fileInfo
-func BrowseFileTree(path string, fileInfo os.FileInfo, currDepth int32, maxDepth int32, directoryObjects []model.DirectoryObject) ([]model.DirectoryObject, error) {
-
-	// if depth reached return
-	if currDepth > maxDepth && maxDepth != 0 {
-		return directoryObjects, nil
-	}
-
-	// if the current one is a file add it to the result list and return
-	if !fileInfo.IsDir() {
-		directoryObjects = append(directoryObjects, helper.ToDirectoryObject(path, fileInfo))
-		return directoryObjects, nil
-	}
-
-	// otherwise proceed with the tree inspection
-	dirAbsPath := filepath.Join(path, fileInfo.Name())
-
-	// read dir
-	readFilesInfo, err := ioutil.ReadDir(dirAbsPath)
-	if err != nil {
-		return directoryObjects, pkgErr.Wrap(err, fmt.Sprintf("can't read directory %s", dirAbsPath))
-	}
-
-	// call recursively
-	for _, file := range readFilesInfo {
-		directoryObjects, err = BrowseFileTree(dirAbsPath, file, currDepth+1, maxDepth, directoryObjects)
-		if err != nil {
-			return directoryObjects, err
-		}
-	}
-
-	return directoryObjects, nil
+// HashContent - return the hex encoded sha256 of the received content, as HashFile does for the files
+func HashContent(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
 }
 
-// ComposeFullPathOrErrorResponse - receives a URL encoded path, decodes it and return the corresponding full path (without filename), the fileInfo of the requested file/folder and a possible REST response containing an error
-func ComposeFullPathOrErrorResponse(encodedPath string) (fullPath string, fileInfoToBrowse os.FileInfo, errorResponse *net.NxfsResponse) {
-
-	// define paths
-	decodedPath, errResponse := DecodePath(encodedPath)
-	if errResponse != nil {
-		return "", nil, errResponse
-	}
-
-	fullPathToBrowse := filepath.Join(helper.GetBrowsableFsRootPath(), decodedPath)
-
-	// does path exist?
-	fileInfoToBrowse, errRespFileExist := GetFileInfoIfPathExistOrErrorResponse(fullPathToBrowse)
-	if nil != errRespFileExist {
-		return "", nil, errRespFileExist
-	}
-
-	// extract pathToBrowse path
-	fullPath = path.Dir(fullPathToBrowse)
-
-	return fullPath, fileInfoToBrowse, nil
-}
-
-// CopyFileTo - copy the file identified by originFile to the destination identified by destinationFile. return an error NxfsResponse if an error occur, nil otherwise
-func CopyFileTo(sourceFile string, destinationFile string) *net.NxfsResponse {
-
-	// create path if needed
-	destinationFolder := path.Dir(destinationFile)
-	if _, implResponse := GetFileInfoIfPathExistOrErrorResponse(destinationFolder); nil != implResponse {
-		err := os.MkdirAll(destinationFolder, os.ModePerm)
-		if err != nil {
-			return helper.ErrorResponse(http.StatusInternalServerError, "path_creation_error", "An error occurred during the creation of the published page path")
-		}
-	}
-
-	// input
-	in, err := os.Open(sourceFile)
+// HashFile - return the hex encoded sha256 of the content of the received file, an empty string if it doesn't exist or is a directory
+func HashFile(filePath string) string {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "draft_read_error", fmt.Sprintf("An error occurred during the read operation of the draft page file %s", sourceFile))
+		return ""
 	}
-	defer in.Close()
+	defer file.Close()
 
-	// output
-	out, err := os.Create(destinationFile)
-	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "published_creation_error", fmt.Sprintf("An error occurred during the creation of the published page file %s", destinationFile))
+	if fileInfo, err := file.Stat(); err != nil || fileInfo.IsDir() {
+		return ""
 	}
-	defer out.Close()
 
-	// copy file
-	_, err = io.Copy(out, in)
-	if err != nil {
-		return helper.ErrorResponse(http.StatusInternalServerError, "published_copy_error", "An error occurred during the copy of the draft page file to the the published page file")
-	} else {
-		return nil
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ""
 	}
+	return hex.EncodeToString(hash.Sum(nil))
 }
@@ -0,0 +1,379 @@
+package nxfsgit
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// committerName - the committer of every commit, the author is the user who made the change
+const committerName = "nxfs"
+const committerEmail = "nxfs@localhost"
+
+// excludedFiles - the patterns of the files of the working tree never committed
+const excludedFiles = ".nxfs-tmp-*\n"
+
+// DefaultHistoryLimit - the number of commits returned by History when no limit is given
+const DefaultHistoryLimit = 50
+
+var commitIdPattern = regexp.MustCompile("^[0-9a-f]{4,40}$")
+
+// field and record separators of the log format
+const fieldSeparator = "\x1f"
+const recordSeparator = "\x1e"
+const logFormat = "--format=" + recordSeparator + "%H" + fieldSeparator + "%an" + fieldSeparator + "%aI" + fieldSeparator + "%s"
+
+// Repo - a git repository whose working tree is the browsable fs, driven through the git command line.
+// its git directory is kept out of the working tree, so it's never exposed by the nxfs APIs
+type Repo struct {
+	gitDir   string
+	workTree string
+	remote   string
+	branch   string
+	// mu - serializes the commands using the index, shared by every operation
+	mu sync.Mutex
+}
+
+// Open - open the repository stored in gitDir whose working tree is workTree, initializing it with a commit of the
+// current content of the working tree if it doesn't exist. remote is the url pushed to and pulled from, empty if none
+func Open(gitDir string, workTree string, remote string, branch string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("the git storage needs the git command: %s", err.Error())
+	}
+
+	r := &Repo{gitDir: gitDir, workTree: workTree, remote: remote, branch: branch}
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); os.IsNotExist(err) {
+		if err = os.MkdirAll(filepath.Dir(gitDir), 0755); err != nil {
+			return nil, err
+		}
+		if _, err = r.run(nil, "init", "-q"); err != nil {
+			return nil, err
+		}
+		if _, err = r.run(nil, "symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(gitDir, "info"), 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(gitDir, "info", "exclude"), []byte(excludedFiles), 0644); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.run(nil, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		if err = r.initialize(); err != nil {
+			return nil, err
+		}
+		log.Printf("Initialized the git storage of %s in %s", workTree, gitDir)
+	}
+	return r, nil
+}
+
+// initialize - create the first commit of a new repository: the branch of the remote, if it exists, with the files of the
+// working tree it doesn't have on top of it. the files the remote branch has are overwritten by its content
+func (r *Repo) initialize() error {
+	if r.remote != "" {
+		if _, err := r.run(nil, "fetch", "-q", r.remote, "refs/heads/"+r.branch); err != nil {
+			log.Printf("Starting a new history, the branch %s can't be fetched from the remote: %s", r.branch, err.Error())
+		} else {
+			if _, err = r.run(nil, "reset", "-q", "FETCH_HEAD"); err != nil {
+				return err
+			}
+			if _, err = r.run(nil, "checkout", "-q", "--", "."); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := r.run(nil, "add", "-A", "--", "."); err != nil {
+		return err
+	}
+	if _, err := r.run(nil, "diff", "--cached", "--quiet"); err == nil {
+		if _, err = r.run(nil, "rev-parse", "-q", "--verify", "HEAD"); err == nil {
+			return nil
+		}
+	}
+	_, err := r.run(authorEnv(committerName), "commit", "-q", "--allow-empty", "--no-verify", "-m", "Initial content of the browsable fs")
+	return err
+}
+
+// Commit - commit the current content of the received paths, relative to the working tree root, with the received author
+// and message. return the id of the commit, empty if the paths haven't changed
+func (r *Repo) Commit(paths []string, author string, message string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var existing, missing []string
+	for _, relPath := range paths {
+		if _, err := os.Lstat(filepath.Join(r.workTree, filepath.FromSlash(relPath))); err == nil {
+			existing = append(existing, pathspec(relPath))
+		} else {
+			missing = append(missing, pathspec(relPath))
+		}
+	}
+	if len(existing) > 0 {
+		if _, err := r.run(nil, append([]string{"add", "-A", "--"}, existing...)...); err != nil {
+			return "", err
+		}
+	}
+	if len(missing) > 0 {
+		if _, err := r.run(nil, append([]string{"rm", "-r", "-q", "--cached", "--ignore-unmatch", "--"}, missing...)...); err != nil {
+			return "", err
+		}
+	}
+
+	// only the staged files are committed: a path that has never been committed can't be part of a pathspec
+	output, err := r.run(nil, append([]string{"diff", "--cached", "--name-only", "--"}, append(existing, missing...)...)...)
+	if err != nil {
+		return "", err
+	}
+	staged := lines(output)
+	if len(staged) == 0 {
+		return "", nil
+	}
+	for i, stagedPath := range staged {
+		staged[i] = pathspec(stagedPath)
+	}
+	if _, err = r.run(authorEnv(author), append([]string{"commit", "-q", "--no-verify", "-m", message, "--"}, staged...)...); err != nil {
+		return "", err
+	}
+	return r.head()
+}
+
+// History - return the last commits changing the received path, relative to the working tree root, newest first
+func (r *Repo) History(relPath string, limit int) ([]model.Commit, error) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	output, err := r.run(nil, "log", logFormat, "--name-only", fmt.Sprintf("-n%d", limit), "--", pathspec(relPath))
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(output), nil
+}
+
+// Get - return the commit identified by the received id with its diff, limited to the received path if not empty
+func (r *Repo) Get(id string, relPath string) (model.Commit, error) {
+	id, err := r.resolve(id)
+	if err != nil {
+		return model.Commit{}, err
+	}
+
+	output, err := r.run(nil, "log", "-n1", logFormat, "--name-only", id)
+	if err != nil {
+		return model.Commit{}, err
+	}
+	commits := parseLog(output)
+	if len(commits) == 0 {
+		return model.Commit{}, notFoundError(id)
+	}
+
+	commit := commits[0]
+	if commit.Diff, err = r.run(nil, "show", "--format=", "--no-color", "--no-ext-diff", id, "--", pathspec(relPath)); err != nil {
+		return model.Commit{}, err
+	}
+	return commit, nil
+}
+
+// Paths - return the paths, relative to the working tree root, changed by the commit identified by the received id
+func (r *Repo) Paths(id string) ([]string, error) {
+	id, err := r.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	output, err := r.run(nil, "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", id)
+	if err != nil {
+		return nil, err
+	}
+	return lines(output), nil
+}
+
+// Revert - commit the changes undoing the commit identified by the received id, authored by the received user.
+// a revert conflicting with the later changes is aborted leaving the working tree untouched
+func (r *Repo) Revert(id string, author string) (model.Commit, error) {
+	id, err := r.resolve(id)
+	if err != nil {
+		return model.Commit{}, err
+	}
+
+	r.mu.Lock()
+	_, err = r.run(authorEnv(author), "revert", "--no-edit", id)
+	if err != nil {
+		r.run(nil, "revert", "--abort")
+	}
+	r.mu.Unlock()
+	if err != nil {
+		log.Printf("Revert of %s failed: %s", id, err.Error())
+		return model.Commit{}, nxfserrors.New(nxfserrors.ErrConflict, "revert_conflict", fmt.Sprintf("The commit %s can't be reverted, it conflicts with the later changes or has already been undone", id))
+	}
+
+	head, err := r.head()
+	if err != nil {
+		return model.Commit{}, err
+	}
+	return r.Get(head, "")
+}
+
+// Push - push the head of the working tree to the received branch of the remote, the configured one if empty
+func (r *Repo) Push(branch string) (model.GitSyncResult, error) {
+	if branch == "" {
+		branch = r.branch
+	}
+	if err := r.checkRemote(branch); err != nil {
+		return model.GitSyncResult{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	head, err := r.head()
+	if err != nil {
+		return model.GitSyncResult{}, err
+	}
+	if _, err = r.run(nil, "push", "-q", r.remote, "HEAD:refs/heads/"+branch); err != nil {
+		log.Printf("Push to %s failed: %s", branch, err.Error())
+		return model.GitSyncResult{}, nxfserrors.New(nxfserrors.ErrConflict, "push_rejected", fmt.Sprintf("The push to the branch %s failed, pull its changes first", branch))
+	}
+	return model.GitSyncResult{Branch: branch, Head: head, Changed: []string{}}, nil
+}
+
+// Pull - fast forward the working tree to the configured branch of the remote. prepare, if not nil, is called with the
+// paths about to change before the working tree is updated
+func (r *Repo) Pull(prepare func(changed []string)) (model.GitSyncResult, error) {
+	if err := r.checkRemote(r.branch); err != nil {
+		return model.GitSyncResult{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, err := r.head()
+	if err != nil {
+		return model.GitSyncResult{}, err
+	}
+	if _, err = r.run(nil, "fetch", "-q", r.remote, "refs/heads/"+r.branch); err != nil {
+		log.Printf("Fetch of %s failed: %s", r.branch, err.Error())
+		return model.GitSyncResult{}, nxfserrors.New(nxfserrors.ErrConflict, "pull_failed", fmt.Sprintf("The branch %s can't be fetched from the remote", r.branch))
+	}
+	output, err := r.run(nil, "diff", "--name-only", before, "FETCH_HEAD")
+	if err != nil {
+		return model.GitSyncResult{}, err
+	}
+	changed := lines(output)
+	if prepare != nil {
+		prepare(changed)
+	}
+	if _, err = r.run(nil, "merge", "-q", "--ff-only", "FETCH_HEAD"); err != nil {
+		log.Printf("Fast forward to %s failed: %s", r.branch, err.Error())
+		return model.GitSyncResult{}, nxfserrors.New(nxfserrors.ErrConflict, "pull_not_fast_forward", fmt.Sprintf("The branch %s has diverged from the local history or conflicts with uncommitted files", r.branch))
+	}
+
+	after, err := r.head()
+	if err != nil {
+		return model.GitSyncResult{}, err
+	}
+	return model.GitSyncResult{Branch: r.branch, Head: after, Changed: changed}, nil
+}
+
+// checkRemote - return an error if no remote is configured or the branch name is not valid
+func (r *Repo) checkRemote(branch string) error {
+	if r.remote == "" {
+		return nxfserrors.New(nxfserrors.ErrInvalid, "git_remote_missing", "No git remote is configured")
+	}
+	if _, err := r.run(nil, "check-ref-format", "--branch", branch); err != nil || strings.HasPrefix(branch, "-") {
+		return nxfserrors.New(nxfserrors.ErrInvalid, "invalid_branch", fmt.Sprintf("%q is not a valid branch name", branch))
+	}
+	return nil
+}
+
+// resolve - return the full id of the commit identified by the received, possibly abbreviated, id
+func (r *Repo) resolve(id string) (string, error) {
+	if !commitIdPattern.MatchString(id) {
+		return "", nxfserrors.New(nxfserrors.ErrInvalid, "invalid_commit_id", fmt.Sprintf("%q is not a valid commit id", id))
+	}
+	output, err := r.run(nil, "rev-parse", "-q", "--verify", id+"^{commit}")
+	if err != nil {
+		return "", notFoundError(id)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// head - return the id of the commit at the head of the working tree
+func (r *Repo) head() (string, error) {
+	output, err := r.run(nil, "rev-parse", "HEAD")
+	return strings.TrimSpace(output), err
+}
+
+// run - run a git command on the repository with the received additional environment, returning its standard output
+func (r *Repo) run(env []string, args ...string) (string, error) {
+	command := exec.Command("git", append([]string{"--git-dir=" + r.gitDir, "--work-tree=" + r.workTree, "-c", "core.quotePath=false"}, args...)...)
+	command.Dir = r.workTree
+	command.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_COMMITTER_NAME="+committerName, "GIT_COMMITTER_EMAIL="+committerEmail)
+	command.Env = append(command.Env, env...)
+
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("git %s: %s: %s", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// authorEnv - return the environment setting the author of a commit
+func authorEnv(author string) []string {
+	return []string{"GIT_AUTHOR_NAME=" + author, "GIT_AUTHOR_EMAIL="}
+}
+
+// pathspec - return the literal pathspec of a path relative to the working tree root, the whole tree if it's empty
+func pathspec(relPath string) string {
+	relPath = filepath.ToSlash(filepath.Clean("/" + relPath))[1:]
+	if relPath == "" {
+		return "."
+	}
+	return ":(literal)" + relPath
+}
+
+// parseLog - parse the output of git log in logFormat with the names of the changed files
+func parseLog(output string) []model.Commit {
+	commits := []model.Commit{}
+	for _, record := range strings.Split(output, recordSeparator) {
+		recordLines := lines(record)
+		if len(recordLines) == 0 {
+			continue
+		}
+		fields := strings.SplitN(recordLines[0], fieldSeparator, 4)
+		if len(fields) < 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, model.Commit{Id: fields[0], Author: fields[1], Date: date.UTC(), Message: fields[3], Paths: recordLines[1:]})
+	}
+	return commits
+}
+
+// lines - return the non empty lines of the received output
+func lines(output string) []string {
+	result := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// notFoundError - return the error of a commit that doesn't exist
+func notFoundError(id string) error {
+	return nxfserrors.New(nxfserrors.ErrNotFound, "commit_not_found", fmt.Sprintf("The commit %s doesn't exist", id))
+}
@@ -0,0 +1,188 @@
+package nxfsgit
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testRepo - a repository whose working tree and git directory are in a temporary directory
+type testRepo struct {
+	*Repo
+	dir string
+}
+
+func newTestRepo(t *testing.T, dir string, name string, remote string) testRepo {
+	workTree := filepath.Join(dir, name, "fs")
+	if err := os.MkdirAll(workTree, 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := Open(filepath.Join(dir, name, "data", "git"), workTree, remote, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testRepo{repo, workTree}
+}
+
+func newTestDir(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "nxfs-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func (r testRepo) write(t *testing.T, relPath string, content string) {
+	fullPath := filepath.Join(r.dir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (r testRepo) commit(t *testing.T, relPath string, author string) string {
+	id, err := r.Commit([]string{relPath}, author, "put "+relPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func (r testRepo) assertContent(t *testing.T, relPath string, expected string) {
+	content, err := ioutil.ReadFile(filepath.Join(r.dir, filepath.FromSlash(relPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Fatalf("%s: expected %q, got %q", relPath, expected, content)
+	}
+}
+
+func TestCommitHistoryAndRevert(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+	repo := newTestRepo(t, dir, "local", "")
+
+	repo.write(t, "docs/a.txt", "first")
+	first := repo.commit(t, "docs/a.txt", "alice")
+	repo.write(t, "docs/a.txt", "second")
+	second := repo.commit(t, "docs/a.txt", "bob")
+	if id := repo.commit(t, "docs/a.txt", "bob"); id != "" {
+		t.Fatalf("an unchanged path has been committed as %s", id)
+	}
+
+	// the temporary files of the atomic writes are never committed
+	repo.write(t, "docs/.nxfs-tmp-123", "partial")
+	if err := os.Remove(filepath.Join(repo.dir, "docs", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	deleted := repo.commit(t, "docs", "carol")
+
+	history, err := repo.History("docs/a.txt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Id != deleted || history[1].Id != second || history[2].Id != first {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	if history[0].Author != "carol" || history[1].Author != "bob" || history[0].Paths[0] != "docs/a.txt" || len(history[0].Paths) != 1 {
+		t.Fatalf("unexpected commits %+v", history)
+	}
+
+	commit, err := repo.Get(second[:8], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Id != second || commit.Diff == "" {
+		t.Fatalf("unexpected commit %+v", commit)
+	}
+	if _, err = repo.Get("0000000", ""); !errors.Is(err, nxfserrors.ErrNotFound) {
+		t.Fatalf("expected a missing commit, got %v", err)
+	}
+	if _, err = repo.Get("HEAD~1", ""); !errors.Is(err, nxfserrors.ErrInvalid) {
+		t.Fatalf("expected an invalid commit id, got %v", err)
+	}
+
+	reverted, err := repo.Revert(deleted, "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Author != "dave" {
+		t.Fatalf("unexpected revert commit %+v", reverted)
+	}
+	repo.assertContent(t, "docs/a.txt", "second")
+
+	// undoing the first version conflicts with the second one
+	if _, err = repo.Revert(first, "dave"); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	repo.assertContent(t, "docs/a.txt", "second")
+}
+
+func TestPushAndPull(t *testing.T) {
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	remote := filepath.Join(dir, "remote.git")
+	if output, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+
+	staging := newTestRepo(t, dir, "staging", remote)
+	staging.write(t, "pages/home.page", "home")
+	staging.commit(t, "pages/home.page", "alice")
+	if _, err := staging.Push(""); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new nxfs pulling from the same branch starts from its content
+	production := newTestRepo(t, dir, "production", remote)
+	production.assertContent(t, "pages/home.page", "home")
+
+	staging.write(t, "pages/home.page", "new home")
+	staging.commit(t, "pages/home.page", "alice")
+	pushed, err := staging.Push("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulled, err := production.Pull(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pulled.Head != pushed.Head || len(pulled.Changed) != 1 || pulled.Changed[0] != "pages/home.page" {
+		t.Fatalf("unexpected pull %+v, pushed %+v", pulled, pushed)
+	}
+	production.assertContent(t, "pages/home.page", "new home")
+
+	// once the histories diverge neither side can push over the other nor fast forward to it
+	production.write(t, "pages/about.page", "about")
+	production.commit(t, "pages/about.page", "bob")
+	if _, err = production.Push(""); err != nil {
+		t.Fatal(err)
+	}
+	staging.write(t, "pages/contacts.page", "contacts")
+	staging.commit(t, "pages/contacts.page", "alice")
+	if _, err = staging.Push(""); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected the push to be rejected, got %v", err)
+	}
+	if _, err = staging.Pull(nil); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected the pull not to fast forward, got %v", err)
+	}
+
+	// a branch can be promoted by pushing to it
+	if _, err = staging.Push("next"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = staging.Push("bad..name"); !errors.Is(err, nxfserrors.ErrInvalid) {
+		t.Fatalf("expected an invalid branch, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package nxfslock
+
+import (
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"sync"
+	"time"
+)
+
+// DefaultClientLockTtl - duration of a client lock acquired without an explicit ttl
+const DefaultClientLockTtl = 5 * time.Minute
+
+// MaxClientLockTtl - the longest duration a client lock can be acquired or refreshed for
+const MaxClientLockTtl = 8 * time.Hour
+
+// ClientLockRegistry - the locks explicitly held by clients on objects, e.g. while a page is edited in the page designer.
+// a locked object can be modified only by the owner of its lock until the lock expires or is released
+type ClientLockRegistry struct {
+	mu    sync.Mutex
+	locks map[string]model.ObjectLock
+}
+
+// NewClientLockRegistry - create an empty ClientLockRegistry
+func NewClientLockRegistry() *ClientLockRegistry {
+	return &ClientLockRegistry{locks: map[string]model.ObjectLock{}}
+}
+
+// Acquire - lock the received path on behalf of owner. acquiring a lock already held by the same owner refreshes it
+func (r *ClientLockRegistry) Acquire(lockPath string, owner string, ttl time.Duration) (model.ObjectLock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockPath = normalize(lockPath)
+	now := time.Now().UTC()
+	acquiredAt := now
+	if lock, ok := r.current(lockPath, now); ok {
+		if lock.Owner != owner {
+			return model.ObjectLock{}, lockedError(lock)
+		}
+		acquiredAt = lock.AcquiredAt
+	}
+
+	lock := model.ObjectLock{Path: lockPath, Owner: owner, AcquiredAt: acquiredAt, ExpiresAt: now.Add(clampTtl(ttl))}
+	r.locks[lockPath] = lock
+	return lock, nil
+}
+
+// Refresh - extend the lock held by owner on the received path
+func (r *ClientLockRegistry) Refresh(lockPath string, owner string, ttl time.Duration) (model.ObjectLock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockPath = normalize(lockPath)
+	now := time.Now().UTC()
+	lock, ok := r.current(lockPath, now)
+	if !ok {
+		return model.ObjectLock{}, notLockedError(lockPath)
+	} else if lock.Owner != owner {
+		return model.ObjectLock{}, lockedError(lock)
+	}
+
+	lock.ExpiresAt = now.Add(clampTtl(ttl))
+	r.locks[lockPath] = lock
+	return lock, nil
+}
+
+// Release - release the lock held by owner on the received path
+func (r *ClientLockRegistry) Release(lockPath string, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockPath = normalize(lockPath)
+	lock, ok := r.current(lockPath, time.Now())
+	if !ok {
+		return notLockedError(lockPath)
+	} else if lock.Owner != owner {
+		return lockedError(lock)
+	}
+
+	delete(r.locks, lockPath)
+	return nil
+}
+
+// ForceRelease - release the lock on the received path whoever holds it, returning the released lock
+func (r *ClientLockRegistry) ForceRelease(lockPath string) (model.ObjectLock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockPath = normalize(lockPath)
+	lock, ok := r.current(lockPath, time.Now())
+	if !ok {
+		return model.ObjectLock{}, notLockedError(lockPath)
+	}
+
+	delete(r.locks, lockPath)
+	return lock, nil
+}
+
+// Get - return the lock currently held on the received path
+func (r *ClientLockRegistry) Get(lockPath string) (model.ObjectLock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lockPath = normalize(lockPath)
+	lock, ok := r.current(lockPath, time.Now())
+	if !ok {
+		return model.ObjectLock{}, notLockedError(lockPath)
+	}
+	return lock, nil
+}
+
+// CheckOwner - return an object_locked error if any of the received paths, or any object under them, is locked by
+// someone other than owner: an operation on a folder covers the objects a client has locked in it
+func (r *ClientLockRegistry) CheckOwner(owner string, lockPaths ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, lockPath := range lockPaths {
+		lockPath = normalize(lockPath)
+		for lockedPath := range r.locks {
+			if lockedPath != lockPath && !isAncestor(lockPath, lockedPath) {
+				continue
+			}
+			if lock, ok := r.current(lockedPath, now); ok && lock.Owner != owner {
+				return lockedError(lock)
+			}
+		}
+	}
+	return nil
+}
+
+// current - return the unexpired lock on the received path, dropping it if expired. must be called holding mu
+func (r *ClientLockRegistry) current(lockPath string, now time.Time) (model.ObjectLock, bool) {
+	lock, ok := r.locks[lockPath]
+	if ok && !now.Before(lock.ExpiresAt) {
+		delete(r.locks, lockPath)
+		return model.ObjectLock{}, false
+	}
+	return lock, ok
+}
+
+// clampTtl - return the received ttl bounded to the allowed range, the default one if not positive
+func clampTtl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return DefaultClientLockTtl
+	} else if ttl > MaxClientLockTtl {
+		return MaxClientLockTtl
+	}
+	return ttl
+}
+
+// lockedError - return the error describing an operation rejected because of the received lock
+func lockedError(lock model.ObjectLock) error {
+	return nxfserrors.New(nxfserrors.ErrLocked, "object_locked",
+		fmt.Sprintf("The object /%s is locked by %s until %s", lock.Path, lock.Owner, lock.ExpiresAt.Format(time.RFC3339)))
+}
+
+// notLockedError - return the error describing a missing lock
+func notLockedError(lockPath string) error {
+	return nxfserrors.New(nxfserrors.ErrNotFound, "lock_not_found", fmt.Sprintf("The object /%s is not locked", lockPath))
+}
@@ -0,0 +1,53 @@
+package nxfslock
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"testing"
+	"time"
+)
+
+func TestCheckOwner(t *testing.T) {
+	registry := NewClientLockRegistry()
+	if _, err := registry.Acquire("docs/sub/a.txt", "alice", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		owner   string
+		paths   []string
+		blocked bool
+	}{
+		{"the locked object", "bob", []string{"docs/sub/a.txt"}, true},
+		{"the owner", "alice", []string{"docs/sub/a.txt", "docs"}, false},
+		{"the folder of the locked object", "bob", []string{"docs/sub"}, true},
+		{"an ancestor of the locked object", "bob", []string{"other", "docs"}, true},
+		{"the root", "bob", []string{"."}, true},
+		{"a sibling", "bob", []string{"docs/sub/b.txt"}, false},
+		{"a common prefix is not a folder", "bob", []string{"docs/su"}, false},
+		{"an object under the locked one", "bob", []string{"docs/sub/a.txt/x"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := registry.CheckOwner(c.owner, c.paths...)
+			if blocked := errors.Is(err, nxfserrors.ErrLocked); blocked != c.blocked {
+				t.Fatalf("expected blocked %v, got %v", c.blocked, err)
+			}
+		})
+	}
+
+	// an expired lock under a folder doesn't block it
+	if _, err := registry.Acquire("docs/old.txt", "carol", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	registry.mu.Lock()
+	lock := registry.locks["docs/old.txt"]
+	lock.ExpiresAt = time.Now().Add(-time.Second)
+	registry.locks["docs/old.txt"] = lock
+	registry.mu.Unlock()
+	if err := registry.CheckOwner("alice", "docs"); err != nil {
+		t.Fatalf("expected the expired lock to be ignored, got %v", err)
+	}
+}
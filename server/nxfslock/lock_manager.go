@@ -0,0 +1,231 @@
+package nxfslock
+
+import (
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode - the kind of access requested on a path
+type Mode int
+
+// List of Mode
+const (
+	Read Mode = iota
+	Write
+)
+
+// Request - a path, relative to the browsable fs root, to lock in a mode
+type Request struct {
+	Path string
+	Mode Mode
+	// Scan - the path is locked only to be searched, the client locks on the objects under it don't apply
+	Scan bool
+}
+
+// ReadRequest - return a Request to lock the received path for reading
+func ReadRequest(lockPath string) Request {
+	return Request{Path: lockPath, Mode: Read}
+}
+
+// ScanRequest - return a Request to lock the received path for reading while searching it, e.g. for the pages
+// referencing an object, without touching the objects under it
+func ScanRequest(lockPath string) Request {
+	return Request{Path: lockPath, Mode: Read, Scan: true}
+}
+
+// WriteRequest - return a Request to lock the received path for writing
+func WriteRequest(lockPath string) Request {
+	return Request{Path: lockPath, Mode: Write}
+}
+
+// pathState - the locks currently held on a path
+type pathState struct {
+	readers int
+	writer  bool
+}
+
+// Manager - a reader/writer lock manager aware of the path hierarchy: a lock on a path conflicts with the locks
+// on the same path, on its ancestors and on its descendants when at least one of them is a write lock
+type Manager struct {
+	mu      sync.Mutex
+	held    map[string]*pathState
+	changed chan struct{}
+	timeout time.Duration
+}
+
+// NewManager - create a Manager whose acquisitions wait at most the received timeout
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{
+		held:    map[string]*pathState{},
+		changed: make(chan struct{}),
+		timeout: timeout,
+	}
+}
+
+// scopeKey - the key of the locks held by a Scope in the contexts it returns
+type scopeKey struct{}
+
+// scope - the locks held by a caller of Scope on a Manager
+type scope struct {
+	manager  *Manager
+	requests []Request
+}
+
+// Lock - acquire all the requested locks at once, waiting until none of them conflicts with the held ones.
+// the locks covered by a Scope of the received context are already held and aren't acquired again.
+// return the function releasing them, or a lock_timeout error if they can't be acquired in time
+func (m *Manager) Lock(ctx context.Context, lockRequests ...Request) (release func(), err error) {
+	held, _ := ctx.Value(scopeKey{}).(*scope)
+	requests := make([]Request, 0, len(lockRequests))
+	for _, request := range lockRequests {
+		request = Request{Path: normalize(request.Path), Mode: request.Mode}
+		if held == nil || held.manager != m || !held.covers(request) {
+			requests = append(requests, request)
+		}
+	}
+	if len(requests) == 0 {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	for {
+		m.mu.Lock()
+		if !m.conflicts(requests) {
+			m.acquire(requests)
+			m.mu.Unlock()
+			return m.releaseFunc(requests), nil
+		}
+		changed := m.changed
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, nxfserrors.New(nxfserrors.ErrConflict, "lock_timeout",
+				fmt.Sprintf("Timed out after %s waiting for another operation on %s", m.timeout, describe(requests)))
+		case <-ctx.Done():
+			return nil, nxfserrors.New(nxfserrors.ErrConflict, "lock_cancelled", "The request was cancelled while waiting for a lock")
+		}
+	}
+}
+
+// Scope - acquire the requested locks like Lock, returning also a context in which they're held: the locks they cover,
+// requested on the same paths or on their descendants, are acquired at once without waiting for the holder itself.
+// it lets an operation execute other ones keeping their paths locked between them; the context must not outlive the release
+func (m *Manager) Scope(ctx context.Context, lockRequests ...Request) (context.Context, func(), error) {
+	release, err := m.Lock(ctx, lockRequests...)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	held := &scope{manager: m}
+	if outer, ok := ctx.Value(scopeKey{}).(*scope); ok && outer.manager == m {
+		held.requests = append(held.requests, outer.requests...)
+	}
+	for _, request := range lockRequests {
+		held.requests = append(held.requests, Request{Path: normalize(request.Path), Mode: request.Mode})
+	}
+	return context.WithValue(ctx, scopeKey{}, held), release, nil
+}
+
+// covers - return true if a held lock includes the request: a write lock includes any request on its path and on its
+// descendants, a read lock only the read requests
+func (s *scope) covers(request Request) bool {
+	for _, held := range s.requests {
+		if (held.Path == request.Path || isAncestor(held.Path, request.Path)) && (held.Mode == Write || request.Mode == Read) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflicts - return true if any of the requests conflicts with the held locks. must be called holding mu
+func (m *Manager) conflicts(requests []Request) bool {
+	for _, request := range requests {
+		for heldPath, state := range m.held {
+			if !related(request.Path, heldPath) {
+				continue
+			}
+			if request.Mode == Write || state.writer {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acquire - register the requests as held. must be called holding mu
+func (m *Manager) acquire(requests []Request) {
+	for _, request := range requests {
+		state, ok := m.held[request.Path]
+		if !ok {
+			state = &pathState{}
+			m.held[request.Path] = state
+		}
+		if request.Mode == Write {
+			state.writer = true
+		} else {
+			state.readers++
+		}
+	}
+}
+
+// releaseFunc - return a function releasing the received requests and waking up the waiting acquisitions, only the first call has effect
+func (m *Manager) releaseFunc(requests []Request) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			for _, request := range requests {
+				state, ok := m.held[request.Path]
+				if !ok {
+					continue
+				}
+				if request.Mode == Write {
+					state.writer = false
+				} else {
+					state.readers--
+				}
+				if !state.writer && state.readers == 0 {
+					delete(m.held, request.Path)
+				}
+			}
+
+			close(m.changed)
+			m.changed = make(chan struct{})
+		})
+	}
+}
+
+// normalize - return the canonical form of a lock path, the root being the empty string
+func normalize(lockPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.Replace(lockPath, "\\", "/", -1)), "/")
+}
+
+// related - return true if the received paths are the same path or one is an ancestor of the other
+func related(first string, second string) bool {
+	return first == second || isAncestor(first, second) || isAncestor(second, first)
+}
+
+// isAncestor - return true if ancestor is a proper ancestor of descendant
+func isAncestor(ancestor string, descendant string) bool {
+	return "" == ancestor || strings.HasPrefix(descendant, ancestor+"/")
+}
+
+// describe - return a human readable list of the requested paths
+func describe(requests []Request) string {
+	paths := make([]string, 0, len(requests))
+	for _, request := range requests {
+		paths = append(paths, "/"+request.Path)
+	}
+	return strings.Join(paths, ", ")
+}
@@ -0,0 +1,200 @@
+package nxfslock
+
+import (
+	"context"
+	"errors"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const shortTimeout = 50 * time.Millisecond
+
+func TestConflicts(t *testing.T) {
+	cases := []struct {
+		name      string
+		held      Request
+		requested Request
+		conflict  bool
+	}{
+		{"readers share a path", ReadRequest("a/b"), ReadRequest("a/b"), false},
+		{"writer excludes reader", WriteRequest("a/b"), ReadRequest("a/b"), true},
+		{"reader excludes writer", ReadRequest("a/b"), WriteRequest("a/b"), true},
+		{"directory delete excludes write inside it", WriteRequest("a"), WriteRequest("a/b/c.txt"), true},
+		{"write inside excludes directory delete", WriteRequest("a/b/c.txt"), WriteRequest("a"), true},
+		{"browse excludes write inside it", ReadRequest("a"), WriteRequest("a/b.txt"), true},
+		{"read inside and read of the parent share", ReadRequest("a/b.txt"), ReadRequest("a"), false},
+		{"siblings don't conflict", WriteRequest("a/b"), WriteRequest("a/c"), false},
+		{"common prefix is not an ancestor", WriteRequest("a/b"), WriteRequest("a/bc"), false},
+		{"root write excludes everything", WriteRequest("."), ReadRequest("a/b"), true},
+		{"equivalent paths are normalized", WriteRequest("a//b/"), ReadRequest("./a/b"), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manager := NewManager(shortTimeout)
+			release, err := manager.Lock(context.Background(), c.held)
+			if err != nil {
+				t.Fatalf("unexpected error acquiring the held lock: %v", err)
+			}
+			defer release()
+
+			secondRelease, err := manager.Lock(context.Background(), c.requested)
+			if c.conflict {
+				if !errors.Is(err, nxfserrors.ErrConflict) {
+					t.Fatalf("expected a conflict error, got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("expected no conflict, got %v", err)
+				}
+				secondRelease()
+			}
+		})
+	}
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	manager := NewManager(time.Second)
+	release, err := manager.Lock(context.Background(), WriteRequest("dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(shortTimeout)
+		release()
+	}()
+
+	secondRelease, err := manager.Lock(context.Background(), WriteRequest("dir/file.txt"))
+	if err != nil {
+		t.Fatalf("expected the lock to be acquired after the release, got %v", err)
+	}
+	secondRelease()
+}
+
+func TestLockTimeout(t *testing.T) {
+	manager := NewManager(shortTimeout)
+	release, err := manager.Lock(context.Background(), WriteRequest("page.page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	start := time.Now()
+	_, err = manager.Lock(context.Background(), ReadRequest("page.page"))
+
+	var nxfsErr *nxfserrors.Error
+	if !errors.As(err, &nxfsErr) || nxfsErr.Code != "lock_timeout" {
+		t.Fatalf("expected a lock_timeout error, got %v", err)
+	}
+	if time.Since(start) < shortTimeout {
+		t.Fatalf("the lock failed before the timeout")
+	}
+}
+
+func TestLockCancelled(t *testing.T) {
+	manager := NewManager(time.Minute)
+	release, err := manager.Lock(context.Background(), WriteRequest("page.page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shortTimeout)
+	defer cancel()
+
+	if _, err = manager.Lock(ctx, WriteRequest("page.page")); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestMultipleRequestsAreAcquiredAtOnce(t *testing.T) {
+	manager := NewManager(shortTimeout)
+	release, err := manager.Lock(context.Background(), WriteRequest("pages/home.page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the draft must not stay locked when the published page can't be locked
+	if _, err = manager.Lock(context.Background(), ReadRequest("draft_pages/home.page"), WriteRequest("pages/home.page")); err == nil {
+		t.Fatal("expected a conflict on the published page")
+	}
+	if secondRelease, err := manager.Lock(context.Background(), WriteRequest("draft_pages/home.page")); err != nil {
+		t.Fatalf("the draft page was left locked: %v", err)
+	} else {
+		secondRelease()
+	}
+	release()
+}
+
+func TestConcurrentWritersAreSerialized(t *testing.T) {
+	manager := NewManager(10 * time.Second)
+
+	// the race detector reports the unsynchronized accesses to counter if the writers overlap
+	counter := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			lockPath := "dir/file.txt"
+			if i%2 == 0 {
+				lockPath = "dir"
+			}
+			release, err := manager.Lock(context.Background(), WriteRequest(lockPath))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+
+			counter++
+		}(i)
+	}
+	wg.Wait()
+
+	if counter != 50 {
+		t.Fatalf("expected 50 increments, got %d", counter)
+	}
+}
+
+func TestScope(t *testing.T) {
+	manager := NewManager(shortTimeout)
+	ctx, release, err := manager.Scope(context.Background(), WriteRequest("batch"), ReadRequest("draft_pages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the operations executed in the scope acquire the covered locks without waiting for it
+	for _, request := range []Request{WriteRequest("batch"), WriteRequest("batch/a.txt"), ReadRequest("./batch/b"), ReadRequest("draft_pages/home.page")} {
+		nestedRelease, err := manager.Lock(ctx, request)
+		if err != nil {
+			t.Fatalf("expected %v to be covered by the scope, got %v", request, err)
+		}
+		nestedRelease()
+	}
+	// a read lock doesn't cover the writes
+	if _, err = manager.Lock(ctx, WriteRequest("draft_pages/home.page")); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected a conflict writing under a read lock of the scope, got %v", err)
+	}
+	// the other operations wait for the scope, even after the release of a nested lock
+	if _, err = manager.Lock(context.Background(), ReadRequest("batch/a.txt")); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected a conflict outside of the scope, got %v", err)
+	}
+	other := NewManager(shortTimeout)
+	otherRelease, _ := other.Lock(context.Background(), ReadRequest("batch"))
+	if _, err = other.Lock(ctx, WriteRequest("batch")); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected the scope not to cover the locks of other managers, got %v", err)
+	}
+	otherRelease()
+
+	release()
+	if otherRelease, err := manager.Lock(context.Background(), WriteRequest("batch/a.txt")); err != nil {
+		t.Fatalf("expected the locks to be released with the scope, got %v", err)
+	} else {
+		otherRelease()
+	}
+}
@@ -0,0 +1,176 @@
+package nxfsmime
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Default - the type of the files whose type is unknown
+const Default = "application/octet-stream"
+
+// SniffLength - how many bytes of the content are enough to detect its type
+const SniffLength = 512
+
+// executable types, detected from the content whatever the extension of the file
+const (
+	Executable         = "application/x-executable"
+	PortableExecutable = "application/vnd.microsoft.portable-executable"
+	MachBinary         = "application/x-mach-binary"
+	ShellScript        = "text/x-shellscript"
+)
+
+// extensionTypes - the types of the extensions used by the sites, so that they don't depend on the mime.types of the host
+var extensionTypes = map[string]string{
+	".page":  "application/vnd.entando.page+json",
+	".ftl":   "text/x-freemarker",
+	".html":  "text/html",
+	".htm":   "text/html",
+	".css":   "text/css",
+	".js":    "text/javascript",
+	".mjs":   "text/javascript",
+	".json":  "application/json",
+	".xml":   "application/xml",
+	".txt":   "text/plain",
+	".md":    "text/markdown",
+	".csv":   "text/csv",
+	".png":   "image/png",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".gif":   "image/gif",
+	".webp":  "image/webp",
+	".avif":  "image/avif",
+	".svg":   "image/svg+xml",
+	".ico":   "image/x-icon",
+	".pdf":   "application/pdf",
+	".zip":   "application/zip",
+	".gz":    "application/gzip",
+	".tar":   "application/x-tar",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".mp3":   "audio/mpeg",
+	".mp4":   "video/mp4",
+	".webm":  "video/webm",
+	".exe":   PortableExecutable,
+	".dll":   PortableExecutable,
+	".sh":    ShellScript,
+}
+
+// executableMagics - the leading bytes of the executables
+var executableMagics = []struct {
+	magic    []byte
+	mimeType string
+}{
+	{[]byte("\x7fELF"), Executable},
+	{[]byte("MZ"), PortableExecutable},
+	{[]byte("\xfe\xed\xfa\xce"), MachBinary},
+	{[]byte("\xfe\xed\xfa\xcf"), MachBinary},
+	{[]byte("\xce\xfa\xed\xfe"), MachBinary},
+	{[]byte("\xcf\xfa\xed\xfe"), MachBinary},
+	{[]byte("#!"), ShellScript},
+}
+
+// FromExtension - return the type corresponding to the extension of the received file name, an empty string if it's unknown
+func FromExtension(name string) string {
+	extension := strings.ToLower(path.Ext(name))
+	if "" == extension {
+		return ""
+	}
+	if mimeType, ok := extensionTypes[extension]; ok {
+		return mimeType
+	}
+	return baseType(mime.TypeByExtension(extension))
+}
+
+// Detect - return the type of a file from its name and its content: executables are recognized by their content, whatever
+// their name, the other files by their extension or, if it's unknown, by sniffing their content
+func Detect(name string, content []byte) string {
+	if len(content) > SniffLength {
+		content = content[:SniffLength]
+	}
+	for _, executable := range executableMagics {
+		if bytes.HasPrefix(content, executable.magic) && (executable.mimeType != PortableExecutable || isBinary(content)) {
+			return executable.mimeType
+		}
+	}
+	if mimeType := FromExtension(name); "" != mimeType {
+		return mimeType
+	}
+	if len(content) == 0 {
+		return Default
+	}
+	return baseType(http.DetectContentType(content))
+}
+
+// Sniffer - an io.Writer keeping the beginning of the content written to it, enough to detect its type
+type Sniffer struct {
+	head []byte
+}
+
+// Write - implement io.Writer
+func (s *Sniffer) Write(p []byte) (int, error) {
+	if missing := SniffLength - len(s.head); missing > 0 {
+		if missing > len(p) {
+			missing = len(p)
+		}
+		s.head = append(s.head, p[:missing]...)
+	}
+	return len(p), nil
+}
+
+// Detect - return the type of the content written so far to a file with the received name, as Detect does
+func (s *Sniffer) Detect(name string) string {
+	return Detect(name, s.head)
+}
+
+// Matches - return true if the type or the name of a file match a pattern: a case insensitive extension like .png,
+// a type like image/png or all the types of a kind like image/*
+func Matches(name string, mimeType string, pattern string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	switch {
+	case strings.HasPrefix(pattern, "."):
+		return strings.ToLower(path.Ext(name)) == pattern
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(mimeType, pattern[:len(pattern)-1])
+	default:
+		return mimeType == pattern
+	}
+}
+
+// MatchesAny - return true if the type or the name of a file match any of the received patterns
+func MatchesAny(name string, mimeType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if Matches(name, mimeType, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSafeInline - return true if the browsers can display contents of the received type without running the scripts
+// they may carry: the images, except the SVG ones
+func IsSafeInline(mimeType string) bool {
+	mimeType = baseType(mimeType)
+	return strings.HasPrefix(mimeType, "image/") && "image/svg+xml" != mimeType
+}
+
+// isBinary - return true if the received content has zero bytes in its header, so that a text starting with MZ isn't an executable
+func isBinary(content []byte) bool {
+	header := content
+	if len(header) > 64 {
+		header = header[:64]
+	}
+	return bytes.IndexByte(header, 0) >= 0
+}
+
+// baseType - return the received type without its parameters
+func baseType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return strings.TrimSpace(strings.Split(mimeType, ";")[0])
+}
@@ -0,0 +1,126 @@
+package nxfsmime
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"home.page", `{"title":"home"}`, "application/vnd.entando.page+json"},
+		{"LOGO.PNG", "not really a png", "image/png"},
+		{"readme", "just some text", "text/plain"},
+		{"logo", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png"},
+		{"empty", "", Default},
+		{"notes.txt", "MZ is not an executable without binary content", "text/plain"},
+		// executables are detected whatever their name
+		{"image.png", "\x7fELF\x02\x01\x01\x00", Executable},
+		{"setup.txt", "MZ\x90\x00\x03\x00\x00\x00", PortableExecutable},
+		{"install.page", "#!/bin/sh\nrm -rf /\n", ShellScript},
+		{"setup.exe", "", PortableExecutable},
+	} {
+		if actual := Detect(test.name, []byte(test.content)); actual != test.expected {
+			t.Errorf("expected %s to be detected as %s, got %s", test.name, test.expected, actual)
+		}
+	}
+
+	var sniffer Sniffer
+	if _, err := io.Copy(&sniffer, strings.NewReader("#!/bin/sh\n"+strings.Repeat("echo\n", 200))); err != nil {
+		t.Fatal(err)
+	}
+	if len(sniffer.head) != SniffLength {
+		t.Fatalf("expected the sniffer to keep %d bytes, got %d", SniffLength, len(sniffer.head))
+	}
+	if actual := sniffer.Detect("script.txt"); actual != ShellScript {
+		t.Fatalf("expected the sniffed content to be detected as %s, got %s", ShellScript, actual)
+	}
+
+	for mimeType, expected := range map[string]bool{"image/png": true, "image/jpeg; q=1": true, "image/svg+xml": false, "text/html": false, Default: false} {
+		if IsSafeInline(mimeType) != expected {
+			t.Errorf("expected %s to be displayed inline: %v", mimeType, expected)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		mimeType string
+		pattern  string
+		expected bool
+	}{
+		{"home.page", "application/vnd.entando.page+json", ".page", true},
+		{"HOME.PAGE", "application/vnd.entando.page+json", ".page", true},
+		{"home.page", "application/vnd.entando.page+json", ".pag", false},
+		{"logo.png", "image/png", "image/*", true},
+		{"logo.png", "image/png", " Image/PNG ", true},
+		{"logo.png", "image/png", "image/jpeg", false},
+		{"style.css", "text/css", "image/*", false},
+	} {
+		if actual := Matches(test.name, test.mimeType, test.pattern); actual != test.expected {
+			t.Errorf("expected %s (%s) matching %q to be %v", test.name, test.mimeType, test.pattern, test.expected)
+		}
+	}
+	if !MatchesAny("logo.png", "image/png", []string{".page", "image/*"}) || MatchesAny("logo.png", "image/png", nil) {
+		t.Fatal("expected MatchesAny to match any of the patterns")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	rules, err := helper.ParseTypeRules(`[
+		{"path": "draft_pages", "allow": [".page"]},
+		{"path": "assets/", "allow": ["image/*", "text/css"], "deny": ["image/svg+xml"]},
+		{"path": "/", "deny": ["application/x-executable", ".exe"]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := NewPolicy(rules)
+
+	if err := policy.Check(
+		File{Path: "draft_pages/news/a.page", MimeType: "application/vnd.entando.page+json"},
+		File{Path: "/assets/logo.png", MimeType: "image/png"},
+		File{Path: "draft_pages_old/readme.txt", MimeType: "text/plain"},
+		File{Path: "readme.txt", MimeType: "text/plain"},
+	); err != nil {
+		t.Fatalf("expected the files to be accepted, got %v", err)
+	}
+
+	err = policy.Check(
+		File{Path: "draft_pages/readme.txt", MimeType: "text/plain"},
+		File{Path: "assets/logo.svg", MimeType: "image/svg+xml"},
+		File{Path: "assets/logo.png", MimeType: "image/png"},
+		File{Path: "tools/run", MimeType: "application/x-executable"},
+		File{Path: "setup.exe", MimeType: PortableExecutable},
+	)
+	var typeErr *nxfserrors.Error
+	if !errors.As(err, &typeErr) || typeErr.Kind != nxfserrors.ErrUnsupportedType || typeErr.Code != "type_not_allowed" {
+		t.Fatalf("expected a type_not_allowed error, got %v", err)
+	}
+	expected := []model.ResultDetail{
+		{Field: "draft_pages/readme.txt", Message: "text/plain files are not allowed in draft_pages"},
+		{Field: "assets/logo.svg", Message: "image/svg+xml files are denied in assets"},
+		{Field: "tools/run", Message: "application/x-executable files are denied in ."},
+		{Field: "setup.exe", Message: PortableExecutable + " files are denied in ."},
+	}
+	if !reflect.DeepEqual(typeErr.Details, expected) {
+		t.Fatalf("expected the details %v, got %v", expected, typeErr.Details)
+	}
+
+	if _, err := helper.ParseTypeRules(`[{"path": "assets", "allow": ["png"]}]`); err == nil {
+		t.Fatal("expected a type that is neither an extension nor a MIME type to be rejected")
+	}
+	if _, err := helper.ParseTypeRules(`[{"path": "../assets", "deny": [".exe"]}]`); err == nil {
+		t.Fatal("expected a folder outside the root to be rejected")
+	}
+}
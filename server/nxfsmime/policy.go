@@ -0,0 +1,54 @@
+package nxfsmime
+
+import (
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"strings"
+)
+
+// File - a file about to be written, with its detected type
+type File struct {
+	Path     string
+	MimeType string
+}
+
+// Policy - the types of files the folders of a browsable fs accept
+type Policy struct {
+	rules []helper.TypeRule
+}
+
+// NewPolicy - create the policy enforcing the received rules
+func NewPolicy(rules []helper.TypeRule) Policy {
+	return Policy{rules: rules}
+}
+
+// Check - return a type_not_allowed error, listing the rejected files, if any of the received files is denied by a rule of
+// a folder containing it, or isn't allowed by a folder restricting the types it accepts
+func (p Policy) Check(files ...File) error {
+	var details []model.ResultDetail
+	for _, file := range files {
+		relPath := strings.TrimPrefix(file.Path, "/")
+		for _, rule := range p.rules {
+			if "." != rule.Path && !strings.HasPrefix(relPath, rule.Path+"/") {
+				continue
+			}
+			if MatchesAny(relPath, file.MimeType, rule.Deny) {
+				details = append(details, model.ResultDetail{Field: relPath, Message: fmt.Sprintf("%s files are denied in %s", file.MimeType, rule.Path)})
+				break
+			}
+			if len(rule.Allow) > 0 && !MatchesAny(relPath, file.MimeType, rule.Allow) {
+				details = append(details, model.ResultDetail{Field: relPath, Message: fmt.Sprintf("%s files are not allowed in %s", file.MimeType, rule.Path)})
+				break
+			}
+		}
+	}
+
+	if len(details) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrUnsupportedType, "type_not_allowed", "The type of some files is not allowed in their folder, nothing has been written")
+		err.Details = details
+		return err
+	}
+	return nil
+}
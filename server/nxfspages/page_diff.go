@@ -0,0 +1,233 @@
+package nxfspages
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfsdiff"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// kinds of change of a frame and of its widget
+const (
+	changeAdded        = "added"
+	changeRemoved      = "removed"
+	changeChanged      = "changed"
+	changeReplaced     = "replaced"
+	changeReconfigured = "reconfigured"
+)
+
+// DiffPage - compare the draft of the received page with its published copy, returning the unified diff from the published
+// page to the draft and, when both are valid page documents, the semantic diff of their properties and frames
+func DiffPage(storage nxfsstorage.Backend, pagePath string) (model.PageDiff, error) {
+	pagePath = AddPageSuffix(pagePath)
+
+	draft, draftExists, err := readOptionalPage(storage, path.Join(helper.GetDraftPagesRelativePath(), pagePath))
+	if err != nil {
+		return model.PageDiff{}, err
+	}
+	published, publishedExists, err := readOptionalPage(storage, path.Join(helper.GetPublishedPagesRelativePath(), pagePath))
+	if err != nil {
+		return model.PageDiff{}, err
+	}
+	if !draftExists && !publishedExists {
+		return model.PageDiff{}, nxfserrors.New(nxfserrors.ErrNotFound, "page_not_found", fmt.Sprintf("The page %s is neither a draft nor published", pagePath))
+	}
+
+	pageDiff := model.PageDiff{Path: pagePath, Status: diffStatus(draft, draftExists, published, publishedExists)}
+	if pageDiff.Status == model.PageUnchanged {
+		return pageDiff, nil
+	}
+
+	pageDiff.Unified = nxfsdiff.Unified(filepath.ToSlash(filepath.Join(helper.GetPublishedPagesRelativePath(), pagePath)),
+		filepath.ToSlash(filepath.Join(helper.GetDraftPagesRelativePath(), pagePath)), string(published), string(draft), nxfsdiff.DefaultContext)
+	pageDiff.Semantic = semanticDiff(published, publishedExists, draft, draftExists)
+	return pageDiff, nil
+}
+
+// DiffPages - list the pages under the received directory, relative to the pages folders, whose draft differs from the published copy
+func DiffPages(storage nxfsstorage.Backend, dir string) ([]model.PageDiff, error) {
+	pages := map[string]bool{}
+	for _, root := range []string{helper.GetDraftPagesRelativePath(), helper.GetPublishedPagesRelativePath()} {
+		if err := collectPages(storage, root, dir, pages); err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]string, 0, len(pages))
+	for page := range pages {
+		sorted = append(sorted, page)
+	}
+	sort.Strings(sorted)
+
+	diffs := make([]model.PageDiff, 0)
+	for _, page := range sorted {
+		draftHash := nxfsstorage.Hash(storage, path.Join(helper.GetDraftPagesRelativePath(), page))
+		publishedHash := nxfsstorage.Hash(storage, path.Join(helper.GetPublishedPagesRelativePath(), page))
+		switch {
+		case draftHash == publishedHash:
+			continue
+		case "" == publishedHash:
+			diffs = append(diffs, model.PageDiff{Path: page, Status: model.PageAdded})
+		case "" == draftHash:
+			diffs = append(diffs, model.PageDiff{Path: page, Status: model.PageRemoved})
+		default:
+			diffs = append(diffs, model.PageDiff{Path: page, Status: model.PageModified})
+		}
+	}
+	return diffs, nil
+}
+
+// collectPages - add to pages the page documents found under dir in the received pages folder, as paths relative to it
+func collectPages(storage nxfsstorage.Backend, root string, dir string, pages map[string]bool) error {
+	err := nxfsstorage.Walk(storage, path.Join(root, dir), func(pagePath string, fileInfo os.FileInfo) error {
+		if !fileInfo.IsDir() && IsPage(fileInfo.Name()) {
+			pages[strings.TrimPrefix(pagePath, root+"/")] = true
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nxfserrors.FromOS(err, "dir_listing_err", "An error occurred during the listing of the pages")
+	}
+	return nil
+}
+
+// readOptionalPage - read a page file, returning false if it doesn't exist
+func readOptionalPage(storage nxfsstorage.Backend, pagePath string) ([]byte, bool, error) {
+	content, _, err := storage.ReadFile(pagePath)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, nxfserrors.FromOS(err, "page_read_error", "An error occurred during the reading of the page")
+	}
+	return content, true, nil
+}
+
+// diffStatus - return how the draft differs from the published page
+func diffStatus(draft []byte, draftExists bool, published []byte, publishedExists bool) model.PageDiffStatus {
+	switch {
+	case !publishedExists:
+		return model.PageAdded
+	case !draftExists:
+		return model.PageRemoved
+	case bytes.Equal(draft, published):
+		return model.PageUnchanged
+	}
+	return model.PageModified
+}
+
+// semanticDiff - return the changes of the page document from the published page to the draft, a missing side being an empty
+// document. nil if any of the existing sides is not a valid page document
+func semanticDiff(published []byte, publishedExists bool, draft []byte, draftExists bool) *model.PageSemanticDiff {
+	var before, after model.PageDocument
+	var err error
+	if publishedExists {
+		if before, err = ParsePage(published); err != nil {
+			return nil
+		}
+	}
+	if draftExists {
+		if after, err = ParsePage(draft); err != nil {
+			return nil
+		}
+	}
+
+	semantic := &model.PageSemanticDiff{Properties: []model.PagePropertyChange{}, Frames: []model.PageFrameChange{}}
+	for _, property := range []struct {
+		field  string
+		before string
+		after  string
+	}{
+		{"schemaVersion", versionString(before.SchemaVersion, publishedExists), versionString(after.SchemaVersion, draftExists)},
+		{"title", before.Title, after.Title},
+		{"description", before.Description, after.Description},
+		{"template", before.Template, after.Template},
+	} {
+		if property.before != property.after {
+			semantic.Properties = append(semantic.Properties, model.PagePropertyChange{Field: property.field, Before: property.before, After: property.after})
+		}
+	}
+
+	semantic.Frames = frameChanges(before.Frames, after.Frames)
+	return semantic
+}
+
+// frameChanges - return the changes of the frames, matched by position, sorted by position
+func frameChanges(before []model.PageFrame, after []model.PageFrame) []model.PageFrameChange {
+	beforeByPos := map[int]model.PageFrame{}
+	positions := map[int]bool{}
+	for _, frame := range before {
+		beforeByPos[frame.Pos] = frame
+		positions[frame.Pos] = true
+	}
+	afterByPos := map[int]model.PageFrame{}
+	for _, frame := range after {
+		afterByPos[frame.Pos] = frame
+		positions[frame.Pos] = true
+	}
+
+	sorted := make([]int, 0, len(positions))
+	for pos := range positions {
+		sorted = append(sorted, pos)
+	}
+	sort.Ints(sorted)
+
+	changes := []model.PageFrameChange{}
+	for _, pos := range sorted {
+		beforeFrame, inBefore := beforeByPos[pos]
+		afterFrame, inAfter := afterByPos[pos]
+		switch {
+		case !inBefore:
+			changes = append(changes, model.PageFrameChange{Pos: pos, Change: changeAdded, After: &afterFrame})
+		case !inAfter:
+			changes = append(changes, model.PageFrameChange{Pos: pos, Change: changeRemoved, Before: &beforeFrame})
+		default:
+			widgetChange := widgetChange(beforeFrame.Widget, afterFrame.Widget)
+			if beforeFrame.Name != afterFrame.Name || "" != widgetChange {
+				changes = append(changes, model.PageFrameChange{Pos: pos, Change: changeChanged, Widget: widgetChange, Before: &beforeFrame, After: &afterFrame})
+			}
+		}
+	}
+	return changes
+}
+
+// widgetChange - return how the widget of a frame changed, an empty string if it didn't
+func widgetChange(before *model.PageWidget, after *model.PageWidget) string {
+	switch {
+	case before == nil && after == nil:
+		return ""
+	case before == nil:
+		return changeAdded
+	case after == nil:
+		return changeRemoved
+	case before.Code != after.Code:
+		return changeReplaced
+	case !reflect.DeepEqual(emptyIfNil(before.Config), emptyIfNil(after.Config)):
+		return changeReconfigured
+	}
+	return ""
+}
+
+// emptyIfNil - return an empty config in place of a missing one, so that the two compare equal
+func emptyIfNil(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return map[string]interface{}{}
+	}
+	return config
+}
+
+// versionString - format a schema version, an empty string for a missing document
+func versionString(version int, exists bool) string {
+	if !exists {
+		return ""
+	}
+	return fmt.Sprintf("%d", version)
+}
@@ -0,0 +1,105 @@
+package nxfspages
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var parsedSchemas map[int]*schemaNode
+var parseSchemasOnce sync.Once
+
+// IsPage - return true if the received path identifies a page document
+func IsPage(pagePath string) bool {
+	return strings.HasSuffix(pagePath, pageSuffix)
+}
+
+// IsDraftPage - return true if the received path, relative to the browsable fs root, identifies a page document in the draft pages folder
+func IsDraftPage(relPath string) bool {
+	rel, err := filepath.Rel(helper.GetDraftPagesRelativePath(), filepath.Clean(relPath))
+	return err == nil && IsPage(rel) && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// GetPageSchema - return the JSON schema of the received page schema version
+func GetPageSchema(version int) (json.RawMessage, bool) {
+	schema, ok := pageSchemas[version]
+	return json.RawMessage(schema), ok
+}
+
+// ValidatePage - validate the content of a page document against the schema of its version. return an invalid_page error listing
+// every violation as a ResultDetail, nil if the document is valid
+func ValidatePage(content []byte) error {
+	parseSchemasOnce.Do(func() {
+		parsedSchemas = map[int]*schemaNode{}
+		for version, schema := range pageSchemas {
+			parsedSchemas[version] = parseSchema(schema)
+		}
+	})
+
+	document, err := decodeJSON(content)
+	if err != nil {
+		return invalidPageError(detail("", "is not a valid JSON document: "+err.Error()))
+	}
+
+	version := CurrentPageSchemaVersion
+	if object, ok := document.(map[string]interface{}); ok {
+		if declared, ok := object["schemaVersion"].(json.Number); ok {
+			declaredVersion, err := declared.Int64()
+			if _, known := parsedSchemas[int(declaredVersion)]; err != nil || !known {
+				return invalidPageError(detail("schemaVersion", fmt.Sprintf("unsupported schema version %s", declared)))
+			}
+			version = int(declaredVersion)
+		}
+	}
+
+	details := parsedSchemas[version].validate(document, "", nil)
+	if len(details) == 0 {
+		details = checkUniqueFramePositions(content)
+	}
+	if len(details) > 0 {
+		return invalidPageError(details...)
+	}
+	return nil
+}
+
+// ParsePage - validate and decode the content of a page document
+func ParsePage(content []byte) (model.PageDocument, error) {
+	var page model.PageDocument
+	if err := ValidatePage(content); err != nil {
+		return page, err
+	}
+	if err := json.Unmarshal(content, &page); err != nil {
+		return page, invalidPageError(detail("", err.Error()))
+	}
+	return page, nil
+}
+
+// checkUniqueFramePositions - return a ResultDetail for every frame whose position is already used by a previous one
+func checkUniqueFramePositions(content []byte) []model.ResultDetail {
+	var page model.PageDocument
+	if err := json.Unmarshal(content, &page); err != nil {
+		return []model.ResultDetail{detail("", err.Error())}
+	}
+
+	var details []model.ResultDetail
+	used := map[int]bool{}
+	for i, frame := range page.Frames {
+		if used[frame.Pos] {
+			details = append(details, detail(fmt.Sprintf("frames[%d].pos", i), fmt.Sprintf("position %d is used by another frame", frame.Pos)))
+		}
+		used[frame.Pos] = true
+	}
+	return details
+}
+
+// invalidPageError - return the error describing an invalid page document
+func invalidPageError(details ...model.ResultDetail) error {
+	err := nxfserrors.New(nxfserrors.ErrUnprocessable, "invalid_page", "The page document is not valid")
+	err.Details = details
+	return err
+}
@@ -1,20 +1,26 @@
 package nxfspages
 
 import (
+	"fmt"
 	"github.com/entando/entando-nxfs/server/helper"
 	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
 	"github.com/entando/entando-nxfs/server/nxfsfiles"
-	"net/http"
-	"os"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"path"
 	"strings"
 )
 
 const pageSuffix = ".page"
 
-// PublishPage - publish the received draft page and return an error NxfsResponse if an error occurs, nil otherwise
-func PublishPage(encodedDraftPagePath string) (errorResp *net.NxfsResponse) {
+// PublishCheck - a check of the content of a draft page, relative to the pages folders, that must pass for the page to be published
+type PublishCheck func(pagePath string, content []byte) error
 
-	var pageFileInfo os.FileInfo
+// PublishPage - publish the received draft page, copying it through the storage, and return an error NxfsResponse if an error occurs, nil otherwise.
+// the page must be a valid page document, every object it references must exist and it must pass the received check, if any.
+// if withAssets is true the referenced objects in the published pages folder are published from their drafts together with the page,
+// and the references to the draft pages folder are refused
+func PublishPage(storage nxfsstorage.Backend, encodedDraftPagePath string, withAssets bool, check PublishCheck) (errorResp *net.NxfsResponse) {
 
 	// decode path
 	decodedPath, errResponse := nxfsfiles.DecodePath(encodedDraftPagePath)
@@ -22,26 +28,77 @@ func PublishPage(encodedDraftPagePath string) (errorResp *net.NxfsResponse) {
 		return errResponse
 	}
 
-	suffixedPage := addPageSuffix(decodedPath)
+	suffixedPage := nxfsstorage.CleanPath(AddPageSuffix(decodedPath))
+	draftPagePath := path.Join(helper.GetDraftPagesRelativePath(), suffixedPage)
 
 	// check if file exist as draft in the correct folder or error
-	pageFileInfo, errResponse = nxfsfiles.GetDraftPageInfoIfExistOrErrorResponse(suffixedPage)
-	if errResponse != nil {
-		return errResponse
+	pageFileInfo, err := storage.Stat(draftPagePath)
+	if err != nil {
+		return helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
 	}
 
 	// if dir error
 	if pageFileInfo.IsDir() {
-		return helper.ErrorResponse(http.StatusUnprocessableEntity, "cannot_publish_dir", "The received path corresponds to a directory, only pages can be published")
+		return helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrUnprocessable, "cannot_publish_dir", "The received path corresponds to a directory, only pages can be published"))
+	}
+
+	// only valid page documents can be published
+	content, version, err := storage.ReadFile(draftPagePath)
+	if err != nil {
+		return helper.ErrorResponse(nxfserrors.FromOS(err, "draft_read_error", "An error occurred during the read operation of the draft page file"))
+	}
+	if err = ValidatePage(content); err != nil {
+		return helper.ErrorResponse(err)
+	}
+	if check != nil {
+		if err = check(suffixedPage, content); err != nil {
+			return helper.ErrorResponse(err)
+		}
+	}
+
+	references := References(content)
+	var assets []string
+	publishedTogether := map[string]bool{}
+	if withAssets {
+		assets = PublishableAssets(storage, references)
+		for _, asset := range assets {
+			publishedTogether[path.Join(helper.GetPublishedPagesRelativePath(), asset)] = true
+		}
+	}
+	if details := DraftReferences(references); withAssets && len(details) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrUnprocessable, "draft_references", "The page references drafts, that can't be published as its assets")
+		err.Details = details
+		return helper.ErrorResponse(err)
+	}
+	if details := MissingReferences(storage, references, publishedTogether); len(details) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrUnprocessable, "missing_references", "The page references objects that don't exist")
+		err.Details = details
+		return helper.ErrorResponse(err)
+	}
+
+	for _, asset := range assets {
+		if errResponse = publishCopy(storage, asset, ""); errResponse != nil {
+			return errResponse
+		}
 	}
 
-	draftPageFullPath := nxfsfiles.RelativizeToDraftPageFolder(suffixedPage)
-	publishedPageFullPath := nxfsfiles.RelativizeToDraftPageFolder(suffixedPage)
-	return nxfsfiles.CopyFileTo(draftPageFullPath, publishedPageFullPath)
+	// the validated content is published, not the one the draft could have meanwhile
+	return publishCopy(storage, suffixedPage, version)
+}
+
+// publishCopy - copy a draft, relative to the pages folders, to the published pages folder. if version isn't empty the draft must still have it
+func publishCopy(storage nxfsstorage.Backend, pagePath string, version string) *net.NxfsResponse {
+	err := storage.Copy(path.Join(helper.GetDraftPagesRelativePath(), pagePath), path.Join(helper.GetPublishedPagesRelativePath(), pagePath), version)
+	if err == nxfsstorage.ErrChanged {
+		return helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrConflict, "draft_changed", fmt.Sprintf("The draft %s changed during its publication", pagePath)))
+	} else if err != nil {
+		return helper.ErrorResponse(nxfserrors.FromOS(err, "published_copy_error", fmt.Sprintf("An error occurred during the copy of the draft %s to the published pages folder", pagePath)))
+	}
+	return nil
 }
 
 // UnpublishPage - unpublish the received published page and return an error NxfsResponse if an error occurs, nil otherwise
-func UnpublishPage(encodedPublishedPagePath string) (errorResp *net.NxfsResponse) {
+func UnpublishPage(storage nxfsstorage.Backend, encodedPublishedPagePath string) (errorResp *net.NxfsResponse) {
 
 	// decode path
 	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPublishedPagePath)
@@ -49,22 +106,21 @@ func UnpublishPage(encodedPublishedPagePath string) (errorResp *net.NxfsResponse
 		return errResponse
 	}
 
-	suffixedPage := addPageSuffix(decodedPath)
-	publishedPageFullPath := nxfsfiles.RelativizeToPublishedPageFolder(suffixedPage)
+	publishedPagePath := path.Join(helper.GetPublishedPagesRelativePath(), nxfsstorage.CleanPath(AddPageSuffix(decodedPath)))
 
-	if _, implResponse := nxfsfiles.GetFileInfoIfPathExistOrErrorResponse(publishedPageFullPath); nil != implResponse {
-		return implResponse
+	if _, err := storage.Stat(publishedPagePath); err != nil {
+		return helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
 	}
 
-	if implResponse := nxfsfiles.DeleteFile(publishedPageFullPath); implResponse != nil {
-		return implResponse
+	if err := storage.Remove(publishedPagePath); err != nil {
+		return helper.ErrorResponse(nxfserrors.FromOS(err, "deletion_error", "An error occurred during the deletion"))
 	}
 
 	return nil
 }
 
-// addSuffix - receive a string and add the suffix if not present, then return it
-func addPageSuffix(value string) (suffixedString string) {
+// AddPageSuffix - receive a string and add the suffix if not present, then return it
+func AddPageSuffix(value string) (suffixedString string) {
 	if strings.HasSuffix(value, pageSuffix) {
 		suffixedString = value
 	} else {
@@ -0,0 +1,154 @@
+package nxfspages
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Reference - a path, relative to the browsable fs root, referenced by a field of a page document
+type Reference struct {
+	Path  string
+	Field string
+}
+
+// References - return the paths referenced by a page document: its template and the widget configuration values
+// that are absolute paths of files, like "/assets/logo.png". content that is not a page document has no references
+func References(content []byte) []Reference {
+	var page model.PageDocument
+	if err := json.Unmarshal(content, &page); err != nil {
+		return nil
+	}
+
+	var references []Reference
+	if "" != page.Template {
+		references = append(references, Reference{Path: cleanReference(page.Template), Field: "template"})
+	}
+	for i, frame := range page.Frames {
+		if frame.Widget != nil {
+			references = configReferences(frame.Widget.Config, fmt.Sprintf("frames[%d].widget.config", i), references)
+		}
+	}
+	return references
+}
+
+// MissingReferences - return a ResultDetail for every reference to a path that doesn't exist and isn't published together with the page
+func MissingReferences(storage nxfsstorage.Backend, references []Reference, publishedTogether map[string]bool) []model.ResultDetail {
+	var details []model.ResultDetail
+	for _, reference := range references {
+		if publishedTogether[reference.Path] {
+			continue
+		}
+		if _, err := storage.Stat(reference.Path); err != nil {
+			details = append(details, model.ResultDetail{Field: reference.Field, Message: fmt.Sprintf("the referenced object /%s doesn't exist", reference.Path)})
+		}
+	}
+	return details
+}
+
+// PublishableAssets - return the references to objects in the published pages folder whose draft exists in the draft pages
+// folder, as paths relative to the pages folders. they are the assets that can be published together with a page
+func PublishableAssets(storage nxfsstorage.Backend, references []Reference) []string {
+	publishedPrefix := helper.GetPublishedPagesRelativePath() + "/"
+
+	var assets []string
+	seen := map[string]bool{}
+	for _, reference := range references {
+		if !strings.HasPrefix(reference.Path, publishedPrefix) || seen[reference.Path] {
+			continue
+		}
+		seen[reference.Path] = true
+
+		asset := strings.TrimPrefix(reference.Path, publishedPrefix)
+		if fileInfo, err := storage.Stat(path.Join(helper.GetDraftPagesRelativePath(), asset)); err == nil && !fileInfo.IsDir() {
+			assets = append(assets, asset)
+		}
+	}
+	return assets
+}
+
+// DraftReferences - return a ResultDetail for every reference to an object in the draft pages folder. the assets
+// published with a page must be referenced by their published path, the published page would depend on a draft otherwise
+func DraftReferences(references []Reference) []model.ResultDetail {
+	draftPrefix := helper.GetDraftPagesRelativePath() + "/"
+
+	var details []model.ResultDetail
+	for _, reference := range references {
+		if strings.HasPrefix(reference.Path, draftPrefix) {
+			published := path.Join(helper.GetPublishedPagesRelativePath(), strings.TrimPrefix(reference.Path, draftPrefix))
+			details = append(details, model.ResultDetail{Field: reference.Field,
+				Message: fmt.Sprintf("the draft /%s can't be published as an asset, the page must reference its published path /%s", reference.Path, published)})
+		}
+	}
+	return details
+}
+
+// Referrers - return the references, held by the draft and the published pages, to the received path or to any path under it
+func Referrers(storage nxfsstorage.Backend, target string) ([]model.PageReference, error) {
+	target = cleanReference(target)
+
+	referrers := []model.PageReference{}
+	for _, pagesFolder := range []string{helper.GetDraftPagesRelativePath(), helper.GetPublishedPagesRelativePath()} {
+		err := nxfsstorage.Walk(storage, pagesFolder, func(pagePath string, fileInfo os.FileInfo) error {
+			if fileInfo.IsDir() || !IsPage(fileInfo.Name()) {
+				return nil
+			}
+
+			content, _, err := storage.ReadFile(pagePath)
+			if err != nil {
+				return err
+			}
+			for _, reference := range References(content) {
+				if "" == target || reference.Path == target || strings.HasPrefix(reference.Path, target+"/") {
+					referrers = append(referrers, model.PageReference{Page: pagePath, Field: reference.Field, Reference: reference.Path})
+				}
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nxfserrors.FromOS(err, "references_scan_error", "An error occurred during the scan of the page references")
+		}
+	}
+	return referrers, nil
+}
+
+// configReferences - append the references found in a widget configuration, descending into the nested objects and arrays
+func configReferences(value interface{}, field string, references []Reference) []Reference {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typedValue))
+		for key := range typedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			references = configReferences(typedValue[key], field+"."+key, references)
+		}
+	case []interface{}:
+		for i, item := range typedValue {
+			references = configReferences(item, fmt.Sprintf("%s[%d]", field, i), references)
+		}
+	case string:
+		if isFileReference(typedValue) {
+			references = append(references, Reference{Path: cleanReference(typedValue), Field: field})
+		}
+	}
+	return references
+}
+
+// isFileReference - return true if a configuration value is the absolute path of a file, excluding the protocol relative urls
+func isFileReference(value string) bool {
+	return strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") && !strings.ContainsAny(value, " \t\n?#") && "" != path.Ext(value)
+}
+
+// cleanReference - return a referenced path relative to the browsable fs root, unable to point outside of it
+func cleanReference(reference string) string {
+	return path.Clean("/" + reference)[1:]
+}
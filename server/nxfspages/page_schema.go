@@ -0,0 +1,75 @@
+package nxfspages
+
+// CurrentPageSchemaVersion - the schema version of the page documents written by the current clients
+const CurrentPageSchemaVersion = 1
+
+// pageSchemas - the JSON schemas of the page documents, indexed by their schemaVersion
+var pageSchemas = map[int]string{
+	1: pageSchemaV1,
+}
+
+// pageSchemaV1 - JSON schema of the version 1 page documents
+const pageSchemaV1 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "urn:entando:nxfs:schema:page:1",
+  "title": "Page",
+  "description": "An nxfs page document: a layout template whose frames host configured widgets",
+  "type": "object",
+  "required": ["schemaVersion", "title", "template", "frames"],
+  "additionalProperties": false,
+  "properties": {
+    "schemaVersion": {
+      "description": "version of the schema the document conforms to",
+      "type": "integer",
+      "const": 1
+    },
+    "title": {
+      "description": "title of the page",
+      "type": "string",
+      "minLength": 1
+    },
+    "description": {
+      "type": "string"
+    },
+    "template": {
+      "description": "path, relative to the browsable fs root, of the layout template rendering the page",
+      "type": "string",
+      "minLength": 1
+    },
+    "frames": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["pos", "name"],
+        "additionalProperties": false,
+        "properties": {
+          "pos": {
+            "description": "position of the frame in the template, unique in the page",
+            "type": "integer",
+            "minimum": 0
+          },
+          "name": {
+            "type": "string",
+            "minLength": 1
+          },
+          "widget": {
+            "type": "object",
+            "required": ["code"],
+            "additionalProperties": false,
+            "properties": {
+              "code": {
+                "description": "code of the widget type",
+                "type": "string",
+                "minLength": 1
+              },
+              "config": {
+                "description": "widget specific configuration",
+                "type": "object"
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
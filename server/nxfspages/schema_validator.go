@@ -0,0 +1,162 @@
+package nxfspages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"sort"
+)
+
+// schemaNode - the subset of JSON schema used by the page schemas
+type schemaNode struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Const                interface{}            `json:"const"`
+	MinLength            *int                   `json:"minLength"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// parseSchema - parse a JSON schema, panicking if it's malformed since schemas are part of the source
+func parseSchema(schema string) *schemaNode {
+	node := &schemaNode{}
+	if err := json.Unmarshal([]byte(schema), node); err != nil {
+		panic("malformed page schema: " + err.Error())
+	}
+	return node
+}
+
+// validate - check value against the schema node appending a ResultDetail for every violation found
+func (node *schemaNode) validate(value interface{}, field string, details []model.ResultDetail) []model.ResultDetail {
+
+	if "" != node.Type && !hasType(value, node.Type) {
+		return append(details, detail(field, fmt.Sprintf("must be of type %s", node.Type)))
+	}
+
+	if node.Const != nil && !sameValue(value, node.Const) {
+		details = append(details, detail(field, fmt.Sprintf("must be %v", node.Const)))
+	}
+	if len(node.Enum) > 0 && !inEnum(value, node.Enum) {
+		details = append(details, detail(field, fmt.Sprintf("must be one of %v", node.Enum)))
+	}
+
+	switch typedValue := value.(type) {
+	case string:
+		if node.MinLength != nil && len([]rune(typedValue)) < *node.MinLength {
+			details = append(details, detail(field, fmt.Sprintf("must be at least %d characters long", *node.MinLength)))
+		}
+	case json.Number:
+		if number, err := typedValue.Float64(); err == nil && node.Minimum != nil && number < *node.Minimum {
+			details = append(details, detail(field, fmt.Sprintf("must be at least %v", *node.Minimum)))
+		}
+	case []interface{}:
+		if node.Items != nil {
+			for i, item := range typedValue {
+				details = node.Items.validate(item, fmt.Sprintf("%s[%d]", field, i), details)
+			}
+		}
+	case map[string]interface{}:
+		for _, required := range node.Required {
+			if _, ok := typedValue[required]; !ok {
+				details = append(details, detail(childField(field, required), "is required"))
+			}
+		}
+		for _, key := range sortedKeys(typedValue) {
+			if propertyNode, ok := node.Properties[key]; ok {
+				details = propertyNode.validate(typedValue[key], childField(field, key), details)
+			} else if node.AdditionalProperties != nil && !*node.AdditionalProperties {
+				details = append(details, detail(childField(field, key), "is not allowed"))
+			}
+		}
+	}
+
+	return details
+}
+
+// hasType - return true if the decoded JSON value is of the received JSON schema type
+func hasType(value interface{}, schemaType string) bool {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return schemaType == "object"
+	case []interface{}:
+		return schemaType == "array"
+	case string:
+		return schemaType == "string"
+	case bool:
+		return schemaType == "boolean"
+	case json.Number:
+		if schemaType == "number" {
+			return true
+		}
+		_, err := typedValue.Int64()
+		return schemaType == "integer" && err == nil
+	case nil:
+		return schemaType == "null"
+	}
+	return false
+}
+
+// sameValue - compare a value decoded with json.Number against a value decoded from the schema
+func sameValue(value interface{}, expected interface{}) bool {
+	if number, ok := value.(json.Number); ok {
+		parsed, err := number.Float64()
+		expectedNumber, isNumber := expected.(float64)
+		return err == nil && isNumber && parsed == expectedNumber
+	}
+	return value == expected
+}
+
+// inEnum - return true if the value is one of the allowed ones
+func inEnum(value interface{}, allowed []interface{}) bool {
+	for _, candidate := range allowed {
+		if sameValue(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// childField - return the path of a property of the received field
+func childField(field string, property string) string {
+	if "" == field {
+		return property
+	}
+	return field + "." + property
+}
+
+// detail - return a ResultDetail for the received field, the document itself if empty
+func detail(field string, message string) model.ResultDetail {
+	if "" == field {
+		field = "$"
+	}
+	return model.ResultDetail{Field: field, Message: message}
+}
+
+// sortedKeys - return the keys of the object in a stable order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeJSON - decode a JSON document keeping the numbers as json.Number, rejecting trailing content
+func decodeJSON(content []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected content after the JSON document")
+	}
+	return value, nil
+}
@@ -0,0 +1,119 @@
+package nxfspages
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"strings"
+	"testing"
+)
+
+// testSchema - a schema using every keyword supported by the validator
+const testSchema = `{
+  "type": "object",
+  "required": ["kind", "count"],
+  "additionalProperties": false,
+  "properties": {
+    "kind": {"type": "string", "const": "page"},
+    "count": {"type": "integer", "minimum": 1},
+    "ratio": {"type": "number", "minimum": 0.5},
+    "color": {"enum": ["red", 2]},
+    "name": {"type": "string", "minLength": 2},
+    "flag": {"type": "boolean"},
+    "nothing": {"type": "null"},
+    "items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["id"],
+        "properties": {"id": {"type": "integer"}}
+      }
+    }
+  }
+}`
+
+// describeDetails - return the details as field: message lines, comparable in the tests
+func describeDetails(details []model.ResultDetail) string {
+	lines := make([]string, 0, len(details))
+	for _, detail := range details {
+		lines = append(lines, detail.Field+": "+detail.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := parseSchema(testSchema)
+	cases := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{"valid", `{"kind":"page","count":3,"ratio":0.5,"color":"red","name":"ab","flag":true,"nothing":null,"items":[{"id":1}]}`, ""},
+		{"type of the document", `[]`, "$: must be of type object"},
+		{"type of a property", `{"kind":1,"count":"3"}`, "count: must be of type integer\nkind: must be of type string"},
+		{"integer with a fraction", `{"kind":"page","count":1.5}`, "count: must be of type integer"},
+		{"number", `{"kind":"page","count":1,"ratio":"high"}`, "ratio: must be of type number"},
+		{"boolean and null", `{"kind":"page","count":1,"flag":"true","nothing":0}`, "flag: must be of type boolean\nnothing: must be of type null"},
+		{"const", `{"kind":"post","count":1}`, "kind: must be page"},
+		{"enum with a string", `{"kind":"page","count":1,"color":"blue"}`, "color: must be one of [red 2]"},
+		{"enum with a number", `{"kind":"page","count":1,"color":2.0}`, ""},
+		{"minimum of an integer", `{"kind":"page","count":0}`, "count: must be at least 1"},
+		{"minimum of a number", `{"kind":"page","count":1,"ratio":0.25}`, "ratio: must be at least 0.5"},
+		{"minimum length in characters", `{"kind":"page","count":1,"name":"è"}`, "name: must be at least 2 characters long"},
+		{"required", `{}`, "kind: is required\ncount: is required"},
+		{"additional properties", `{"kind":"page","count":1,"extra":true,"another":1}`, "another: is not allowed\nextra: is not allowed"},
+		{"additional properties allowed by default", `{"kind":"page","count":1,"items":[{"id":1,"extra":true}]}`, ""},
+		{"paths of the array items", `{"kind":"page","count":1,"items":[{"id":1},{},{"id":"2"},3]}`,
+			"items[1].id: is required\nitems[2].id: must be of type integer\nitems[3]: must be of type object"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			document, err := decodeJSON([]byte(c.document))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := describeDetails(schema.validate(document, "", nil)); actual != c.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidatePage(t *testing.T) {
+	cases := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{"valid", `{"schemaVersion":1,"title":"Home","template":"t.ftl","frames":[{"pos":0,"name":"a","widget":{"code":"w","config":{"any":1}}},{"pos":1,"name":"b"}]}`, ""},
+		{"current version by default", `{"title":"Home","template":"t.ftl","frames":[]}`, "schemaVersion: is required"},
+		{"unsupported version", `{"schemaVersion":99,"title":"Home","template":"t.ftl","frames":[]}`, "schemaVersion: unsupported schema version 99"},
+		{"not json", `{"title":`, "$: is not a valid JSON document: unexpected EOF"},
+		{"trailing content", `{} {}`, "$: is not a valid JSON document: unexpected content after the JSON document"},
+		{"paths of the nested violations", `{"schemaVersion":1,"title":"","template":"t.ftl","frames":[{"pos":-1,"name":"a","widget":{"config":[]}}]}`,
+			"frames[0].pos: must be at least 0\nframes[0].widget.code: is required\nframes[0].widget.config: must be of type object\ntitle: must be at least 1 characters long"},
+		{"unique frame positions", `{"schemaVersion":1,"title":"Home","template":"t.ftl","frames":[{"pos":1,"name":"a"},{"pos":2,"name":"b"},{"pos":1,"name":"c"},{"pos":2,"name":"d"}]}`,
+			"frames[2].pos: position 1 is used by another frame\nframes[3].pos: position 2 is used by another frame"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidatePage([]byte(c.document))
+			if "" == c.expected {
+				if err != nil {
+					t.Fatalf("expected a valid page, got %v", err)
+				}
+				return
+			}
+
+			var nxfsErr *nxfserrors.Error
+			if !errors.As(err, &nxfsErr) || nxfsErr.Code != "invalid_page" || !errors.Is(err, nxfserrors.ErrUnprocessable) {
+				t.Fatalf("expected an invalid_page error, got %v", err)
+			}
+			if actual := describeDetails(nxfsErr.Details); actual != c.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", c.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package nxfsquota
+
+import (
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Write - a file about to be written, with its new size
+type Write struct {
+	Path string
+	Size int64
+}
+
+// usage - the bytes and files held by a folder
+type usage struct {
+	bytes int64
+	files int64
+}
+
+// Tracker - track the bytes and files held by the folders of a browsable fs that have a quota, and by the whole fs.
+// the sizes of the files are counted once, then updated by every write and removal
+type Tracker struct {
+	storage     nxfsstorage.Backend
+	maxFileSize int64
+	// quotas - the quotas of the folders, the one of the whole fs first
+	quotas []helper.Quota
+	mutex  sync.Mutex
+	// sizes - the size of every file of the fs, nil until they're counted
+	sizes map[string]int64
+	usage []usage
+	// generation - incremented when the sizes are counted again, so that an outdated reservation isn't undone
+	generation int
+}
+
+// NewTracker - create a tracker of the received storage rejecting the files larger than maxFileSize, if it's not zero, and the
+// writes exceeding the received quotas
+func NewTracker(storage nxfsstorage.Backend, maxFileSize int64, quotas []helper.Quota) *Tracker {
+	tracker := &Tracker{storage: storage, maxFileSize: maxFileSize, quotas: []helper.Quota{{Path: "."}}}
+	for _, quota := range quotas {
+		if "." == quota.Path {
+			tracker.quotas[0] = quota
+		} else {
+			tracker.quotas = append(tracker.quotas, quota)
+		}
+	}
+	return tracker
+}
+
+// Reserve - record the received writes, before executing them, if they don't make any folder exceed its quota.
+// otherwise nothing is recorded and a quota_exceeded error, listing the exceeded quotas, is returned.
+// the returned function undoes the reservation, it must be called if the writes fail
+func (t *Tracker) Reserve(writes ...Write) (func(), error) {
+	for _, write := range writes {
+		if t.maxFileSize > 0 && write.Size > t.maxFileSize {
+			return nil, nxfserrors.New(nxfserrors.ErrTooLarge, "file_too_large", fmt.Sprintf("The file %s is larger than %d bytes", write.Path, t.maxFileSize))
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := t.count(); err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(writes))
+	for _, write := range writes {
+		sizes[nxfsstorage.CleanPath(write.Path)] = write.Size
+	}
+	deltas := t.deltas(sizes)
+
+	var details []model.ResultDetail
+	for i, quota := range t.quotas {
+		bytes, files := t.usage[i].bytes+deltas[i].bytes, t.usage[i].files+deltas[i].files
+		if quota.MaxBytes > 0 && deltas[i].bytes > 0 && bytes > quota.MaxBytes {
+			details = append(details, model.ResultDetail{Field: quota.Path, Message: fmt.Sprintf("would use %d of the %d bytes allowed", bytes, quota.MaxBytes)})
+		}
+		if quota.MaxFiles > 0 && deltas[i].files > 0 && files > quota.MaxFiles {
+			details = append(details, model.ResultDetail{Field: quota.Path, Message: fmt.Sprintf("would hold %d of the %d files allowed", files, quota.MaxFiles)})
+		}
+	}
+	if len(details) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrNoSpace, "quota_exceeded", "The write would exceed the quota of some folders, nothing has been written")
+		err.Details = details
+		return nil, err
+	}
+
+	previous := t.apply(sizes)
+	generation := t.generation
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if generation == t.generation {
+			t.apply(previous)
+		}
+	}, nil
+}
+
+// Removed - record the removal of the received files
+func (t *Tracker) Removed(relPaths ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.sizes == nil {
+		return
+	}
+
+	sizes := make(map[string]int64, len(relPaths))
+	for _, relPath := range relPaths {
+		sizes[nxfsstorage.CleanPath(relPath)] = -1
+	}
+	t.apply(sizes)
+}
+
+// Refresh - record the current size of the received files, changed without reservations like the ones restored by a rollback
+func (t *Tracker) Refresh(relPaths ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.sizes == nil {
+		return
+	}
+
+	sizes := make(map[string]int64, len(relPaths))
+	for _, relPath := range relPaths {
+		relPath = nxfsstorage.CleanPath(relPath)
+		if fileInfo, err := t.storage.Stat(relPath); err == nil && !fileInfo.IsDir() {
+			sizes[relPath] = fileInfo.Size()
+		} else {
+			sizes[relPath] = -1
+		}
+	}
+	t.apply(sizes)
+}
+
+// Invalidate - forget the sizes of the files, so that they're counted again. to be called when unknown files changed
+func (t *Tracker) Invalidate() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sizes = nil
+	t.generation++
+}
+
+// Usage - return the bytes and files held by the whole fs and by every folder having a quota
+func (t *Tracker) Usage() ([]model.QuotaUsage, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := t.count(); err != nil {
+		return nil, err
+	}
+
+	usages := make([]model.QuotaUsage, 0, len(t.quotas))
+	for i, quota := range t.quotas {
+		usages = append(usages, model.QuotaUsage{Path: quota.Path, Bytes: t.usage[i].bytes, Files: t.usage[i].files, MaxBytes: quota.MaxBytes, MaxFiles: quota.MaxFiles})
+	}
+	return usages, nil
+}
+
+// count - count the sizes of the files of the fs, if they're not known
+func (t *Tracker) count() error {
+	if t.sizes != nil {
+		return nil
+	}
+
+	sizes := map[string]int64{}
+	err := nxfsstorage.Walk(t.storage, "", func(relPath string, fileInfo os.FileInfo) error {
+		if !fileInfo.IsDir() {
+			sizes[relPath] = fileInfo.Size()
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nxfserrors.FromOS(err, "quota_count_error", "An error occurred during the count of the space used by the browsable fs")
+	}
+
+	t.sizes = map[string]int64{}
+	t.usage = make([]usage, len(t.quotas))
+	t.apply(sizes)
+	return nil
+}
+
+// deltas - return how the usage of every quota changes if the files get the received sizes, -1 for the removed ones
+func (t *Tracker) deltas(sizes map[string]int64) []usage {
+	deltas := make([]usage, len(t.quotas))
+	for relPath, size := range sizes {
+		oldSize, existed := t.sizes[relPath]
+		var bytes, files int64
+		switch {
+		case size < 0 && existed:
+			bytes, files = -oldSize, -1
+		case size >= 0 && existed:
+			bytes = size - oldSize
+		case size >= 0:
+			bytes, files = size, 1
+		}
+		for i, quota := range t.quotas {
+			if contains(quota.Path, relPath) {
+				deltas[i].bytes += bytes
+				deltas[i].files += files
+			}
+		}
+	}
+	return deltas
+}
+
+// apply - give the files the received sizes, -1 for the removed ones, returning their previous sizes
+func (t *Tracker) apply(sizes map[string]int64) map[string]int64 {
+	deltas := t.deltas(sizes)
+	for i := range t.usage {
+		t.usage[i].bytes += deltas[i].bytes
+		t.usage[i].files += deltas[i].files
+	}
+
+	previous := make(map[string]int64, len(sizes))
+	for relPath, size := range sizes {
+		if oldSize, existed := t.sizes[relPath]; existed {
+			previous[relPath] = oldSize
+		} else {
+			previous[relPath] = -1
+		}
+		if size < 0 {
+			delete(t.sizes, relPath)
+		} else {
+			t.sizes[relPath] = size
+		}
+	}
+	return previous
+}
+
+// contains - return true if the received file is inside the folder, "." being the whole fs
+func contains(folder string, relPath string) bool {
+	return "." == folder || strings.HasPrefix(relPath, folder+"/")
+}
@@ -0,0 +1,123 @@
+package nxfsquota
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func assertUsage(t *testing.T, tracker *Tracker, expected ...model.QuotaUsage) {
+	t.Helper()
+	usage, err := tracker.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Fatalf("expected the usage %v, got %v", expected, usage)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	storage := nxfsstorage.NewMemory()
+	for _, dir := range []string{"pages", "pages/news", "assets"} {
+		if err := storage.Mkdir(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for relPath, content := range map[string]string{"pages/home.page": "12345", "pages/news/a.page": "123", "assets/logo.png": "1234567890"} {
+		if err := storage.WriteFile(relPath, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	quotas, err := helper.ParseQuotas("/=30, pages/=:3, missing=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewTracker(storage, 20, quotas)
+	assertUsage(t, tracker,
+		model.QuotaUsage{Path: ".", Bytes: 18, Files: 3, MaxBytes: 30},
+		model.QuotaUsage{Path: "pages", Bytes: 8, Files: 2, MaxFiles: 3},
+		model.QuotaUsage{Path: "missing", MaxBytes: 1})
+
+	// a replaced file only adds the difference of the sizes
+	undo, err := tracker.Reserve(Write{Path: "pages/home.page", Size: 10}, Write{Path: "pages/news/b.page", Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUsage(t, tracker,
+		model.QuotaUsage{Path: ".", Bytes: 24, Files: 4, MaxBytes: 30},
+		model.QuotaUsage{Path: "pages", Bytes: 14, Files: 3, MaxFiles: 3},
+		model.QuotaUsage{Path: "missing", MaxBytes: 1})
+
+	// nothing is reserved if any quota would be exceeded
+	_, err = tracker.Reserve(Write{Path: "pages/c.page", Size: 1}, Write{Path: "assets/big.png", Size: 7})
+	var quotaErr *nxfserrors.Error
+	if !errors.As(err, &quotaErr) || quotaErr.Code != "quota_exceeded" || quotaErr.Status() != 507 || len(quotaErr.Details) != 2 {
+		t.Fatalf("expected both quotas to be exceeded, got %v", err)
+	}
+	if quotaErr.Details[0].Field != "." || quotaErr.Details[1].Field != "pages" {
+		t.Fatalf("unexpected details %v", quotaErr.Details)
+	}
+	if _, err = tracker.Reserve(Write{Path: "assets/huge.png", Size: 21}); !errors.Is(err, nxfserrors.ErrTooLarge) {
+		t.Fatalf("expected the file to be too large, got %v", err)
+	}
+	// shrinking a folder over its quota is allowed
+	if _, err = tracker.Reserve(Write{Path: "pages/home.page", Size: 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the undone reservation restores the sizes the files had before it
+	undo()
+	assertUsage(t, tracker,
+		model.QuotaUsage{Path: ".", Bytes: 18, Files: 3, MaxBytes: 30},
+		model.QuotaUsage{Path: "pages", Bytes: 8, Files: 2, MaxFiles: 3},
+		model.QuotaUsage{Path: "missing", MaxBytes: 1})
+
+	tracker.Removed("pages/news/a.page", "pages/news")
+	assertUsage(t, tracker,
+		model.QuotaUsage{Path: ".", Bytes: 15, Files: 2, MaxBytes: 30},
+		model.QuotaUsage{Path: "pages", Bytes: 5, Files: 1, MaxFiles: 3},
+		model.QuotaUsage{Path: "missing", MaxBytes: 1})
+
+	// the changes made without reservations are recorded by refreshing their files, or counting everything again
+	if err = storage.WriteFile("pages/news/a.page", strings.NewReader("1")); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Refresh("pages/news/a.page", "pages/home.page")
+	assertUsage(t, tracker,
+		model.QuotaUsage{Path: ".", Bytes: 16, Files: 3, MaxBytes: 30},
+		model.QuotaUsage{Path: "pages", Bytes: 6, Files: 2, MaxFiles: 3},
+		model.QuotaUsage{Path: "missing", MaxBytes: 1})
+
+	undo, _ = tracker.Reserve(Write{Path: "assets/icon.png", Size: 2})
+	tracker.Invalidate()
+	// a reservation made before the count is not undone
+	undo()
+	assertUsage(t, tracker,
+		model.QuotaUsage{Path: ".", Bytes: 16, Files: 3, MaxBytes: 30},
+		model.QuotaUsage{Path: "pages", Bytes: 6, Files: 2, MaxFiles: 3},
+		model.QuotaUsage{Path: "missing", MaxBytes: 1})
+}
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := helper.ParseQuotas(" / = 1KiB : 10 ,draft_pages/news=2M,pages=:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []helper.Quota{{Path: ".", MaxBytes: 1024, MaxFiles: 10}, {Path: "draft_pages/news", MaxBytes: 2 << 20}, {Path: "pages", MaxFiles: 5}}
+	if !reflect.DeepEqual(quotas, expected) {
+		t.Fatalf("expected %v, got %v", expected, quotas)
+	}
+
+	for _, invalid := range []string{"pages", "pages=1X", "pages=-1", "pages=1:x", "../up=1", "a=1,a/=2"} {
+		if _, err = helper.ParseQuotas(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
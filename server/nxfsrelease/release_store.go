@@ -0,0 +1,380 @@
+package nxfsrelease
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfsblob"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const manifestFileName = "release.json"
+const snapshotDirName = "before"
+
+// commitStagedFile - swaps a staged page in place, replaced by the tests to simulate failures
+var commitStagedFile = nxfsfiles.CommitStagedFile
+
+// Change - the new content of a published page, a nil Content unpublishes the page
+type Change struct {
+	// path of the page, relative to the pages folders
+	Path    string
+	Content []byte
+}
+
+// Store - applies the releases to the published pages and keeps, for every release, a manifest and a snapshot
+// of the published pages it replaced, so that it can be inspected and rolled back later. published pages and snapshots
+// share their contents through the blob store
+type Store struct {
+	mu                 sync.Mutex
+	dir                string
+	publishedPagesPath string
+	blobs              *nxfsblob.Store
+}
+
+// NewStore - create a Store keeping the releases in the received directory and the contents in the received blob store,
+// applying them to the received published pages folder
+func NewStore(dir string, publishedPagesPath string, blobs *nxfsblob.Store) *Store {
+	return &Store{dir: dir, publishedPagesPath: publishedPagesPath, blobs: blobs}
+}
+
+// Apply - publish all the received changes as a single release. the new contents are staged next to the published pages
+// and swapped in only once all of them have been written; if any swap fails the already swapped pages are restored
+func (s *Store) Apply(user string, rollbackOf string, changes []Change) (model.Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	release := model.Release{
+		Id:         helper.NewRandomId(),
+		Status:     model.ReleasePending,
+		RollbackOf: rollbackOf,
+		CreatedBy:  user,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	// snapshot the published pages that are going to be replaced
+	for _, change := range changes {
+		beforeHash, err := s.writeSnapshot(release.Id, change.Path)
+		if err != nil {
+			s.discard(release.Id)
+			return model.Release{}, nxfserrors.FromOS(err, "release_snapshot_error", fmt.Sprintf("An error occurred during the snapshot of the published page %s", change.Path))
+		}
+		release.Pages = append(release.Pages, model.ReleasePage{Path: change.Path, BeforeHash: beforeHash, AfterHash: hashOf(change.Content, change.Content != nil)})
+	}
+
+	// the pending manifest lets Recover undo a release interrupted while swapping
+	if err := s.save(release); err != nil {
+		s.discard(release.Id)
+		return model.Release{}, err
+	}
+
+	staged, err := s.stage(changes)
+	if err != nil {
+		s.discard(release.Id)
+		return model.Release{}, err
+	}
+
+	for i, change := range changes {
+		publishedPath := s.publishedPath(change.Path)
+		if change.Content == nil {
+			err = os.Remove(publishedPath)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			if err = commitStagedFile(staged[i], publishedPath); err == nil {
+				staged[i] = ""
+			}
+		}
+
+		if err != nil {
+			discardStaged(staged)
+			return model.Release{}, s.fail(release, release.Pages[:i+1],
+				nxfserrors.FromOS(err, "release_swap_error", fmt.Sprintf("An error occurred during the publication of %s, the release has been rolled back", change.Path)))
+		}
+	}
+
+	release.Status = model.ReleaseApplied
+	if err = s.save(release); err != nil {
+		return model.Release{}, s.fail(release, release.Pages, err)
+	}
+	return release, nil
+}
+
+// Rollback - restore the published pages of the received applied release to their content before it, as a new release.
+// the rollback is refused if any page of the release has been modified after it
+func (s *Store) Rollback(user string, id string) (model.Release, error) {
+	release, err := s.Get(id)
+	if err != nil {
+		return model.Release{}, err
+	}
+	if release.Status != model.ReleaseApplied {
+		return model.Release{}, nxfserrors.New(nxfserrors.ErrConflict, "release_not_applied", fmt.Sprintf("The release %s is %s and can't be rolled back", id, release.Status))
+	}
+
+	var details []model.ResultDetail
+	changes := make([]Change, 0, len(release.Pages))
+	for _, page := range release.Pages {
+		if nxfsfiles.HashFile(s.publishedPath(page.Path)) != page.AfterHash {
+			details = append(details, model.ResultDetail{Field: page.Path, Message: "modified after the release"})
+			continue
+		}
+
+		change := Change{Path: page.Path}
+		if "" != page.BeforeHash {
+			if change.Content, err = ioutil.ReadFile(s.snapshotPath(id, page.Path)); err != nil {
+				return model.Release{}, nxfserrors.FromOS(err, "release_snapshot_error", fmt.Sprintf("The snapshot of %s can't be read", page.Path))
+			}
+		}
+		changes = append(changes, change)
+	}
+	if len(details) > 0 {
+		conflictErr := nxfserrors.New(nxfserrors.ErrConflict, "release_superseded", fmt.Sprintf("Some pages of the release %s have been modified after it", id))
+		conflictErr.Details = details
+		return model.Release{}, conflictErr
+	}
+
+	rollback, err := s.Apply(user, id, changes)
+	if err != nil {
+		return model.Release{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	release.Status = model.ReleaseRolledBack
+	release.RolledBackBy = rollback.Id
+	if err = s.save(release); err != nil {
+		return model.Release{}, err
+	}
+	return rollback, nil
+}
+
+// Get - return the release identified by the received id
+func (s *Store) Get(id string) (model.Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !isReleaseId(id) {
+		return model.Release{}, notFoundError(id)
+	}
+	return s.load(id)
+}
+
+// List - return all the releases, the most recent first
+func (s *Store) List() ([]model.Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	releases, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].CreatedAt.After(releases[j].CreatedAt)
+	})
+	return releases, nil
+}
+
+// Recover - roll back the releases left pending by an interrupted swap, returning how many have been recovered
+func (s *Store) Recover() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	releases, err := s.loadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, release := range releases {
+		if release.Status == model.ReleasePending {
+			if err = s.fail(release, release.Pages, nil); err != nil {
+				return recovered, err
+			}
+			recovered++
+		}
+	}
+	return recovered, nil
+}
+
+// fail - restore the received pages of a release to their snapshot and mark it as failed, returning cause.
+// must be called holding mu
+func (s *Store) fail(release model.Release, swapped []model.ReleasePage, cause error) error {
+	for _, page := range swapped {
+		if err := s.restore(release.Id, page); err != nil {
+			return nxfserrors.FromOS(err, "release_restore_error", fmt.Sprintf("The release %s failed and the page %s can't be restored", release.Id, page.Path))
+		}
+	}
+
+	release.Status = model.ReleaseFailed
+	if err := s.save(release); err != nil && cause == nil {
+		return err
+	}
+	return cause
+}
+
+// restore - bring back the published page to its content before the release. must be called holding mu
+func (s *Store) restore(id string, page model.ReleasePage) error {
+	publishedPath := s.publishedPath(page.Path)
+	if "" == page.BeforeHash {
+		if err := os.Remove(publishedPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(publishedPath), 0755); err != nil {
+		return err
+	}
+	_, err := s.blobs.Copy(s.snapshotPath(id, page.Path), publishedPath)
+	return err
+}
+
+// load - read the manifest of the received release. must be called holding mu
+func (s *Store) load(id string) (model.Release, error) {
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, id, manifestFileName))
+	if os.IsNotExist(err) {
+		return model.Release{}, notFoundError(id)
+	} else if err != nil {
+		return model.Release{}, nxfserrors.FromOS(err, "release_read_error", fmt.Sprintf("An error occurred during the reading of the release %s", id))
+	}
+
+	var release model.Release
+	if err = json.Unmarshal(content, &release); err != nil {
+		return model.Release{}, nxfserrors.New(nxfserrors.ErrInternal, "release_read_error", fmt.Sprintf("The manifest of the release %s is corrupted: %s", id, err.Error()))
+	}
+	return release, nil
+}
+
+// loadAll - read the manifests of all the releases. must be called holding mu
+func (s *Store) loadAll() ([]model.Release, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nxfserrors.FromOS(err, "release_read_error", "An error occurred during the listing of the releases")
+	}
+
+	releases := make([]model.Release, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !isReleaseId(entry.Name()) {
+			continue
+		}
+		release, err := s.load(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// save - atomically write the manifest of the received release. must be called holding mu
+func (s *Store) save(release model.Release) error {
+	content, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		return nxfserrors.Wrap(err, "release_write_error")
+	}
+
+	manifestPath := filepath.Join(s.dir, release.Id, manifestFileName)
+	if err = os.MkdirAll(filepath.Dir(manifestPath), 0755); err == nil {
+		err = nxfsfiles.WriteFileAtomic(manifestPath, bytes.NewReader(content), 0644)
+	}
+	if err != nil {
+		return nxfserrors.FromOS(err, "release_write_error", fmt.Sprintf("An error occurred during the writing of the release %s", release.Id))
+	}
+	return nil
+}
+
+// writeSnapshot - store the content a published page has before the release, returning its hash or an empty string
+// if the page is not published. must be called holding mu
+func (s *Store) writeSnapshot(id string, pagePath string) (string, error) {
+	publishedPath := s.publishedPath(pagePath)
+	if _, err := os.Stat(publishedPath); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	snapshotPath := s.snapshotPath(id, pagePath)
+	if err := os.MkdirAll(filepath.Dir(snapshotPath), 0755); err != nil {
+		return "", err
+	}
+	return s.blobs.Copy(publishedPath, snapshotPath)
+}
+
+// publishedPath - return the full path of the published page identified by the received path, relative to the pages folders
+func (s *Store) publishedPath(pagePath string) string {
+	return filepath.Join(s.publishedPagesPath, filepath.FromSlash(pagePath))
+}
+
+// snapshotPath - return the path of the snapshot of a page taken by the received release
+func (s *Store) snapshotPath(id string, pagePath string) string {
+	return filepath.Join(s.dir, id, snapshotDirName, filepath.FromSlash(pagePath))
+}
+
+// discard - remove everything stored for a release that has not been applied. must be called holding mu
+func (s *Store) discard(id string) {
+	os.RemoveAll(filepath.Join(s.dir, id))
+}
+
+// stage - store the new content of every changed page and link it next to the published one, returning the staged paths
+// indexed as the changes, an empty string for the pages to unpublish
+func (s *Store) stage(changes []Change) ([]string, error) {
+	staged := make([]string, len(changes))
+	for i, change := range changes {
+		if change.Content == nil {
+			continue
+		}
+
+		publishedPath := s.publishedPath(change.Path)
+		hash, err := s.blobs.Put(bytes.NewReader(change.Content))
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(publishedPath), 0755)
+		}
+		if err == nil {
+			staged[i], err = s.blobs.Stage(hash, publishedPath)
+		}
+		if err != nil {
+			discardStaged(staged)
+			return nil, nxfserrors.FromOS(err, "release_stage_error", fmt.Sprintf("An error occurred during the staging of %s", change.Path))
+		}
+	}
+	return staged, nil
+}
+
+// discardStaged - remove the staged files not yet committed
+func discardStaged(staged []string) {
+	for _, stagedPath := range staged {
+		if "" != stagedPath {
+			nxfsfiles.DiscardStagedFile(stagedPath)
+		}
+	}
+}
+
+// hashOf - return the hex encoded sha256 of content, as nxfsfiles.HashFile does, or an empty string if it doesn't exist
+func hashOf(content []byte, exists bool) string {
+	if !exists {
+		return ""
+	}
+	return nxfsfiles.HashContent(content)
+}
+
+// isReleaseId - return true if the received string has the format of a release id, so it can be safely used as a dir name
+func isReleaseId(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// notFoundError - return the error describing a missing release
+func notFoundError(id string) error {
+	return nxfserrors.New(nxfserrors.ErrNotFound, "release_not_found", fmt.Sprintf("No release has id %s", id))
+}
@@ -0,0 +1,167 @@
+package nxfsrelease
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfsblob"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var browsableFs string
+
+func TestMain(m *testing.M) {
+	var err error
+	if browsableFs, err = ioutil.TempDir("", "nxfs-release-test"); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(browsableFs)
+	os.Exit(code)
+}
+
+func newTestStore(t *testing.T) *Store {
+	os.RemoveAll(filepath.Join(browsableFs, "pages"))
+	dir, err := ioutil.TempDir("", "nxfs-releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(dir, filepath.Join(browsableFs, "pages"), nxfsblob.NewStore(filepath.Join(dir, "blobs")))
+}
+
+func publish(t *testing.T, pagePath string, content string) {
+	publishedPath := filepath.Join(browsableFs, "pages", pagePath)
+	if err := os.MkdirAll(filepath.Dir(publishedPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(publishedPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertPublished(t *testing.T, pagePath string, expected string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join(browsableFs, "pages", pagePath))
+	if expected == "" {
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be unpublished, got %q %v", pagePath, content, err)
+		}
+		return
+	}
+	if err != nil || string(content) != expected {
+		t.Fatalf("expected %s to contain %q, got %q %v", pagePath, expected, content, err)
+	}
+}
+
+func TestApplyAndRollback(t *testing.T) {
+	store := newTestStore(t)
+	defer os.RemoveAll(store.dir)
+	publish(t, "home.page", "old home")
+
+	release, err := store.Apply("editor", "", []Change{
+		{Path: "home.page", Content: []byte("new home")},
+		{Path: "news/a.page", Content: []byte("news")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPublished(t, "home.page", "new home")
+	assertPublished(t, "news/a.page", "news")
+
+	rollback, err := store.Rollback("editor", release.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPublished(t, "home.page", "old home")
+	assertPublished(t, "news/a.page", "")
+
+	if rolledBack, _ := store.Get(release.Id); rolledBack.Status != model.ReleaseRolledBack || rolledBack.RolledBackBy != rollback.Id {
+		t.Fatalf("the release has not been marked as rolled back: %+v", rolledBack)
+	}
+	if _, err = store.Rollback("editor", release.Id); !errors.Is(err, nxfserrors.ErrConflict) {
+		t.Fatalf("expected a conflict rolling back twice, got %v", err)
+	}
+}
+
+func TestFailedSwapRestoresTheSwappedPages(t *testing.T) {
+	store := newTestStore(t)
+	defer os.RemoveAll(store.dir)
+	publish(t, "a.page", "old a")
+	publish(t, "b.page", "old b")
+
+	commitStagedFile = func(stagedPath string, filePath string) error {
+		if filepath.Base(filePath) == "b.page" {
+			return errors.New("simulated rename failure")
+		}
+		return nxfsfiles.CommitStagedFile(stagedPath, filePath)
+	}
+	defer func() { commitStagedFile = nxfsfiles.CommitStagedFile }()
+
+	_, err := store.Apply("editor", "", []Change{
+		{Path: "a.page", Content: []byte("new a")},
+		{Path: "b.page", Content: []byte("new b")},
+	})
+	if err == nil {
+		t.Fatal("expected the release to fail")
+	}
+	assertPublished(t, "a.page", "old a")
+	assertPublished(t, "b.page", "old b")
+
+	releases, _ := store.List()
+	if len(releases) != 1 || releases[0].Status != model.ReleaseFailed {
+		t.Fatalf("expected a failed release, got %+v", releases)
+	}
+	if removed, _ := nxfsfiles.SweepTempFiles(browsableFs); removed != 0 {
+		t.Fatalf("%d staged files have been left behind", removed)
+	}
+}
+
+func TestRollbackRefusedWhenPagesChanged(t *testing.T) {
+	store := newTestStore(t)
+	defer os.RemoveAll(store.dir)
+
+	release, err := store.Apply("editor", "", []Change{{Path: "home.page", Content: []byte("released")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, "home.page", "edited after the release")
+
+	_, err = store.Rollback("editor", release.Id)
+	var nxfsErr *nxfserrors.Error
+	if !errors.As(err, &nxfsErr) || nxfsErr.Code != "release_superseded" || len(nxfsErr.Details) != 1 {
+		t.Fatalf("expected a release_superseded error, got %v", err)
+	}
+	assertPublished(t, "home.page", "edited after the release")
+}
+
+func TestRecoverRollsBackPendingReleases(t *testing.T) {
+	store := newTestStore(t)
+	defer os.RemoveAll(store.dir)
+	publish(t, "home.page", "old home")
+
+	release, err := store.Apply("editor", "", []Change{{Path: "home.page", Content: []byte("new home")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash during the swap
+	release.Status = model.ReleasePending
+	content, _ := json.Marshal(release)
+	if err = ioutil.WriteFile(filepath.Join(store.dir, release.Id, manifestFileName), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if recovered, err := store.Recover(); err != nil || recovered != 1 {
+		t.Fatalf("expected 1 recovered release, got %d %v", recovered, err)
+	}
+	assertPublished(t, "home.page", "old home")
+	if recovered, _ := store.Get(release.Id); recovered.Status != model.ReleaseFailed {
+		t.Fatalf("expected the recovered release to be failed, got %s", recovered.Status)
+	}
+}
@@ -0,0 +1,79 @@
+package nxfsrender
+
+import (
+	"bytes"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Rendition - an image rendered with some parameters
+type Rendition struct {
+	Content     []byte
+	ContentType string
+	// ETag - identifies the source image and the parameters, it changes when the source changes
+	ETag string
+}
+
+// Cache - the renditions of the images of the browsable fs, stored under a directory as <path of the image>/<hash of
+// the image>-<parameters>, so that a rendition is never served for another version of its image
+type Cache struct {
+	dir string
+}
+
+// NewCache - create a cache storing the renditions under the received directory
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Get - return the rendition of the received image, identified by its path relative to the browsable fs root, rendering
+// it if it isn't cached yet
+func (c *Cache) Get(relPath string, source []byte, p Params) (Rendition, error) {
+	_, format, err := sourceFormat(source)
+	if err != nil {
+		return Rendition{}, err
+	}
+	if "" == p.Format {
+		p.Format = format
+	}
+	name := nxfsfiles.HashContent(source) + "-" + p.key()
+	cachedPath := filepath.Join(c.pathOf(relPath), name)
+	rendition := Rendition{ContentType: ContentType(p.Format), ETag: name}
+
+	if rendition.Content, err = ioutil.ReadFile(cachedPath); err == nil {
+		return rendition, nil
+	}
+	if rendition.Content, err = Render(source, p); err != nil {
+		return Rendition{}, err
+	}
+
+	// a rendition that can't be cached is served anyway, it'll be rendered again
+	if err = os.MkdirAll(filepath.Dir(cachedPath), 0755); err == nil {
+		err = nxfsfiles.WriteFileAtomic(cachedPath, bytes.NewReader(rendition.Content), 0644)
+	}
+	if err != nil {
+		log.Printf("Caching of a rendition of %s failed: %s", relPath, err.Error())
+	}
+	return rendition, nil
+}
+
+// Invalidate - remove the renditions of the received images, and of the images under them if they're directories,
+// because they've been overwritten or removed
+func (c *Cache) Invalidate(relPaths ...string) error {
+	for _, relPath := range relPaths {
+		if err := os.RemoveAll(c.pathOf(relPath)); err != nil {
+			return nxfserrors.FromOS(err, "rendition_cache_error", "An error occurred during the removal of the cached renditions")
+		}
+	}
+	return nil
+}
+
+// pathOf - return the directory of the renditions of an image, identified by its path relative to the browsable fs root
+func (c *Cache) pathOf(relPath string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(relPath)), "/")))
+}
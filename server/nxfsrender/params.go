@@ -0,0 +1,97 @@
+package nxfsrender
+
+import (
+	"fmt"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"strconv"
+	"strings"
+)
+
+// MaxDimension - the largest width and height of a rendition
+const MaxDimension = 4096
+
+// how a rendition fits the requested width and height, when both are set
+const (
+	// FitContain - scale the image to fit inside the box keeping its proportions, never enlarging it
+	FitContain = "contain"
+	// FitCover - scale the image to cover the box keeping its proportions, cropping its center to the box
+	FitCover = "cover"
+	// FitFill - stretch the image to the box
+	FitFill = "fill"
+)
+
+// the formats of the renditions
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// Params - the parameters of a rendition. a zero width or height is computed from the other one keeping the proportions
+// of the image, an empty format is the one of the image
+type Params struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// ParseParams - parse the parameters of a rendition as received in a query. when they're all empty no rendition is requested,
+// and the zero Params is returned
+func ParseParams(width string, height string, fit string, format string) (Params, error) {
+	params := Params{Fit: strings.ToLower(fit), Format: strings.ToLower(format)}
+	var err error
+	if params.Width, err = parseDimension("width", width); err != nil {
+		return Params{}, err
+	}
+	if params.Height, err = parseDimension("height", height); err != nil {
+		return Params{}, err
+	}
+
+	switch params.Fit {
+	case "":
+		if params.Width > 0 || params.Height > 0 || "" != params.Format {
+			params.Fit = FitContain
+		}
+	case FitContain, FitCover, FitFill:
+		if params.Width == 0 && params.Height == 0 {
+			return Params{}, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_rendition", "The fit parameter needs a width or a height")
+		}
+	default:
+		return Params{}, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_rendition", fmt.Sprintf("Unknown fit %q, it must be %s, %s or %s", fit, FitContain, FitCover, FitFill))
+	}
+
+	switch params.Format {
+	case "", FormatJPEG, FormatPNG:
+	case "jpg":
+		params.Format = FormatJPEG
+	case FormatWebP:
+		// decoders of WebP exist in pure Go, encoders don't
+		return Params{}, nxfserrors.New(nxfserrors.ErrNotImplemented, "format_unsupported", "The renditions can't be encoded as WebP yet, use jpeg or png")
+	default:
+		return Params{}, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_rendition", fmt.Sprintf("Unknown format %q, it must be %s or %s", format, FormatJPEG, FormatPNG))
+	}
+	return params, nil
+}
+
+// IsZero - return true if no rendition is requested, the image is served as is
+func (p Params) IsZero() bool {
+	return p == Params{}
+}
+
+// key - return the part of the name of the cached rendition identifying its parameters, once its format is known
+func (p Params) key() string {
+	return fmt.Sprintf("%dx%d-%s.%s", p.Width, p.Height, p.Fit, p.Format)
+}
+
+// parseDimension - parse a width or a height, an empty value is 0
+func parseDimension(name string, value string) (int, error) {
+	if "" == value {
+		return 0, nil
+	}
+	dimension, err := strconv.Atoi(value)
+	if err != nil || dimension < 1 || dimension > MaxDimension {
+		return 0, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_rendition", fmt.Sprintf("The %s must be an integer between 1 and %d", name, MaxDimension))
+	}
+	return dimension, nil
+}
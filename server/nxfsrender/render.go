@@ -0,0 +1,77 @@
+package nxfsrender
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"runtime"
+)
+
+// MaxSourcePixels - the images with more pixels aren't rendered, not to exhaust the memory decoding them
+const MaxSourcePixels = 40 * 1000 * 1000
+
+// jpegQuality - the quality of the JPEG renditions
+const jpegQuality = 85
+
+// renders - a slot for every image being rendered: an image is decoded and resized only holding one of them, so that
+// the concurrent requests of renditions don't decode more images at once than the cpus can resize
+var renders = make(chan struct{}, runtime.NumCPU())
+
+// ContentType - return the content type of a rendition format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// sourceFormat - return the format of the received image and the format of its renditions when none is requested:
+// the same one, PNG for the formats that can't be encoded
+func sourceFormat(source []byte) (image.Config, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil {
+		return image.Config{}, "", nxfserrors.New(nxfserrors.ErrUnsupportedType, "image_unsupported", "Only the JPEG, PNG and GIF images can be rendered")
+	}
+	if config.Width < 1 || config.Height < 1 || int64(config.Width)*int64(config.Height) > MaxSourcePixels {
+		return image.Config{}, "", nxfserrors.New(nxfserrors.ErrUnprocessable, "image_too_large", fmt.Sprintf("Only the images up to %d pixels can be rendered", MaxSourcePixels))
+	}
+	if format != FormatJPEG {
+		format = FormatPNG
+	}
+	return config, format, nil
+}
+
+// Render - render the received image with the received parameters, whose format must be set
+func Render(source []byte, p Params) ([]byte, error) {
+	if _, _, err := sourceFormat(source); err != nil {
+		return nil, err
+	}
+	renders <- struct{}{}
+	defer func() { <-renders }()
+
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return nil, nxfserrors.New(nxfserrors.ErrUnprocessable, "image_corrupted", "The image can't be decoded: "+err.Error())
+	}
+
+	crop, width, height := geometry(img.Bounds().Dx(), img.Bounds().Dy(), p)
+	rendition := resize(img, crop, width, height)
+
+	var encoded bytes.Buffer
+	if p.Format == FormatJPEG {
+		// JPEG has no transparency, the transparent pixels become white
+		flattened := image.NewRGBA(rendition.Bounds())
+		draw.Draw(flattened, flattened.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flattened, flattened.Bounds(), rendition, image.Point{}, draw.Over)
+		err = jpeg.Encode(&encoded, flattened, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&encoded, rendition)
+	}
+	if err != nil {
+		return nil, nxfserrors.Wrap(err, "rendition_error")
+	}
+	return encoded.Bytes(), nil
+}
@@ -0,0 +1,198 @@
+package nxfsrender
+
+import (
+	"bytes"
+	"errors"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// encodedImage - return a width x height image, opaque red on the left half and transparent on the right one, encoded
+// with the received encoder
+func encodedImage(t *testing.T, width int, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width/2; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+func encodePNG(w *bytes.Buffer, img image.Image) error {
+	return png.Encode(w, img)
+}
+
+func encodeJPEG(w *bytes.Buffer, img image.Image) error {
+	return jpeg.Encode(w, img, nil)
+}
+
+func encodeGIF(w *bytes.Buffer, img image.Image) error {
+	return gif.Encode(w, img, nil)
+}
+
+func expectKind(t *testing.T, err error, kind error, code string) {
+	t.Helper()
+	var nxfsErr *nxfserrors.Error
+	if !errors.As(err, &nxfsErr) || nxfsErr.Kind != kind || nxfsErr.Code != code {
+		t.Fatalf("expected a %s error, got %v", code, err)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	if params, err := ParseParams("", "", "", ""); err != nil || !params.IsZero() {
+		t.Fatalf("expected no rendition, got %+v %v", params, err)
+	}
+	if params, err := ParseParams("100", "", "", "JPG"); err != nil || params != (Params{Width: 100, Fit: FitContain, Format: FormatJPEG}) {
+		t.Fatalf("unexpected params %+v %v", params, err)
+	}
+	for _, invalid := range [][]string{{"0", "", "", ""}, {"abc", "", "", ""}, {"", "5000", "", ""}, {"10", "10", "stretch", ""}, {"", "", "cover", ""}, {"10", "", "", "bmp"}} {
+		_, err := ParseParams(invalid[0], invalid[1], invalid[2], invalid[3])
+		expectKind(t, err, nxfserrors.ErrInvalid, "invalid_rendition")
+	}
+	_, err := ParseParams("10", "", "", "webp")
+	expectKind(t, err, nxfserrors.ErrNotImplemented, "format_unsupported")
+}
+
+func TestGeometry(t *testing.T) {
+	tests := []struct {
+		params        Params
+		crop          image.Rectangle
+		width, height int
+	}{
+		{Params{Format: FormatPNG}, image.Rect(0, 0, 200, 100), 200, 100},
+		{Params{Width: 50, Fit: FitContain}, image.Rect(0, 0, 200, 100), 50, 25},
+		{Params{Height: 50, Fit: FitFill}, image.Rect(0, 0, 200, 100), 100, 50},
+		{Params{Width: 400, Fit: FitContain}, image.Rect(0, 0, 200, 100), 200, 100},
+		{Params{Width: 400, Fit: FitCover}, image.Rect(0, 0, 200, 100), 400, 200},
+		{Params{Width: 50, Height: 50, Fit: FitContain}, image.Rect(0, 0, 200, 100), 50, 25},
+		{Params{Width: 50, Height: 50, Fit: FitCover}, image.Rect(50, 0, 150, 100), 50, 50},
+		{Params{Width: 50, Height: 50, Fit: FitFill}, image.Rect(0, 0, 200, 100), 50, 50},
+	}
+	for _, test := range tests {
+		crop, width, height := geometry(200, 100, test.params)
+		if crop != test.crop || width != test.width || height != test.height {
+			t.Errorf("%+v: expected %v %dx%d, got %v %dx%d", test.params, test.crop, test.width, test.height, crop, width, height)
+		}
+	}
+}
+
+func TestResizeMemory(t *testing.T) {
+	// a tall image keeping its width used to be resampled through a buffer of its whole height, here more than 1 GB
+	src := image.NewUniform(color.NRGBA{R: 255, G: 128, A: 255})
+	crop := image.Rect(0, 0, 4000, 10000)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	resized := resize(src, crop, 4000, 10)
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 8<<20 {
+		t.Fatalf("expected the resize to allocate a few rows, got %d bytes", allocated)
+	}
+	if resized.Bounds() != image.Rect(0, 0, 4000, 10) || resized.RGBAAt(2000, 5) != (color.RGBA{R: 255, G: 128, A: 255}) {
+		t.Fatalf("unexpected rendition %v %v", resized.Bounds(), resized.RGBAAt(2000, 5))
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := encodedImage(t, 200, 100, encodePNG)
+
+	rendered, err := Render(source, Params{Width: 50, Height: 50, Fit: FitFill, Format: FormatPNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(rendered))
+	if err != nil || img.Bounds().Dx() != 50 || img.Bounds().Dy() != 50 {
+		t.Fatalf("expected a 50x50 png, got %v %v", img, err)
+	}
+	// the colors far from the edge between the halves are kept, the transparency as well
+	if r, _, _, a := img.At(5, 25).RGBA(); r>>8 != 255 || a>>8 != 255 {
+		t.Errorf("expected an opaque red pixel, got %v", img.At(5, 25))
+	}
+	if _, _, _, a := img.At(45, 25).RGBA(); a != 0 {
+		t.Errorf("expected a transparent pixel, got %v", img.At(45, 25))
+	}
+
+	// JPEG has no transparency
+	rendered, err = Render(source, Params{Width: 20, Fit: FitContain, Format: FormatJPEG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = jpeg.Decode(bytes.NewReader(rendered))
+	if err != nil || img.Bounds().Dx() != 20 || img.Bounds().Dy() != 10 {
+		t.Fatalf("expected a 20x10 jpeg, got %v %v", img, err)
+	}
+	if r, g, b, _ := img.At(18, 5).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("expected the transparent pixels to become white, got %v", img.At(18, 5))
+	}
+
+	_, err = Render([]byte("not an image"), Params{Width: 20, Fit: FitContain, Format: FormatPNG})
+	expectKind(t, err, nxfserrors.ErrUnsupportedType, "image_unsupported")
+	_, err = Render(encodedImage(t, 8000, 8000, encodePNG), Params{Width: 20, Fit: FitContain, Format: FormatPNG})
+	expectKind(t, err, nxfserrors.ErrUnprocessable, "image_too_large")
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-renditions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := NewCache(dir)
+
+	// without a format the renditions keep the one of the image, the formats that can't be encoded become PNG
+	for _, test := range []struct {
+		source      []byte
+		contentType string
+	}{{encodedImage(t, 40, 40, encodeJPEG), "image/jpeg"}, {encodedImage(t, 40, 40, encodePNG), "image/png"}, {encodedImage(t, 40, 40, encodeGIF), "image/png"}} {
+		rendition, err := cache.Get("images/a", test.source, Params{Width: 10, Fit: FitContain})
+		if err != nil || rendition.ContentType != test.contentType {
+			t.Fatalf("expected a %s rendition, got %s %v", test.contentType, rendition.ContentType, err)
+		}
+	}
+
+	source := encodedImage(t, 40, 40, encodePNG)
+	params := Params{Width: 10, Fit: FitContain, Format: FormatPNG}
+	rendition, err := cache.Get("/images/logo.png", source, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := filepath.Join(dir, "images", "logo.png", rendition.ETag)
+	if content, err := ioutil.ReadFile(cached); err != nil || !bytes.Equal(content, rendition.Content) {
+		t.Fatalf("expected the rendition to be cached, got %v", err)
+	}
+
+	// the cached rendition is served as is
+	if err = ioutil.WriteFile(cached, []byte("cached"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := cache.Get("images/logo.png", source, params); err != nil || "cached" != string(again.Content) || again.ETag != rendition.ETag {
+		t.Fatalf("expected the cached rendition, got %q %v", again.Content, err)
+	}
+
+	// another version of the image has other renditions
+	if other, err := cache.Get("images/logo.png", encodedImage(t, 41, 40, encodePNG), params); err != nil || other.ETag == rendition.ETag || "cached" == string(other.Content) {
+		t.Fatalf("expected another rendition, got %s %v", other.ETag, err)
+	}
+
+	if err = cache.Invalidate("images"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "images")); !os.IsNotExist(err) {
+		t.Fatalf("expected the renditions under images to be removed, got %v", err)
+	}
+}
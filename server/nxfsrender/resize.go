@@ -0,0 +1,165 @@
+package nxfsrender
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// geometry - return the part of an image of the received size that's kept in the rendition, and the size it's scaled to
+func geometry(srcWidth int, srcHeight int, p Params) (image.Rectangle, int, int) {
+	crop := image.Rect(0, 0, srcWidth, srcHeight)
+	if p.Width == 0 && p.Height == 0 {
+		return crop, srcWidth, srcHeight
+	}
+
+	// with a single dimension the other one keeps the proportions, whatever the fit
+	if p.Width == 0 || p.Height == 0 {
+		scale := float64(p.Width) / float64(srcWidth)
+		if p.Width == 0 {
+			scale = float64(p.Height) / float64(srcHeight)
+		}
+		if p.Fit == FitContain && scale > 1 {
+			scale = 1
+		}
+		return crop, scaled(srcWidth, scale), scaled(srcHeight, scale)
+	}
+
+	switch p.Fit {
+	case FitFill:
+		return crop, p.Width, p.Height
+	case FitCover:
+		scale := math.Max(float64(p.Width)/float64(srcWidth), float64(p.Height)/float64(srcHeight))
+		cropWidth := clamp(int(math.Round(float64(p.Width)/scale)), 1, srcWidth)
+		cropHeight := clamp(int(math.Round(float64(p.Height)/scale)), 1, srcHeight)
+		x, y := (srcWidth-cropWidth)/2, (srcHeight-cropHeight)/2
+		return image.Rect(x, y, x+cropWidth, y+cropHeight), p.Width, p.Height
+	}
+	scale := math.Min(1, math.Min(float64(p.Width)/float64(srcWidth), float64(p.Height)/float64(srcHeight)))
+	return crop, scaled(srcWidth, scale), scaled(srcHeight, scale)
+}
+
+// sourceRowsKept - how many rows converted to RGBA are kept by the sourceRows of a resize
+const sourceRowsKept = 4
+
+// resize - resample the received part of an image to the received size with a triangle filter, as wide as the scale
+// when shrinking so that every source pixel contributes. the pixels are resampled premultiplied by their alpha, one
+// rendition row at a time: the memory used besides the rendition is a few rows of the source, whatever its height
+func resize(src image.Image, crop image.Rectangle, width int, height int) *image.RGBA {
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	origin := src.Bounds().Min.Add(crop.Min)
+	if width == crop.Dx() && height == crop.Dy() {
+		draw.Draw(resized, resized.Bounds(), src, origin, draw.Src)
+		return resized
+	}
+
+	// every rendition row resamples the source rows to a line as wide as the source, then the columns of the line
+	rows := newSourceRows(src, origin, crop.Dx())
+	columns := weights(crop.Dx(), width)
+	line := make([]float64, 4*crop.Dx())
+	for y, contributions := range weights(crop.Dy(), height) {
+		for i := range line {
+			line[i] = 0
+		}
+		for _, c := range contributions {
+			for i, value := range rows.get(c.index) {
+				line[i] += c.weight * float64(value)
+			}
+		}
+
+		out := resized.Pix[y*resized.Stride:]
+		for x, contributions := range columns {
+			var pixel [4]float64
+			for _, c := range contributions {
+				for i := 0; i < 4; i++ {
+					pixel[i] += c.weight * line[4*c.index+i]
+				}
+			}
+			for i := 0; i < 4; i++ {
+				out[4*x+i] = uint8(clamp(int(math.Round(pixel[i])), 0, 255))
+			}
+			// premultiplied components can't exceed the alpha
+			for i := 0; i < 3; i++ {
+				if out[4*x+i] > out[4*x+3] {
+					out[4*x+i] = out[4*x+3]
+				}
+			}
+		}
+	}
+	return resized
+}
+
+// sourceRows - the rows of the resized part of an image, converted to RGBA when requested. the last sourceRowsKept
+// ones are kept, so that the consecutive rendition rows sharing them don't convert them again
+type sourceRows struct {
+	src     image.Image
+	origin  image.Point
+	kept    *image.RGBA
+	indexes [sourceRowsKept]int
+}
+
+// newSourceRows - create the sourceRows of the part of an image starting at origin and as wide as the received width
+func newSourceRows(src image.Image, origin image.Point, width int) *sourceRows {
+	rows := &sourceRows{src: src, origin: origin, kept: image.NewRGBA(image.Rect(0, 0, width, sourceRowsKept))}
+	for i := range rows.indexes {
+		rows.indexes[i] = -1
+	}
+	return rows
+}
+
+// get - return the RGBA pixels of the row of the resized part with the received index
+func (r *sourceRows) get(index int) []uint8 {
+	slot := index % sourceRowsKept
+	if r.indexes[slot] != index {
+		draw.Draw(r.kept, image.Rect(0, slot, r.kept.Rect.Dx(), slot+1), r.src, r.origin.Add(image.Pt(0, index)), draw.Src)
+		r.indexes[slot] = index
+	}
+	return r.kept.Pix[slot*r.kept.Stride : slot*r.kept.Stride+4*r.kept.Rect.Dx()]
+}
+
+// contribution - the weight of a source pixel in a resampled one
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// weights - return, for every pixel of a line resampled from srcLength to dstLength pixels, the normalized weights of
+// the source pixels contributing to it
+func weights(srcLength int, dstLength int) [][]contribution {
+	scale := float64(srcLength) / float64(dstLength)
+	support := math.Max(1, scale)
+	result := make([][]contribution, dstLength)
+	for i := range result {
+		center := (float64(i)+0.5)*scale - 0.5
+		var contributions []contribution
+		total := 0.0
+		for j := int(math.Floor(center - support)); j <= int(math.Ceil(center+support)); j++ {
+			weight := 1 - math.Abs(float64(j)-center)/support
+			if weight <= 0 {
+				continue
+			}
+			contributions = append(contributions, contribution{index: clamp(j, 0, srcLength-1), weight: weight})
+			total += weight
+		}
+		for k := range contributions {
+			contributions[k].weight /= total
+		}
+		result[i] = contributions
+	}
+	return result
+}
+
+// scaled - return a dimension scaled by the received factor, at least one pixel
+func scaled(dimension int, scale float64) int {
+	return clamp(int(math.Round(float64(dimension)*scale)), 1, MaxDimension)
+}
+
+func clamp(value int, min int, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,114 @@
+package nxfsscan
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize - the size of the chunks the content is streamed to clamd in
+const chunkSize = 64 << 10
+
+// maxReplySize - the replies of clamd longer than this are truncated
+const maxReplySize = 4 << 10
+
+// Clamd - a Scanner streaming the content to a clamd compatible daemon with the INSTREAM command
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd - create a scanner connecting to the daemon at the received address: unix:/path or /path for a unix socket,
+// tcp:host:port or host:port for a tcp one. a scan taking longer than the timeout fails
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		return &Clamd{network: "unix", address: strings.TrimPrefix(address, "unix:"), timeout: timeout}
+	case strings.HasPrefix(address, "/"):
+		return &Clamd{network: "unix", address: address, timeout: timeout}
+	}
+	return &Clamd{network: "tcp", address: strings.TrimPrefix(address, "tcp:"), timeout: timeout}
+}
+
+// Name - implement Scanner
+func (c *Clamd) Name() string {
+	return "clamd"
+}
+
+// Scan - implement Scanner. the content is sent in chunks, each one preceded by its length as a 4 bytes big endian
+// integer and the last one empty; clamd replies with "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR"
+func (c *Clamd) Scan(content io.Reader) (string, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return "", fmt.Errorf("can't connect to clamd: %s", err.Error())
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", err
+	}
+
+	if sendErr := c.send(conn, content); sendErr != nil {
+		// clamd stops reading a stream larger than its StreamMaxLength, its reply tells why
+		if reply, readErr := readReply(conn); readErr == nil && "" != reply {
+			return parseReply(reply)
+		}
+		return "", sendErr
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return "", fmt.Errorf("can't read the reply of clamd: %s", err.Error())
+	}
+	return parseReply(reply)
+}
+
+// send - send the INSTREAM command followed by the content
+func (c *Clamd) send(conn net.Conn, content io.Reader) error {
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return fmt.Errorf("can't send the content to clamd: %s", err.Error())
+	}
+
+	chunk := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := io.ReadFull(content, chunk[4:])
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return readErr
+		}
+		binary.BigEndian.PutUint32(chunk, uint32(n))
+		if _, err := conn.Write(chunk[:4+n]); err != nil {
+			return fmt.Errorf("can't send the content to clamd: %s", err.Error())
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+// readReply - read the reply of clamd, terminated by a zero byte or by the end of the connection
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(io.LimitReader(conn, maxReplySize)).ReadString(0)
+	if err != nil && (err != io.EOF || "" == reply) {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// parseReply - return the signature of a FOUND reply, an empty string for an OK one and an error for the others
+func parseReply(reply string) (string, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		if signature := strings.TrimSpace(strings.TrimSuffix(result, " FOUND")); "" != signature {
+			return signature, nil
+		}
+		return unknownSignature, nil
+	}
+	return "", fmt.Errorf("clamd replied %q", reply)
+}
@@ -0,0 +1,107 @@
+package nxfsscan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Guard - scans the files before they're written, keeping the ones that are infected or can't be scanned in a quarantine
+// directory instead of the browsable fs. every quarantined file is stored as <id> next to its description, <id>.json
+type Guard struct {
+	scanner    Scanner
+	quarantine string
+}
+
+// NewGuard - create a guard scanning the files with the received scanner and quarantining them in the received directory
+func NewGuard(scanner Scanner, quarantine string) *Guard {
+	return &Guard{scanner: scanner, quarantine: quarantine}
+}
+
+// Check - scan the content about to be written by the received user to a path relative to the browsable fs root, returning
+// the result of the scan if it's clean. an infected content is quarantined and rejected with an infected error, one that
+// couldn't be scanned with a scan_failed error
+func (g *Guard) Check(relPath string, content io.Reader, user string) (model.ScanResult, error) {
+	if err := os.MkdirAll(g.quarantine, 0700); err != nil {
+		return model.ScanResult{}, nxfserrors.FromOS(err, "quarantine_error", "An error occurred during the creation of the quarantine directory")
+	}
+
+	// the content is spooled while it's scanned, to be quarantined without reading it again
+	id := helper.NewRandomId()
+	quarantinedPath := filepath.Join(g.quarantine, id)
+	spooledPath := nxfsfiles.TempPathFor(quarantinedPath)
+	spooled, err := os.OpenFile(spooledPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return model.ScanResult{}, nxfserrors.FromOS(err, "quarantine_error", "An error occurred during the spooling of the content to scan")
+	}
+	defer os.Remove(spooledPath)
+	defer spooled.Close()
+
+	hash := sha256.New()
+	tee := io.TeeReader(content, io.MultiWriter(spooled, hash))
+	signature, scanErr := g.scanner.Scan(tee)
+	// the scanner may stop reading before the end, the whole content is spooled anyway
+	if _, err = io.Copy(ioutil.Discard, tee); err != nil {
+		return model.ScanResult{}, nxfserrors.Wrap(err, "scan_read_error")
+	}
+
+	result := model.ScanResult{Status: model.ScanClean, Scanner: g.scanner.Name(), Hash: hex.EncodeToString(hash.Sum(nil)), At: time.Now().UTC()}
+	switch {
+	case scanErr != nil:
+		result.Status, result.Error = model.ScanFailed, scanErr.Error()
+	case "" != signature:
+		result.Status, result.Signature = model.ScanInfected, signature
+	default:
+		return result, nil
+	}
+
+	if err = g.keep(id, spooled, relPath, user, result); err != nil {
+		return model.ScanResult{}, err
+	}
+	log.Printf("Quarantined the file written to %s by %q as %s: %s%s", relPath, user, id, result.Signature, result.Error)
+
+	var rejection *nxfserrors.Error
+	if result.Status == model.ScanInfected {
+		rejection = nxfserrors.New(nxfserrors.ErrUnprocessable, "infected", fmt.Sprintf("The file %s is infected by %s, it has been quarantined", relPath, signature))
+	} else {
+		rejection = nxfserrors.New(nxfserrors.ErrUnavailable, "scan_failed", fmt.Sprintf("The file %s couldn't be scanned, it has been quarantined", relPath))
+		rejection.Err = scanErr
+	}
+	rejection.Details = []model.ResultDetail{{Field: relPath, Message: "quarantined as " + id}}
+	return model.ScanResult{}, rejection
+}
+
+// keep - move the spooled content to the quarantine, next to its description
+func (g *Guard) keep(id string, spooled *os.File, relPath string, user string, result model.ScanResult) error {
+	fileInfo, err := spooled.Stat()
+	if err == nil {
+		err = spooled.Close()
+	}
+	if err == nil {
+		err = nxfsfiles.CommitStagedFile(spooled.Name(), filepath.Join(g.quarantine, id))
+	}
+	if err != nil {
+		return nxfserrors.FromOS(err, "quarantine_error", "An error occurred during the quarantine of a rejected file")
+	}
+
+	description, err := json.MarshalIndent(model.QuarantinedFile{Id: id, Path: relPath, Size: fileInfo.Size(), User: user, At: result.At, Scan: result}, "", "  ")
+	if err != nil {
+		return nxfserrors.Wrap(err, "quarantine_error")
+	}
+	if err = nxfsfiles.WriteFileAtomic(filepath.Join(g.quarantine, id+".json"), bytes.NewReader(description), 0600); err != nil {
+		return nxfserrors.FromOS(err, "quarantine_error", "An error occurred during the description of a quarantined file")
+	}
+	return nil
+}
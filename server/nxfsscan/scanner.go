@@ -0,0 +1,86 @@
+package nxfsscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Scanner - scans the content of the files before they're written to the browsable fs
+type Scanner interface {
+	// Name - the name of the scanner, recorded with the results of its scans
+	Name() string
+	// Scan - return the name of the malware found in the received content, an empty string if it's clean, or an error
+	// if the content couldn't be scanned
+	Scan(content io.Reader) (string, error)
+}
+
+// unknownSignature - the signature of the malware found by a scanner that doesn't name it
+const unknownSignature = "unknown"
+
+// Exec - a Scanner running a command with the content on its standard input, like clamscan --no-summary -.
+// the command exits with 0 if the content is clean and with 1 if it's infected, printing the name of the malware on the
+// first line of its output; any other exit code, or a command running longer than the timeout, is a failure
+type Exec struct {
+	command []string
+	timeout time.Duration
+}
+
+// NewExec - create a scanner running the received command, made of the program and its arguments
+func NewExec(command []string, timeout time.Duration) *Exec {
+	return &Exec{command: command, timeout: timeout}
+}
+
+// Name - implement Scanner
+func (e *Exec) Name() string {
+	return "exec"
+}
+
+// Scan - implement Scanner
+func (e *Exec) Scan(content io.Reader) (string, error) {
+	if len(e.command) == 0 {
+		return "", fmt.Errorf("no scan command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Stdin = content
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	switch exitErr, isExitErr := err.(*exec.ExitError); {
+	case ctx.Err() != nil:
+		return "", fmt.Errorf("the scan command didn't complete in %s", e.timeout)
+	case err == nil:
+		return "", nil
+	case isExitErr && exitErr.ExitCode() == 1:
+		return signatureOf(stdout.String()), nil
+	case isExitErr:
+		return "", fmt.Errorf("the scan command exited with %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+	default:
+		return "", fmt.Errorf("the scan command can't be run: %s", err.Error())
+	}
+}
+
+// signatureOf - return the name of the malware printed on the first line of the output of a scan command,
+// stripping the "stdin: " prefix and the " FOUND" suffix of clamscan
+func signatureOf(output string) string {
+	line, _ := bufio.NewReader(strings.NewReader(strings.TrimSpace(output))).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if colon := strings.LastIndex(line, ": "); colon >= 0 {
+		line = line[colon+2:]
+	}
+	if line = strings.TrimSpace(strings.TrimSuffix(line, " FOUND")); "" != line {
+		return line
+	}
+	return unknownSignature
+}
@@ -0,0 +1,219 @@
+package nxfsscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd - a clamd daemon finding the EICAR malware in the streams containing EICAR and failing on those containing BOOM
+type fakeClamd struct {
+	listener net.Listener
+	// chunks - the sizes of the chunks of the last stream
+	chunks []int
+}
+
+func startFakeClamd(t *testing.T, network string, address string) *fakeClamd {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &fakeClamd{listener: listener}
+	go daemon.serve()
+	return daemon
+}
+
+func (d *fakeClamd) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		d.handle(conn)
+	}
+}
+
+func (d *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	command := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+
+	d.chunks = nil
+	var stream bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		d.chunks = append(d.chunks, int(size))
+		if _, err := io.CopyN(&stream, conn, int64(size)); err != nil {
+			return
+		}
+	}
+
+	switch {
+	case strings.Contains(stream.String(), "EICAR"):
+		io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+	case strings.Contains(stream.String(), "BOOM"):
+		io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+	default:
+		io.WriteString(conn, "stream: OK\x00")
+	}
+}
+
+func TestClamd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-clamd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tcpDaemon := startFakeClamd(t, "tcp", "127.0.0.1:0")
+	defer tcpDaemon.listener.Close()
+	unixDaemon := startFakeClamd(t, "unix", filepath.Join(dir, "clamd.sock"))
+	defer unixDaemon.listener.Close()
+
+	for _, address := range []string{tcpDaemon.listener.Addr().String(), "tcp:" + tcpDaemon.listener.Addr().String(), "unix:" + filepath.Join(dir, "clamd.sock"), filepath.Join(dir, "clamd.sock")} {
+		scanner := NewClamd(address, 5*time.Second)
+		if signature, err := scanner.Scan(strings.NewReader("clean content")); err != nil || "" != signature {
+			t.Fatalf("%s: expected a clean content, got %q %v", address, signature, err)
+		}
+		if signature, err := scanner.Scan(strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR")); err != nil || "Eicar-Test-Signature" != signature {
+			t.Fatalf("%s: expected an infected content, got %q %v", address, signature, err)
+		}
+		if _, err := scanner.Scan(strings.NewReader("BOOM")); err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+			t.Fatalf("%s: expected the error of clamd, got %v", address, err)
+		}
+	}
+
+	// the content is streamed in chunks
+	if _, err := NewClamd(tcpDaemon.listener.Addr().String(), 5*time.Second).Scan(bytes.NewReader(make([]byte, chunkSize+10))); err != nil {
+		t.Fatal(err)
+	}
+	if len(tcpDaemon.chunks) != 2 || tcpDaemon.chunks[0] != chunkSize || tcpDaemon.chunks[1] != 10 {
+		t.Fatalf("unexpected chunks %v", tcpDaemon.chunks)
+	}
+
+	if _, err := NewClamd("unix:"+filepath.Join(dir, "missing.sock"), time.Second).Scan(strings.NewReader("content")); err == nil {
+		t.Fatal("expected a missing daemon to fail the scan")
+	}
+}
+
+func TestExec(t *testing.T) {
+	script := `content=$(cat); case "$content" in *EICAR*) echo "stdin: Eicar-Test-Signature FOUND"; exit 1;; *BOOM*) echo "can't scan" >&2; exit 2;; esac`
+	scanner := NewExec([]string{"sh", "-c", script}, 5*time.Second)
+
+	if signature, err := scanner.Scan(strings.NewReader("clean content")); err != nil || "" != signature {
+		t.Fatalf("expected a clean content, got %q %v", signature, err)
+	}
+	if signature, err := scanner.Scan(strings.NewReader("EICAR")); err != nil || "Eicar-Test-Signature" != signature {
+		t.Fatalf("expected an infected content, got %q %v", signature, err)
+	}
+	if _, err := scanner.Scan(strings.NewReader("BOOM")); err == nil || !strings.Contains(err.Error(), "exited with 2: can't scan") {
+		t.Fatalf("expected the failure of the command, got %v", err)
+	}
+	if signature, err := NewExec([]string{"sh", "-c", "exit 1"}, 5*time.Second).Scan(strings.NewReader("")); err != nil || unknownSignature != signature {
+		t.Fatalf("expected an unknown malware, got %q %v", signature, err)
+	}
+	if _, err := NewExec([]string{"sh", "-c", "exec sleep 5"}, 100*time.Millisecond).Scan(strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "didn't complete") {
+		t.Fatalf("expected the scan to time out, got %v", err)
+	}
+	if _, err := NewExec([]string{filepath.Join(os.TempDir(), "missing-scanner")}, time.Second).Scan(strings.NewReader("")); err == nil {
+		t.Fatal("expected a missing command to fail the scan")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	guard := NewGuard(NewExec([]string{"sh", "-c", `c=$(head -c 16); case "$c" in *EICAR*) echo Eicar; exit 1;; *BOOM*) exit 2;; esac`}, 5*time.Second), filepath.Join(dir, "quarantine"))
+
+	result, err := guard.Check("docs/a.txt", strings.NewReader("clean content"), "alice")
+	if err != nil || result.Status != model.ScanClean || result.Scanner != "exec" || len(result.Hash) != 64 {
+		t.Fatalf("expected a clean result, got %+v %v", result, err)
+	}
+
+	_, err = guard.Check("docs/b.txt", strings.NewReader("EICAR"), "alice")
+	var scanErr *nxfserrors.Error
+	if !errors.As(err, &scanErr) || scanErr.Kind != nxfserrors.ErrUnprocessable || scanErr.Code != "infected" || len(scanErr.Details) != 1 {
+		t.Fatalf("expected an infected error, got %v", err)
+	}
+	id := strings.TrimPrefix(scanErr.Details[0].Message, "quarantined as ")
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "quarantine", id)); err != nil || "EICAR" != string(content) {
+		t.Fatalf("expected the infected content to be quarantined, got %q %v", content, err)
+	}
+	var quarantined model.QuarantinedFile
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "quarantine", id+".json")); err != nil || json.Unmarshal(content, &quarantined) != nil {
+		t.Fatalf("expected the quarantined file to be described, got %v", err)
+	}
+	if quarantined.Path != "docs/b.txt" || quarantined.User != "alice" || quarantined.Size != 5 || quarantined.Scan.Status != model.ScanInfected || quarantined.Scan.Signature != "Eicar" {
+		t.Fatalf("unexpected description %+v", quarantined)
+	}
+
+	// the command reads only the beginning of the content, the whole content is quarantined anyway
+	failed := strings.Repeat("BOOM", 100000)
+	if _, err = guard.Check("docs/c.txt", strings.NewReader(failed), "bob"); !errors.As(err, &scanErr) || scanErr.Kind != nxfserrors.ErrUnavailable || scanErr.Code != "scan_failed" {
+		t.Fatalf("expected a scan_failed error, got %v", err)
+	}
+	id = strings.TrimPrefix(scanErr.Details[0].Message, "quarantined as ")
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "quarantine", id)); err != nil || failed != string(content) {
+		t.Fatalf("expected the whole content to be quarantined, got %d bytes %v", len(content), err)
+	}
+
+	if entries, _ := ioutil.ReadDir(filepath.Join(dir, "quarantine")); len(entries) != 4 {
+		t.Fatalf("expected only the two rejected files and their descriptions in the quarantine, got %d entries", len(entries))
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-scans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storePath := filepath.Join(dir, "data", "scans.json")
+
+	store := NewStore(storePath)
+	clean := model.ScanResult{Status: model.ScanClean, Scanner: "clamd", Hash: "hash", At: time.Now().UTC().Truncate(time.Second)}
+	if err = store.Record(map[string]model.ScanResult{"/docs/a.txt": clean, "docs/sub/b.txt": clean, "docsa.txt": clean}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the results survive a restart
+	store = NewStore(storePath)
+	if result, err := store.Get("docs/a.txt"); err != nil || result == nil || *result != clean {
+		t.Fatalf("expected the recorded result, got %v %v", result, err)
+	}
+	if result, err := store.Get("docs/missing.txt"); err != nil || result != nil {
+		t.Fatalf("expected no result, got %v %v", result, err)
+	}
+
+	if err = store.Removed("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	for relPath, expected := range map[string]bool{"docs/a.txt": false, "docs/sub/b.txt": false, "docsa.txt": true} {
+		if result, _ := store.Get(relPath); (result != nil) != expected {
+			t.Errorf("expected the result of %s to be kept: %v", relPath, expected)
+		}
+	}
+}
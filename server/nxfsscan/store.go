@@ -0,0 +1,131 @@
+package nxfsscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store - the results of the scans of the files of the browsable fs by path, persisted as a JSON file rewritten atomically
+// on every change. a file without a stored result hasn't been scanned
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	loaded  bool
+	results map[string]model.ScanResult
+}
+
+// NewStore - create a Store backed by the file identified by the received path. the file is read on the first access
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Get - return the result of the last scan of the file identified by the received path, relative to the browsable fs root,
+// nil if it hasn't been scanned
+func (s *Store) Get(relPath string) (*model.ScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if result, ok := s.results[cleanPath(relPath)]; ok {
+		return &result, nil
+	}
+	return nil, nil
+}
+
+// Record - record the results of the scans of the received files, by path relative to the browsable fs root
+func (s *Store) Record(results map[string]model.ScanResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	for relPath, result := range results {
+		s.results[cleanPath(relPath)] = result
+	}
+	return s.save()
+}
+
+// Removed - forget the results of the received files, and of the files under them if they're directories,
+// because they've been removed or written without scanning them
+func (s *Store) Removed(relPaths ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	removed := false
+	for scannedPath := range s.results {
+		for _, relPath := range relPaths {
+			if relPath = cleanPath(relPath); "" == relPath || scannedPath == relPath || strings.HasPrefix(scannedPath, relPath+"/") {
+				delete(s.results, scannedPath)
+				removed = true
+				break
+			}
+		}
+	}
+	if !removed {
+		return nil
+	}
+	return s.save()
+}
+
+// cleanPath - return the received path, relative to the browsable fs root, as a clean slash separated path without leading slash
+func cleanPath(relPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(relPath)), "/")
+}
+
+// load - read the results file if not already done, a missing file is an empty store. must be called holding mu
+func (s *Store) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	results := map[string]model.ScanResult{}
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nxfserrors.FromOS(err, "scan_store_error", "An error occurred during the reading of the scan results")
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &results); err != nil {
+			return nxfserrors.New(nxfserrors.ErrInternal, "scan_store_error", fmt.Sprintf("The scan results file %s is corrupted: %s", s.path, err.Error()))
+		}
+	}
+
+	s.results = results
+	s.loaded = true
+	return nil
+}
+
+// save - atomically rewrite the results file. must be called holding mu
+func (s *Store) save() error {
+	content, err := json.MarshalIndent(s.results, "", "  ")
+	if err != nil {
+		return nxfserrors.Wrap(err, "scan_store_error")
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nxfserrors.FromOS(err, "scan_store_error", "An error occurred during the creation of the data dir")
+	}
+	if err = nxfsfiles.WriteFileAtomic(s.path, bytes.NewReader(content), 0644); err != nil {
+		return nxfserrors.FromOS(err, "scan_store_error", "An error occurred during the writing of the scan results")
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package nxfsschedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// interruptedError - the error of the schedules found running when the store is loaded
+const interruptedError = "the execution was interrupted by the shutdown of nxfs"
+
+// Store - the pending, running and failed schedules, persisted as a JSON file rewritten atomically on every change
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	loaded    bool
+	schedules map[string]model.Schedule
+}
+
+// NewStore - create a Store backed by the file identified by the received path. the file is read on the first access
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Add - persist a new pending schedule
+func (s *Store) Add(schedule model.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	schedule.Status = model.SchedulePending
+	s.schedules[schedule.Id] = schedule
+	if err := s.save(); err != nil {
+		delete(s.schedules, schedule.Id)
+		return err
+	}
+	return nil
+}
+
+// List - return the schedules sorted by execution time
+func (s *Store) List() ([]model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	list := make([]model.Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		list = append(list, schedule)
+	}
+	sortSchedules(list)
+	return list, nil
+}
+
+// Remove - delete the schedule identified by the received id, cancelling it if pending, returning it
+func (s *Store) Remove(id string) (model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return model.Schedule{}, err
+	}
+
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return model.Schedule{}, nxfserrors.New(nxfserrors.ErrNotFound, "schedule_not_found", fmt.Sprintf("No schedule has id %s", id))
+	}
+
+	delete(s.schedules, id)
+	if err := s.save(); err != nil {
+		s.schedules[id] = schedule
+		return model.Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// Claim - mark as running the pending schedule identified by the received id, returning it, so that it's never executed
+// twice even if nxfs stops during its execution
+func (s *Store) Claim(id string) (model.Schedule, error) {
+	return s.update(id, model.SchedulePending, func(schedule *model.Schedule) {
+		schedule.Status = model.ScheduleRunning
+	})
+}
+
+// Fail - mark as failed, with the received error, the running schedule identified by the received id. the failed
+// schedules are kept until they're removed
+func (s *Store) Fail(id string, message string, at time.Time) (model.Schedule, error) {
+	return s.update(id, model.ScheduleRunning, func(schedule *model.Schedule) {
+		at = at.UTC()
+		schedule.Status = model.ScheduleFailed
+		schedule.Error = message
+		schedule.ExecutedAt = &at
+	})
+}
+
+// update - change the schedule identified by the received id if it's in the received status, a schedule_not_found error otherwise
+func (s *Store) update(id string, status model.ScheduleStatus, change func(schedule *model.Schedule)) (model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return model.Schedule{}, err
+	}
+
+	schedule, ok := s.schedules[id]
+	if !ok || schedule.Status != status {
+		return model.Schedule{}, nxfserrors.New(nxfserrors.ErrNotFound, "schedule_not_found", fmt.Sprintf("No %s schedule has id %s", status, id))
+	}
+
+	updated := schedule
+	change(&updated)
+	s.schedules[id] = updated
+	if err := s.save(); err != nil {
+		s.schedules[id] = schedule
+		return model.Schedule{}, err
+	}
+	return updated, nil
+}
+
+// Due - return the pending schedules whose execution time is not after now, sorted by execution time
+func (s *Store) Due(now time.Time) ([]model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var due []model.Schedule
+	for _, schedule := range s.schedules {
+		if schedule.Status == model.SchedulePending && !schedule.At.After(now) {
+			due = append(due, schedule)
+		}
+	}
+	sortSchedules(due)
+	return due, nil
+}
+
+// load - read the schedules file if not already done, a missing file is an empty store. the schedules left running
+// by a previous execution of nxfs are failed, they may have been partially executed. must be called holding mu
+func (s *Store) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	schedules := map[string]model.Schedule{}
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nxfserrors.FromOS(err, "schedule_store_error", "An error occurred during the reading of the schedules")
+	}
+
+	if len(content) > 0 {
+		var list model.ScheduleList
+		if err = json.Unmarshal(content, &list); err != nil {
+			return nxfserrors.New(nxfserrors.ErrInternal, "schedule_store_error", fmt.Sprintf("The schedules file %s is corrupted: %s", s.path, err.Error()))
+		}
+		for _, schedule := range list.List {
+			if schedule.Status == model.ScheduleRunning {
+				schedule.Status = model.ScheduleFailed
+				schedule.Error = interruptedError
+			}
+			schedules[schedule.Id] = schedule
+		}
+	}
+
+	s.schedules = schedules
+	s.loaded = true
+	return nil
+}
+
+// save - atomically rewrite the schedules file with the current schedules. must be called holding mu
+func (s *Store) save() error {
+	list := model.ScheduleList{List: make([]model.Schedule, 0, len(s.schedules))}
+	for _, schedule := range s.schedules {
+		list.List = append(list.List, schedule)
+	}
+	sortSchedules(list.List)
+
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return nxfserrors.Wrap(err, "schedule_store_error")
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nxfserrors.FromOS(err, "schedule_store_error", "An error occurred during the creation of the data dir")
+	}
+	if err = nxfsfiles.WriteFileAtomic(s.path, bytes.NewReader(content), 0644); err != nil {
+		return nxfserrors.FromOS(err, "schedule_store_error", "An error occurred during the writing of the schedules")
+	}
+	return nil
+}
+
+// sortSchedules - sort the schedules by execution time, then by id to keep the order stable
+func sortSchedules(schedules []model.Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].At.Equal(schedules[j].At) {
+			return schedules[i].Id < schedules[j].Id
+		}
+		return schedules[i].At.Before(schedules[j].At)
+	})
+}
@@ -0,0 +1,143 @@
+package nxfsschedule
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore - return a Store backed by a file in a new temporary directory, removed by the returned function
+func newTestStore(t *testing.T) (*Store, string, func()) {
+	dir, err := ioutil.TempDir("", "nxfs-schedules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storePath := filepath.Join(dir, "data", "schedules.json")
+	return NewStore(storePath), storePath, func() { os.RemoveAll(dir) }
+}
+
+// ids - return the ids of the schedules, comma separated
+func ids(schedules []model.Schedule) string {
+	list := make([]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		list = append(list, schedule.Id)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestStoreDue(t *testing.T) {
+	store, _, cleanup := newTestStore(t)
+	defer cleanup()
+
+	now := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, schedule := range []model.Schedule{
+		{Id: "later", At: now.Add(time.Minute)},
+		{Id: "b", At: now.Add(-time.Hour)},
+		{Id: "now", At: now},
+		{Id: "a", At: now.Add(-time.Hour)},
+		{Id: "first", At: now.Add(-2 * time.Hour)},
+	} {
+		if err := store.Add(schedule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sorted by execution time, then by id
+	due, err := store.Due(now)
+	if err != nil || ids(due) != "first,a,b,now" {
+		t.Fatalf("unexpected due schedules %s %v", ids(due), err)
+	}
+	// only the pending ones
+	if _, err = store.Claim("a"); err != nil {
+		t.Fatal(err)
+	}
+	if due, _ = store.Due(now); ids(due) != "first,b,now" {
+		t.Fatalf("expected the running schedule not to be due, got %s", ids(due))
+	}
+	if list, _ := store.List(); ids(list) != "first,a,b,now,later" {
+		t.Fatalf("unexpected schedules %s", ids(list))
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	store, storePath, cleanup := newTestStore(t)
+	defer cleanup()
+
+	at := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, id := range []string{"pending", "running", "failed", "removed"} {
+		if err := store.Add(model.Schedule{Id: id, Operation: model.SchedulePublish, Path: "home", At: at, CreatedBy: "alice", Status: model.SchedulePending}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Claim("running"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Claim("failed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Fail("failed", "not_approved: the page is a draft", at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Remove("removed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the schedule left running by a stopped nxfs may have been partially executed, it's not executed again
+	reloaded, err := NewStore(storePath).List()
+	if err != nil || ids(reloaded) != "failed,pending,running" {
+		t.Fatalf("unexpected reloaded schedules %s %v", ids(reloaded), err)
+	}
+	failed, pending, interrupted := reloaded[0], reloaded[1], reloaded[2]
+	if pending.Status != model.SchedulePending || pending.Path != "home" || pending.CreatedBy != "alice" || !pending.At.Equal(at) {
+		t.Fatalf("unexpected pending schedule %+v", pending)
+	}
+	if failed.Status != model.ScheduleFailed || failed.Error != "not_approved: the page is a draft" || failed.ExecutedAt == nil || !failed.ExecutedAt.Equal(at.Add(time.Minute)) {
+		t.Fatalf("unexpected failed schedule %+v", failed)
+	}
+	if interrupted.Status != model.ScheduleFailed || interrupted.Error != interruptedError {
+		t.Fatalf("unexpected interrupted schedule %+v", interrupted)
+	}
+
+	// the status of every schedule is stored
+	if content, err := ioutil.ReadFile(storePath); err != nil || strings.Count(string(content), `"status":`) != len(reloaded) {
+		t.Fatalf("expected the status of the %d schedules to be stored, got %s %v", len(reloaded), content, err)
+	}
+
+	if err = ioutil.WriteFile(storePath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewStore(storePath).List(); !errors.Is(err, nxfserrors.ErrInternal) {
+		t.Fatalf("expected the corrupted file to be reported, got %v", err)
+	}
+}
+
+func TestStoreTransitions(t *testing.T) {
+	store, _, cleanup := newTestStore(t)
+	defer cleanup()
+
+	if err := store.Add(model.Schedule{Id: "s", Status: model.SchedulePending}); err != nil {
+		t.Fatal(err)
+	}
+	// a pending schedule can't fail, a running one can't be claimed again
+	if _, err := store.Fail("s", "error", time.Now()); !errors.Is(err, nxfserrors.ErrNotFound) {
+		t.Fatalf("expected the pending schedule not to fail, got %v", err)
+	}
+	if claimed, err := store.Claim("s"); err != nil || claimed.Status != model.ScheduleRunning {
+		t.Fatalf("expected the schedule to be running, got %+v %v", claimed, err)
+	}
+	if _, err := store.Claim("s"); !errors.Is(err, nxfserrors.ErrNotFound) {
+		t.Fatalf("expected the running schedule not to be claimed again, got %v", err)
+	}
+	if _, err := store.Remove("s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Remove("s"); !errors.Is(err, nxfserrors.ErrNotFound) {
+		t.Fatalf("expected the removed schedule not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package nxfsschedule
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"log"
+	"time"
+)
+
+// DefaultPollInterval - how often the scheduler looks for due schedules
+const DefaultPollInterval = time.Second
+
+// Executor - executes a due schedule, the error describes a failed execution
+type Executor func(schedule model.Schedule) error
+
+// Scheduler - executes the schedules of a Store when they become due
+type Scheduler struct {
+	store        *Store
+	execute      Executor
+	pollInterval time.Duration
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewScheduler - create a Scheduler executing the due schedules of store with execute
+func NewScheduler(store *Store, execute Executor, pollInterval time.Duration) *Scheduler {
+	return &Scheduler{
+		store:        store,
+		execute:      execute,
+		pollInterval: pollInterval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start - start executing the due schedules in background, the schedules that became due while nxfs was down are executed immediately
+func (s *Scheduler) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.RunDue(time.Now())
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stop the scheduler, waiting for the running executions to complete
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// RunDue - execute the schedules due at the received time. a schedule is marked as running before its execution, so that
+// it is never executed twice even if nxfs stops during it, and then removed if executed or kept as failed with its error
+func (s *Scheduler) RunDue(now time.Time) {
+	due, err := s.store.Due(now)
+	if err != nil {
+		log.Printf("Reading of the due schedules failed: %s", err.Error())
+		return
+	}
+
+	for _, dueSchedule := range due {
+		schedule, err := s.store.Claim(dueSchedule.Id)
+		if errors.Is(err, nxfserrors.ErrNotFound) {
+			// cancelled in the meantime
+			continue
+		} else if err != nil {
+			log.Printf("Update of the schedule %s failed, it will be retried: %s", dueSchedule.Id, err.Error())
+			continue
+		}
+
+		if err = s.execute(schedule); err != nil {
+			log.Printf("Scheduled %s of %s by %s failed: %s", schedule.Operation, schedule.Path, schedule.CreatedBy, err.Error())
+			_, err = s.store.Fail(schedule.Id, err.Error(), time.Now())
+		} else {
+			log.Printf("Scheduled %s of %s by %s executed", schedule.Operation, schedule.Path, schedule.CreatedBy)
+			_, err = s.store.Remove(schedule.Id)
+		}
+		// a schedule cancelled during its execution is already gone
+		if err != nil && !errors.Is(err, nxfserrors.ErrNotFound) {
+			log.Printf("Update of the executed schedule %s failed: %s", schedule.Id, err.Error())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package nxfsschedule
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"testing"
+	"time"
+)
+
+func TestRunDue(t *testing.T) {
+	store, _, cleanup := newTestStore(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	for _, schedule := range []model.Schedule{
+		{Id: "succeeds", At: now.Add(-2 * time.Minute)},
+		{Id: "fails", At: now.Add(-time.Minute)},
+		{Id: "cancelled", At: now.Add(-time.Second)},
+		{Id: "cancelled while running", At: now},
+		{Id: "future", At: now.Add(time.Hour)},
+	} {
+		if err := store.Add(schedule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var executed []model.Schedule
+	scheduler := NewScheduler(store, func(schedule model.Schedule) error {
+		executed = append(executed, schedule)
+		switch schedule.Id {
+		case "succeeds":
+			// cancelled once RunDue has read the due schedules
+			if _, err := store.Remove("cancelled"); err != nil {
+				t.Error(err)
+			}
+		case "fails":
+			return errors.New("not_approved: the page is a draft")
+		case "cancelled while running":
+			if _, err := store.Remove(schedule.Id); err != nil {
+				t.Error(err)
+			}
+			return errors.New("lock_timeout: the page is locked")
+		}
+		return nil
+	}, DefaultPollInterval)
+
+	scheduler.RunDue(now)
+
+	if ids(executed) != "succeeds,fails,cancelled while running" {
+		t.Fatalf("unexpected executions %s", ids(executed))
+	}
+	for _, schedule := range executed {
+		if schedule.Status != model.ScheduleRunning {
+			t.Fatalf("expected the schedule to be running during its execution, got %+v", schedule)
+		}
+	}
+	// the executed and the cancelled schedules are removed, the failed one is kept with its error
+	list, err := store.List()
+	if err != nil || ids(list) != "fails,future" {
+		t.Fatalf("unexpected schedules %s %v", ids(list), err)
+	}
+	if failed := list[0]; failed.Status != model.ScheduleFailed || failed.Error != "not_approved: the page is a draft" || failed.ExecutedAt == nil {
+		t.Fatalf("unexpected failed schedule %+v", failed)
+	}
+
+	// the failed schedules aren't retried
+	executed = nil
+	scheduler.RunDue(now.Add(2 * time.Hour))
+	if ids(executed) != "future" {
+		t.Fatalf("expected only the future schedule to be executed, got %s", ids(executed))
+	}
+}
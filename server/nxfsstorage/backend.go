@@ -0,0 +1,166 @@
+package nxfsstorage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrChanged - returned by Copy when the source file doesn't have the expected version anymore
+var ErrChanged = errors.New("the file has changed")
+
+// Backend - the storage of the objects of the browsable fs. the paths are slash separated and relative to the browsable
+// fs root, the root itself being "". the errors are the ones of the os package, so that nxfserrors.FromOS maps them
+type Backend interface {
+	// Stat - return the info of a file or a directory
+	Stat(relPath string) (os.FileInfo, error)
+	// ReadDir - return the files and the directories inside a directory, sorted by name
+	ReadDir(relPath string) ([]os.FileInfo, error)
+	// ReadFile - return the content of a file and its version, an opaque value changing with the content
+	ReadFile(relPath string) (content []byte, version string, err error)
+	// Open - open a file to stream its content, returning it with its entity tag, an opaque value changing with the
+	// content known without reading it
+	Open(relPath string) (content ReadSeekCloser, etag string, err error)
+	// WriteFile - create or replace a file as a whole, its directory must exist
+	WriteFile(relPath string, content io.Reader) error
+	// Mkdir - create a directory, its parent must exist
+	Mkdir(relPath string) error
+	// Remove - remove a file or an empty directory
+	Remove(relPath string) error
+	// Copy - copy a file to dst, creating the directories of dst. if version isn't empty and the file doesn't have
+	// that version anymore ErrChanged is returned and nothing is copied
+	Copy(src string, dst string, version string) error
+}
+
+// ReadSeekCloser - the content of an opened file
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// IsLocal - return true if the objects of the received backend are files of the local fs, as the features working
+// directly on the browsable fs directory need
+func IsLocal(backend Backend) bool {
+	_, ok := backend.(*Local)
+	return ok
+}
+
+// CleanPath - return the received path as a clean slash separated path relative to the browsable fs root, unable to point outside of it
+func CleanPath(relPath string) string {
+	return path.Clean("/" + filepath.ToSlash(relPath))[1:]
+}
+
+// modTimeETag - return an entity tag identifying the content of a file by its modification time and its size, the
+// files being replaced as a whole when they're written
+func modTimeETag(modTime time.Time, size int64) string {
+	return fmt.Sprintf("%x-%x", modTime.UnixNano(), size)
+}
+
+// Hash - return the hex encoded sha256 of the content of a file, as nxfsfiles.HashFile does, an empty string if it
+// doesn't exist or is a directory
+func Hash(backend Backend, relPath string) string {
+	content, _, err := backend.ReadFile(relPath)
+	if err != nil {
+		return ""
+	}
+	return nxfsfiles.HashContent(content)
+}
+
+// Walk - call fn for the received file or directory and, for a directory, for every file and directory under it,
+// in lexical order. the error of fn stops the walk and is returned
+func Walk(backend Backend, relPath string, fn func(relPath string, fileInfo os.FileInfo) error) error {
+	relPath = CleanPath(relPath)
+	fileInfo, err := backend.Stat(relPath)
+	if err != nil {
+		return err
+	}
+	return walk(backend, relPath, fileInfo, fn)
+}
+
+func walk(backend Backend, relPath string, fileInfo os.FileInfo, fn func(relPath string, fileInfo os.FileInfo) error) error {
+	if err := fn(relPath, fileInfo); err != nil || !fileInfo.IsDir() {
+		return err
+	}
+	children, err := backend.ReadDir(relPath)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err = walk(backend, path.Join(relPath, child.Name()), child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Browse - return the files found under the received path up to maxDepth levels below it, every level if it's 0.
+// a file is returned by itself. every returned object has the path of its directory, as the browse api does
+func Browse(backend Backend, relPath string, maxDepth int32) ([]model.DirectoryObject, error) {
+	relPath = CleanPath(relPath)
+	fileInfo, err := backend.Stat(relPath)
+	if err != nil {
+		return nil, err
+	}
+	if !fileInfo.IsDir() {
+		return []model.DirectoryObject{ToDirectoryObject(path.Dir("/" + relPath)[1:], fileInfo)}, nil
+	}
+	return browse(backend, relPath, 1, maxDepth, []model.DirectoryObject{})
+}
+
+func browse(backend Backend, dir string, depth int32, maxDepth int32, objects []model.DirectoryObject) ([]model.DirectoryObject, error) {
+	if depth > maxDepth && maxDepth != 0 {
+		return objects, nil
+	}
+	children, err := backend.ReadDir(dir)
+	if err != nil {
+		return objects, err
+	}
+	for _, child := range children {
+		if !child.IsDir() {
+			objects = append(objects, ToDirectoryObject(dir, child))
+		} else if objects, err = browse(backend, path.Join(dir, child.Name()), depth+1, maxDepth, objects); err != nil {
+			return objects, err
+		}
+	}
+	return objects, nil
+}
+
+// ToDirectoryObject - return the DirectoryObject of the object described by fileInfo, in the received directory
+func ToDirectoryObject(dir string, fileInfo os.FileInfo) model.DirectoryObject {
+	return helper.ToDirectoryObject(dir, fileInfo)
+}
+
+// objectInfo - the os.FileInfo of an object of a backend not backed by the local fs
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i objectInfo) Name() string       { return i.name }
+func (i objectInfo) Size() int64        { return i.size }
+func (i objectInfo) ModTime() time.Time { return i.modTime }
+func (i objectInfo) IsDir() bool        { return i.dir }
+func (i objectInfo) Sys() interface{}   { return nil }
+
+func (i objectInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// sortByName - sort the received infos by name, as ioutil.ReadDir does
+func sortByName(infos []os.FileInfo) []os.FileInfo {
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos
+}
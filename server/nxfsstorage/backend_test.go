@@ -0,0 +1,321 @@
+package nxfsstorage
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/nxfsblob"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "fs")
+	if err = os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := nxfsblob.NewStore(filepath.Join(dir, "blobs"))
+	testBackend(t, NewLocal(root, blobs))
+
+	// a published page links the blob of its draft, and the copies are copy-on-write
+	local := NewLocal(root, blobs)
+	for _, dirPath := range []string{"draft_pages", "pages"} {
+		if err = local.Mkdir(dirPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = local.WriteFile("draft_pages/home.page", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	_, version, _ := local.ReadFile("draft_pages/home.page")
+	for _, published := range []string{"pages/home.page", "pages/copy.page"} {
+		if err = local.Copy("draft_pages/home.page", published, version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blobInfo, err := os.Stat(blobs.Path(version))
+	if err != nil {
+		t.Fatalf("expected the content of the draft to be stored as a blob, got %v", err)
+	}
+	for _, published := range []string{"pages/home.page", "pages/copy.page"} {
+		if fileInfo, err := local.Stat(published); err != nil || !os.SameFile(blobInfo, fileInfo) {
+			t.Fatalf("expected %s to link the blob of its draft, got %v", published, err)
+		}
+	}
+	if refCount, ok, _ := blobs.RefCount(version); ok && refCount != 2 {
+		t.Fatalf("expected the blob to be shared by the two published pages, got %d references", refCount)
+	}
+
+	// editing the draft, or one of the copies, doesn't write through the blob
+	if err = local.WriteFile("draft_pages/home.page", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+	if err = local.WriteFile("pages/copy.page", strings.NewReader("edited copy")); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{"pages/home.page": "first", "pages/copy.page": "edited copy", "draft_pages/home.page": "second"} {
+		if content, _, err := local.ReadFile(path); err != nil || string(content) != expected {
+			t.Fatalf("expected %s to contain %q, got %q %v", path, expected, content, err)
+		}
+	}
+	if content, _ := ioutil.ReadFile(blobs.Path(version)); string(content) != "first" {
+		t.Fatalf("expected the blob to keep its content, got %q", content)
+	}
+	if refCount, ok, _ := blobs.RefCount(version); ok && refCount != 1 {
+		t.Fatalf("expected the edited copy to drop its link to the blob, got %d references", refCount)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, NewMemory())
+
+	// a preview starts from a copy of a local directory
+	dir, err := ioutil.TempDir("", "nxfs-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.MkdirAll(filepath.Join(dir, "pages", "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "pages", "home.page"), []byte("home"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "pages", ".nxfs-tmp-1"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NewMemory()
+	if err = backend.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	children, err := backend.ReadDir("pages")
+	if err != nil || len(children) != 2 || !children[0].IsDir() || children[1].Name() != "home.page" {
+		t.Fatalf("unexpected children %v %v", children, err)
+	}
+	if content, _, err := backend.ReadFile("pages/home.page"); err != nil || string(content) != "home" {
+		t.Fatalf("unexpected content %q %v", content, err)
+	}
+	if err = backend.WriteFile("pages/home.page", strings.NewReader("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "pages", "home.page")); err != nil || string(content) != "home" {
+		t.Fatalf("the local directory has been changed to %q %v", content, err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	// the small pages make the listings of the test continue
+	fake, server := newFakeS3("bucket", 2)
+	defer server.Close()
+
+	backend, err := NewS3(S3Config{Endpoint: server.URL, Bucket: "bucket", Prefix: "/sites/main/", AccessKey: "access", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, backend)
+
+	for _, unexpected := range fake.errors {
+		t.Error(unexpected)
+	}
+	for key := range fake.objects {
+		if !strings.HasPrefix(key, "sites/main/") {
+			t.Errorf("object %q outside of the prefix", key)
+		}
+	}
+
+	// a directory exists even without its marker, when objects are under it
+	fake.objects["sites/main/implicit/page.page"] = []byte("page")
+	if fileInfo, err := backend.Stat("implicit"); err != nil || !fileInfo.IsDir() {
+		t.Fatalf("expected an implicit directory, got %v %v", fileInfo, err)
+	}
+
+	// an opened file isn't read mixing the content of two versions
+	file, _, err := backend.Open("implicit/page.page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fake.objects["sites/main/implicit/page.page"] = []byte("changed page")
+	if _, err = ioutil.ReadAll(file); !errors.Is(err, ErrChanged) {
+		t.Fatalf("expected the changed object to fail the read, got %v", err)
+	}
+}
+
+// testBackend - check the behaviour every Backend must have, starting from an empty root
+func testBackend(t *testing.T, backend Backend) {
+	assertKind := func(err error, kind error) {
+		t.Helper()
+		if !errors.Is(nxfserrors.FromOS(err, "storage_error", "test"), kind) {
+			t.Fatalf("expected an error of kind %v, got %v", kind, err)
+		}
+	}
+
+	if fileInfo, err := backend.Stat(""); err != nil || !fileInfo.IsDir() {
+		t.Fatalf("the root must be a directory, got %v %v", fileInfo, err)
+	}
+	if children, err := backend.ReadDir(""); err != nil || len(children) != 0 {
+		t.Fatalf("expected an empty root, got %v %v", children, err)
+	}
+
+	// the directories must be created before their files
+	assertKind(backend.WriteFile("docs/a.txt", strings.NewReader("a")), nxfserrors.ErrNotFound)
+	if err := backend.Mkdir("docs"); err != nil {
+		t.Fatal(err)
+	}
+	assertKind(backend.Mkdir("docs"), nxfserrors.ErrExists)
+	if err := backend.Mkdir("docs/empty"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"docs/a.txt", "docs/b.txt", "docs/c d.txt", "docs/empty-not.txt", "top.txt"} {
+		if err := backend.WriteFile(name, strings.NewReader("content of "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := backend.WriteFile("docs/a.txt", strings.NewReader("new a")); err != nil {
+		t.Fatal(err)
+	}
+	assertKind(backend.WriteFile("docs", strings.NewReader("x")), nxfserrors.ErrConflict)
+	assertKind(backend.WriteFile("top.txt/x", strings.NewReader("x")), nxfserrors.ErrConflict)
+
+	fileInfo, err := backend.Stat("/docs/../docs/a.txt")
+	if err != nil || fileInfo.IsDir() || fileInfo.Name() != "a.txt" || fileInfo.Size() != 5 {
+		t.Fatalf("unexpected info %v %v", fileInfo, err)
+	}
+	if fileInfo, err = backend.Stat("docs/empty"); err != nil || !fileInfo.IsDir() || fileInfo.Name() != "empty" {
+		t.Fatalf("unexpected info %v %v", fileInfo, err)
+	}
+	_, err = backend.Stat("missing")
+	assertKind(err, nxfserrors.ErrNotFound)
+
+	children, err := backend.ReadDir("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, child := range children {
+		names = append(names, child.Name())
+	}
+	if strings.Join(names, ",") != "a.txt,b.txt,c d.txt,empty,empty-not.txt" || !children[3].IsDir() || children[0].IsDir() {
+		t.Fatalf("unexpected children %v", names)
+	}
+	_, err = backend.ReadDir("top.txt")
+	assertKind(err, nxfserrors.ErrConflict)
+	_, err = backend.ReadDir("missing")
+	assertKind(err, nxfserrors.ErrNotFound)
+
+	content, version, err := backend.ReadFile("docs/a.txt")
+	if err != nil || string(content) != "new a" || version == "" {
+		t.Fatalf("unexpected content %q %q %v", content, version, err)
+	}
+	if Hash(backend, "docs/a.txt") != nxfsfiles.HashContent([]byte("new a")) {
+		t.Fatal("the hash must be the sha256 of the content")
+	}
+	if Hash(backend, "docs") != "" || Hash(backend, "missing") != "" {
+		t.Fatal("directories and missing files have no hash")
+	}
+	_, _, err = backend.ReadFile("docs")
+	assertKind(err, nxfserrors.ErrConflict)
+
+	// the opened files are streamed from any offset
+	file, etag, err := backend.Open("docs/b.txt")
+	if err != nil || etag == "" {
+		t.Fatalf("unexpected open %q %v", etag, err)
+	}
+	if content, err = ioutil.ReadAll(file); err != nil || string(content) != "content of docs/b.txt" {
+		t.Fatalf("unexpected content %q %v", content, err)
+	}
+	if size, err := file.Seek(0, io.SeekEnd); err != nil || size != int64(len("content of docs/b.txt")) {
+		t.Fatalf("unexpected size %d %v", size, err)
+	}
+	if _, err = file.Seek(11, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = ioutil.ReadAll(file); err != nil || string(content) != "docs/b.txt" {
+		t.Fatalf("unexpected content %q %v", content, err)
+	}
+	file.Close()
+	if err = backend.WriteFile("docs/b.txt", strings.NewReader("new content of docs/b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if file, changedETag, err := backend.Open("docs/b.txt"); err != nil || changedETag == etag {
+		t.Fatalf("expected the entity tag to change with the content, got %q %v", changedETag, err)
+	} else {
+		file.Close()
+	}
+	_, _, err = backend.Open("docs")
+	assertKind(err, nxfserrors.ErrConflict)
+	_, _, err = backend.Open("missing")
+	assertKind(err, nxfserrors.ErrNotFound)
+
+	objects, err := Browse(backend, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var browsed []string
+	for _, object := range objects {
+		browsed = append(browsed, object.Path+"|"+object.Name)
+	}
+	if strings.Join(browsed, ",") != "docs|a.txt,docs|b.txt,docs|c d.txt,docs|empty-not.txt,.|top.txt" {
+		t.Fatalf("unexpected browse %v", browsed)
+	}
+	if objects, err = Browse(backend, "", 1); err != nil || len(objects) != 1 || objects[0].Name != "top.txt" {
+		t.Fatalf("unexpected browse %v %v", objects, err)
+	}
+	if objects, err = Browse(backend, "docs/b.txt", 0); err != nil || len(objects) != 1 || objects[0].Path != "docs" {
+		t.Fatalf("unexpected browse %v %v", objects, err)
+	}
+
+	var walked []string
+	err = Walk(backend, "docs", func(relPath string, fileInfo os.FileInfo) error {
+		walked = append(walked, relPath)
+		return nil
+	})
+	if err != nil || strings.Join(walked, ",") != "docs,docs/a.txt,docs/b.txt,docs/c d.txt,docs/empty,docs/empty-not.txt" {
+		t.Fatalf("unexpected walk %v %v", walked, err)
+	}
+
+	// the copies create the directories of the destination and check the version of the source
+	if err = backend.Copy("docs/a.txt", "published/docs/a.txt", version); err != nil {
+		t.Fatal(err)
+	}
+	if content, _, err = backend.ReadFile("published/docs/a.txt"); err != nil || string(content) != "new a" {
+		t.Fatalf("unexpected copy %q %v", content, err)
+	}
+	if fileInfo, err = backend.Stat("published/docs"); err != nil || !fileInfo.IsDir() {
+		t.Fatalf("unexpected copy directory %v %v", fileInfo, err)
+	}
+	if err = backend.WriteFile("docs/a.txt", strings.NewReader("newer a")); err != nil {
+		t.Fatal(err)
+	}
+	if err = backend.Copy("docs/a.txt", "published/docs/a.txt", version); err != ErrChanged {
+		t.Fatalf("expected the copy to find the source changed, got %v", err)
+	}
+	if err = backend.Copy("docs/a.txt", "published/docs/a.txt", ""); err != nil {
+		t.Fatal(err)
+	}
+	assertKind(backend.Copy("missing", "published/missing", ""), nxfserrors.ErrNotFound)
+
+	assertKind(backend.Remove("docs"), nxfserrors.ErrNotEmpty)
+	if err = backend.Remove("docs/empty"); err != nil {
+		t.Fatal(err)
+	}
+	if err = backend.Remove("docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = backend.Stat("docs/empty")
+	assertKind(err, nxfserrors.ErrNotFound)
+	_, err = backend.Stat("docs/a.txt")
+	assertKind(err, nxfserrors.ErrNotFound)
+	assertKind(backend.Remove("docs/a.txt"), nxfserrors.ErrNotFound)
+}
@@ -0,0 +1,109 @@
+package nxfsstorage
+
+import (
+	"github.com/entando/entando-nxfs/server/nxfsblob"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Local - a Backend keeping the objects as files of a local directory. the copies share their content through the blob store
+type Local struct {
+	root  string
+	blobs *nxfsblob.Store
+}
+
+// NewLocal - create a Local backend whose root is the received directory
+func NewLocal(root string, blobs *nxfsblob.Store) *Local {
+	return &Local{root: root, blobs: blobs}
+}
+
+// fullPath - return the path of the local file corresponding to the received relative path
+func (l *Local) fullPath(relPath string) string {
+	return filepath.Join(l.root, filepath.FromSlash(CleanPath(relPath)))
+}
+
+// Stat - return the info of a file or a directory
+func (l *Local) Stat(relPath string) (os.FileInfo, error) {
+	return os.Stat(l.fullPath(relPath))
+}
+
+// ReadDir - return the files and the directories inside a directory, skipping the temporary files of the writes in progress
+func (l *Local) ReadDir(relPath string) ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(l.fullPath(relPath))
+	if err != nil {
+		return nil, err
+	}
+	children := infos[:0]
+	for _, info := range infos {
+		if !nxfsfiles.IsTempFile(info.Name()) {
+			children = append(children, info)
+		}
+	}
+	return children, nil
+}
+
+// ReadFile - return the content of a file, whose version is its sha256
+func (l *Local) ReadFile(relPath string) ([]byte, string, error) {
+	content, err := ioutil.ReadFile(l.fullPath(relPath))
+	if err != nil {
+		return nil, "", err
+	}
+	return content, nxfsfiles.HashContent(content), nil
+}
+
+// Open - open a file, its entity tag is made of its modification time and its size
+func (l *Local) Open(relPath string) (ReadSeekCloser, string, error) {
+	file, err := os.Open(l.fullPath(relPath))
+	if err != nil {
+		return nil, "", err
+	}
+	fileInfo, err := file.Stat()
+	if err == nil && fileInfo.IsDir() {
+		err = &os.PathError{Op: "read", Path: relPath, Err: syscall.EISDIR}
+	}
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return file, modTimeETag(fileInfo.ModTime(), fileInfo.Size()), nil
+}
+
+// WriteFile - atomically create or replace a file. the file is never written in place, so a file linking a blob of the
+// copies gets its own content and the blob is left as is
+func (l *Local) WriteFile(relPath string, content io.Reader) error {
+	// renaming over a directory fails with an error depending on the platform
+	if fileInfo, err := os.Stat(l.fullPath(relPath)); err == nil && fileInfo.IsDir() {
+		return &os.PathError{Op: "write", Path: relPath, Err: syscall.EISDIR}
+	}
+	return nxfsfiles.WriteFileAtomic(l.fullPath(relPath), content, 0755)
+}
+
+// Mkdir - create a directory
+func (l *Local) Mkdir(relPath string) error {
+	return os.Mkdir(l.fullPath(relPath), 0755)
+}
+
+// Remove - remove a file or an empty directory
+func (l *Local) Remove(relPath string) error {
+	return os.Remove(l.fullPath(relPath))
+}
+
+// Copy - make dst share the content of src through the blob store, creating the directories of dst
+func (l *Local) Copy(src string, dst string, version string) error {
+	hash, err := l.blobs.PutFile(l.fullPath(src))
+	if err != nil {
+		return err
+	}
+	if "" != version && hash != version {
+		return ErrChanged
+	}
+	dstPath := l.fullPath(dst)
+	if err = os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
+	return l.blobs.Write(hash, dstPath)
+}
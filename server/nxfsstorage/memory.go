@@ -0,0 +1,286 @@
+package nxfsstorage
+
+import (
+	"bytes"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Memory - a Backend keeping the objects in memory, behaving like the local directory. it suits the tests and the
+// ephemeral previews, whose changes are lost when nxfs exits
+type Memory struct {
+	mu sync.RWMutex
+	// objects - the files and the directories by path, the root excluded. the contents are never modified in place,
+	// so the copies share them
+	objects map[string]*memoryObject
+}
+
+type memoryObject struct {
+	content []byte
+	modTime time.Time
+	dir     bool
+}
+
+// NewMemory - create an empty Memory backend
+func NewMemory() *Memory {
+	return &Memory{objects: map[string]*memoryObject{}}
+}
+
+// Load - copy into the backend the files and the directories found under the received local directory,
+// skipping the temporary files of the writes in progress
+func (m *Memory) Load(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return filepath.Walk(dir, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil || filePath == dir {
+			return err
+		}
+		if nxfsfiles.IsTempFile(fileInfo.Name()) {
+			return nil
+		}
+		relPath, _ := filepath.Rel(dir, filePath)
+		object := &memoryObject{modTime: fileInfo.ModTime(), dir: fileInfo.IsDir()}
+		if !object.dir {
+			if object.content, err = ioutil.ReadFile(filePath); err != nil {
+				return err
+			}
+		}
+		m.objects[filepath.ToSlash(relPath)] = object
+		return nil
+	})
+}
+
+// Stat - return the info of a file or a directory
+func (m *Memory) Stat(relPath string) (os.FileInfo, error) {
+	relPath = CleanPath(relPath)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	object, err := m.lookup("stat", relPath)
+	if err != nil {
+		return nil, err
+	}
+	return object.info(relPath), nil
+}
+
+// ReadDir - return the files and the directories inside a directory
+func (m *Memory) ReadDir(relPath string) ([]os.FileInfo, error) {
+	relPath = CleanPath(relPath)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	object, err := m.lookup("readdir", relPath)
+	if err != nil {
+		return nil, err
+	}
+	if !object.dir {
+		return nil, &os.PathError{Op: "readdir", Path: relPath, Err: syscall.ENOTDIR}
+	}
+	return sortByName(m.children(relPath)), nil
+}
+
+// ReadFile - return the content of a file, whose version is its sha256
+func (m *Memory) ReadFile(relPath string) ([]byte, string, error) {
+	relPath = CleanPath(relPath)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	object, err := m.lookupFile("read", relPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return append([]byte(nil), object.content...), nxfsfiles.HashContent(object.content), nil
+}
+
+// Open - open a file, its entity tag is made of its modification time and its size
+func (m *Memory) Open(relPath string) (ReadSeekCloser, string, error) {
+	relPath = CleanPath(relPath)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	object, err := m.lookupFile("read", relPath)
+	if err != nil {
+		return nil, "", err
+	}
+	// the content of an object is replaced, never changed, by the writes
+	return memoryFile{bytes.NewReader(object.content)}, modTimeETag(object.modTime, int64(len(object.content))), nil
+}
+
+// memoryFile - the content of an opened Memory file
+type memoryFile struct {
+	*bytes.Reader
+}
+
+// Close - nothing to release
+func (memoryFile) Close() error {
+	return nil
+}
+
+// WriteFile - create or replace a file
+func (m *Memory) WriteFile(relPath string, content io.Reader) error {
+	relPath = CleanPath(relPath)
+	// read before locking, a slow reader must not block the other operations
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return &os.PathError{Op: "write", Path: relPath, Err: err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.checkParent("write", relPath); err != nil {
+		return err
+	}
+	if object, ok := m.objects[relPath]; ok && object.dir {
+		return &os.PathError{Op: "write", Path: relPath, Err: syscall.EISDIR}
+	}
+	m.objects[relPath] = &memoryObject{content: data, modTime: time.Now()}
+	return nil
+}
+
+// Mkdir - create a directory
+func (m *Memory) Mkdir(relPath string) error {
+	relPath = CleanPath(relPath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkParent("mkdir", relPath); err != nil {
+		return err
+	}
+	if _, ok := m.objects[relPath]; ok {
+		return &os.PathError{Op: "mkdir", Path: relPath, Err: os.ErrExist}
+	}
+	m.objects[relPath] = &memoryObject{modTime: time.Now(), dir: true}
+	return nil
+}
+
+// Remove - remove a file or an empty directory
+func (m *Memory) Remove(relPath string) error {
+	relPath = CleanPath(relPath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	object, err := m.lookup("remove", relPath)
+	if err != nil {
+		return err
+	}
+	if object.dir && len(m.children(relPath)) > 0 {
+		return &os.PathError{Op: "remove", Path: relPath, Err: syscall.ENOTEMPTY}
+	}
+	if relPath == "" {
+		return &os.PathError{Op: "remove", Path: relPath, Err: syscall.EBUSY}
+	}
+	delete(m.objects, relPath)
+	return nil
+}
+
+// Copy - make dst share the content of src, creating the directories of dst
+func (m *Memory) Copy(src string, dst string, version string) error {
+	src, dst = CleanPath(src), CleanPath(dst)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	object, err := m.lookupFile("copy", src)
+	if err != nil {
+		return err
+	}
+	if "" != version && nxfsfiles.HashContent(object.content) != version {
+		return ErrChanged
+	}
+
+	if err = m.mkdirAll(path.Dir("/" + dst)[1:]); err != nil {
+		return err
+	}
+	if existing, ok := m.objects[dst]; ok && existing.dir {
+		return &os.PathError{Op: "copy", Path: dst, Err: syscall.EISDIR}
+	}
+	m.objects[dst] = &memoryObject{content: object.content, modTime: time.Now()}
+	return nil
+}
+
+// lookup - return the object at the received path, with the error os.Stat would return if it doesn't exist
+func (m *Memory) lookup(op string, relPath string) (*memoryObject, error) {
+	if relPath == "" {
+		return &memoryObject{dir: true}, nil
+	}
+	if object, ok := m.objects[relPath]; ok {
+		return object, nil
+	}
+	if parent, err := m.lookup(op, path.Dir("/" + relPath)[1:]); err == nil && !parent.dir {
+		return nil, &os.PathError{Op: op, Path: relPath, Err: syscall.ENOTDIR}
+	}
+	return nil, &os.PathError{Op: op, Path: relPath, Err: os.ErrNotExist}
+}
+
+// lookupFile - return the file at the received path, an error if it's a directory
+func (m *Memory) lookupFile(op string, relPath string) (*memoryObject, error) {
+	object, err := m.lookup(op, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if object.dir {
+		return nil, &os.PathError{Op: op, Path: relPath, Err: syscall.EISDIR}
+	}
+	return object, nil
+}
+
+// checkParent - return an error unless the directory of the received path exists
+func (m *Memory) checkParent(op string, relPath string) error {
+	if relPath == "" {
+		return &os.PathError{Op: op, Path: relPath, Err: syscall.EISDIR}
+	}
+	parent, err := m.lookup(op, path.Dir("/" + relPath)[1:])
+	if err != nil {
+		return &os.PathError{Op: op, Path: relPath, Err: err.(*os.PathError).Err}
+	}
+	if !parent.dir {
+		return &os.PathError{Op: op, Path: relPath, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// mkdirAll - create a directory and its missing parents
+func (m *Memory) mkdirAll(relPath string) error {
+	if relPath == "" {
+		return nil
+	}
+	if object, ok := m.objects[relPath]; ok {
+		if !object.dir {
+			return &os.PathError{Op: "mkdir", Path: relPath, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(path.Dir("/" + relPath)[1:]); err != nil {
+		return err
+	}
+	m.objects[relPath] = &memoryObject{modTime: time.Now(), dir: true}
+	return nil
+}
+
+// children - return the info of the objects directly inside the received directory
+func (m *Memory) children(relPath string) []os.FileInfo {
+	prefix := ""
+	if relPath != "" {
+		prefix = relPath + "/"
+	}
+	var infos []os.FileInfo
+	for objectPath, object := range m.objects {
+		if name := strings.TrimPrefix(objectPath, prefix); strings.HasPrefix(objectPath, prefix) && !strings.Contains(name, "/") {
+			infos = append(infos, object.info(objectPath))
+		}
+	}
+	return infos
+}
+
+func (o *memoryObject) info(relPath string) os.FileInfo {
+	return objectInfo{name: strings.TrimPrefix(path.Base("/"+relPath), "/"), size: int64(len(o.content)), modTime: o.modTime, dir: o.dir}
+}
@@ -0,0 +1,498 @@
+package nxfsstorage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// S3Config - the location of the objects in an S3 compatible service and the credentials to access them
+type S3Config struct {
+	// Endpoint - the url of the service, the bucket is addressed in the path
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix - the prefix of the keys of the objects, the browsable fs root
+	Prefix    string
+	AccessKey string
+	// SecretKey - the requests are not signed if empty
+	SecretKey string
+}
+
+// S3 - a Backend keeping the objects in a bucket of an S3 compatible service. a directory exists if an object has its
+// path as prefix, the empty directories are kept by a marker object whose key ends with a slash. the copies are server side
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	prefix   string
+	client   *http.Client
+}
+
+// s3ListResult - the body of a ListObjectsV2 response
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// s3Error - the body of an error response
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+// NewS3 - create an S3 backend for the received configuration
+func NewS3(config S3Config) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("no S3 bucket configured")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	prefix := strings.Trim(config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{config: config, endpoint: endpoint, prefix: prefix, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+// key - return the key of the file at the received path
+func (s *S3) key(relPath string) string {
+	return s.prefix + relPath
+}
+
+// dirKey - return the prefix of the keys of the objects in the directory at the received path, which is also the key of its marker
+func (s *S3) dirKey(relPath string) string {
+	if relPath == "" {
+		return s.prefix
+	}
+	return s.prefix + relPath + "/"
+}
+
+// Stat - return the info of a file or a directory
+func (s *S3) Stat(relPath string) (os.FileInfo, error) {
+	relPath = CleanPath(relPath)
+	if relPath == "" {
+		return objectInfo{dir: true}, nil
+	}
+
+	resp, err := s.do(http.MethodHead, s.key(relPath), nil, nil, nil)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: relPath, Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+		return objectInfo{name: path.Base(relPath), size: resp.ContentLength, modTime: modTime}, nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return nil, s.errorOf("stat", relPath, resp, nil)
+	}
+
+	result, err := s.list(s.dirKey(relPath), "", 1, "")
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: relPath, Err: err}
+	}
+	if len(result.Contents) == 0 {
+		return nil, &os.PathError{Op: "stat", Path: relPath, Err: os.ErrNotExist}
+	}
+	return objectInfo{name: path.Base(relPath), modTime: result.Contents[0].LastModified, dir: true}, nil
+}
+
+// ReadDir - return the files and the directories inside a directory, listing the keys with its prefix
+func (s *S3) ReadDir(relPath string) ([]os.FileInfo, error) {
+	relPath = CleanPath(relPath)
+	dirKey := s.dirKey(relPath)
+
+	children := map[string]os.FileInfo{}
+	token := ""
+	for {
+		result, err := s.list(dirKey, "/", 0, token)
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: relPath, Err: err}
+		}
+		for _, prefix := range result.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(prefix.Prefix, dirKey), "/")
+			children[name] = objectInfo{name: name, dir: true}
+		}
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, dirKey)
+			// the marker of the directory itself
+			if name == "" {
+				continue
+			}
+			if _, ok := children[name]; !ok {
+				children[name] = objectInfo{name: name, size: object.Size, modTime: object.LastModified}
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	if len(children) == 0 {
+		fileInfo, err := s.Stat(relPath)
+		if err != nil {
+			return nil, err
+		}
+		if !fileInfo.IsDir() {
+			return nil, &os.PathError{Op: "readdir", Path: relPath, Err: syscall.ENOTDIR}
+		}
+	}
+	infos := make([]os.FileInfo, 0, len(children))
+	for _, info := range children {
+		infos = append(infos, info)
+	}
+	return sortByName(infos), nil
+}
+
+// ReadFile - return the content of a file, whose version is its ETag
+func (s *S3) ReadFile(relPath string) ([]byte, string, error) {
+	relPath = CleanPath(relPath)
+	if relPath == "" {
+		return nil, "", &os.PathError{Op: "read", Path: relPath, Err: syscall.EISDIR}
+	}
+
+	resp, err := s.do(http.MethodGet, s.key(relPath), nil, nil, nil)
+	if err != nil {
+		return nil, "", &os.PathError{Op: "read", Path: relPath, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		if fileInfo, statErr := s.Stat(relPath); statErr == nil && fileInfo.IsDir() {
+			return nil, "", &os.PathError{Op: "read", Path: relPath, Err: syscall.EISDIR}
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", s.errorOf("read", relPath, resp, nil)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", &os.PathError{Op: "read", Path: relPath, Err: err}
+	}
+	return content, resp.Header.Get("ETag"), nil
+}
+
+// Open - open a file whose content is read with range requests, its entity tag is the one of the object
+func (s *S3) Open(relPath string) (ReadSeekCloser, string, error) {
+	relPath = CleanPath(relPath)
+	if relPath == "" {
+		return nil, "", &os.PathError{Op: "read", Path: relPath, Err: syscall.EISDIR}
+	}
+
+	resp, err := s.do(http.MethodHead, s.key(relPath), nil, nil, nil)
+	if err != nil {
+		return nil, "", &os.PathError{Op: "read", Path: relPath, Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		if fileInfo, statErr := s.Stat(relPath); statErr == nil && fileInfo.IsDir() {
+			return nil, "", &os.PathError{Op: "read", Path: relPath, Err: syscall.EISDIR}
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", s.errorOf("read", relPath, resp, nil)
+	}
+	etag := resp.Header.Get("ETag")
+	return &s3File{s3: s, relPath: relPath, etag: etag, size: resp.ContentLength}, strings.Trim(etag, `"`), nil
+}
+
+// s3File - the content of an opened S3 file, read from the current offset to the end with a range request made at the
+// first read after opening it or seeking. the requests fail if the object has been replaced meanwhile
+type s3File struct {
+	s3      *S3
+	relPath string
+	etag    string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// Read - read the content from the current offset
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		resp, err := f.s3.do(http.MethodGet, f.s3.key(f.relPath), nil, nil, map[string]string{
+			"Range":    fmt.Sprintf("bytes=%d-", f.offset),
+			"If-Match": f.etag,
+		})
+		if err != nil {
+			return 0, &os.PathError{Op: "read", Path: f.relPath, Err: err}
+		}
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			resp.Body.Close()
+			return 0, &os.PathError{Op: "read", Path: f.relPath, Err: ErrChanged}
+		}
+		if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && f.offset == 0) {
+			defer resp.Body.Close()
+			return 0, f.s3.errorOf("read", f.relPath, resp, nil)
+		}
+		f.body = resp.Body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek - move the offset of the next read, the content is requested again from there
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.relPath, Err: syscall.EINVAL}
+	}
+	if offset != f.offset {
+		f.Close()
+		f.offset = offset
+	}
+	return offset, nil
+}
+
+// Close - release the response being read, if any
+func (f *s3File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+// WriteFile - create or replace a file. a single object is put, so the readers never see it partially written
+func (s *S3) WriteFile(relPath string, content io.Reader) error {
+	relPath = CleanPath(relPath)
+	if err := s.checkParent("write", relPath); err != nil {
+		return err
+	}
+	if fileInfo, err := s.Stat(relPath); err == nil && fileInfo.IsDir() {
+		return &os.PathError{Op: "write", Path: relPath, Err: syscall.EISDIR}
+	}
+
+	body, err := ioutil.ReadAll(content)
+	if err != nil {
+		return &os.PathError{Op: "write", Path: relPath, Err: err}
+	}
+	return s.put("write", relPath, s.key(relPath), body, nil)
+}
+
+// Mkdir - create a directory, putting its marker
+func (s *S3) Mkdir(relPath string) error {
+	relPath = CleanPath(relPath)
+	if err := s.checkParent("mkdir", relPath); err != nil {
+		return err
+	}
+	if _, err := s.Stat(relPath); err == nil {
+		return &os.PathError{Op: "mkdir", Path: relPath, Err: os.ErrExist}
+	}
+	return s.put("mkdir", relPath, s.dirKey(relPath), []byte{}, nil)
+}
+
+// Remove - remove a file or an empty directory, with its marker
+func (s *S3) Remove(relPath string) error {
+	relPath = CleanPath(relPath)
+	fileInfo, err := s.Stat(relPath)
+	if err != nil {
+		return err
+	}
+
+	key := s.key(relPath)
+	if fileInfo.IsDir() {
+		children, err := s.ReadDir(relPath)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return &os.PathError{Op: "remove", Path: relPath, Err: syscall.ENOTEMPTY}
+		}
+		if relPath == "" {
+			return &os.PathError{Op: "remove", Path: relPath, Err: syscall.EBUSY}
+		}
+		key = s.dirKey(relPath)
+	}
+
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: relPath, Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s.errorOf("remove", relPath, resp, nil)
+	}
+	return nil
+}
+
+// Copy - copy a file server side. the directories of dst are implicit in its key
+func (s *S3) Copy(src string, dst string, version string) error {
+	src, dst = CleanPath(src), CleanPath(dst)
+	for dir := path.Dir(dst); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if fileInfo, err := s.Stat(dir); err == nil && !fileInfo.IsDir() {
+			return &os.PathError{Op: "copy", Path: dst, Err: syscall.ENOTDIR}
+		}
+	}
+
+	headers := map[string]string{"X-Amz-Copy-Source": "/" + s.config.Bucket + "/" + escapeURI(s.key(src), false)}
+	if "" != version {
+		headers["X-Amz-Copy-Source-If-Match"] = version
+	}
+	return s.put("copy", src, s.key(dst), []byte{}, headers)
+}
+
+// put - put an object, or copy one when the copy headers are present
+func (s *S3) put(op string, relPath string, key string, body []byte, headers map[string]string) error {
+	resp, err := s.do(http.MethodPut, key, nil, body, headers)
+	if err != nil {
+		return &os.PathError{Op: op, Path: relPath, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed && op == "copy" {
+		return ErrChanged
+	}
+
+	// a copy can fail after its response status has been sent, in which case the error is in the body
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &os.PathError{Op: op, Path: relPath, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK || bytes.Contains(respBody, []byte("<Error>")) {
+		return s.errorOf(op, relPath, resp, respBody)
+	}
+	return nil
+}
+
+// checkParent - return an error unless the directory of the received path exists
+func (s *S3) checkParent(op string, relPath string) error {
+	if relPath == "" {
+		return &os.PathError{Op: op, Path: relPath, Err: syscall.EISDIR}
+	}
+	parent := path.Dir(relPath)
+	if parent == "." {
+		return nil
+	}
+	fileInfo, err := s.Stat(parent)
+	if err != nil {
+		return &os.PathError{Op: op, Path: relPath, Err: os.ErrNotExist}
+	}
+	if !fileInfo.IsDir() {
+		return &os.PathError{Op: op, Path: relPath, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+// list - list the keys with the received prefix, a page of at most maxKeys if not 0
+func (s *S3) list(prefix string, delimiter string, maxKeys int, token string) (*s3ListResult, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	resp, err := s.do(http.MethodGet, "", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.errorOf("list", prefix, resp, nil)
+	}
+
+	var result s3ListResult
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid listing of %q: %s", prefix, err.Error())
+	}
+	return &result, nil
+}
+
+// do - send a signed request for the received key of the bucket, or for the bucket if the key is empty
+func (s *S3) do(method string, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	uri := *s.endpoint
+	uri.RawPath = s.endpoint.EscapedPath() + "/" + escapeURI(s.config.Bucket, true) + "/" + escapeURI(key, false)
+	uri.Path, _ = url.PathUnescape(uri.RawPath)
+	uri.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, uri.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hashHex(body))
+	if s.config.AccessKey != "" {
+		signV4(req, s.config.Region, s.config.AccessKey, s.config.SecretKey, time.Now())
+	}
+	return s.client.Do(req)
+}
+
+// errorOf - return the os error corresponding to the received failed response
+func (s *S3) errorOf(op string, relPath string, resp *http.Response, body []byte) error {
+	if body == nil {
+		body, _ = ioutil.ReadAll(resp.Body)
+	}
+	var s3Err s3Error
+	_ = xml.Unmarshal(body, &s3Err)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || s3Err.Code == "NoSuchKey":
+		return &os.PathError{Op: op, Path: relPath, Err: os.ErrNotExist}
+	case resp.StatusCode == http.StatusForbidden || s3Err.Code == "AccessDenied":
+		return &os.PathError{Op: op, Path: relPath, Err: os.ErrPermission}
+	case s3Err.Code != "":
+		return &os.PathError{Op: op, Path: relPath, Err: fmt.Errorf("%s: %s", s3Err.Code, s3Err.Message)}
+	default:
+		return &os.PathError{Op: op, Path: relPath, Err: fmt.Errorf("unexpected response %s", resp.Status)}
+	}
+}
+
+// canonicalQuery - return the received query escaped and sorted as the signature requires
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escapeURI(name, true)+"="+escapeURI(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
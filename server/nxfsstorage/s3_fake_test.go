@@ -0,0 +1,175 @@
+package nxfsstorage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeS3 - an in-process stand-in of an S3 compatible service, with a single bucket and path style addressing
+type fakeS3 struct {
+	bucket   string
+	pageSize int
+	mu       sync.Mutex
+	objects  map[string][]byte
+	errors   []string
+}
+
+type fakeObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type fakeListResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Contents              []fakeObject
+	CommonPrefixes        []struct{ Prefix string }
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+var fakeModTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newFakeS3(bucket string, pageSize int) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{bucket: bucket, pageSize: pageSize, objects: map[string][]byte{}}
+	return fake, httptest.NewServer(fake)
+}
+
+func etag(content []byte) string {
+	hash := md5.Sum(content)
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+// fail - record an unexpected request, reported by the test
+func (f *fakeS3) fail(w http.ResponseWriter, format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+	http.Error(w, "unexpected request", http.StatusBadRequest)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		f.fail(w, "unsigned request %s %s", r.Method, r.URL)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hashHex(body) {
+		f.fail(w, "wrong payload hash in %s %s", r.Method, r.URL)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/"+f.bucket+"/") {
+		f.fail(w, "unknown bucket in %s", r.URL)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/"+f.bucket+"/")
+
+	switch {
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r.URL.Query())
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		content, ok := f.objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != etag(content) {
+			writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		w.Header().Set("ETag", etag(content))
+		w.Header().Set("Last-Modified", fakeModTime.Format(http.TimeFormat))
+		status := http.StatusOK
+		if byteRange := r.Header.Get("Range"); byteRange != "" {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(byteRange, "bytes="), "-"))
+			content, status = content[start:], http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		content, ok := f.objects[strings.TrimPrefix(source, "/"+f.bucket+"/")]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if match := r.Header.Get("X-Amz-Copy-Source-If-Match"); match != "" && match != etag(content) {
+			writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		f.objects[key] = content
+		_, _ = fmt.Fprintf(w, "<CopyObjectResult><ETag>%s</ETag></CopyObjectResult>", etag(content))
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, "unsupported request %s %s", r.Method, r.URL)
+	}
+}
+
+// list - answer a ListObjectsV2 request, in pages of pageSize keys and common prefixes
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := f.pageSize
+	if value, err := strconv.Atoi(query.Get("max-keys")); err == nil && value < maxKeys {
+		maxKeys = value
+	}
+
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := fakeListResult{}
+	seenPrefixes := map[string]bool{}
+	count := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || key <= query.Get("continuation-token") {
+			continue
+		}
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			commonPrefix := key[:len(prefix)+i+1]
+			if !seenPrefixes[commonPrefix] {
+				seenPrefixes[commonPrefix] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, struct{ Prefix string }{commonPrefix})
+				count++
+			}
+		} else {
+			result.Contents = append(result.Contents, fakeObject{Key: key, Size: int64(len(f.objects[key])), LastModified: fakeModTime})
+			count++
+		}
+		result.NextContinuationToken = key
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
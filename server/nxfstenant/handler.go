@@ -0,0 +1,262 @@
+package nxfstenant
+
+import (
+	"fmt"
+	nxsiteman "github.com/entando/entando-nxfs/server"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// PathPrefix - the path prefix selecting the tenant, followed by its name, when the tenant source is the path
+const PathPrefix = "/tenants/"
+
+// Factory - create the handler serving a tenant, and the function releasing its resources once the tenant is removed or
+// changed. an error leaves the tenant unserved
+type Factory func(tenant Tenant) (http.Handler, func(), error)
+
+// Selector - how the tenant of a request is selected
+type Selector struct {
+	// Source - the part of the request carrying the tenant: helper.TenantFromPath, helper.TenantFromHeader or helper.TenantFromClaim
+	Source string
+	// Header - the header carrying the tenant for helper.TenantFromHeader
+	Header string
+	// Claim - the JWT claim carrying the tenant of the caller, who can only access that tenant. the requests are routed
+	// by a trusted gateway if it's empty, for the path and header sources only
+	Claim string
+}
+
+// DefaultSelector - return the Selector configured by the environment
+func DefaultSelector() Selector {
+	return Selector{Source: helper.GetTenantSource(), Header: helper.GetTenantHeader(), Claim: helper.GetTenantClaim()}
+}
+
+// Handler - dispatches every request to the handler of its tenant. the handlers are created by the factory as soon as
+// their tenant is configured, and released as soon as it's removed or changed, once the requests they serve complete
+type Handler struct {
+	selector Selector
+	factory  Factory
+	// updating - serializes the updates of the tenants
+	updating sync.Mutex
+	mu       sync.RWMutex
+	served   map[string]*servedTenant
+}
+
+// servedTenant - a tenant with its handler
+type servedTenant struct {
+	tenant  Tenant
+	handler http.Handler
+	release func()
+	// ready - closed once the handler is started, or can't be: a changed tenant waits for the previous handler to be
+	// drained, so that two handlers never serve the same tenant at once
+	ready chan struct{}
+	// mu - guards released and the registration of the requests in flight
+	mu       sync.Mutex
+	inFlight sync.WaitGroup
+	released bool
+}
+
+// NewHandler - create a Handler of the tenants of the received registry, following its changes
+func NewHandler(registry *Registry, selector Selector, factory Factory) *Handler {
+	h := &Handler{selector: selector, factory: factory, served: map[string]*servedTenant{}}
+	registry.OnChange(h.update)
+	h.update(registry.Tenants())
+	return h
+}
+
+// update - release the handlers of the removed and changed tenants, and create the ones of the added and changed
+// tenants. a changed tenant whose handler can't be created is served again with its previous settings
+func (h *Handler) update(tenants map[string]Tenant) {
+	h.updating.Lock()
+	defer h.updating.Unlock()
+
+	h.mu.RLock()
+	current := make(map[string]*servedTenant, len(h.served))
+	for name, served := range h.served {
+		current[name] = served
+	}
+	h.mu.RUnlock()
+
+	for name, served := range current {
+		tenant, ok := tenants[name]
+		if ok && reflect.DeepEqual(tenant, served.tenant) {
+			continue
+		}
+
+		// the tenant is swapped out first, its new requests wait for the next handler while the previous one is drained
+		next := &servedTenant{tenant: tenant, ready: make(chan struct{})}
+		h.mu.Lock()
+		if ok {
+			h.served[name] = next
+		} else {
+			delete(h.served, name)
+		}
+		h.mu.Unlock()
+		served.drain()
+		log.Printf("Stopped serving the tenant %s", name)
+		if !ok {
+			continue
+		}
+
+		err := h.start(next, tenant)
+		if err != nil {
+			log.Printf("Can't serve the tenant %s with its new settings, keeping the previous ones: %s", name, err.Error())
+			err = h.start(next, served.tenant)
+		}
+		if err != nil {
+			log.Printf("Can't serve the tenant %s: %s", name, err.Error())
+			h.mu.Lock()
+			delete(h.served, name)
+			h.mu.Unlock()
+			next.released = true
+		}
+		close(next.ready)
+	}
+
+	for name, tenant := range tenants {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		started := &servedTenant{ready: make(chan struct{})}
+		if err := h.start(started, tenant); err != nil {
+			log.Printf("Can't serve the tenant %s: %s", name, err.Error())
+			continue
+		}
+		close(started.ready)
+		h.mu.Lock()
+		h.served[name] = started
+		h.mu.Unlock()
+	}
+}
+
+// start - create the handler of the received tenant, setting it in the received servedTenant
+func (h *Handler) start(served *servedTenant, tenant Tenant) error {
+	handler, release, err := h.factory(tenant)
+	if err != nil {
+		return err
+	}
+	log.Printf("Serving the tenant %s from %s", tenant.Name, tenant.Root)
+	served.tenant, served.handler, served.release = tenant, handler, release
+	return nil
+}
+
+// ServeHTTP - serve the request with the handler of its tenant. the path prefix selecting the tenant is removed from the request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, r, err := h.selector.resolve(r)
+	if err != nil {
+		nxsiteman.EncodeErrorResponse(err, w, r)
+		return
+	}
+
+	// the caller must be bound to the selected tenant by its token, unless no claim is configured
+	if "" != h.selector.Claim && helper.GetClaim(r.Context(), h.selector.Claim) != name {
+		nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrPermission, "tenant_forbidden", fmt.Sprintf("The caller can't access the tenant %s, its token must have the %s claim naming it", name, h.selector.Claim)), w, r)
+		return
+	}
+
+	for {
+		h.mu.RLock()
+		served, ok := h.served[name]
+		h.mu.RUnlock()
+		if !ok {
+			nxsiteman.EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrNotFound, "tenant_not_found", fmt.Sprintf("No tenant is named %q", name)), w, r)
+			return
+		}
+		select {
+		case <-served.ready:
+		case <-r.Context().Done():
+			return
+		}
+		if served.serve(w, r) {
+			return
+		}
+		// the tenant has been released meanwhile, the request is served by its new handler
+	}
+}
+
+// serve - serve the request with the handler of the tenant, registered as in flight so the tenant isn't released
+// meanwhile. return false if the tenant has been released
+func (served *servedTenant) serve(w http.ResponseWriter, r *http.Request) bool {
+	served.mu.Lock()
+	if served.released {
+		served.mu.Unlock()
+		return false
+	}
+	served.inFlight.Add(1)
+	served.mu.Unlock()
+	defer served.inFlight.Done()
+
+	served.handler.ServeHTTP(w, r)
+	return true
+}
+
+// drain - stop accepting requests, wait for the ones in flight to complete and release the resources of the tenant
+func (served *servedTenant) drain() {
+	served.mu.Lock()
+	served.released = true
+	served.mu.Unlock()
+
+	served.inFlight.Wait()
+	served.release()
+}
+
+// resolve - return the name of the tenant selected by the request, with the request to pass to its handler
+func (s Selector) resolve(r *http.Request) (string, *http.Request, error) {
+	var name string
+	switch s.Source {
+	case helper.TenantFromHeader:
+		name = strings.TrimSpace(r.Header.Get(s.Header))
+	case helper.TenantFromClaim:
+		name = helper.GetClaim(r.Context(), s.Claim)
+	default:
+		return resolvePath(r)
+	}
+	if "" == name {
+		return "", r, nxfserrors.New(nxfserrors.ErrInvalid, "tenant_required", fmt.Sprintf("The request must select a tenant with the %s %s", s.Source, s.selectedBy()))
+	}
+	return name, r, nil
+}
+
+// resolvePath - return the tenant named by the path prefix of the request, with a copy of the request without the prefix
+func resolvePath(r *http.Request) (string, *http.Request, error) {
+	escapedPath := r.URL.EscapedPath()
+	if !strings.HasPrefix(escapedPath, PathPrefix) {
+		return "", r, nxfserrors.New(nxfserrors.ErrInvalid, "tenant_required", fmt.Sprintf("The request path must start with %s{tenant}", PathPrefix))
+	}
+
+	escapedName := strings.TrimPrefix(escapedPath, PathPrefix)
+	rest := ""
+	if i := strings.Index(escapedName, "/"); i >= 0 {
+		escapedName, rest = escapedName[:i], escapedName[i:]
+	}
+	name, err := url.PathUnescape(escapedName)
+	if err != nil || "" == name {
+		return "", r, nxfserrors.New(nxfserrors.ErrInvalid, "tenant_required", fmt.Sprintf("The request path must start with %s{tenant}", PathPrefix))
+	}
+	if "" == rest {
+		rest = "/"
+	}
+
+	unescapedRest, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", r, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_path", err.Error())
+	}
+	tenantRequest := r.WithContext(r.Context())
+	tenantURL := *r.URL
+	tenantURL.Path, tenantURL.RawPath = unescapedRest, rest
+	tenantRequest.URL = &tenantURL
+	return name, tenantRequest, nil
+}
+
+// selectedBy - return what selects the tenant, for the error messages
+func (s Selector) selectedBy() string {
+	if helper.TenantFromHeader == s.Source {
+		return s.Header
+	}
+	return s.Claim
+}
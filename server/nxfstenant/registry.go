@@ -0,0 +1,257 @@
+package nxfstenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// validName - the tenant names, used as path segments
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Tenant - a site served by nxfs, with its own browsable fs, data and policies
+type Tenant struct {
+	Name string
+	helper.FsConfig
+}
+
+// tenantsFile - the content of the file configuring the tenants
+type tenantsFile struct {
+	Tenants []tenantConfig `json:"tenants"`
+}
+
+// tenantConfig - the configuration of a tenant, the missing directories are the ones named as the tenant under the
+// directories configured by the environment, the missing policies are the ones configured by the environment
+type tenantConfig struct {
+	Name          string   `json:"name"`
+	Root          string   `json:"root,omitempty"`
+	DataDir       string   `json:"dataDir,omitempty"`
+	S3Prefix      string   `json:"s3Prefix,omitempty"`
+	WorkflowPaths []string `json:"workflowPaths,omitempty"`
+	// MaxFileSize and Quotas - written as NXFS_MAX_FILE_SIZE and NXFS_QUOTAS
+	MaxFileSize string  `json:"maxFileSize,omitempty"`
+	Quotas      *string `json:"quotas,omitempty"`
+	// TypeRules - written as NXFS_TYPE_RULES
+	TypeRules []helper.TypeRule `json:"typeRules,omitempty"`
+}
+
+// Registry - the tenants configured by a JSON file, reloaded when the file changes
+type Registry struct {
+	path      string
+	mu        sync.RWMutex
+	tenants   map[string]Tenant
+	modTime   time.Time
+	size      int64
+	listeners []func(tenants map[string]Tenant)
+	stop      chan struct{}
+}
+
+// NewRegistry - create a Registry of the tenants configured by the received file, empty until it's loaded
+func NewRegistry(path string) *Registry {
+	return &Registry{path: path, tenants: map[string]Tenant{}}
+}
+
+// Tenants - return the configured tenants by name, the returned map must not be modified
+func (r *Registry) Tenants() map[string]Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tenants
+}
+
+// OnChange - call the received function with the configured tenants every time they change
+func (r *Registry) OnChange(listener func(tenants map[string]Tenant)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Load - read the tenants file. an invalid file leaves the tenants as they were
+func (r *Registry) Load() error {
+	fileInfo, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.modTime, r.size = fileInfo.ModTime(), fileInfo.Size()
+	r.mu.Unlock()
+
+	tenants, err := parseTenants(content)
+	if err != nil {
+		return fmt.Errorf("invalid tenants file %s: %s", r.path, err.Error())
+	}
+
+	r.mu.Lock()
+	r.tenants = tenants
+	listeners := r.listeners
+	r.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(tenants)
+	}
+	return nil
+}
+
+// Reload - load the tenants file if it changed since the last load, returning true if it has been read
+func (r *Registry) Reload() (bool, error) {
+	fileInfo, err := os.Stat(r.path)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	changed := !fileInfo.ModTime().Equal(r.modTime) || fileInfo.Size() != r.size
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, r.Load()
+}
+
+// Watch - reload the tenants file in background every time it changes, checking it at the received interval
+func (r *Registry) Watch(interval time.Duration) {
+	r.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if reloaded, err := r.Reload(); err != nil {
+					log.Printf("Keeping the current tenants, reload failed: %s", err.Error())
+				} else if reloaded {
+					log.Printf("Reloaded the tenants file %s", r.path)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopWatching - stop the reloads started by Watch
+func (r *Registry) StopWatching() {
+	close(r.stop)
+}
+
+// parseTenants - decode and validate the content of a tenants file: the names must be valid and unique, and the
+// directories and prefixes of different tenants must not contain each other, so that no tenant can reach the objects of another
+func parseTenants(content []byte) (map[string]Tenant, error) {
+	var file tenantsFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	tenants := map[string]Tenant{}
+	for _, config := range file.Tenants {
+		if !validName.MatchString(config.Name) {
+			return nil, fmt.Errorf("the tenant name %q is not valid", config.Name)
+		}
+		if _, ok := tenants[config.Name]; ok {
+			return nil, fmt.Errorf("the tenant %s is configured twice", config.Name)
+		}
+		tenant, err := newTenant(config)
+		if err != nil {
+			return nil, fmt.Errorf("the tenant %s has %s", config.Name, err.Error())
+		}
+		tenants[config.Name] = tenant
+	}
+
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		for _, other := range names[i+1:] {
+			if err := checkDisjoint(tenants[name], tenants[other]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tenants, nil
+}
+
+// newTenant - return the tenant of the received configuration, with the defaults of what it doesn't configure
+func newTenant(config tenantConfig) (Tenant, error) {
+	tenant := Tenant{Name: config.Name, FsConfig: helper.FsConfig{
+		Root:          config.Root,
+		DataDir:       config.DataDir,
+		S3Prefix:      config.S3Prefix,
+		WorkflowPaths: config.WorkflowPaths,
+	}}
+	if "" == tenant.Root {
+		tenant.Root = filepath.Join(helper.GetBrowsableFsRootPath(), config.Name)
+	}
+	if "" == tenant.DataDir {
+		tenant.DataDir = filepath.Join(helper.GetDataDirPath(), config.Name)
+	}
+	if "" == tenant.S3Prefix {
+		tenant.S3Prefix = path.Join(helper.GetS3Prefix(), config.Name)
+	}
+	if nil == tenant.WorkflowPaths {
+		tenant.WorkflowPaths = helper.GetWorkflowPaths()
+	}
+
+	var err error
+	tenant.MaxFileSize = helper.GetMaxFileSize()
+	if "" != config.MaxFileSize {
+		if tenant.MaxFileSize, err = helper.ParseSize(config.MaxFileSize); err != nil {
+			return Tenant{}, fmt.Errorf("an invalid maxFileSize: %s", err.Error())
+		}
+	}
+	// an empty string removes the quotas configured by the environment
+	tenant.Quotas = helper.GetQuotas()
+	if nil != config.Quotas {
+		if tenant.Quotas, err = helper.ParseQuotas(*config.Quotas); err != nil {
+			return Tenant{}, fmt.Errorf("invalid quotas: %s", err.Error())
+		}
+	}
+	tenant.TypeRules = helper.GetTypeRules()
+	if nil != config.TypeRules {
+		if tenant.TypeRules, err = helper.CleanTypeRules(config.TypeRules); err != nil {
+			return Tenant{}, fmt.Errorf("invalid type rules: %s", err.Error())
+		}
+	}
+	return tenant, nil
+}
+
+// checkDisjoint - return an error if a directory or the prefix of a tenant contains one of the other tenant
+func checkDisjoint(tenant Tenant, other Tenant) error {
+	dirs := func(t Tenant) []string {
+		root, _ := filepath.Abs(t.Root)
+		dataDir, _ := filepath.Abs(t.DataDir)
+		return []string{root, dataDir}
+	}
+	for _, dir := range dirs(tenant) {
+		for _, otherDir := range dirs(other) {
+			if nested(dir, otherDir, string(filepath.Separator)) {
+				return fmt.Errorf("the tenants %s and %s share the directory %s", tenant.Name, other.Name, dir)
+			}
+		}
+	}
+	prefix, otherPrefix := path.Clean("/"+tenant.S3Prefix), path.Clean("/"+other.S3Prefix)
+	if nested(prefix, otherPrefix, "/") {
+		return fmt.Errorf("the tenants %s and %s share the S3 prefix %s", tenant.Name, other.Name, prefix)
+	}
+	return nil
+}
+
+// nested - return true if one of the received cleaned paths is or contains the other
+func nested(a string, b string, separator string) bool {
+	return a == b || strings.HasPrefix(a, strings.TrimSuffix(b, separator)+separator) || strings.HasPrefix(b, strings.TrimSuffix(a, separator)+separator)
+}
@@ -0,0 +1,380 @@
+package nxfstenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	nxsiteman "github.com/entando/entando-nxfs/server"
+	"github.com/entando/entando-nxfs/server/controller"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/nxfsauth"
+	"github.com/entando/entando-nxfs/server/service"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// token - a bearer JWT carrying the received claims, unsigned as the tokens verified by a trusted gateway
+func token(claims map[string]interface{}) string {
+	encode := func(value interface{}) string {
+		data, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	return "Bearer " + encode(map[string]string{"alg": "none"}) + "." + encode(claims) + ".x"
+}
+
+// signedToken - a bearer JWT carrying the received claims, signed with HS256 and the received secret
+func signedToken(secret string, claims map[string]interface{}) string {
+	encode := func(value interface{}) string {
+		data, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return "Bearer " + signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// bound - the headers of a caller whose token binds it to the received tenant
+func bound(tenant string) map[string]string {
+	return map[string]string{"Authorization": token(map[string]interface{}{"tenant": tenant})}
+}
+
+// writeTenants - write a tenants file, changing its modification time so that a reload always sees it changed
+func writeTenants(t *testing.T, tenantsPath string, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(tenantsPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tenantsPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// echoFactory - a Factory whose handlers answer with the tenant name and the path they receive, recording the released tenants
+func echoFactory(released *[]string) Factory {
+	return func(tenant Tenant) (http.Handler, func(), error) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s", tenant.Name, r.URL.EscapedPath())
+		})
+		return handler, func() { *released = append(*released, tenant.Name) }, nil
+	}
+}
+
+func serve(handler http.Handler, method string, target string, header map[string]string) (int, string) {
+	request := httptest.NewRequest(method, target, nil)
+	for name, value := range header {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestParseTenants(t *testing.T) {
+	tenants, err := parseTenants([]byte(`{"tenants":[{"name":"a"},{"name":"b","root":"/sites/b","dataDir":"/data/b","s3Prefix":"b/","workflowPaths":["news"],"maxFileSize":"1M","quotas":"/=1G:100","typeRules":[{"path":"/draft_pages/","allow":[".page"]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := tenants["a"]
+	if a.Root != filepath.Join(helper.GetBrowsableFsRootPath(), "a") || a.DataDir != filepath.Join(helper.GetDataDirPath(), "a") || a.S3Prefix != "a" {
+		t.Fatalf("unexpected defaults %+v", a)
+	}
+	if b := tenants["b"]; b.Root != "/sites/b" || b.DataDir != "/data/b" || strings.Join(b.WorkflowPaths, ",") != "news" {
+		t.Fatalf("unexpected tenant %+v", b)
+	}
+	if b := tenants["b"]; b.MaxFileSize != 1<<20 || len(b.Quotas) != 1 || b.Quotas[0] != (helper.Quota{Path: ".", MaxBytes: 1 << 30, MaxFiles: 100}) {
+		t.Fatalf("unexpected limits %+v", b)
+	}
+	if b := tenants["b"]; len(b.TypeRules) != 1 || b.TypeRules[0].Path != "draft_pages" || strings.Join(b.TypeRules[0].Allow, ",") != ".page" {
+		t.Fatalf("unexpected type rules %+v", b.TypeRules)
+	}
+
+	for content, expected := range map[string]string{
+		`{"tenants":[{"name":"../a"}]}`:                                                    "not valid",
+		`{"tenants":[{"name":""}]}`:                                                        "not valid",
+		`{"tenants":[{"name":"a"},{"name":"a"}]}`:                                          "twice",
+		`{"tenants":[{"name":"a","root":"/sites"},{"name":"b","root":"/sites/b"}]}`:        "share the directory",
+		`{"tenants":[{"name":"a","dataDir":"/data"},{"name":"b","root":"/data/b/fs"}]}`:    "share the directory",
+		`{"tenants":[{"name":"a","s3Prefix":"sites"},{"name":"b","s3Prefix":"/sites/b"}]}`: "share the S3 prefix",
+		`{"tenants":[{"name":"a","maxFileSize":"big"}]}`:                                   "invalid maxFileSize",
+		`{"tenants":[{"name":"a","quotas":"pages"}]}`:                                      "invalid quotas",
+		`{"tenants":[{"name":"a","typeRules":[{"path":"pages","deny":["exe"]}]}]}`:         "invalid type rules",
+		`{"tenants":`: "unexpected end",
+	} {
+		if _, err := parseTenants([]byte(content)); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", content, expected, err)
+		}
+	}
+	if _, err := parseTenants([]byte(`{"tenants":[{"name":"a","root":"/sites/a"},{"name":"ab","root":"/sites/ab"}]}`)); err != nil {
+		t.Fatalf("sibling directories must be accepted, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tenantsPath := filepath.Join(dir, "tenants.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeTenants(t, tenantsPath, `{"tenants":[{"name":"a"},{"name":"b"}]}`, modTime)
+
+	registry := NewRegistry(tenantsPath)
+	if err = registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var released []string
+	byPath := nxfsauth.Handler(NewHandler(registry, Selector{Source: helper.TenantFromPath, Claim: "tenant"}, echoFactory(&released)), nil)
+	byHeader := nxfsauth.Handler(NewHandler(registry, Selector{Source: helper.TenantFromHeader, Header: "X-Tenant", Claim: "tenant"}, echoFactory(&released)), nil)
+	byClaim := nxfsauth.Handler(NewHandler(registry, Selector{Source: helper.TenantFromClaim, Claim: "site"}, echoFactory(&released)), nil)
+	// a gateway routing every caller to its tenant, nxfs doesn't check the tenant of the callers
+	byGateway := nxfsauth.Handler(NewHandler(registry, Selector{Source: helper.TenantFromPath}, echoFactory(&released)), nil)
+	verifier, err := nxfsauth.NewVerifier(nxfsauth.Config{Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := nxfsauth.Handler(NewHandler(registry, Selector{Source: helper.TenantFromPath, Claim: "tenant"}, echoFactory(&released)), verifier)
+
+	// the prefix is removed keeping the encoding of the rest of the path
+	if code, body := serve(byPath, "GET", "/tenants/a/api/nxfs/objects/docs%2Fa.txt", bound("a")); code != http.StatusOK || body != "a /api/nxfs/objects/docs%2Fa.txt" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if code, body := serve(byHeader, "GET", "/api/nxfs/browse/docs", map[string]string{"X-Tenant": "b", "Authorization": bound("b")["Authorization"]}); code != http.StatusOK || body != "b /api/nxfs/browse/docs" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if code, body := serve(byGateway, "GET", "/tenants/b/api/nxfs/browse/docs", nil); code != http.StatusOK || body != "b /api/nxfs/browse/docs" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if code, body := serve(verified, "GET", "/tenants/a/api/nxfs/browse/docs", map[string]string{"Authorization": signedToken("secret", map[string]interface{}{"tenant": "a"})}); code != http.StatusOK || body != "a /api/nxfs/browse/docs" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if code, body := serve(byClaim, "GET", "/api/nxfs/browse/docs", map[string]string{"Authorization": token(map[string]interface{}{"site": "a"})}); code != http.StatusOK || body != "a /api/nxfs/browse/docs" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+
+	for _, unexpected := range []struct {
+		handler http.Handler
+		target  string
+		header  map[string]string
+		status  int
+		code    string
+	}{
+		{byPath, "/api/nxfs/browse/docs", nil, http.StatusBadRequest, "tenant_required"},
+		{byPath, "/tenants/", nil, http.StatusBadRequest, "tenant_required"},
+		{byPath, "/tenants/c/api/nxfs/browse/docs", bound("c"), http.StatusNotFound, "tenant_not_found"},
+		{byHeader, "/api/nxfs/browse/docs", nil, http.StatusBadRequest, "tenant_required"},
+		{byHeader, "/api/nxfs/browse/docs", map[string]string{"X-Tenant": "../a", "Authorization": bound("../a")["Authorization"]}, http.StatusNotFound, "tenant_not_found"},
+		{byClaim, "/api/nxfs/browse/docs", map[string]string{"X-Tenant": "a"}, http.StatusBadRequest, "tenant_required"},
+		// a caller bound to a tenant by its token can't select another one
+		{byPath, "/tenants/b/api/nxfs/browse/docs", map[string]string{"Authorization": token(map[string]interface{}{"tenant": "a"})}, http.StatusForbidden, "tenant_forbidden"},
+		{byHeader, "/api/nxfs/browse/docs", map[string]string{"X-Tenant": "b", "Authorization": token(map[string]interface{}{"tenant": "a"})}, http.StatusForbidden, "tenant_forbidden"},
+		// and a caller not bound to any tenant can't access them
+		{byPath, "/tenants/a/api/nxfs/browse/docs", nil, http.StatusForbidden, "tenant_forbidden"},
+		{byHeader, "/api/nxfs/browse/docs", map[string]string{"X-Tenant": "a"}, http.StatusForbidden, "tenant_forbidden"},
+		// the tokens naming a tenant must be signed when they are verified by nxfs
+		{verified, "/tenants/a/api/nxfs/browse/docs", bound("a"), http.StatusUnauthorized, "invalid_token"},
+		{verified, "/tenants/a/api/nxfs/browse/docs", map[string]string{"Authorization": signedToken("other", map[string]interface{}{"tenant": "a"})}, http.StatusUnauthorized, "invalid_token"},
+		{verified, "/tenants/a/api/nxfs/browse/docs", nil, http.StatusForbidden, "tenant_forbidden"},
+	} {
+		code, body := serve(unexpected.handler, "GET", unexpected.target, unexpected.header)
+		if code != unexpected.status || !strings.Contains(body, `"code":"`+unexpected.code+`"`) {
+			t.Errorf("%s %v: expected %d %s, got %d %s", unexpected.target, unexpected.header, unexpected.status, unexpected.code, code, body)
+		}
+	}
+
+	// the tenants follow the file: b is removed, a changed and c added, without restarting
+	if reloaded, err := registry.Reload(); reloaded || err != nil {
+		t.Fatalf("the unchanged file must not be reloaded, got %v %v", reloaded, err)
+	}
+	writeTenants(t, tenantsPath, `{"tenants":[{"name":"a","workflowPaths":["news"]},{"name":"c"}]}`, modTime.Add(time.Minute))
+	if reloaded, err := registry.Reload(); !reloaded || err != nil {
+		t.Fatalf("expected the changed file to be reloaded, got %v %v", reloaded, err)
+	}
+	if len(released) != 10 {
+		t.Fatalf("expected a and b to be released by the five handlers, got %v", released)
+	}
+	if code, body := serve(byPath, "GET", "/tenants/c/api/nxfs/audit", bound("c")); code != http.StatusOK || body != "c /api/nxfs/audit" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if code, _ := serve(byPath, "GET", "/tenants/b/api/nxfs/audit", bound("b")); code != http.StatusNotFound {
+		t.Fatalf("expected the removed tenant to be not found, got %d", code)
+	}
+
+	// an invalid file keeps the current tenants
+	writeTenants(t, tenantsPath, `{"tenants":[{"name":"a"},{"name":"a"}]}`, modTime.Add(2*time.Minute))
+	if _, err := registry.Reload(); err == nil {
+		t.Fatal("expected the invalid file to be rejected")
+	}
+	if code, _ := serve(byPath, "GET", "/tenants/c/api/nxfs/audit", bound("c")); code != http.StatusOK || len(released) != 10 {
+		t.Fatalf("expected the tenants to be kept, got %d %v", code, released)
+	}
+}
+
+func TestHandlerRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tenantsPath := filepath.Join(dir, "tenants.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeTenants(t, tenantsPath, `{"tenants":[{"name":"a","workflowPaths":["news"]}]}`, modTime)
+	registry := NewRegistry(tenantsPath)
+	if err = registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the handlers answer with the workflow paths of their tenant, the slow requests once unblocked. the tenants with a
+	// broken workflow path can't be served
+	var inFlight int32
+	var releasedInFlight []int32
+	started, unblock := make(chan bool), make(chan bool)
+	handler := NewHandler(registry, Selector{Source: helper.TenantFromPath}, func(tenant Tenant) (http.Handler, func(), error) {
+		if strings.Join(tenant.WorkflowPaths, ",") == "broken" {
+			return nil, nil, fmt.Errorf("broken tenant")
+		}
+		served := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			if strings.HasSuffix(r.URL.Path, "/slow") {
+				started <- true
+				<-unblock
+			}
+			fmt.Fprint(w, strings.Join(tenant.WorkflowPaths, ","))
+		})
+		return served, func() { releasedInFlight = append(releasedInFlight, atomic.LoadInt32(&inFlight)) }, nil
+	})
+
+	slow := make(chan string)
+	go func() {
+		_, body := serve(handler, "GET", "/tenants/a/slow", nil)
+		slow <- body
+	}()
+	<-started
+
+	// the changed tenant is released only once the request it's serving completes
+	writeTenants(t, tenantsPath, `{"tenants":[{"name":"a","workflowPaths":["blog"]},{"name":"b","workflowPaths":["broken"]}]}`, modTime.Add(time.Minute))
+	reloaded := make(chan error)
+	go func() {
+		_, err := registry.Reload()
+		reloaded <- err
+	}()
+	select {
+	case err = <-reloaded:
+		t.Fatalf("expected the reload to wait for the request, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// the tenant is already swapped out, a request received meanwhile waits for the new settings
+	waiting := make(chan string)
+	go func() {
+		_, body := serve(handler, "GET", "/tenants/a/fast", nil)
+		waiting <- body
+	}()
+	close(unblock)
+	if body := <-slow; body != "news" {
+		t.Fatalf("expected the request to complete with the previous settings, got %s", body)
+	}
+	if err = <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	if body := <-waiting; body != "blog" {
+		t.Fatalf("expected the request received during the drain to be served with the new settings, got %s", body)
+	}
+	if len(releasedInFlight) != 1 || releasedInFlight[0] != 0 {
+		t.Fatalf("expected the tenant to be released without requests in flight, got %v", releasedInFlight)
+	}
+	if _, body := serve(handler, "GET", "/tenants/a/fast", nil); body != "blog" {
+		t.Fatalf("expected the new settings, got %s", body)
+	}
+	// a tenant that can't be served doesn't prevent the others from being served
+	if code, _ := serve(handler, "GET", "/tenants/b/fast", nil); code != http.StatusNotFound {
+		t.Fatalf("expected the broken tenant to be not found, got %d", code)
+	}
+
+	// a changed tenant that can't be served keeps its previous settings
+	writeTenants(t, tenantsPath, `{"tenants":[{"name":"a","workflowPaths":["broken"]}]}`, modTime.Add(2*time.Minute))
+	if _, err = registry.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := serve(handler, "GET", "/tenants/a/fast", nil); code != http.StatusOK || body != "blog" {
+		t.Fatalf("expected the previous settings to be kept, got %d %s", code, body)
+	}
+}
+
+func TestTenantsIsolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tenantsPath := filepath.Join(dir, "tenants.json")
+	tenantsFile := fmt.Sprintf(`{"tenants":[{"name":"a","root":%q,"dataDir":%q},{"name":"b","root":%q,"dataDir":%q}]}`,
+		filepath.Join(dir, "a", "fs"), filepath.Join(dir, "a", "data"), filepath.Join(dir, "b", "fs"), filepath.Join(dir, "b", "data"))
+	writeTenants(t, tenantsPath, tenantsFile, time.Now())
+	for _, root := range []string{filepath.Join(dir, "a", "fs"), filepath.Join(dir, "b", "fs")} {
+		if err = os.MkdirAll(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := NewRegistry(tenantsPath)
+	if err = registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(registry, Selector{Source: helper.TenantFromPath, Claim: "tenant"}, func(tenant Tenant) (http.Handler, func(), error) {
+		apiService, err := service.NewFsApiService(tenant.FsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nxsiteman.NewRouter(controller.NewDefaultApiController(apiService)), apiService.Close, nil
+	})
+	server := httptest.NewServer(nxfsauth.Handler(handler, nil))
+	defer server.Close()
+
+	// the callers are bound to the tenant they access
+	send := func(method string, target string, body string) int {
+		request, _ := http.NewRequest(method, server.URL+target, strings.NewReader(body))
+		request.Header.Set("Authorization", bound(strings.Split(strings.TrimPrefix(target, PathPrefix), "/")[0])["Authorization"])
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+	put := func(target string, object string) int {
+		return send("PUT", target, object)
+	}
+	get := func(target string) int {
+		return send("GET", target, "")
+	}
+
+	if status := put("/tenants/a/api/nxfs/objects/page.txt", `{"name":"page.txt","type":"f","content":"a"}`); status != http.StatusCreated {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if status := get("/tenants/a/api/nxfs/objects/page.txt"); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if status := get("/tenants/b/api/nxfs/objects/page.txt"); status != http.StatusNotFound {
+		t.Fatalf("tenant b must not see the objects of a, got %d", status)
+	}
+	// the paths are relative to the root of the tenant, they can't escape it
+	if status := get("/tenants/b/api/nxfs/objects/..%2F..%2Fa%2Ffs%2Fpage.txt"); status != http.StatusNotFound {
+		t.Fatalf("tenant b must not reach the root of a, got %d", status)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "a", "data", "audit.log")); err != nil {
+		t.Fatalf("expected the audit log of a in its data dir: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "b", "data", "audit.log")); !os.IsNotExist(err) {
+		t.Fatalf("expected no audit log for b: %v", err)
+	}
+}
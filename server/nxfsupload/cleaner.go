@@ -0,0 +1,56 @@
+package nxfsupload
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultCleanupInterval - how often the cleaner looks for expired uploads
+const DefaultCleanupInterval = time.Minute
+
+// Cleaner - removes the expired uploads of a Store, and the bytes they received
+type Cleaner struct {
+	store    *Store
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewCleaner - create a Cleaner removing the expired uploads of store every interval
+func NewCleaner(store *Store, interval time.Duration) *Cleaner {
+	return &Cleaner{
+		store:    store,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start - start removing the expired uploads in background, the ones that expired while nxfs was down are removed immediately
+func (c *Cleaner) Start() {
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			if removed, err := c.store.RemoveExpired(time.Now()); err != nil {
+				log.Printf("Removal of the expired uploads failed: %s", err.Error())
+			} else if removed > 0 {
+				log.Printf("Removed %d expired uploads", removed)
+			}
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stop the cleaner, waiting for the running removal to complete
+func (c *Cleaner) Stop() {
+	close(c.stop)
+	<-c.done
+}
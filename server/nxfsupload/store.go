@@ -0,0 +1,230 @@
+package nxfsupload
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store - the resumable uploads, each one kept in a directory as <id>.json describing it next to <id>.part holding the
+// bytes received so far, so that they survive the restarts. the offset of an upload is the size of its part file
+type Store struct {
+	dir        string
+	expiration time.Duration
+	mu         sync.Mutex
+	// busy - the uploads receiving a chunk or being finalized
+	busy map[string]bool
+}
+
+// NewStore - create a store keeping the uploads under the received directory, discarding them when they don't receive
+// chunks for longer than expiration
+func NewStore(dir string, expiration time.Duration) *Store {
+	return &Store{dir: dir, expiration: expiration, busy: map[string]bool{}}
+}
+
+// Create - start the upload of a file of the received length to a path relative to the browsable fs root, the checksum
+// is the optional SHA-256 of the whole file
+func (s *Store) Create(relPath string, length int64, checksum string, user string) (model.Upload, error) {
+	if length < 1 {
+		return model.Upload{}, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_upload", "The length of the upload must be positive, a file with empty content can't be saved")
+	}
+	checksum = strings.ToLower(checksum)
+	if decoded, err := hex.DecodeString(checksum); "" != checksum && (err != nil || len(decoded) != 32) {
+		return model.Upload{}, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_upload", "The checksum must be a hex encoded SHA-256")
+	}
+
+	now := time.Now().UTC()
+	upload := model.Upload{Id: helper.NewRandomId(), Path: relPath, Length: length, Checksum: checksum, CreatedBy: user, CreatedAt: now, ExpiresAt: now.Add(s.expiration)}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return model.Upload{}, nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the creation of the uploads directory")
+	}
+	if err := ioutil.WriteFile(s.PartPath(upload.Id), nil, 0600); err != nil {
+		return model.Upload{}, nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the creation of the upload")
+	}
+	if err := s.save(upload); err != nil {
+		_ = os.Remove(s.PartPath(upload.Id))
+		return model.Upload{}, err
+	}
+	return upload, nil
+}
+
+// Get - return the upload identified by the received id, with its current offset
+func (s *Store) Get(id string) (model.Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(id)
+}
+
+// Acquire - return the upload identified by the received id, preventing the other requests from changing it until the
+// returned function is called. an upload receiving a chunk or being finalized can't be acquired
+func (s *Store) Acquire(id string) (model.Upload, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, err := s.load(id)
+	if err != nil {
+		return model.Upload{}, nil, err
+	}
+	if s.busy[id] {
+		return model.Upload{}, nil, nxfserrors.New(nxfserrors.ErrConflict, "upload_busy", "The upload is already receiving a chunk or being finalized")
+	}
+	s.busy[id] = true
+	return upload, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.busy, id)
+	}, nil
+}
+
+// Append - append a chunk, starting at the received offset, to an upload acquired by the caller, returning the upload
+// with its new offset. the bytes received before a failure of the chunk are kept, the upload resumes from them
+func (s *Store) Append(upload model.Upload, offset int64, chunk io.Reader) (model.Upload, error) {
+	if offset != upload.Offset {
+		err := nxfserrors.New(nxfserrors.ErrConflict, "offset_mismatch", fmt.Sprintf("The chunk starts at %d, the upload continues from %d", offset, upload.Offset))
+		err.Details = []model.ResultDetail{{Field: "offset", Message: fmt.Sprint(upload.Offset)}}
+		return upload, err
+	}
+
+	part, err := os.OpenFile(s.PartPath(upload.Id), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return upload, nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the opening of the upload")
+	}
+	written, copyErr := io.Copy(part, io.LimitReader(chunk, upload.Length-upload.Offset))
+	if copyErr == nil {
+		// the chunk can't go beyond the length of the upload
+		if n, _ := chunk.Read(make([]byte, 1)); n > 0 {
+			copyErr = nxfserrors.New(nxfserrors.ErrInvalid, "upload_overflow", fmt.Sprintf("The chunk goes beyond the length of the upload, %d bytes", upload.Length))
+			if err = part.Truncate(upload.Offset); err == nil {
+				written = 0
+			}
+		}
+	}
+	if err = part.Close(); err != nil && copyErr == nil {
+		copyErr = nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the writing of the chunk")
+	}
+	upload.Offset += written
+
+	// every chunk postpones the expiration
+	upload.ExpiresAt = time.Now().UTC().Add(s.expiration)
+	if err = s.save(upload); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if copyErr != nil {
+		return upload, nxfserrors.Wrap(copyErr, "chunk_error")
+	}
+	return upload, nil
+}
+
+// PartPath - return the path of the file holding the bytes received by the upload identified by the received id
+func (s *Store) PartPath(id string) string {
+	return filepath.Join(s.dir, id+".part")
+}
+
+// Remove - remove the upload identified by the received id and the bytes it received
+func (s *Store) Remove(id string) error {
+	for _, removed := range []string{s.descriptionPath(id), s.PartPath(id)} {
+		if err := os.Remove(removed); err != nil && !os.IsNotExist(err) {
+			return nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the removal of the upload")
+		}
+	}
+	return nil
+}
+
+// RemoveExpired - remove the uploads expired at the received time, and the part files left without description by an
+// interrupted creation, returning how many uploads have been removed
+func (s *Store) RemoveExpired(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the listing of the uploads")
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if s.busy[id] || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		// the files without a readable description expire from their last change
+		upload, err := s.read(id)
+		if err == nil && !now.After(upload.ExpiresAt) || err != nil && now.Sub(entry.ModTime()) <= s.expiration {
+			continue
+		}
+		if err = s.Remove(id); err != nil {
+			return removed, err
+		}
+		if filepath.Ext(entry.Name()) == ".json" {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// load - read the description of an upload and its offset, an expired upload doesn't exist anymore. must be called holding mu
+func (s *Store) load(id string) (model.Upload, error) {
+	upload, err := s.read(id)
+	if err == nil && time.Now().After(upload.ExpiresAt) {
+		err = os.ErrNotExist
+	}
+	var fileInfo os.FileInfo
+	if err == nil {
+		fileInfo, err = os.Stat(s.PartPath(id))
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return model.Upload{}, nxfserrors.New(nxfserrors.ErrNotFound, "upload_not_found", "The upload doesn't exist or has expired")
+		}
+		return model.Upload{}, nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the reading of the upload")
+	}
+	upload.Offset = fileInfo.Size()
+	return upload, nil
+}
+
+// read - read the description of an upload, an os error if it's missing or unreadable
+func (s *Store) read(id string) (model.Upload, error) {
+	if "" == id || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return model.Upload{}, os.ErrNotExist
+	}
+	content, err := ioutil.ReadFile(s.descriptionPath(id))
+	if err != nil {
+		return model.Upload{}, err
+	}
+	var upload model.Upload
+	if err = json.Unmarshal(content, &upload); err != nil {
+		return model.Upload{}, fmt.Errorf("the upload %s is corrupted: %s", id, err.Error())
+	}
+	return upload, nil
+}
+
+// save - atomically rewrite the description of an upload
+func (s *Store) save(upload model.Upload) error {
+	content, err := json.MarshalIndent(upload, "", "  ")
+	if err != nil {
+		return nxfserrors.Wrap(err, "upload_store_error")
+	}
+	if err = nxfsfiles.WriteFileAtomic(s.descriptionPath(upload.Id), bytes.NewReader(content), 0600); err != nil {
+		return nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the writing of the upload")
+	}
+	return nil
+}
+
+func (s *Store) descriptionPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
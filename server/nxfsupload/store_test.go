@@ -0,0 +1,125 @@
+package nxfsupload
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var nxfsErr *nxfserrors.Error
+	if !errors.As(err, &nxfsErr) || nxfsErr.Code != code {
+		t.Fatalf("expected a %s error, got %v", code, err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewStore(dir, time.Hour)
+
+	_, err = store.Create("docs/a.txt", 0, "", "alice")
+	expectCode(t, err, "invalid_upload")
+	_, err = store.Create("docs/a.txt", 10, "abc", "alice")
+	expectCode(t, err, "invalid_upload")
+
+	upload, err := store.Create("docs/a.txt", 10, "", "alice")
+	if err != nil || upload.Offset != 0 || upload.CreatedBy != "alice" {
+		t.Fatalf("unexpected upload %+v %v", upload, err)
+	}
+
+	acquired, release, err := store.Acquire(upload.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = store.Acquire(upload.Id)
+	expectCode(t, err, "upload_busy")
+	if acquired, err = store.Append(acquired, 0, strings.NewReader("01234")); err != nil || acquired.Offset != 5 {
+		t.Fatalf("expected the offset to move to 5, got %+v %v", acquired, err)
+	}
+	_, err = store.Append(acquired, 0, strings.NewReader("01234"))
+	expectCode(t, err, "offset_mismatch")
+	_, err = store.Append(acquired, 5, strings.NewReader("56789X"))
+	expectCode(t, err, "upload_overflow")
+	release()
+
+	// the uploads survive a restart, with the bytes received so far
+	store = NewStore(dir, time.Hour)
+	if upload, err = store.Get(upload.Id); err != nil || upload.Offset != 5 {
+		t.Fatalf("expected the upload to continue from 5, got %+v %v", upload, err)
+	}
+	acquired, release, err = store.Acquire(upload.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acquired, err = store.Append(acquired, 5, strings.NewReader("56789")); err != nil || acquired.Offset != 10 {
+		t.Fatalf("expected the upload to be complete, got %+v %v", acquired, err)
+	}
+	release()
+	if content, err := ioutil.ReadFile(store.PartPath(upload.Id)); err != nil || "0123456789" != string(content) {
+		t.Fatalf("unexpected content %q %v", content, err)
+	}
+
+	_, err = store.Get("../uploads")
+	expectCode(t, err, "upload_not_found")
+}
+
+func TestRemoveExpired(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nxfs-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewStore(dir, time.Hour)
+
+	expired, _ := store.Create("docs/expired.txt", 10, "", "alice")
+	kept, _ := store.Create("docs/kept.txt", 10, "", "alice")
+	busy, _ := store.Create("docs/busy.txt", 10, "", "alice")
+	_, release, err := store.Acquire(busy.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	// a part file whose creation has been interrupted
+	if err = ioutil.WriteFile(filepath.Join(dir, "orphan.part"), []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunks postpone the expiration
+	later := time.Now().Add(90 * time.Minute)
+	acquired, releaseKept, _ := store.Acquire(kept.Id)
+	store.expiration = 2 * time.Hour
+	if _, err = store.Append(acquired, 0, strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+	releaseKept()
+	store.expiration = time.Hour
+
+	if removed, err := store.RemoveExpired(later); err != nil || removed != 1 {
+		t.Fatalf("expected an expired upload to be removed, got %d %v", removed, err)
+	}
+	if _, err = store.Get(expired.Id); err == nil {
+		t.Fatal("expected the expired upload to be removed")
+	}
+	if _, err = os.Stat(store.PartPath(expired.Id)); !os.IsNotExist(err) {
+		t.Fatalf("expected the bytes of the expired upload to be removed, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "orphan.part")); !os.IsNotExist(err) {
+		t.Fatalf("expected the orphan part file to be removed, got %v", err)
+	}
+	if _, err = os.Stat(store.PartPath(busy.Id)); err != nil {
+		t.Fatalf("expected the busy upload to be kept, got %v", err)
+	}
+	if upload, err := store.Get(kept.Id); err != nil || upload.Offset != 4 {
+		t.Fatalf("expected the upload receiving chunks to be kept, got %+v %v", upload, err)
+	}
+}
@@ -0,0 +1,309 @@
+package nxfsworkflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transition - the states an action can be executed from and the state it leads to
+type transition struct {
+	from []model.WorkflowState
+	to   model.WorkflowState
+}
+
+// transitions - the state machine of the review workflow for the actions requested by the users.
+// publish, edit and unpublish are applied by nxfs itself when the page is published, saved or unpublished
+var transitions = map[model.WorkflowAction]transition{
+	model.WorkflowSubmit:  {from: []model.WorkflowState{model.WorkflowDraft}, to: model.WorkflowInReview},
+	model.WorkflowApprove: {from: []model.WorkflowState{model.WorkflowInReview}, to: model.WorkflowApproved},
+	model.WorkflowReject:  {from: []model.WorkflowState{model.WorkflowInReview, model.WorkflowApproved}, to: model.WorkflowDraft},
+}
+
+// Store - the workflow state of the pages, persisted as a JSON file rewritten atomically on every change.
+// pages without a stored state are drafts
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	pages  map[string]model.PageWorkflow
+	// workflowPaths - the directories, relative to the pages folders, whose pages are subject to the workflow, see helper.GetWorkflowPaths
+	workflowPaths []string
+}
+
+// NewStore - create a Store backed by the file identified by the received path, applying the workflow to the pages
+// under the received directories. the file is read on the first access
+func NewStore(path string, workflowPaths []string) *Store {
+	return &Store{path: path, workflowPaths: workflowPaths}
+}
+
+// Enabled - return true if the received page, relative to the pages folders, is subject to the review workflow
+func (s *Store) Enabled(pagePath string) bool {
+	pagePath = path.Clean("/" + pagePath)
+	for _, workflowPath := range s.workflowPaths {
+		workflowPath = path.Clean("/" + workflowPath)
+		if workflowPath == "/" || pagePath == workflowPath || strings.HasPrefix(pagePath, workflowPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Get - return the workflow state of the received page
+func (s *Store) Get(pagePath string) (model.PageWorkflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return model.PageWorkflow{}, err
+	}
+	return s.current(pagePath), nil
+}
+
+// Transition - execute an action requested by user on the received page, whose draft has the received hash
+func (s *Store) Transition(pagePath string, action model.WorkflowAction, user string, comment string, draftHash string) (model.PageWorkflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return model.PageWorkflow{}, err
+	}
+
+	allowed, ok := transitions[action]
+	if !ok {
+		return model.PageWorkflow{}, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_action",
+			fmt.Sprintf("The workflow action must be one of %s, %s or %s", model.WorkflowSubmit, model.WorkflowApprove, model.WorkflowReject))
+	}
+	if action == model.WorkflowReject && "" == strings.TrimSpace(comment) {
+		return model.PageWorkflow{}, nxfserrors.New(nxfserrors.ErrInvalid, "comment_required", "A comment explaining the rejection is required")
+	}
+
+	page := s.current(pagePath)
+	if !containsState(allowed.from, page.State) {
+		return model.PageWorkflow{}, nxfserrors.New(nxfserrors.ErrConflict, "invalid_transition",
+			fmt.Sprintf("The page %s is %s and can't be %s", pagePath, page.State, describe(action)))
+	}
+
+	approvedHash := ""
+	if action == model.WorkflowApprove {
+		approvedHash = draftHash
+	}
+	return s.record(page, action, allowed.to, user, comment, approvedHash)
+}
+
+// CheckPublishable - return a not_approved error if the page is subject to the workflow and its draft, with the received hash,
+// is not the approved one
+func (s *Store) CheckPublishable(pagePath string, draftHash string) error {
+	if !s.Enabled(pagePath) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	page := s.current(pagePath)
+	if page.State != model.WorkflowApproved {
+		return nxfserrors.New(nxfserrors.ErrConflict, "not_approved", fmt.Sprintf("The page %s is %s, only approved pages can be published", pagePath, page.State))
+	} else if page.ApprovedHash != draftHash {
+		return nxfserrors.New(nxfserrors.ErrConflict, "not_approved", fmt.Sprintf("The draft of the page %s changed after its approval", pagePath))
+	}
+	return nil
+}
+
+// Published - record the publication of an approved page
+func (s *Store) Published(pagePath string, user string) error {
+	return s.apply(pagePath, model.WorkflowPublish, model.WorkflowPublished, user, []model.WorkflowState{model.WorkflowApproved})
+}
+
+// Edited - bring back to draft a page whose draft has been modified, its review restarts
+func (s *Store) Edited(pagePath string, user string) error {
+	return s.apply(pagePath, model.WorkflowEdit, model.WorkflowDraft, user, []model.WorkflowState{model.WorkflowInReview, model.WorkflowApproved, model.WorkflowPublished})
+}
+
+// Unpublished - bring back to draft a page that has been unpublished
+func (s *Store) Unpublished(pagePath string, user string) error {
+	return s.apply(pagePath, model.WorkflowUnpublish, model.WorkflowDraft, user, []model.WorkflowState{model.WorkflowPublished})
+}
+
+// Restore - bring a page back to a state previously returned by Get, dropping the events recorded since then.
+// used to undo the operations rolled back
+func (s *Store) Restore(page model.PageWorkflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	previous, stored := s.pages[page.Path]
+	if len(page.History) == 0 {
+		delete(s.pages, page.Path)
+	} else {
+		s.pages[page.Path] = page
+	}
+	if err := s.save(); err != nil {
+		if stored {
+			s.pages[page.Path] = previous
+		} else {
+			delete(s.pages, page.Path)
+		}
+		return err
+	}
+	return nil
+}
+
+// List - return the workflow state of the received pages, optionally filtered by state
+func (s *Store) List(pagePaths []string, state model.WorkflowState) ([]model.PageWorkflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	pages := make([]model.PageWorkflow, 0, len(pagePaths))
+	for _, pagePath := range pagePaths {
+		if page := s.current(pagePath); "" == state || page.State == state {
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+// apply - record an action executed by nxfs if the page is subject to the workflow and in one of the received states
+func (s *Store) apply(pagePath string, action model.WorkflowAction, to model.WorkflowState, user string, from []model.WorkflowState) error {
+	if !s.Enabled(pagePath) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	page := s.current(pagePath)
+	if !containsState(from, page.State) {
+		return nil
+	}
+	approvedHash := ""
+	if to == model.WorkflowPublished {
+		approvedHash = page.ApprovedHash
+	}
+	_, err := s.record(page, action, to, user, "", approvedHash)
+	return err
+}
+
+// record - move the page to the received state appending the event to its history. must be called holding mu
+func (s *Store) record(page model.PageWorkflow, action model.WorkflowAction, to model.WorkflowState, user string, comment string, approvedHash string) (model.PageWorkflow, error) {
+	now := time.Now().UTC()
+	previous := page
+
+	page.History = append(append([]model.WorkflowEvent(nil), page.History...),
+		model.WorkflowEvent{Action: action, From: page.State, To: to, User: user, Comment: comment, At: now})
+	page.State = to
+	page.ApprovedHash = approvedHash
+	page.UpdatedBy = user
+	page.UpdatedAt = &now
+
+	s.pages[page.Path] = page
+	if err := s.save(); err != nil {
+		s.pages[page.Path] = previous
+		return model.PageWorkflow{}, err
+	}
+	return page, nil
+}
+
+// current - return the stored state of a page, a draft without history if none is stored. must be called holding mu
+func (s *Store) current(pagePath string) model.PageWorkflow {
+	if page, ok := s.pages[pagePath]; ok {
+		return page
+	}
+	return model.PageWorkflow{Path: pagePath, State: model.WorkflowDraft, History: []model.WorkflowEvent{}}
+}
+
+// load - read the workflow file if not already done, a missing file is an empty store. must be called holding mu
+func (s *Store) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	pages := map[string]model.PageWorkflow{}
+	content, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nxfserrors.FromOS(err, "workflow_store_error", "An error occurred during the reading of the workflow state")
+	}
+
+	if len(content) > 0 {
+		var list model.PageWorkflowList
+		if err = json.Unmarshal(content, &list); err != nil {
+			return nxfserrors.New(nxfserrors.ErrInternal, "workflow_store_error", fmt.Sprintf("The workflow file %s is corrupted: %s", s.path, err.Error()))
+		}
+		for _, page := range list.List {
+			pages[page.Path] = page
+		}
+	}
+
+	s.pages = pages
+	s.loaded = true
+	return nil
+}
+
+// save - atomically rewrite the workflow file with the current state of the pages. must be called holding mu
+func (s *Store) save() error {
+	list := model.PageWorkflowList{List: make([]model.PageWorkflow, 0, len(s.pages))}
+	for _, page := range s.pages {
+		list.List = append(list.List, page)
+	}
+	sort.Slice(list.List, func(i, j int) bool { return list.List[i].Path < list.List[j].Path })
+
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return nxfserrors.Wrap(err, "workflow_store_error")
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nxfserrors.FromOS(err, "workflow_store_error", "An error occurred during the creation of the data dir")
+	}
+	if err = nxfsfiles.WriteFileAtomic(s.path, bytes.NewReader(content), 0644); err != nil {
+		return nxfserrors.FromOS(err, "workflow_store_error", "An error occurred during the writing of the workflow state")
+	}
+	return nil
+}
+
+// containsState - return true if state is one of the received states
+func containsState(states []model.WorkflowState, state model.WorkflowState) bool {
+	for _, candidate := range states {
+		if candidate == state {
+			return true
+		}
+	}
+	return false
+}
+
+// describe - return the past participle of an action, used in the error messages
+func describe(action model.WorkflowAction) string {
+	switch action {
+	case model.WorkflowSubmit:
+		return "submitted"
+	case model.WorkflowApprove:
+		return "approved"
+	}
+	return "rejected"
+}
@@ -0,0 +1,208 @@
+package nxfsworkflow
+
+import (
+	"errors"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestStore - return a Store applying the workflow to news, backed by a file in a new temporary directory removed by the returned function
+func newTestStore(t *testing.T) (*Store, string, func()) {
+	dir, err := ioutil.TempDir("", "nxfs-workflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storePath := filepath.Join(dir, "data", "workflow.json")
+	return NewStore(storePath, []string{"news"}), storePath, func() { os.RemoveAll(dir) }
+}
+
+// reach - bring a draft page to the received state through the actions of the users and of nxfs, approving the draft with hash
+func reach(t *testing.T, store *Store, pagePath string, state model.WorkflowState) {
+	t.Helper()
+	var err error
+	switch state {
+	case model.WorkflowInReview:
+		_, err = store.Transition(pagePath, model.WorkflowSubmit, "alice", "", "")
+	case model.WorkflowApproved:
+		reach(t, store, pagePath, model.WorkflowInReview)
+		_, err = store.Transition(pagePath, model.WorkflowApprove, "rita", "", "hash")
+	case model.WorkflowPublished:
+		reach(t, store, pagePath, model.WorkflowApproved)
+		err = store.Published(pagePath, "alice")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// code - return the code of an nxfs error, empty if there's no error
+func code(err error) string {
+	var nxfsErr *nxfserrors.Error
+	if errors.As(err, &nxfsErr) {
+		return nxfsErr.Code
+	} else if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestTransitions(t *testing.T) {
+	cases := []struct {
+		from     model.WorkflowState
+		action   model.WorkflowAction
+		expected string
+	}{
+		{model.WorkflowDraft, model.WorkflowSubmit, "in_review"},
+		{model.WorkflowDraft, model.WorkflowApprove, "invalid_transition"},
+		{model.WorkflowDraft, model.WorkflowReject, "invalid_transition"},
+		{model.WorkflowInReview, model.WorkflowSubmit, "invalid_transition"},
+		{model.WorkflowInReview, model.WorkflowApprove, "approved"},
+		{model.WorkflowInReview, model.WorkflowReject, "draft"},
+		{model.WorkflowApproved, model.WorkflowSubmit, "invalid_transition"},
+		{model.WorkflowApproved, model.WorkflowApprove, "invalid_transition"},
+		{model.WorkflowApproved, model.WorkflowReject, "draft"},
+		{model.WorkflowPublished, model.WorkflowSubmit, "invalid_transition"},
+		{model.WorkflowPublished, model.WorkflowApprove, "invalid_transition"},
+		{model.WorkflowPublished, model.WorkflowReject, "invalid_transition"},
+		// the actions applied by nxfs itself can't be requested
+		{model.WorkflowApproved, model.WorkflowPublish, "invalid_action"},
+		{model.WorkflowPublished, model.WorkflowEdit, "invalid_action"},
+		{model.WorkflowPublished, model.WorkflowUnpublish, "invalid_action"},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.from)+" "+string(c.action), func(t *testing.T) {
+			store, _, cleanup := newTestStore(t)
+			defer cleanup()
+			reach(t, store, "news/item.page", c.from)
+
+			page, err := store.Transition("news/item.page", c.action, "rita", "needs work", "hash")
+			if actual := code(err); "" != actual {
+				if actual != c.expected {
+					t.Fatalf("expected %s, got %v", c.expected, err)
+				}
+				if unchanged, _ := store.Get("news/item.page"); unchanged.State != c.from {
+					t.Fatalf("expected the refused action to leave the page %s, got %s", c.from, unchanged.State)
+				}
+				return
+			}
+			if string(page.State) != c.expected {
+				t.Fatalf("expected the page to be %s, got %s", c.expected, page.State)
+			}
+			last := page.History[len(page.History)-1]
+			if last.Action != c.action || last.From != c.from || last.To != page.State || last.User != "rita" || last.Comment != "needs work" {
+				t.Fatalf("unexpected history event %+v", last)
+			}
+		})
+	}
+
+	store, _, cleanup := newTestStore(t)
+	defer cleanup()
+	reach(t, store, "news/item.page", model.WorkflowInReview)
+	if _, err := store.Transition("news/item.page", model.WorkflowReject, "rita", " ", ""); code(err) != "comment_required" {
+		t.Fatalf("expected a rejection without comment to be refused, got %v", err)
+	}
+}
+
+func TestCheckPublishable(t *testing.T) {
+	store, _, cleanup := newTestStore(t)
+	defer cleanup()
+
+	// the pages outside of the workflow paths are always publishable
+	if err := store.CheckPublishable("home.page", "any"); err != nil {
+		t.Fatalf("expected the page outside of the workflow to be publishable, got %v", err)
+	}
+	for _, state := range []model.WorkflowState{model.WorkflowDraft, model.WorkflowInReview} {
+		reach(t, store, "news/"+string(state)+".page", state)
+		if err := store.CheckPublishable("news/"+string(state)+".page", "hash"); !errors.Is(err, nxfserrors.ErrConflict) || code(err) != "not_approved" {
+			t.Fatalf("expected the %s page not to be publishable, got %v", state, err)
+		}
+	}
+
+	reach(t, store, "news/item.page", model.WorkflowApproved)
+	if err := store.CheckPublishable("news/item.page", "hash"); err != nil {
+		t.Fatalf("expected the approved draft to be publishable, got %v", err)
+	}
+	err := store.CheckPublishable("news/item.page", "edited")
+	if code(err) != "not_approved" || !strings.Contains(err.Error(), "changed after its approval") {
+		t.Fatalf("expected the draft changed after its approval not to be publishable, got %v", err)
+	}
+}
+
+func TestAppliedActionsPersistence(t *testing.T) {
+	store, storePath, cleanup := newTestStore(t)
+	defer cleanup()
+
+	reach(t, store, "news/published.page", model.WorkflowPublished)
+	reach(t, store, "news/edited.page", model.WorkflowPublished)
+	reach(t, store, "news/unpublished.page", model.WorkflowPublished)
+	reach(t, store, "news/in_review.page", model.WorkflowInReview)
+	for _, err := range []error{
+		store.Edited("news/edited.page", "bob"),
+		store.Unpublished("news/unpublished.page", "bob"),
+		// the actions applied by nxfs are ignored in the other states and outside of the workflow
+		store.Published("news/in_review.page", "bob"),
+		store.Unpublished("news/in_review.page", "bob"),
+		store.Edited("home.page", "bob"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded := NewStore(storePath, []string{"news"})
+	pages, err := reloaded.List([]string{"news/edited.page", "news/in_review.page", "news/published.page", "news/unpublished.page", "home.page"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, page := range pages {
+		last := model.WorkflowAction("")
+		if len(page.History) > 0 {
+			last = page.History[len(page.History)-1].Action
+		}
+		states = append(states, page.Path+" "+string(page.State)+" "+string(last))
+	}
+	if joined := strings.Join(states, ","); joined != "news/edited.page draft edit,news/in_review.page in_review submit,"+
+		"news/published.page published publish,news/unpublished.page draft unpublish,home.page draft " {
+		t.Fatalf("unexpected reloaded states %s", joined)
+	}
+	if published, _ := reloaded.Get("news/published.page"); published.ApprovedHash != "hash" || published.UpdatedBy != "alice" || published.UpdatedAt == nil {
+		t.Fatalf("expected the published page to keep its approved hash, got %+v", published)
+	}
+	if filtered, _ := reloaded.List([]string{"news/edited.page", "news/published.page"}, model.WorkflowPublished); len(filtered) != 1 {
+		t.Fatalf("expected one published page, got %v", filtered)
+	}
+
+	// a restored page drops the events recorded after it was read
+	before, _ := reloaded.Get("news/in_review.page")
+	if _, err = reloaded.Transition("news/in_review.page", model.WorkflowApprove, "rita", "", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err = reloaded.Restore(before); err != nil {
+		t.Fatal(err)
+	}
+	if restored, _ := NewStore(storePath, []string{"news"}).Get("news/in_review.page"); restored.State != model.WorkflowInReview || len(restored.History) != 1 {
+		t.Fatalf("expected the restored page to be in review again, got %+v", restored)
+	}
+}
+
+func TestCorruptedStore(t *testing.T) {
+	store, storePath, cleanup := newTestStore(t)
+	defer cleanup()
+
+	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(storePath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("news/item.page"); code(err) != "workflow_store_error" {
+		t.Fatalf("expected the corrupted file to be reported, got %v", err)
+	}
+}
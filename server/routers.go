@@ -10,16 +10,33 @@
 package nxsiteman
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfscompress"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
 	"github.com/gorilla/mux"
+	"io"
 	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const problemJSONContentType = "application/problem+json"
+const problemTypePrefix = "urn:entando:nxfs:problem:"
+
+// defaultCacheControl - the api responses need authentication, the clients can only cache them for themselves and must
+// revalidate them before every use
+const defaultCacheControl = "private, no-cache"
+
 // A Route defines the parameters for an api endpoint
 type Route struct {
 	Name        string
@@ -38,12 +55,20 @@ type Router interface {
 
 // NewRouter creates a new router for any number of api routers
 func NewRouter(routers ...Router) *mux.Router {
-	router := mux.NewRouter().StrictSlash(true)
+	router := mux.NewRouter().StrictSlash(true).UseEncodedPath()
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrNotFound, "route_not_found", "No api matches the requested path"), w, r)
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		EncodeErrorResponse(nxfserrors.New(nxfserrors.ErrMethodNotAllowed, "method_not_allowed", "The requested method is not supported by this api"), w, r)
+	})
 	for _, api := range routers {
 		for _, route := range api.Routes() {
 			var handler http.Handler
 			handler = route.HandlerFunc
+			handler = nxfscompress.Handler(handler, helper.GetCompressionLevel(), helper.GetCompressionMinSize())
 			handler = helper.Logger(handler, route.Name)
+			handler = helper.WithRequestInfo(handler)
 
 			router.
 				Methods(route.Method).
@@ -68,6 +93,120 @@ func EncodeJSONResponse(i interface{}, status *int, w http.ResponseWriter) error
 	return json.NewEncoder(w).Encode(i)
 }
 
+// EncodeResponse writes a service result to the http response. streamed bodies are written as they are, error results
+// are written as application/problem+json when the client accepts it, as a Result otherwise
+func EncodeResponse(result net.NxfsResponse, w http.ResponseWriter, r *http.Request) error {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Cache-Control", defaultCacheControl)
+	}
+	if stream, ok := result.Body.(*net.NxfsStream); ok {
+		w.Header().Set("Content-Type", stream.ContentType)
+		if "" != stream.FileName {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": stream.FileName}))
+		}
+		w.WriteHeader(result.Code)
+		return stream.Write(w)
+	}
+	if content, ok := result.Body.(*net.NxfsContent); ok {
+		if closer, ok := content.Content.(io.Closer); ok {
+			defer closer.Close()
+		}
+		// the content is uploaded by the users, it's served from the api origin without letting it run scripts there
+		w.Header().Set("Content-Type", content.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		disposition := "inline"
+		if content.Attachment {
+			disposition = "attachment"
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": content.FileName}))
+		w.Header().Set("Cache-Control", content.CacheControl)
+		if "" != content.ETag {
+			w.Header().Set("ETag", `"`+content.ETag+`"`)
+		}
+		http.ServeContent(w, r, "", content.ModTime, content.Content)
+		return nil
+	}
+	if cacheable, ok := result.Body.(*net.NxfsCacheable); ok {
+		return encodeCacheableResponse(cacheable, result.Code, w, r)
+	}
+
+	if errorResult, ok := result.Body.(*model.Result); ok && result.Code >= http.StatusBadRequest && acceptsProblemJSON(r) {
+		problem := model.Problem{
+			Type:     problemTypePrefix + errorResult.Code,
+			Title:    http.StatusText(result.Code),
+			Status:   result.Code,
+			Detail:   errorResult.Message,
+			Instance: r.URL.Path,
+			Code:     errorResult.Code,
+			Details:  errorResult.Details,
+		}
+		w.Header().Set("Content-Type", problemJSONContentType)
+		w.WriteHeader(result.Code)
+		return json.NewEncoder(w).Encode(problem)
+	}
+
+	return EncodeJSONResponse(result.Body, &result.Code, w)
+}
+
+// encodeCacheableResponse writes a JSON body with its validators, or only the validators when the copy of the client is
+// still fresh
+func encodeCacheableResponse(cacheable *net.NxfsCacheable, status int, w http.ResponseWriter, r *http.Request) error {
+	var encoded bytes.Buffer
+	if err := json.NewEncoder(&encoded).Encode(cacheable.Body); err != nil {
+		return err
+	}
+	etag := `"` + nxfsfiles.HashContent(encoded.Bytes()) + `"`
+
+	if "" != cacheable.CacheControl {
+		w.Header().Set("Cache-Control", cacheable.CacheControl)
+	}
+	w.Header().Set("ETag", etag)
+	if !cacheable.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", cacheable.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, cacheable.ModTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_, err := w.Write(encoded.Bytes())
+	return err
+}
+
+// notModified returns true if the request is conditional and the copy of the client matches the received validators.
+// If-None-Match takes precedence over If-Modified-Since, whose precision is the second
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); "" != ifNoneMatch {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			// the weak comparison, the compressed bodies have weak etags
+			if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); "*" == tag || etag == tag {
+				return true
+			}
+		}
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.IsZero() && !modTime.Truncate(time.Second).After(ifModifiedSince)
+}
+
+// EncodeErrorResponse converts an error to an error result and writes it to the http response
+func EncodeErrorResponse(err error, w http.ResponseWriter, r *http.Request) error {
+	return EncodeResponse(*helper.ErrorResponse(err), w, r)
+}
+
+// acceptsProblemJSON returns true if the client declared to accept application/problem+json responses
+func acceptsProblemJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if strings.TrimSpace(strings.Split(accepted, ";")[0]) == problemJSONContentType {
+			return true
+		}
+	}
+	return false
+}
+
 // ReadFormFileToTempFile reads file data from a request form and writes it to a temporary file
 func ReadFormFileToTempFile(r *http.Request, key string) (*os.File, error) {
 	_, fileHeader, err := r.FormFile(key)
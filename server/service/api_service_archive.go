@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsarchive"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"github.com/entando/entando-nxfs/server/nxfsquota"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ApiNxfsExportEncodedPathGet - Exports an object and everything under it as an archive
+func (s *DefaultApiService) ApiNxfsExportEncodedPathGet(ctx context.Context, encodedPath string, format string) (net.NxfsResponse, error) {
+
+	if err := s.checkLocalStorage("exports"); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	archiveFormat, err := nxfsarchive.ParseFormat(format)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	relPath, errResponse := archivePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+
+	release, err := s.locks.Lock(ctx, nxfslock.ReadRequest(relPath))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	manifest, err := nxfsarchive.Manifest(s.config.Root, relPath, helper.GetRequestInfo(ctx).User)
+	if err != nil {
+		release()
+		return *helper.ErrorResponse(err), nil
+	}
+
+	fileName := "nxfs"
+	if "." != relPath {
+		fileName = path.Base(relPath)
+	}
+	return helper.SuccessResponse(http.StatusOK, &net.NxfsStream{
+		ContentType: archiveFormat.ContentType(),
+		FileName:    fileName + "." + string(archiveFormat),
+		Write: func(w io.Writer) error {
+			// the exported objects stay locked until the whole archive has been streamed
+			defer release()
+			if err := nxfsarchive.Export(w, s.config.Root, manifest, archiveFormat); err != nil {
+				log.Printf("Export of %s failed: %s", relPath, err.Error())
+				return err
+			}
+			return nil
+		},
+	}), nil
+}
+
+// ApiNxfsImportEncodedPathPost - Imports an archive into a directory
+func (s *DefaultApiService) ApiNxfsImportEncodedPathPost(ctx context.Context, encodedPath string, policy string, dryRun bool, archive io.Reader) (net.NxfsResponse, error) {
+
+	if err := s.checkLocalStorage("imports"); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	requestInfo := helper.GetRequestInfo(ctx)
+	if !requestInfo.IsAdmin() {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrPermission, "admin_required", "Only administrators can import archives")), nil
+	}
+
+	importPolicy := model.ImportPolicy(policy)
+	switch importPolicy {
+	case "":
+		importPolicy = model.ImportFail
+	case model.ImportOverwrite, model.ImportSkip, model.ImportFail:
+	default:
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_policy", "The policy must be overwrite, skip or fail")), nil
+	}
+	relPath, errResponse := archivePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+
+	// the archive is spooled to a temporary file: it's read twice and zip archives need random access
+	spooled, err := s.spoolArchive(archive)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer os.Remove(spooled.Name())
+	defer spooled.Close()
+
+	locks := []nxfslock.Request{nxfslock.WriteRequest(relPath)}
+	if dryRun {
+		locks = []nxfslock.Request{nxfslock.ReadRequest(relPath)}
+	}
+	release, err := s.locks.Lock(ctx, locks...)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+	if !dryRun {
+		if err = s.checkClientLocks(ctx, locks); err != nil {
+			return *helper.ErrorResponse(err), nil
+		}
+	}
+
+	// the scans of the files are recorded once they're written
+	var scan func(relPath string, content io.Reader) error
+	scanned := map[string]model.ScanResult{}
+	if s.scanner != nil {
+		scan = func(relPath string, content io.Reader) error {
+			result, err := s.scanner.Check(relPath, content, requestInfo.User)
+			scanned[relPath] = result
+			return err
+		}
+	}
+	written := map[string]model.ScanResult{}
+	var unscanned []string
+
+	reserved := false
+	report, err := nxfsarchive.Import(spooled, s.config.Root, relPath, importPolicy, dryRun, func(entries []model.ImportEntry) error {
+		// the types and the quotas are checked before writing anything, a dry run reports whether they would be refused
+		var files []nxfsmime.File
+		var writes []nxfsquota.Write
+		for _, entry := range entries {
+			if entry.Type == model.F && (entry.Action == model.EntryCreated || entry.Action == model.EntryOverwritten) {
+				files = append(files, nxfsmime.File{Path: path.Join(relPath, entry.Path), MimeType: entry.MimeType})
+				writes = append(writes, nxfsquota.Write{Path: path.Join(relPath, entry.Path), Size: entry.Size})
+			}
+		}
+		if err := s.types.Check(files...); err != nil {
+			return err
+		}
+		undo, err := s.quota.Reserve(writes...)
+		if err == nil && dryRun {
+			undo()
+		}
+		reserved = err == nil && !dryRun
+		return err
+	}, scan, func(writtenPath string, beforeHash string, afterHash string) {
+		if result, ok := scanned[writtenPath]; ok {
+			written[writtenPath] = result
+		} else {
+			unscanned = append(unscanned, writtenPath)
+		}
+		s.invalidateRenditions(writtenPath)
+		s.appendAudit(ctx, nxfsaudit.OpImport, writtenPath, beforeHash, afterHash, helper.SuccessResponse(http.StatusCreated, nil))
+		// an imported draft must be reviewed again
+		if nxfspages.IsDraftPage(writtenPath) {
+			s.recordWorkflow(s.workflow.Edited(pagePathOf(writtenPath, helper.GetDraftPagesRelativePath()), requestInfo.User))
+		}
+	})
+	if !dryRun {
+		// an import failing midway keeps the files written before the failure, they're committed as well
+		s.recordScans(written, unscanned...)
+		s.commit(ctx, nxfsaudit.OpImport+" "+relPath, locks)
+	}
+	if err != nil {
+		if reserved {
+			// which files have been written before the failure is unknown, they're counted again
+			s.quota.Invalidate()
+		}
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, report), nil
+}
+
+// archivePath - return the path, relative to the browsable fs root, of the object identified by the received encoded path,
+// unable to point outside of the browsable fs. the root itself is "."
+func archivePath(encodedPath string) (string, *net.NxfsResponse) {
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return "", errResponse
+	}
+	if relPath := path.Clean("/" + filepath.ToSlash(decodedPath))[1:]; "" != relPath {
+		return relPath, nil
+	}
+	return ".", nil
+}
+
+// spoolArchive - copy the received archive to a temporary file in the nxfs data directory, returning it open
+func (s *DefaultApiService) spoolArchive(archive io.Reader) (*os.File, error) {
+	if err := os.MkdirAll(s.config.DataDir, 0755); err != nil {
+		return nil, nxfserrors.FromOS(err, "import_spool_error", "An error occurred during the reception of the archive")
+	}
+
+	spooled, err := os.OpenFile(nxfsfiles.TempPathFor(filepath.Join(s.config.DataDir, "import")), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, nxfserrors.FromOS(err, "import_spool_error", "An error occurred during the reception of the archive")
+	}
+	if _, err = io.Copy(spooled, archive); err != nil {
+		spooled.Close()
+		os.Remove(spooled.Name())
+		if errors.Is(err, nxfserrors.ErrTooLarge) {
+			return nil, err
+		}
+		return nil, nxfserrors.FromOS(err, "import_spool_error", "An error occurred during the reception of the archive")
+	}
+	return spooled, nil
+}
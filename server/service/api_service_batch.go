@@ -0,0 +1,433 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// dirState - the state of a directory, see objectState
+const dirState = "/"
+
+// batchSnapshot - an object changed by an operation of a batch, with what's needed to restore it
+type batchSnapshot struct {
+	relPath string
+	// before, after - the states of the object before and after the operation, see objectState
+	before  string
+	after   string
+	content []byte
+	scan    *model.ScanResult
+	// workflow, workflowAfter - the workflow states, before and after the operation, of the page whose draft or published
+	// copy is the object, nil if it's not a page subject to the workflow
+	workflow      *model.PageWorkflow
+	workflowAfter *model.PageWorkflow
+}
+
+// batchStep - an operation of a batch that has been executed, with the snapshots of the objects it changed
+type batchStep struct {
+	index      int
+	operation  model.BatchOperation
+	snapshots  []batchSnapshot
+	scheduleId string
+}
+
+// ApiNxfsBatchPost - Executes a list of operations in order, all or nothing or each one on its own
+func (s *DefaultApiService) ApiNxfsBatchPost(ctx context.Context, batchRequest model.BatchRequest) (net.NxfsResponse, error) {
+
+	mode := batchRequest.Mode
+	if "" == mode {
+		mode = model.BatchAtomic
+	}
+	if mode != model.BatchAtomic && mode != model.BatchBestEffort {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_batch", fmt.Sprintf("The mode %q is not supported, it must be atomic or best-effort", mode))), nil
+	}
+	if len(batchRequest.Operations) == 0 {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_batch", "The batch doesn't contain any operation")), nil
+	}
+	// nothing is executed if any operation is malformed
+	for i, operation := range batchRequest.Operations {
+		if message := checkBatchOperation(operation); "" != message {
+			err := nxfserrors.New(nxfserrors.ErrInvalid, "invalid_batch", fmt.Sprintf("The operation %d is not valid: %s", i, message))
+			err.Details = []model.ResultDetail{{Field: operationField(i), Message: message}}
+			return *helper.ErrorResponse(err), nil
+		}
+	}
+
+	// an atomic batch keeps the paths of all its operations locked until it's done or rolled back, so no other request
+	// sees it half applied or changes what it would restore
+	if mode == model.BatchAtomic {
+		var locks []nxfslock.Request
+		for _, operation := range batchRequest.Operations {
+			locks = append(locks, batchLocks(operation)...)
+		}
+		scoped, release, err := s.locks.Scope(ctx, locks...)
+		if err != nil {
+			return *helper.ErrorResponse(err), nil
+		}
+		defer release()
+		ctx = scoped
+	}
+
+	result := model.BatchResult{Mode: mode, List: make([]model.BatchOperationResult, 0, len(batchRequest.Operations))}
+	var executed []batchStep
+	for i, operation := range batchRequest.Operations {
+		step, response, failures := s.executeBatchStep(ctx, i, operation)
+		operationResult := model.BatchOperationResult{Op: operation.Op, Path: operation.Path, Status: response.Code}
+
+		if errorResult, ok := response.Body.(*model.Result); ok && response.Code >= http.StatusBadRequest {
+			if mode == model.BatchAtomic {
+				for j := len(executed) - 1; j >= 0; j-- {
+					failures = append(failures, s.undoBatchStep(ctx, executed[j])...)
+				}
+				return batchFailure(i, operation, response.Code, errorResult, failures), nil
+			}
+			if len(failures) > 0 {
+				errorResult.Details = append(errorResult.Details, failures...)
+			}
+			operationResult.Error = errorResult
+		} else {
+			operationResult.Result = response.Body
+			executed = append(executed, step)
+		}
+		result.List = append(result.List, operationResult)
+	}
+
+	return helper.SuccessResponse(http.StatusOK, result), nil
+}
+
+// checkBatchOperation - return why the received operation of a batch is malformed, an empty string if it's well formed
+func checkBatchOperation(operation model.BatchOperation) string {
+	if "" == nxfsstorage.CleanPath(operation.Path) {
+		return "the path is missing"
+	}
+	switch operation.Op {
+	case model.BatchPut:
+		if operation.Object == nil {
+			return "a put needs the object to write"
+		}
+	case model.BatchMove, model.BatchCopy:
+		if "" == nxfsstorage.CleanPath(operation.To) {
+			return fmt.Sprintf("a %s needs the destination path", operation.Op)
+		}
+	case model.BatchDelete, model.BatchPublish, model.BatchUnpublish:
+	default:
+		return fmt.Sprintf("the operation %q is not supported", operation.Op)
+	}
+	if "" != operation.At && operation.Op != model.BatchPublish && operation.Op != model.BatchUnpublish {
+		return "only a publish or an unpublish can be scheduled"
+	}
+	return ""
+}
+
+// executeBatchStep - execute an operation of a batch through the same path of the single requests, once the objects
+// it changes have been saved to restore them. its paths stay locked until a failed operation is undone, like a single
+// request it leaves nothing behind; the details of what couldn't be restored are returned
+func (s *DefaultApiService) executeBatchStep(ctx context.Context, index int, operation model.BatchOperation) (batchStep, net.NxfsResponse, []model.ResultDetail) {
+	step := batchStep{index: index, operation: operation}
+	ctx, release, err := s.locks.Scope(ctx, batchLocks(operation)...)
+	if err != nil {
+		return step, *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	var snapshots []batchSnapshot
+	for _, relPath := range s.batchPaths(operation) {
+		snapshot, err := s.takeSnapshot(relPath)
+		if err != nil {
+			return step, *helper.ErrorResponse(err), nil
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	step.snapshots = snapshots
+
+	encodedPath := url.PathEscape(nxfsstorage.CleanPath(operation.Path))
+	var response net.NxfsResponse
+	switch operation.Op {
+	case model.BatchPut:
+		response, err = s.ApiNxfsObjectsEncodedPathPut(ctx, encodedPath, *operation.Object)
+	case model.BatchDelete:
+		response, err = s.ApiNxfsObjectsEncodedPathDelete(ctx, encodedPath)
+	case model.BatchCopy:
+		response = s.copyObject(ctx, operation.Path, operation.To)
+	case model.BatchMove:
+		if response = s.copyObject(ctx, operation.Path, operation.To); response.Code < http.StatusBadRequest {
+			if deleted, _ := s.ApiNxfsObjectsEncodedPathDelete(ctx, encodedPath); deleted.Code >= http.StatusBadRequest {
+				response = deleted
+			}
+		}
+	case model.BatchPublish:
+		response, err = s.ApiNxfsObjectsEncodedPathPublishPost(ctx, encodedPath, operation.At, operation.WithAssets)
+	case model.BatchUnpublish:
+		response, err = s.ApiNxfsObjectsEncodedPathUnpublishPost(ctx, encodedPath, operation.At)
+	}
+	if err != nil {
+		response = *helper.ErrorResponse(err)
+	}
+
+	if schedule, ok := response.Body.(model.Schedule); ok {
+		step.scheduleId = schedule.Id
+	}
+	for i := range step.snapshots {
+		step.snapshots[i].after = s.objectState(step.snapshots[i].relPath)
+		if step.snapshots[i].workflow != nil {
+			if page, err := s.workflow.Get(step.snapshots[i].workflow.Path); err == nil {
+				step.snapshots[i].workflowAfter = &page
+			}
+		}
+	}
+
+	if response.Code >= http.StatusBadRequest {
+		return step, response, s.undoBatchStep(ctx, step)
+	}
+	return step, response, nil
+}
+
+// copyObject - copy a file to another path, writing it like a put
+func (s *DefaultApiService) copyObject(ctx context.Context, from string, to string) net.NxfsResponse {
+	fromPath, toPath := nxfsstorage.CleanPath(from), nxfsstorage.CleanPath(to)
+	if fromPath == toPath {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_batch", "The destination must be another path"))
+	}
+
+	fileInfo, err := s.storage.Stat(fromPath)
+	if err != nil {
+		return *helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
+	}
+	if fileInfo.IsDir() {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "dir_requested", "The received path corresponds to a directory, only files can be moved or copied"))
+	}
+	content, _, err := s.storage.ReadFile(fromPath)
+	if err != nil {
+		return *helper.ErrorResponse(nxfserrors.FromOS(err, "err_reading_content", "An error occurred during the reading of the file content"))
+	}
+
+	response, err := s.ApiNxfsObjectsEncodedPathPut(ctx, url.PathEscape(toPath), model.FileObject{Name: path.Base(toPath), Type: model.F, Content: string(content)})
+	if err != nil {
+		return *helper.ErrorResponse(err)
+	}
+	return response
+}
+
+// batchLocks - return the locks an operation of a batch acquires, the same of the single requests
+func batchLocks(operation model.BatchOperation) []nxfslock.Request {
+	switch operation.Op {
+	case model.BatchPut:
+		return []nxfslock.Request{nxfslock.WriteRequest(nxfsstorage.CleanPath(operation.Path))}
+	case model.BatchDelete:
+		return deleteLocks(nxfsstorage.CleanPath(operation.Path))
+	case model.BatchCopy:
+		return []nxfslock.Request{nxfslock.ReadRequest(nxfsstorage.CleanPath(operation.Path)), nxfslock.WriteRequest(nxfsstorage.CleanPath(operation.To))}
+	case model.BatchMove:
+		return append(deleteLocks(nxfsstorage.CleanPath(operation.Path)), nxfslock.WriteRequest(nxfsstorage.CleanPath(operation.To)))
+	}
+	if "" != operation.At {
+		return nil
+	}
+
+	draftRelPath, publishedRelPath := pagePaths(url.PathEscape(operation.Path))
+	if operation.Op == model.BatchUnpublish {
+		return []nxfslock.Request{nxfslock.WriteRequest(publishedRelPath)}
+	} else if operation.WithAssets {
+		return []nxfslock.Request{nxfslock.ReadRequest(helper.GetDraftPagesRelativePath()), nxfslock.WriteRequest(helper.GetPublishedPagesRelativePath())}
+	}
+	return []nxfslock.Request{nxfslock.ReadRequest(draftRelPath), nxfslock.WriteRequest(publishedRelPath)}
+}
+
+// batchPaths - return the objects, relative to the browsable fs root, an operation of a batch can change. the scheduled
+// operations change nothing until they're due
+func (s *DefaultApiService) batchPaths(operation model.BatchOperation) []string {
+	switch operation.Op {
+	case model.BatchPut, model.BatchDelete:
+		return []string{nxfsstorage.CleanPath(operation.Path)}
+	case model.BatchCopy:
+		return []string{nxfsstorage.CleanPath(operation.To)}
+	case model.BatchMove:
+		return []string{nxfsstorage.CleanPath(operation.Path), nxfsstorage.CleanPath(operation.To)}
+	}
+	if "" != operation.At {
+		return nil
+	}
+
+	draftRelPath, publishedRelPath := pagePaths(url.PathEscape(operation.Path))
+	paths := []string{publishedRelPath}
+	if operation.Op == model.BatchPublish && operation.WithAssets {
+		if content, _, err := s.storage.ReadFile(draftRelPath); err == nil {
+			for _, asset := range nxfspages.PublishableAssets(s.storage, nxfspages.References(content)) {
+				paths = append(paths, publishedPageRelPath(asset))
+			}
+		}
+	}
+	return paths
+}
+
+// takeSnapshot - save what's needed to restore an object to its current state
+func (s *DefaultApiService) takeSnapshot(relPath string) (batchSnapshot, error) {
+	snapshot := batchSnapshot{relPath: relPath, before: s.objectState(relPath)}
+	if "" != snapshot.before && dirState != snapshot.before {
+		var err error
+		if snapshot.content, _, err = s.storage.ReadFile(relPath); err != nil {
+			return snapshot, nxfserrors.FromOS(err, "err_reading_content", "An error occurred during the reading of the file content")
+		}
+		snapshot.scan = s.scanResult(relPath)
+	}
+	if pagePath := s.workflowPageOf(relPath); "" != pagePath {
+		page, err := s.workflow.Get(pagePath)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.workflow = &page
+	}
+	return snapshot, nil
+}
+
+// workflowPageOf - return the page, relative to the pages folders, whose workflow state is changed with the received
+// draft or published page, empty if the path is not a page subject to the workflow
+func (s *DefaultApiService) workflowPageOf(relPath string) string {
+	for _, pagesFolder := range []string{helper.GetDraftPagesRelativePath(), helper.GetPublishedPagesRelativePath()} {
+		pagePath := pagePathOf(relPath, pagesFolder)
+		if nxfspages.IsPage(pagePath) && ".." != pagePath && !strings.HasPrefix(pagePath, "../") && s.workflow.Enabled(pagePath) {
+			return pagePath
+		}
+	}
+	return ""
+}
+
+// changed - return true if the operation changed the object or the workflow state of its page
+func (snapshot batchSnapshot) changed() bool {
+	return snapshot.before != snapshot.after || !reflect.DeepEqual(snapshot.workflow, snapshot.workflowAfter)
+}
+
+// objectState - identify the state of an object: empty if it's missing, dirState for a directory, the hash of a file
+func (s *DefaultApiService) objectState(relPath string) string {
+	fileInfo, err := s.storage.Stat(relPath)
+	if err != nil {
+		return ""
+	}
+	if fileInfo.IsDir() {
+		return dirState
+	}
+	return nxfsstorage.Hash(s.storage, relPath)
+}
+
+// undoBatchStep - restore the objects changed by an operation of a batch and cancel its schedule, returning the details
+// of what couldn't be undone
+func (s *DefaultApiService) undoBatchStep(ctx context.Context, step batchStep) []model.ResultDetail {
+	var failures []model.ResultDetail
+	if "" != step.scheduleId {
+		if _, err := s.schedules.Remove(step.scheduleId); err != nil {
+			failures = append(failures, model.ResultDetail{Field: operationField(step.index), Message: "the schedule can't be cancelled: " + err.Error()})
+		} else {
+			log.Printf("Scheduled %s of %s cancelled by the rollback of a batch", step.operation.Op, step.operation.Path)
+		}
+	}
+	for i := len(step.snapshots) - 1; i >= 0; i-- {
+		if snapshot := step.snapshots[i]; snapshot.changed() {
+			if failure := s.restoreSnapshot(ctx, snapshot); failure != nil {
+				failures = append(failures, model.ResultDetail{Field: operationField(step.index), Message: fmt.Sprintf("%s can't be restored: %s", snapshot.relPath, failure.Message)})
+			}
+		}
+	}
+	return failures
+}
+
+// restoreSnapshot - bring an object and the workflow state of its page back to their state before an operation of a
+// batch, unless they've been changed by another request meanwhile. the restored objects aren't checked like new ones,
+// they were there before
+func (s *DefaultApiService) restoreSnapshot(ctx context.Context, snapshot batchSnapshot) *model.Result {
+	relPath := snapshot.relPath
+	response := s.mutate(ctx, nxfsaudit.OpRestore, relPath, []nxfslock.Request{nxfslock.WriteRequest(relPath)}, func() net.NxfsResponse {
+		if s.objectState(relPath) != snapshot.after || !s.sameWorkflow(snapshot.workflow, snapshot.workflowAfter) {
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrConflict, "rollback_conflict", "The object has been changed by another request"))
+		}
+
+		if snapshot.before != snapshot.after {
+			if errorResponse := s.restoreObject(snapshot); errorResponse != nil {
+				return *errorResponse
+			}
+		}
+		if !reflect.DeepEqual(snapshot.workflow, snapshot.workflowAfter) {
+			if err := s.workflow.Restore(*snapshot.workflow); err != nil {
+				return *helper.ErrorResponse(err)
+			}
+		}
+		return helper.SuccessResponse(http.StatusOK, nil)
+	})
+
+	if result, ok := response.Body.(*model.Result); ok && response.Code >= http.StatusBadRequest {
+		return result
+	}
+	return nil
+}
+
+// restoreObject - write back the object of a snapshot as it was before the operation. it must be called holding a
+// write lock on the path
+func (s *DefaultApiService) restoreObject(snapshot batchSnapshot) *net.NxfsResponse {
+	relPath := snapshot.relPath
+	var err error
+	switch snapshot.before {
+	case "":
+		err = s.storage.Remove(relPath)
+	case dirState:
+		err = s.storage.Mkdir(relPath)
+	default:
+		err = s.storage.WriteFile(relPath, bytes.NewReader(snapshot.content))
+	}
+	if err != nil {
+		return helper.ErrorResponse(nxfserrors.FromOS(err, "rollback_error", "An error occurred during the restoration of the object"))
+	}
+
+	s.quota.Refresh(relPath)
+	s.invalidateRenditions(relPath)
+	if snapshot.scan != nil {
+		s.recordScans(map[string]model.ScanResult{relPath: *snapshot.scan})
+	} else {
+		s.recordScans(nil, relPath)
+	}
+	return nil
+}
+
+// sameWorkflow - return true if the workflow state of a page is still the received one, always if the page is not
+// subject to the workflow
+func (s *DefaultApiService) sameWorkflow(page *model.PageWorkflow, expected *model.PageWorkflow) bool {
+	if page == nil {
+		return true
+	}
+	current, err := s.workflow.Get(page.Path)
+	return err == nil && reflect.DeepEqual(&current, expected)
+}
+
+// batchFailure - return the response of an atomic batch rolled back because of the failure of one of its operations,
+// with the status and the code of the failure. an incomplete rollback is a conflict to solve by hand
+func batchFailure(index int, operation model.BatchOperation, status int, failure *model.Result, rollbackFailures []model.ResultDetail) net.NxfsResponse {
+	details := append([]model.ResultDetail{{Field: operationField(index), Message: failure.Code + ": " + failure.Message}}, failure.Details...)
+	if len(rollbackFailures) > 0 {
+		err := nxfserrors.New(nxfserrors.ErrConflict, "rollback_failed", fmt.Sprintf("The operation %d (%s %s) failed and some of the executed operations can't be undone", index, operation.Op, operation.Path))
+		err.Details = append(details, rollbackFailures...)
+		return *helper.ErrorResponse(err)
+	}
+
+	return net.NxfsResponse{Code: status, Body: &model.Result{
+		Code:    failure.Code,
+		Message: fmt.Sprintf("The operation %d (%s %s) failed, the batch has been rolled back: %s", index, operation.Op, operation.Path, failure.Message),
+		Details: details,
+	}}
+}
+
+// operationField - return the field identifying an operation of a batch in the details of an error
+func operationField(index int) string {
+	return fmt.Sprintf("operations[%d]", index)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"net/http"
+	"path/filepath"
+)
+
+// ApiNxfsDiffEncodedPathGet - Compares a draft page with its published copy
+func (s *DefaultApiService) ApiNxfsDiffEncodedPathGet(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+	pagePath, _ := cleanPagePath(decodedPath)
+
+	draftRelPath, publishedRelPath := pagePaths(encodedPath)
+	release, err := s.locks.Lock(ctx, nxfslock.ReadRequest(draftRelPath), nxfslock.ReadRequest(publishedRelPath))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	pageDiff, err := nxfspages.DiffPage(s.storage, pagePath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, pageDiff), nil
+}
+
+// ApiNxfsDiffGet - Lists the pages whose draft differs from the published copy
+func (s *DefaultApiService) ApiNxfsDiffGet(ctx context.Context, dir string) (net.NxfsResponse, error) {
+
+	dir, _ = cleanPagePath(dir)
+	release, err := s.locks.Lock(ctx,
+		nxfslock.ReadRequest(filepath.Join(helper.GetDraftPagesRelativePath(), dir)),
+		nxfslock.ReadRequest(filepath.Join(helper.GetPublishedPagesRelativePath(), dir)))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	pageDiffs, err := nxfspages.DiffPages(s.storage, dir)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.PageDiffList{List: pageDiffs}), nil
+}
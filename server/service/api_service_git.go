@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"net/http"
+)
+
+// ApiNxfsHistoryEncodedPathGet - Lists the commits changing an object
+func (s *DefaultApiService) ApiNxfsHistoryEncodedPathGet(ctx context.Context, encodedPath string, limit int32) (net.NxfsResponse, error) {
+
+	if err := s.checkGitEnabled(); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	relPath, errResponse := archivePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+
+	commits, err := s.git.History(relPath, int(limit))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.CommitList{List: commits}), nil
+}
+
+// ApiNxfsCommitsIdGet - Gets a commit with its diff
+func (s *DefaultApiService) ApiNxfsCommitsIdGet(ctx context.Context, id string, encodedPath string) (net.NxfsResponse, error) {
+
+	if err := s.checkGitEnabled(); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	relPath, errResponse := archivePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+
+	commit, err := s.git.Get(id, relPath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, commit), nil
+}
+
+// ApiNxfsCommitsIdRevertPost - Commits the changes undoing a commit
+func (s *DefaultApiService) ApiNxfsCommitsIdRevertPost(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	if err := s.checkGitAdmin(ctx); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	paths, err := s.git.Paths(id)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	locks := make([]nxfslock.Request, 0, len(paths))
+	for _, relPath := range paths {
+		locks = append(locks, nxfslock.WriteRequest(relPath))
+	}
+
+	var commit model.Commit
+	response := s.syncWorkingTree(ctx, nxfsaudit.OpRevert, locks, func(prepare func(changed []string)) (err error) {
+		prepare(paths)
+		commit, err = s.git.Revert(id, helper.GetRequestInfo(ctx).User)
+		return err
+	})
+	if response != nil {
+		return *response, nil
+	}
+
+	return helper.SuccessResponse(http.StatusCreated, commit), nil
+}
+
+// ApiNxfsGitPushPost - Pushes the committed changes to a branch of the git remote
+func (s *DefaultApiService) ApiNxfsGitPushPost(ctx context.Context, branch string) (net.NxfsResponse, error) {
+
+	if err := s.checkGitAdmin(ctx); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	result, err := s.git.Push(branch)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, result), nil
+}
+
+// ApiNxfsGitPullPost - Fast forwards the browsable fs to the branch of the git remote
+func (s *DefaultApiService) ApiNxfsGitPullPost(ctx context.Context) (net.NxfsResponse, error) {
+
+	if err := s.checkGitAdmin(ctx); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	// the changed paths are known only once the branch is fetched, so the whole browsable fs is locked
+	var result model.GitSyncResult
+	response := s.syncWorkingTree(ctx, nxfsaudit.OpPull, []nxfslock.Request{nxfslock.WriteRequest("")}, func(prepare func(changed []string)) (err error) {
+		result, err = s.git.Pull(prepare)
+		return err
+	})
+	if response != nil {
+		return *response, nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, result), nil
+}
+
+// syncWorkingTree - hold the received locks while git updates the working tree, then append an audit record for each
+// changed path and send the changed drafts back to review. update calls prepare with the paths it's about to change
+func (s *DefaultApiService) syncWorkingTree(ctx context.Context, operation string, locks []nxfslock.Request, update func(prepare func(changed []string)) error) *net.NxfsResponse {
+
+	release, err := s.locks.Lock(ctx, locks...)
+	if err != nil {
+		return helper.ErrorResponse(err)
+	}
+	if err = s.checkClientLocks(ctx, locks); err != nil {
+		release()
+		return helper.ErrorResponse(err)
+	}
+
+	beforeHashes := map[string]string{}
+	var changed []string
+	err = update(func(paths []string) {
+		changed = paths
+		for _, relPath := range paths {
+			beforeHashes[relPath] = nxfsfiles.HashFile(s.config.FullPath(relPath))
+		}
+	})
+	afterHashes := map[string]string{}
+	for _, relPath := range changed {
+		afterHashes[relPath] = nxfsfiles.HashFile(s.config.FullPath(relPath))
+	}
+	s.quota.Refresh(changed...)
+	s.invalidateRenditions(changed...)
+	release()
+	if err != nil {
+		return helper.ErrorResponse(err)
+	}
+
+	user := helper.GetRequestInfo(ctx).User
+	for _, relPath := range changed {
+		s.appendAudit(ctx, operation, relPath, beforeHashes[relPath], afterHashes[relPath], helper.SuccessResponse(http.StatusOK, nil))
+		// a draft changed by git must be reviewed again
+		if nxfspages.IsDraftPage(relPath) && "" != afterHashes[relPath] {
+			s.recordWorkflow(s.workflow.Edited(pagePathOf(relPath, helper.GetDraftPagesRelativePath()), user))
+		}
+	}
+	return nil
+}
+
+// checkGitEnabled - return an error if the git storage is disabled
+func (s *DefaultApiService) checkGitEnabled() error {
+	if s.git == nil {
+		return nxfserrors.New(nxfserrors.ErrNotFound, "git_disabled", "The git storage of the browsable fs is not enabled")
+	}
+	return nil
+}
+
+// checkGitAdmin - return an error if the git storage is disabled or the caller is not an administrator
+func (s *DefaultApiService) checkGitAdmin(ctx context.Context) error {
+	if err := s.checkGitEnabled(); err != nil {
+		return err
+	}
+	if !helper.GetRequestInfo(ctx).IsAdmin() {
+		return nxfserrors.New(nxfserrors.ErrPermission, "admin_required", "Only administrators can change the browsable fs through git")
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"github.com/entando/entando-nxfs/server/nxfsquota"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+)
+
+// mutate - lock the received paths, check that no client holds a lock on them, execute the mutation, commit it to the git storage and append its outcome to the audit log, hashing the file identified by relPath before and after it
+func (s *DefaultApiService) mutate(ctx context.Context, operation string, relPath string, locks []nxfslock.Request, mutation func() net.NxfsResponse) net.NxfsResponse {
+
+	var response net.NxfsResponse
+	var beforeHash, afterHash string
+
+	if release, err := s.locks.Lock(ctx, locks...); err != nil {
+		response = *helper.ErrorResponse(err)
+	} else if err = s.checkClientLocks(ctx, locks); err != nil {
+		release()
+		response = *helper.ErrorResponse(err)
+	} else {
+		beforeHash = nxfsstorage.Hash(s.storage, relPath)
+		response = mutation()
+		afterHash = nxfsstorage.Hash(s.storage, relPath)
+		if response.Code < http.StatusBadRequest {
+			s.commit(ctx, operation+" "+filepath.ToSlash(relPath), locks)
+		}
+		release()
+	}
+
+	s.appendAudit(ctx, operation, relPath, beforeHash, afterHash, response)
+	return response
+}
+
+// appendAudit - append to the audit log a record of the operation executed on relPath, whose outcome is derived from the response
+func (s *DefaultApiService) appendAudit(ctx context.Context, operation string, relPath string, beforeHash string, afterHash string, response net.NxfsResponse) {
+	outcome := nxfsaudit.OutcomeSuccess
+	if result, ok := response.Body.(*model.Result); ok && response.Code >= http.StatusBadRequest {
+		outcome = result.Code
+	}
+
+	if _, err := s.audit.Append(ctx, operation, relPath, beforeHash, afterHash, outcome, response.Code); err != nil {
+		log.Printf("Audit log append failed for %s %s: %s", operation, relPath, err.Error())
+	}
+}
+
+// commit - commit to the git storage, if enabled, the paths write locked by an operation, authored by the caller.
+// it must be called holding the locks; a failed commit is logged, the operation has been executed anyway
+func (s *DefaultApiService) commit(ctx context.Context, message string, locks []nxfslock.Request) {
+	if s.git == nil {
+		return
+	}
+
+	var paths []string
+	for _, lock := range locks {
+		if lock.Mode == nxfslock.Write {
+			paths = append(paths, lock.Path)
+		}
+	}
+	if _, err := s.git.Commit(paths, helper.GetRequestInfo(ctx).User, message); err != nil {
+		log.Printf("Git commit of %q failed: %s", message, err.Error())
+	}
+}
+
+// writeFile - write a file of the received size to a path relative to the browsable fs root, once its type, its content
+// and the quotas are checked, returning the result of its scan, nil if scanning is disabled. it must be called holding a
+// write lock on the path
+func (s *DefaultApiService) writeFile(ctx context.Context, relPath string, content io.ReadSeeker, size int64) (*model.ScanResult, error) {
+	// draft pages must be valid page documents
+	if nxfspages.IsDraftPage(relPath) {
+		page, err := ioutil.ReadAll(content)
+		if err != nil {
+			return nil, nxfserrors.Wrap(err, "read_error")
+		}
+		if err = nxfspages.ValidatePage(page); err != nil {
+			return nil, err
+		}
+	}
+
+	// the types, the content and the quotas are checked before writing anything
+	var sniffer nxfsmime.Sniffer
+	if err := rewind(content); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(&sniffer, io.LimitReader(content, nxfsmime.SniffLength)); err != nil {
+		return nil, nxfserrors.Wrap(err, "read_error")
+	}
+	if err := s.types.Check(nxfsmime.File{Path: relPath, MimeType: sniffer.Detect(relPath)}); err != nil {
+		return nil, err
+	}
+	if err := rewind(content); err != nil {
+		return nil, err
+	}
+	scan, err := s.scan(ctx, relPath, content)
+	if err != nil {
+		return nil, err
+	}
+	if err = rewind(content); err != nil {
+		return nil, err
+	}
+	undo, err := s.quota.Reserve(nxfsquota.Write{Path: relPath, Size: size})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.storage.WriteFile(relPath, content); err != nil {
+		undo()
+		return nil, nxfserrors.FromOS(err, "write_error", "An error occurred during the write of the file")
+	}
+
+	if scan != nil {
+		s.recordScans(map[string]model.ScanResult{relPath: *scan})
+	} else {
+		s.recordScans(nil, relPath)
+	}
+	s.invalidateRenditions(relPath)
+	// a modified draft must be reviewed again
+	if nxfspages.IsDraftPage(relPath) {
+		s.recordWorkflow(s.workflow.Edited(pagePathOf(relPath, helper.GetDraftPagesRelativePath()), helper.GetRequestInfo(ctx).User))
+	}
+	return scan, nil
+}
+
+// rewind - seek back to the beginning of a content, to read it again
+func rewind(content io.Seeker) error {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nxfserrors.Wrap(err, "read_error")
+	}
+	return nil
+}
+
+// checkClientLocks - return an error if any of the paths to lock, or any object under them, is locked by a client other
+// than the caller. the paths locked only to be scanned aren't checked
+func (s *DefaultApiService) checkClientLocks(ctx context.Context, locks []nxfslock.Request) error {
+	lockPaths := make([]string, 0, len(locks))
+	for _, lock := range locks {
+		if !lock.Scan {
+			lockPaths = append(lockPaths, lock.Path)
+		}
+	}
+	return s.clientLocks.CheckOwner(helper.GetRequestInfo(ctx).User, lockPaths...)
+}
+
+// objectPath - return the path, relative to the browsable fs root, corresponding to the received encoded path
+func objectPath(encodedPath string) string {
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return encodedPath
+	}
+	return filepath.Clean(decodedPath)
+}
+
+// pagePaths - return the paths, relative to the browsable fs root, of the draft and of the published page corresponding to the received encoded path
+func pagePaths(encodedPath string) (draftRelPath string, publishedRelPath string) {
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return encodedPath, encodedPath
+	}
+	// cleaned as the pages manager does, so the locked and audited paths are the written ones
+	suffixedPage := nxfsstorage.CleanPath(nxfspages.AddPageSuffix(decodedPath))
+	draftRelPath = path.Join(helper.GetDraftPagesRelativePath(), suffixedPage)
+	publishedRelPath = path.Join(helper.GetPublishedPagesRelativePath(), suffixedPage)
+	return draftRelPath, publishedRelPath
+}
+
+// publishedPageRelPath - return the path, relative to the browsable fs root, of the received page path relative to the pages folders
+func publishedPageRelPath(pagePath string) string {
+	return path.Join(helper.GetPublishedPagesRelativePath(), pagePath)
+}
+
+// pagePathOf - return the path of a page relative to the pages folder, given its path relative to the browsable fs root
+func pagePathOf(relPath string, pagesFolder string) string {
+	pagePath, _ := filepath.Rel(pagesFolder, relPath)
+	return filepath.ToSlash(pagePath)
+}
@@ -16,150 +16,521 @@ import (
 	"github.com/entando/entando-nxfs/server/helper"
 	"github.com/entando/entando-nxfs/server/model"
 	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfsblob"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
 	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfsgit"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
 	"github.com/entando/entando-nxfs/server/nxfspages"
-	"io/ioutil"
+	"github.com/entando/entando-nxfs/server/nxfsquota"
+	"github.com/entando/entando-nxfs/server/nxfsrelease"
+	"github.com/entando/entando-nxfs/server/nxfsrender"
+	"github.com/entando/entando-nxfs/server/nxfsscan"
+	"github.com/entando/entando-nxfs/server/nxfsschedule"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"github.com/entando/entando-nxfs/server/nxfsupload"
+	"github.com/entando/entando-nxfs/server/nxfsworkflow"
+	"log"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // DefaultApiService is a service that implents the logic for the DefaultApiServicer
 // This service should implement the business logic for every endpoint for the DefaultApi API.
 // Include any external packages or services that will be required by this service.
 type DefaultApiService struct {
+	audit       *nxfsaudit.Log
+	locks       *nxfslock.Manager
+	clientLocks *nxfslock.ClientLockRegistry
+	schedules   *nxfsschedule.Store
+	releases    *nxfsrelease.Store
+	workflow    *nxfsworkflow.Store
+	blobs       *nxfsblob.Store
+	// storage - the backend storing the objects of the browsable fs
+	storage nxfsstorage.Backend
+	// git - the repository committing every change of the browsable fs, nil if the git storage is disabled
+	git *nxfsgit.Repo
+	// config - where the browsable fs and the data of the service are
+	config    helper.FsConfig
+	scheduler *nxfsschedule.Scheduler
+	// quota - the usage of the browsable fs, checked against its quotas before every write
+	quota *nxfsquota.Tracker
+	// types - the types of files accepted by the folders, checked before every write
+	types nxfsmime.Policy
+	// scanner - scans the uploaded files before writing them, nil if scanning is disabled
+	scanner *nxfsscan.Guard
+	// scans - the results of the scans of the files
+	scans *nxfsscan.Store
+	// renditions - the cached renditions of the images
+	renditions *nxfsrender.Cache
+	// renditionMaxAge - for how long the clients can cache the raw files and their renditions
+	renditionMaxAge time.Duration
+	// uploads - the resumable uploads not finalized yet
+	uploads *nxfsupload.Store
+	cleaner *nxfsupload.Cleaner
 }
 
 // NewDefaultApiService creates a default api service
 func NewDefaultApiService() controller.DefaultApiServicer {
-	return &DefaultApiService{}
+	s, err := NewFsApiService(helper.GetDefaultFsConfig())
+	if err != nil {
+		log.Fatalf("Can't serve the browsable fs: %s", err.Error())
+	}
+	return s
+}
+
+// NewFsApiService - create an api service serving the browsable fs of the received configuration, like the one of a tenant.
+// return an error if the configuration can't be served, e.g. if its storage or its git repository can't be opened
+func NewFsApiService(config helper.FsConfig) (*DefaultApiService, error) {
+	blobs := nxfsblob.NewStore(config.BlobsPath())
+	storage, err := newStorage(config, blobs)
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := newScanGuard(config)
+	if err != nil {
+		return nil, err
+	}
+	s := &DefaultApiService{
+		audit:       nxfsaudit.NewLog(config.AuditLogPath()),
+		locks:       nxfslock.NewManager(helper.GetLockWaitTimeout()),
+		clientLocks: nxfslock.NewClientLockRegistry(),
+		schedules:   nxfsschedule.NewStore(config.SchedulesPath()),
+		releases:    nxfsrelease.NewStore(config.ReleasesPath(), config.PublishedPagesPath(), blobs),
+		workflow:    nxfsworkflow.NewStore(config.WorkflowPath(), config.WorkflowPaths),
+		blobs:       blobs,
+		storage:     storage,
+		config:      config,
+		scanner:     scanner,
+		scans:       nxfsscan.NewStore(config.ScansPath()),
+		renditions:  nxfsrender.NewCache(config.RenditionsPath()),
+		uploads:     nxfsupload.NewStore(config.UploadsPath(), helper.GetUploadExpiration()),
+	}
+	s.quota = nxfsquota.NewTracker(s.storage, config.MaxFileSize, config.Quotas)
+	s.types = nxfsmime.NewPolicy(config.TypeRules)
+	s.renditionMaxAge = helper.GetRenditionMaxAge()
+
+	if helper.IsGitEnabled() {
+		if !nxfsstorage.IsLocal(s.storage) {
+			return nil, fmt.Errorf("the git storage needs the browsable fs in a local directory, it can't be enabled with the %s storage", helper.GetStorage())
+		}
+		repo, err := nxfsgit.Open(config.GitPath(), config.Root, helper.GetGitRemote(), helper.GetGitBranch())
+		if err != nil {
+			return nil, fmt.Errorf("can't open the git storage: %s", err.Error())
+		}
+		s.git = repo
+	}
+
+	if recovered, err := s.releases.Recover(); err != nil {
+		log.Printf("Recovery of the interrupted releases failed: %s", err.Error())
+	} else if recovered > 0 {
+		log.Printf("Rolled back %d interrupted releases", recovered)
+	}
+	s.scheduler = nxfsschedule.NewScheduler(s.schedules, s.executeSchedule, nxfsschedule.DefaultPollInterval)
+	s.scheduler.Start()
+	s.cleaner = nxfsupload.NewCleaner(s.uploads, nxfsupload.DefaultCleanupInterval)
+	s.cleaner.Start()
+	return s, nil
+}
+
+// Close - stop executing the pending schedules and removing the expired uploads, the service must not be used anymore
+func (s *DefaultApiService) Close() {
+	s.scheduler.Stop()
+	s.cleaner.Stop()
 }
 
-// ApiNxfsBrowseEncodedPathGet - Gets the list of objects in a directory
-func (s *DefaultApiService) ApiNxfsBrowseEncodedPathGet(ctx context.Context, encodedPath string, maxdepth int32) (net.NxfsResponse, error) {
+// newStorage - create the backend of the configured storage, failing if it's misconfigured
+func newStorage(config helper.FsConfig, blobs *nxfsblob.Store) (nxfsstorage.Backend, error) {
+	switch helper.GetStorage() {
+	case helper.StorageMemory:
+		// an ephemeral preview of the content of the browsable fs directory, if any
+		storage := nxfsstorage.NewMemory()
+		if err := storage.Load(config.Root); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("can't load the browsable fs in memory: %s", err.Error())
+		}
+		log.Printf("Keeping the browsable fs in memory, its changes will be lost at exit")
+		return storage, nil
+	case helper.StorageLocal:
+		return nxfsstorage.NewLocal(config.Root, blobs), nil
+	}
+
+	accessKey, secretKey := helper.GetS3Credentials()
+	storage, err := nxfsstorage.NewS3(nxfsstorage.S3Config{
+		Endpoint:  helper.GetS3Endpoint(),
+		Region:    helper.GetS3Region(),
+		Bucket:    helper.GetS3Bucket(),
+		Prefix:    config.S3Prefix,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't configure the S3 storage: %s", err.Error())
+	}
+	log.Printf("Storing the browsable fs in the bucket %s of %s", helper.GetS3Bucket(), helper.GetS3Endpoint())
+	return storage, nil
+}
 
-	return composeFullPathOrErrorAndExecuteApiNxfsFunction(ctx, encodedPath, func(pathToBrowse string, fileInfoToBrowse os.FileInfo) (net.NxfsResponse, error) {
+// contentType - return the type of a stored file from its extension or, if it's unknown, from its content
+func (s *DefaultApiService) contentType(relPath string) string {
+	if mimeType := nxfsmime.FromExtension(relPath); "" != mimeType {
+		return mimeType
+	}
+	content, _, err := s.storage.ReadFile(relPath)
+	if err != nil {
+		return nxfsmime.Default
+	}
+	return nxfsmime.Detect(relPath, content)
+}
+
+// checkLocalStorage - return a storage_unsupported error if the browsable fs is not in a local directory, as the received feature needs
+func (s *DefaultApiService) checkLocalStorage(feature string) error {
+	if !nxfsstorage.IsLocal(s.storage) {
+		return nxfserrors.New(nxfserrors.ErrNotImplemented, "storage_unsupported", fmt.Sprintf("The %s are not supported by the %s storage", feature, helper.GetStorage()))
+	}
+	return nil
+}
+
+// ApiNxfsBrowseEncodedPathGet - Gets the list of objects in a directory, only the files of the received types if any
+func (s *DefaultApiService) ApiNxfsBrowseEncodedPathGet(ctx context.Context, encodedPath string, maxdepth int32, mimeTypes []string) (net.NxfsResponse, error) {
+
+	return s.statAndExecuteApiNxfsFunction(ctx, encodedPath, func(relPath string, fileInfoToBrowse os.FileInfo) (net.NxfsResponse, error) {
 		// recursive function
-		dirObjectArray, err := nxfsfiles.BrowseFileTree(pathToBrowse, fileInfoToBrowse, 0, maxdepth, []model.DirectoryObject{})
+		dirObjectArray, err := nxfsstorage.Browse(s.storage, relPath, maxdepth)
 		if err != nil {
-			return *helper.ErrorResponse(http.StatusInternalServerError, "dir_listing_err", err.Error()), nil
+			return *helper.ErrorResponse(nxfserrors.FromOS(err, "dir_listing_err", "An error occurred during the directory listing")), nil
+		}
+
+		// the listing changes when the directory or one of its entries change
+		lastModified := fileInfoToBrowse.ModTime()
+		for _, object := range dirObjectArray {
+			if object.Updated.At.After(lastModified) {
+				lastModified = object.Updated.At
+			}
+		}
+
+		// the directories have no type, a filter on the types only keeps files
+		filtered := dirObjectArray[:0]
+		for _, object := range dirObjectArray {
+			objectPath := path.Join(object.Path, object.Name)
+			if object.Type == model.F {
+				object.MimeType = s.contentType(objectPath)
+				object.Scan = s.scanResult(objectPath)
+			}
+			if len(mimeTypes) == 0 || (object.Type == model.F && nxfsmime.MatchesAny(objectPath, object.MimeType, mimeTypes)) {
+				filtered = append(filtered, object)
+			}
 		}
 
-		return helper.SuccessResponse(http.StatusOK, model.FlatDirectoryTree{List: dirObjectArray}), nil
+		return helper.SuccessResponse(http.StatusOK, &net.NxfsCacheable{Body: model.FlatDirectoryTree{List: filtered}, ModTime: lastModified}), nil
 	})
 }
 
 // ApiNxfsObjectsEncodedPathDelete - Deletes an object
 func (s *DefaultApiService) ApiNxfsObjectsEncodedPathDelete(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
 
-	pathToDelete, fileToDelete, errorResponse := nxfsfiles.ComposeFullPathOrErrorResponse(encodedPath)
-	if errorResponse != nil {
-		if errorResponse.Code == http.StatusNotFound {
-			return helper.SuccessResponse(http.StatusNoContent, nil), nil
-		} else {
-			return *errorResponse, nil
+	relPath := objectPath(encodedPath)
+
+	return s.mutate(ctx, nxfsaudit.OpDelete, relPath, deleteLocks(relPath), func() net.NxfsResponse {
+		fileToDelete, errorResponse := s.statObject(encodedPath)
+		if errorResponse != nil {
+			if errorResponse.Code == http.StatusNotFound {
+				return helper.SuccessResponse(http.StatusNoContent, nil)
+			} else {
+				return *errorResponse
+			}
 		}
-	}
-	absPathFile := path.Join(pathToDelete, fileToDelete.Name())
 
-	if nxfsfiles.IsDirWithChildren(absPathFile, fileToDelete) {
-		return *helper.ErrorResponse(http.StatusUnprocessableEntity, "dir_not_empty", "The folder to delete is not empty"), nil
-	}
+		if fileToDelete.IsDir() {
+			if children, _ := s.storage.ReadDir(relPath); len(children) > 0 {
+				return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrNotEmpty, "dir_not_empty", "The folder to delete is not empty"))
+			}
+		}
 
-	if errorResponse = nxfsfiles.DeleteFile(absPathFile); errorResponse != nil {
-		return *errorResponse, nil
-	}
+		if err := s.checkNotReferenced(relPath); err != nil {
+			return *helper.ErrorResponse(err)
+		}
 
-	return helper.SuccessResponse(http.StatusNoContent, nil), nil
+		if err := s.storage.Remove(relPath); err != nil {
+			return *helper.ErrorResponse(nxfserrors.FromOS(err, "deletion_error", "An error occurred during the deletion"))
+		}
+		s.quota.Removed(relPath)
+		s.recordScans(nil, relPath)
+		s.invalidateRenditions(relPath)
+
+		return helper.SuccessResponse(http.StatusNoContent, nil)
+	}), nil
 }
 
 // ApiNxfsObjectsEncodedPathGet - Gets an object
 func (s *DefaultApiService) ApiNxfsObjectsEncodedPathGet(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
 
-	return composeFullPathOrErrorAndExecuteApiNxfsFunction(ctx, encodedPath, func(pathToBrowse string, requestedFile os.FileInfo) (net.NxfsResponse, error) {
+	return s.statAndExecuteApiNxfsFunction(ctx, encodedPath, func(relPath string, requestedFile os.FileInfo) (net.NxfsResponse, error) {
 		// if dir return error
 		if requestedFile.IsDir() {
-			return *helper.ErrorResponse(http.StatusBadRequest, "dir_requested", "The received encoded path "+
-				"corresponds to a directory. This endpoint returns files content, to browse a directory please use the browse one"), nil
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "dir_requested", "The received encoded path "+
+				"corresponds to a directory. This endpoint returns files content, to browse a directory please use the browse one")), nil
 		}
 
 		// return file content
-		fileContent, err := ioutil.ReadFile(path.Join(pathToBrowse, requestedFile.Name()))
+		fileContent, _, err := s.storage.ReadFile(relPath)
 		if err != nil {
-			return *helper.ErrorResponse(http.StatusBadRequest, "err_reading_content",
-				fmt.Sprintf("An error occurred during the reading of the file content: %q", err.Error())), nil
+			return *helper.ErrorResponse(nxfserrors.FromOS(err, "err_reading_content",
+				"An error occurred during the reading of the file content")), nil
 		}
 
 		// Convert []byte to string and print to screen
 		fileContentString := string(fileContent)
 
-		return helper.SuccessResponse(http.StatusOK, helper.ToFileObject(pathToBrowse, requestedFile, fileContentString)), nil
+		fileObject := helper.ToFileObject(filepath.Dir(relPath), requestedFile, fileContentString)
+		fileObject.MimeType = nxfsmime.Detect(relPath, fileContent)
+		fileObject.Scan = s.scanResult(relPath)
+		return helper.SuccessResponse(http.StatusOK, &net.NxfsCacheable{Body: fileObject, ModTime: requestedFile.ModTime()}), nil
 	})
 }
 
 // ApiNxfsObjectsEncodedPathPut - Creates or updates an object
 func (s *DefaultApiService) ApiNxfsObjectsEncodedPathPut(ctx context.Context, encodedPath string, fileObject model.FileObject) (net.NxfsResponse, error) {
 
-	// dir can't have content
-	if fileObject.Type == model.D && "" != fileObject.Content {
-		return *helper.ErrorResponse(http.StatusBadRequest, "full_content_for_dir", "A creation dir request can't contain a file content value"), nil
-	} else if fileObject.Type == model.F && "" == fileObject.Content {
-		// file must have content
-		return *helper.ErrorResponse(http.StatusBadRequest, "empty_content", "A file with empty content can't be saved"), nil
+	relPath := objectPath(encodedPath)
+
+	return s.mutate(ctx, nxfsaudit.OpPut, relPath, []nxfslock.Request{nxfslock.WriteRequest(relPath)}, func() net.NxfsResponse {
+		// dir can't have content
+		if fileObject.Type == model.D && "" != fileObject.Content {
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "full_content_for_dir", "A creation dir request can't contain a file content value"))
+		} else if fileObject.Type == model.F && "" == fileObject.Content {
+			// file must have content
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "empty_content", "A file with empty content can't be saved"))
+		}
+
+		var scan *model.ScanResult
+		if fileObject.Type == model.D {
+			// an existing object is left as it is
+			if _, err := s.storage.Stat(relPath); os.IsNotExist(err) {
+				if err = s.storage.Mkdir(relPath); err != nil {
+					return *helper.ErrorResponse(nxfserrors.FromOS(err, "dir_write_error", "An error occurred during the creation of the directory"))
+				}
+			}
+		} else {
+			var err error
+			if scan, err = s.writeFile(ctx, relPath, strings.NewReader(fileObject.Content), int64(len(fileObject.Content))); err != nil {
+				return *helper.ErrorResponse(err)
+			}
+		}
+
+		savedFile, err := s.storage.Stat(relPath)
+		if err != nil {
+			return *helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
+		}
+
+		savedObject := helper.ToDirectoryObject(filepath.Dir(relPath), savedFile)
+		if fileObject.Type == model.F {
+			savedObject.MimeType = nxfsmime.Detect(relPath, []byte(fileObject.Content))
+			savedObject.Scan = scan
+		}
+		return helper.SuccessResponse(http.StatusCreated, savedObject)
+	}), nil
+}
+
+// ApiNxfsObjectsEncodedPathPublishPost - Publishes an object, immediately or at the received time
+func (s *DefaultApiService) ApiNxfsObjectsEncodedPathPublishPost(ctx context.Context, encodedPath string, at string, withAssets bool) (net.NxfsResponse, error) {
+
+	if "" != at {
+		return s.schedule(ctx, model.SchedulePublish, encodedPath, at, withAssets), nil
 	}
+	return s.publish(ctx, encodedPath, withAssets), nil
+}
+
+// ApiNxfsObjectsEncodedPathUnpublishPost - Unpublishes an object, immediately or at the received time
+func (s *DefaultApiService) ApiNxfsObjectsEncodedPathUnpublishPost(ctx context.Context, encodedPath string, at string) (net.NxfsResponse, error) {
 
-	decodedPath, decodeErrResp := nxfsfiles.DecodePath(encodedPath)
-	if decodeErrResp != nil {
-		return *decodeErrResp, nil
+	if "" != at {
+		return s.schedule(ctx, model.ScheduleUnpublish, encodedPath, at, false), nil
 	}
+	return s.unpublish(ctx, encodedPath), nil
+}
 
-	fullPathToSave := filepath.Join(helper.GetBrowsableFsRootPath(), decodedPath)
+// ApiNxfsSchedulesGet - Lists the pending and the failed schedules
+func (s *DefaultApiService) ApiNxfsSchedulesGet(ctx context.Context) (net.NxfsResponse, error) {
 
-	var creationErrResp *net.NxfsResponse
-	if fileObject.Type == model.D {
-		creationErrResp = nxfsfiles.CreateDirectory(fullPathToSave)
-	} else {
-		creationErrResp = nxfsfiles.CreateFile(fullPathToSave, fileObject)
+	schedules, err := s.schedules.List()
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
 	}
 
-	if creationErrResp != nil {
-		return *creationErrResp, nil
-	} else {
-		return helper.SuccessResponse(http.StatusCreated, helper.ToDirectoryObjectFromFilePath(fullPathToSave)), nil
+	return helper.SuccessResponse(http.StatusOK, model.ScheduleList{List: schedules}), nil
+}
+
+// ApiNxfsSchedulesIdDelete - Cancels a pending schedule or dismisses a failed one
+func (s *DefaultApiService) ApiNxfsSchedulesIdDelete(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	schedule, err := s.schedules.Remove(id)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
 	}
+
+	log.Printf("Scheduled %s of %s by %s cancelled by %s", schedule.Operation, schedule.Path, schedule.CreatedBy, helper.GetRequestInfo(ctx).User)
+	return helper.SuccessResponse(http.StatusNoContent, nil), nil
 }
 
-// ApiNxfsObjectsEncodedPathPublishPost - Publishes an object
-func (s *DefaultApiService) ApiNxfsObjectsEncodedPathPublishPost(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+// ApiNxfsAuditGet - Queries the audit log
+func (s *DefaultApiService) ApiNxfsAuditGet(ctx context.Context, auditPath string, user string, from string, to string) (net.NxfsResponse, error) {
 
-	if errorResponse := nxfspages.PublishPage(encodedPath); errorResponse != nil {
-		return *errorResponse, nil
-	} else {
-		return helper.SuccessResponse(http.StatusOK, nil), nil
+	filter := nxfsaudit.Filter{Path: auditPath, User: user}
+
+	var err error
+	if filter.From, err = parseOptionalTime(from); err != nil {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_from", fmt.Sprintf("The from parameter must be an RFC 3339 timestamp: %q", err.Error()))), nil
 	}
+	if filter.To, err = parseOptionalTime(to); err != nil {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_to", fmt.Sprintf("The to parameter must be an RFC 3339 timestamp: %q", err.Error()))), nil
+	}
+
+	records, err := s.audit.Query(filter)
+	if err != nil {
+		return *helper.ErrorResponse(nxfserrors.FromOS(err, "audit_read_error", "An error occurred during the reading of the audit log")), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.AuditRecordList{List: records}), nil
 }
 
-// ApiNxfsObjectsEncodedPathUnpublishPost - Publishes an object
-func (s *DefaultApiService) ApiNxfsObjectsEncodedPathUnpublishPost(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+// ApiNxfsUsageGet - Gets the space used by the browsable fs and by the folders having a quota
+func (s *DefaultApiService) ApiNxfsUsageGet(ctx context.Context) (net.NxfsResponse, error) {
 
-	if errorResponse := nxfspages.UnpublishPage(encodedPath); errorResponse != nil {
-		return *errorResponse, nil
-	} else {
-		return helper.SuccessResponse(http.StatusOK, nil), nil
+	usage, err := s.quota.Usage()
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.QuotaUsageList{List: usage}), nil
+}
+
+// ApiNxfsObjectsEncodedPathLockGet - Gets the client lock held on an object
+func (s *DefaultApiService) ApiNxfsObjectsEncodedPathLockGet(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+
+	relPath := objectPath(encodedPath)
+	lock, err := s.clientLocks.Get(relPath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
 	}
+
+	return helper.SuccessResponse(http.StatusOK, lock), nil
 }
 
-// DecodedPathAndExecuteApiNxfsFunction - decode the received encodedPath and execute the fnWithDecodedPath function passing it the result of the decoding
-func composeFullPathOrErrorAndExecuteApiNxfsFunction(ctx context.Context, encodedPath string, fnWithDecodedPath apiNxfsFunctionWithComposeFullPathOrError) (net.NxfsResponse, error) {
+// ApiNxfsObjectsEncodedPathLockPost - Acquires a client lock on an object
+func (s *DefaultApiService) ApiNxfsObjectsEncodedPathLockPost(ctx context.Context, encodedPath string, lockRequest model.LockRequest) (net.NxfsResponse, error) {
+
+	relPath := objectPath(encodedPath)
+	lock, err := s.clientLocks.Acquire(relPath, helper.GetRequestInfo(ctx).User, time.Duration(lockRequest.Ttl)*time.Second)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, lock), nil
+}
 
-	pathToBrowse, fileInfoToBrowse, errorResponse := nxfsfiles.ComposeFullPathOrErrorResponse(encodedPath)
+// ApiNxfsObjectsEncodedPathLockPut - Refreshes the client lock held on an object
+func (s *DefaultApiService) ApiNxfsObjectsEncodedPathLockPut(ctx context.Context, encodedPath string, lockRequest model.LockRequest) (net.NxfsResponse, error) {
+
+	relPath := objectPath(encodedPath)
+	lock, err := s.clientLocks.Refresh(relPath, helper.GetRequestInfo(ctx).User, time.Duration(lockRequest.Ttl)*time.Second)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, lock), nil
+}
+
+// ApiNxfsObjectsEncodedPathLockDelete - Releases the client lock held on an object
+func (s *DefaultApiService) ApiNxfsObjectsEncodedPathLockDelete(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+
+	relPath := objectPath(encodedPath)
+	if err := s.clientLocks.Release(relPath, helper.GetRequestInfo(ctx).User); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusNoContent, nil), nil
+}
+
+// ApiNxfsAdminLocksEncodedPathDelete - Forcibly releases the client lock held on an object
+func (s *DefaultApiService) ApiNxfsAdminLocksEncodedPathDelete(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+
+	requestInfo := helper.GetRequestInfo(ctx)
+	if !requestInfo.IsAdmin() {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrPermission, "admin_required", "Only administrators can release the locks of other users")), nil
+	}
+
+	relPath := objectPath(encodedPath)
+	lock, err := s.clientLocks.ForceRelease(relPath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	log.Printf("Lock held by %s on %s forcibly released by %s", lock.Owner, lock.Path, requestInfo.User)
+	return helper.SuccessResponse(http.StatusOK, lock), nil
+}
+
+// ApiNxfsSchemasPageVersionGet - Gets the JSON schema of a page document version
+func (s *DefaultApiService) ApiNxfsSchemasPageVersionGet(ctx context.Context, version string) (net.NxfsResponse, error) {
+
+	schemaVersion, err := strconv.Atoi(version)
+	if err != nil {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_schema_version", "The schema version must be an integer")), nil
+	}
+
+	schema, ok := nxfspages.GetPageSchema(schemaVersion)
+	if !ok {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrNotFound, "schema_not_found", fmt.Sprintf("No page schema has version %d", schemaVersion))), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, schema), nil
+}
+
+// statAndExecuteApiNxfsFunction - decode the received encodedPath and execute the fnWithStat function passing it the decoded path and the info of the object, holding a read lock on the path
+func (s *DefaultApiService) statAndExecuteApiNxfsFunction(ctx context.Context, encodedPath string, fnWithStat apiNxfsFunctionWithStat) (net.NxfsResponse, error) {
+
+	relPath := objectPath(encodedPath)
+	release, err := s.locks.Lock(ctx, nxfslock.ReadRequest(relPath))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	fileInfo, errorResponse := s.statObject(encodedPath)
 	if errorResponse != nil {
 		return *errorResponse, nil
 	}
 
-	return fnWithDecodedPath(pathToBrowse, fileInfoToBrowse)
+	return fnWithStat(nxfsstorage.CleanPath(relPath), fileInfo)
+}
+
+// statObject - decode the received encoded path and return the info of the object it identifies, or an error NxfsResponse
+func (s *DefaultApiService) statObject(encodedPath string) (os.FileInfo, *net.NxfsResponse) {
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	fileInfo, err := s.storage.Stat(decodedPath)
+	if err != nil {
+		return nil, helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
+	}
+	return fileInfo, nil
 }
 
-// apiNxfsFunctionWithcomposeFullPathOrError - a function that receives the result of a path decoding
-type apiNxfsFunctionWithComposeFullPathOrError func(pathToBrowse string, fileInfoToBrowse os.FileInfo) (net.NxfsResponse, error)
+// apiNxfsFunctionWithStat - a function that receives a decoded path, relative to the browsable fs root, and the info of the object it identifies
+type apiNxfsFunctionWithStat func(relPath string, fileInfo os.FileInfo) (net.NxfsResponse, error)
+
+// parseOptionalTime - parse an RFC 3339 timestamp, an empty string corresponds to the zero time
+func parseOptionalTime(value string) (time.Time, error) {
+	if "" == value {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
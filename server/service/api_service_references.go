@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"net/http"
+	"path/filepath"
+)
+
+// ApiNxfsReferencesEncodedPathGet - Lists the pages referencing an object
+func (s *DefaultApiService) ApiNxfsReferencesEncodedPathGet(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+
+	release, err := s.locks.Lock(ctx,
+		nxfslock.ReadRequest(helper.GetDraftPagesRelativePath()),
+		nxfslock.ReadRequest(helper.GetPublishedPagesRelativePath()))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	referrers, err := nxfspages.Referrers(s.storage, decodedPath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.PageReferenceList{List: referrers}), nil
+}
+
+// deleteLocks - return the locks of the deletion of the object identified by the received path: a write lock on it and
+// scan locks on the pages folders, so that no page starts referencing the object between checkNotReferenced and its removal
+func deleteLocks(relPath string) []nxfslock.Request {
+	return []nxfslock.Request{
+		nxfslock.WriteRequest(relPath),
+		nxfslock.ScanRequest(helper.GetDraftPagesRelativePath()),
+		nxfslock.ScanRequest(helper.GetPublishedPagesRelativePath()),
+	}
+}
+
+// checkNotReferenced - return an object_in_use error listing the pages, other than the object itself, that reference the object
+// identified by the received path relative to the browsable fs root. it must be called holding the deleteLocks of the object
+func (s *DefaultApiService) checkNotReferenced(relPath string) error {
+	referrers, err := nxfspages.Referrers(s.storage, filepath.ToSlash(relPath))
+	if err != nil {
+		return err
+	}
+
+	var details []model.ResultDetail
+	for _, referrer := range referrers {
+		if referrer.Page != filepath.ToSlash(relPath) {
+			details = append(details, model.ResultDetail{Field: referrer.Page, Message: fmt.Sprintf("referenced by %s as /%s", referrer.Field, referrer.Reference)})
+		}
+	}
+	if len(details) > 0 {
+		inUseErr := nxfserrors.New(nxfserrors.ErrConflict, "object_in_use", "The object is referenced by some pages")
+		inUseErr.Details = details
+		return inUseErr
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"github.com/entando/entando-nxfs/server/nxfsrelease"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ApiNxfsReleasesPost - Publishes a set of draft pages as a single release
+func (s *DefaultApiService) ApiNxfsReleasesPost(ctx context.Context, releaseRequest model.ReleaseRequest) (net.NxfsResponse, error) {
+
+	if err := s.checkLocalStorage("releases"); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	selected, directory, err := releaseSelection(releaseRequest)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	// the directory is locked as a whole before being listed, so that no page is added to it meanwhile
+	locks := make([]nxfslock.Request, 0, 2*len(selected)+2)
+	for _, page := range selected {
+		locks = append(locks, nxfslock.ReadRequest(path.Join(helper.GetDraftPagesRelativePath(), page)), nxfslock.WriteRequest(publishedPageRelPath(page)))
+	}
+	if directory != nil {
+		locks = append(locks, nxfslock.ReadRequest(path.Join(helper.GetDraftPagesRelativePath(), *directory)),
+			nxfslock.WriteRequest(path.Join(helper.GetPublishedPagesRelativePath(), *directory)))
+	}
+
+	return s.applyRelease(ctx, nxfsaudit.OpRelease, locks, func() ([]string, model.Release, error) {
+		pages, err := s.releasePages(selected, directory)
+		if err != nil {
+			return nil, model.Release{}, err
+		}
+		changes, err := s.readAndValidateDrafts(pages, func(pagePath string, content []byte) error {
+			return s.workflow.CheckPublishable(pagePath, nxfsfiles.HashContent(content))
+		})
+		if err != nil {
+			return pages, model.Release{}, err
+		}
+
+		// checked and reserved like the pages published one by one
+		pagePaths := make([]string, 0, len(changes))
+		for _, change := range changes {
+			pagePaths = append(pagePaths, change.Path)
+		}
+		undo, err := s.reservePublication(pagePaths)
+		if err != nil {
+			return pages, model.Release{}, err
+		}
+
+		release, err := s.releases.Apply(helper.GetRequestInfo(ctx).User, "", changes)
+		if err != nil {
+			undo()
+		} else {
+			for _, page := range release.Pages {
+				s.recordWorkflow(s.workflow.Published(page.Path, release.CreatedBy))
+				s.invalidateRenditions(publishedPageRelPath(page.Path))
+			}
+		}
+		return pages, release, err
+	}), nil
+}
+
+// ApiNxfsReleasesGet - Lists the releases
+func (s *DefaultApiService) ApiNxfsReleasesGet(ctx context.Context) (net.NxfsResponse, error) {
+
+	releases, err := s.releases.List()
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.ReleaseList{List: releases}), nil
+}
+
+// ApiNxfsReleasesIdGet - Gets a release
+func (s *DefaultApiService) ApiNxfsReleasesIdGet(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	release, err := s.releases.Get(id)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, release), nil
+}
+
+// ApiNxfsReleasesIdRollbackPost - Restores the pages of a release to their content before it
+func (s *DefaultApiService) ApiNxfsReleasesIdRollbackPost(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	if err := s.checkLocalStorage("releases"); err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	release, err := s.releases.Get(id)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	pages := make([]string, 0, len(release.Pages))
+	locks := make([]nxfslock.Request, 0, len(release.Pages))
+	for _, page := range release.Pages {
+		pages = append(pages, page.Path)
+		locks = append(locks, nxfslock.WriteRequest(publishedPageRelPath(page.Path)))
+	}
+
+	return s.applyRelease(ctx, nxfsaudit.OpRestore, locks, func() ([]string, model.Release, error) {
+		release, err := s.releases.Rollback(helper.GetRequestInfo(ctx).User, id)
+		// the rollback restores the pages as they were, even beyond the quotas
+		for _, page := range pages {
+			s.quota.Refresh(publishedPageRelPath(page))
+			s.invalidateRenditions(publishedPageRelPath(page))
+		}
+		return pages, release, err
+	}), nil
+}
+
+// applyRelease - hold the received locks while applying a release and committing it, then append an audit record for each
+// of its pages, or of the pages it failed to apply
+func (s *DefaultApiService) applyRelease(ctx context.Context, operation string, locks []nxfslock.Request, apply func() ([]string, model.Release, error)) net.NxfsResponse {
+
+	var response net.NxfsResponse
+	var pages []string
+	var release model.Release
+
+	if unlock, err := s.locks.Lock(ctx, locks...); err != nil {
+		response = *helper.ErrorResponse(err)
+	} else if err = s.checkClientLocks(ctx, locks); err != nil {
+		unlock()
+		response = *helper.ErrorResponse(err)
+	} else {
+		pages, release, err = apply()
+		if err == nil {
+			s.commit(ctx, operation+" "+release.Id, locks)
+		}
+		unlock()
+		if err != nil {
+			response = *helper.ErrorResponse(err)
+		} else {
+			response = helper.SuccessResponse(http.StatusCreated, release)
+		}
+	}
+
+	if response.Code >= http.StatusBadRequest {
+		for _, page := range pages {
+			s.appendAudit(ctx, operation, publishedPageRelPath(page), "", "", response)
+		}
+	}
+	for _, page := range release.Pages {
+		s.appendAudit(ctx, operation, publishedPageRelPath(page.Path), page.BeforeHash, page.AfterHash, response)
+	}
+	return response
+}
+
+// releaseSelection - return the sorted draft pages, relative to the draft pages folder, listed by a release request
+// and the directory whose pages it selects, nil if none
+func releaseSelection(releaseRequest model.ReleaseRequest) ([]string, *string, error) {
+
+	selected := map[string]bool{}
+	for _, page := range releaseRequest.Pages {
+		pagePath, ok := cleanPagePath(page)
+		if !ok {
+			return nil, nil, nxfserrors.New(nxfserrors.ErrInvalid, "invalid_release", fmt.Sprintf("The page path %q is not valid", page))
+		}
+		selected[nxfspages.AddPageSuffix(pagePath)] = true
+	}
+
+	var directory *string
+	if "" != releaseRequest.Directory {
+		cleaned, _ := cleanPagePath(releaseRequest.Directory)
+		directory = &cleaned
+	} else if len(selected) == 0 {
+		return nil, nil, emptyReleaseError()
+	}
+
+	pages := make([]string, 0, len(selected))
+	for page := range selected {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	return pages, directory, nil
+}
+
+// releasePages - return the sorted draft pages, relative to the draft pages folder, of a release: the selected ones and
+// the ones under the received directory, if not nil. it must be called holding the locks of the directory
+func (s *DefaultApiService) releasePages(selectedPages []string, directory *string) ([]string, error) {
+
+	selected := map[string]bool{}
+	for _, page := range selectedPages {
+		selected[page] = true
+	}
+
+	if directory != nil {
+		draftPagesPath := s.config.DraftPagesPath()
+		err := filepath.Walk(filepath.Join(draftPagesPath, filepath.FromSlash(*directory)), func(filePath string, fileInfo os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fileInfo.IsDir() && nxfspages.IsPage(fileInfo.Name()) {
+				relPath, _ := filepath.Rel(draftPagesPath, filePath)
+				selected[filepath.ToSlash(relPath)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nxfserrors.FromOS(err, "release_dir_error", fmt.Sprintf("An error occurred during the listing of the directory %s", *directory))
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, emptyReleaseError()
+	}
+
+	pages := make([]string, 0, len(selected))
+	for page := range selected {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	return pages, nil
+}
+
+// emptyReleaseError - return the error of a release without pages
+func emptyReleaseError() error {
+	return nxfserrors.New(nxfserrors.ErrInvalid, "empty_release", "The release doesn't contain any page")
+}
+
+// readAndValidateDrafts - read the received draft pages returning them as release changes, or an invalid_release error
+// listing the problems of every page. the objects referenced by every page must exist or be part of the release, and every page must pass the received check
+func (s *DefaultApiService) readAndValidateDrafts(pages []string, check nxfspages.PublishCheck) ([]nxfsrelease.Change, error) {
+
+	publishedTogether := map[string]bool{}
+	for _, page := range pages {
+		publishedTogether[path.Join(helper.GetPublishedPagesRelativePath(), page)] = true
+	}
+
+	var details []model.ResultDetail
+	changes := make([]nxfsrelease.Change, 0, len(pages))
+	for _, page := range pages {
+		content, err := ioutil.ReadFile(filepath.Join(s.config.DraftPagesPath(), filepath.FromSlash(page)))
+		if os.IsNotExist(err) {
+			details = append(details, model.ResultDetail{Field: page, Message: "draft page not found"})
+			continue
+		} else if err != nil {
+			return nil, nxfserrors.FromOS(err, "draft_read_error", fmt.Sprintf("An error occurred during the read operation of the draft page %s", page))
+		}
+
+		if err = nxfspages.ValidatePage(content); err != nil {
+			if pageErr, ok := err.(*nxfserrors.Error); ok && len(pageErr.Details) > 0 {
+				for _, detail := range pageErr.Details {
+					details = append(details, model.ResultDetail{Field: page + ":" + detail.Field, Message: detail.Message})
+				}
+			} else {
+				details = append(details, model.ResultDetail{Field: page, Message: err.Error()})
+			}
+			continue
+		}
+		if missing := nxfspages.MissingReferences(s.storage, nxfspages.References(content), publishedTogether); len(missing) > 0 {
+			for _, detail := range missing {
+				details = append(details, model.ResultDetail{Field: page + ":" + detail.Field, Message: detail.Message})
+			}
+			continue
+		}
+		if err = check(page, content); err != nil {
+			details = append(details, model.ResultDetail{Field: page, Message: nxfserrors.Wrap(err, "").Message})
+			continue
+		}
+
+		changes = append(changes, nxfsrelease.Change{Path: page, Content: content})
+	}
+
+	if len(details) > 0 {
+		releaseErr := nxfserrors.New(nxfserrors.ErrUnprocessable, "invalid_release", "Some pages of the release are not valid, nothing has been published")
+		releaseErr.Details = details
+		return nil, releaseErr
+	}
+	return changes, nil
+}
+
+// cleanPagePath - clean a path relative to the pages folders so that it can't point outside of them, returning false if it's empty
+func cleanPagePath(pagePath string) (string, bool) {
+	cleaned := path.Clean("/" + strings.TrimSpace(pagePath))[1:]
+	return cleaned, "" != cleaned
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
+	"github.com/entando/entando-nxfs/server/nxfsrender"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// ApiNxfsRawEncodedPathGet - Gets the content of a file as is, or a rendition of an image
+func (s *DefaultApiService) ApiNxfsRawEncodedPathGet(ctx context.Context, encodedPath string, width string, height string, fit string, format string) (net.NxfsResponse, error) {
+
+	params, err := nxfsrender.ParseParams(width, height, fit, format)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return s.statAndExecuteApiNxfsFunction(ctx, encodedPath, func(relPath string, requestedFile os.FileInfo) (net.NxfsResponse, error) {
+		if requestedFile.IsDir() {
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "dir_requested", "The received encoded path "+
+				"corresponds to a directory. This endpoint returns files content, to browse a directory please use the browse one")), nil
+		}
+
+		// the clients can cache the content, but only for themselves since it needs authentication
+		content := &net.NxfsContent{
+			ModTime:      requestedFile.ModTime(),
+			CacheControl: fmt.Sprintf("private, max-age=%d", int64(s.renditionMaxAge/time.Second)),
+			FileName:     path.Base(relPath),
+		}
+		if params.IsZero() {
+			// the file is streamed, it may be too large to be held in memory
+			file, etag, err := s.storage.Open(relPath)
+			if err != nil {
+				return *helper.ErrorResponse(nxfserrors.FromOS(err, "err_reading_content",
+					"An error occurred during the reading of the file content")), nil
+			}
+			head := make([]byte, nxfsmime.SniffLength)
+			n, err := io.ReadFull(file, head)
+			if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+				_, err = file.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				file.Close()
+				return *helper.ErrorResponse(nxfserrors.FromOS(err, "err_reading_content",
+					"An error occurred during the reading of the file content")), nil
+			}
+			content.ContentType, content.ETag, content.Content = nxfsmime.Detect(relPath, head[:n]), etag, file
+		} else {
+			fileContent, _, err := s.storage.ReadFile(relPath)
+			if err != nil {
+				return *helper.ErrorResponse(nxfserrors.FromOS(err, "err_reading_content",
+					"An error occurred during the reading of the file content")), nil
+			}
+			rendition, err := s.renditions.Get(relPath, fileContent, params)
+			if err != nil {
+				return *helper.ErrorResponse(err), nil
+			}
+			content.ContentType, content.ETag, content.Content = rendition.ContentType, rendition.ETag, bytes.NewReader(rendition.Content)
+		}
+		// only the images are displayed, the other files are downloaded
+		content.Attachment = !nxfsmime.IsSafeInline(content.ContentType)
+		return helper.SuccessResponse(http.StatusOK, content), nil
+	})
+}
+
+// invalidateRenditions - remove the cached renditions of the received objects, relative to the browsable fs root, because
+// they've been overwritten or removed
+func (s *DefaultApiService) invalidateRenditions(relPaths ...string) {
+	if err := s.renditions.Invalidate(relPaths...); err != nil {
+		log.Printf("Invalidation of the renditions failed: %s", err.Error())
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/nxfsscan"
+	"io"
+	"log"
+)
+
+// newScanGuard - create the guard scanning the files with the configured scanner, nil if scanning is disabled.
+// fail if the scanner is misconfigured, rather than writing files without scanning them
+func newScanGuard(config helper.FsConfig) (*nxfsscan.Guard, error) {
+	var scanner nxfsscan.Scanner
+	switch helper.GetScanner() {
+	case helper.ScannerNone:
+		return nil, nil
+	case helper.ScannerClamd:
+		scanner = nxfsscan.NewClamd(helper.GetClamdAddress(), helper.GetScanTimeout())
+	case helper.ScannerExec:
+		command := helper.GetScanCommand()
+		if len(command) == 0 {
+			return nil, fmt.Errorf("the exec scanner needs a scan command")
+		}
+		scanner = nxfsscan.NewExec(command, helper.GetScanTimeout())
+	default:
+		return nil, fmt.Errorf("unknown scanner %q, it must be %s or %s", helper.GetScanner(), helper.ScannerClamd, helper.ScannerExec)
+	}
+	return nxfsscan.NewGuard(scanner, config.QuarantinePath()), nil
+}
+
+// scan - scan the content about to be written to a path relative to the browsable fs root, quarantining it if it's rejected.
+// return the result of the scan, nil if scanning is disabled
+func (s *DefaultApiService) scan(ctx context.Context, relPath string, content io.Reader) (*model.ScanResult, error) {
+	if s.scanner == nil {
+		return nil, nil
+	}
+	result, err := s.scanner.Check(relPath, content, helper.GetRequestInfo(ctx).User)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// recordScans - record the results of the scans of the written files, forgetting the previous results of the files
+// written without scanning them
+func (s *DefaultApiService) recordScans(results map[string]model.ScanResult, unscanned ...string) {
+	err := s.scans.Record(results)
+	if err == nil && len(unscanned) > 0 {
+		err = s.scans.Removed(unscanned...)
+	}
+	if err != nil {
+		log.Printf("Recording of the scan results failed: %s", err.Error())
+	}
+}
+
+// scanResult - return the result of the last scan of a file, relative to the browsable fs root, nil if it hasn't been scanned
+func (s *DefaultApiService) scanResult(relPath string) *model.ScanResult {
+	result, err := s.scans.Get(relPath)
+	if err != nil {
+		log.Printf("Reading of the scan result of %s failed: %s", relPath, err.Error())
+	}
+	return result
+}
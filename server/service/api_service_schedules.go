@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"github.com/entando/entando-nxfs/server/nxfsquota"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// schedulerClientIp - the client ip recorded in the audit log for the operations executed by the scheduler
+const schedulerClientIp = "scheduler"
+
+// publish - publish the draft page identified by the received encoded path, with its referenced assets if withAssets is true
+func (s *DefaultApiService) publish(ctx context.Context, encodedPath string, withAssets bool) net.NxfsResponse {
+
+	draftRelPath, publishedRelPath := pagePaths(encodedPath)
+	locks := []nxfslock.Request{nxfslock.ReadRequest(draftRelPath), nxfslock.WriteRequest(publishedRelPath)}
+	if withAssets {
+		// the assets are known only once the draft is read, so the pages folders are locked as a whole
+		locks = []nxfslock.Request{nxfslock.ReadRequest(helper.GetDraftPagesRelativePath()), nxfslock.WriteRequest(helper.GetPublishedPagesRelativePath())}
+	}
+
+	return s.mutate(ctx, nxfsaudit.OpPublish, publishedRelPath, locks, func() net.NxfsResponse {
+		var publishedPage string
+		var published []string
+		undo := func() {}
+		if errorResponse := nxfspages.PublishPage(s.storage, encodedPath, withAssets, func(pagePath string, content []byte) error {
+			publishedPage = pagePath
+			if err := s.workflow.CheckPublishable(pagePath, nxfsfiles.HashContent(content)); err != nil {
+				return err
+			}
+			published = []string{pagePath}
+			if withAssets {
+				published = append(published, nxfspages.PublishableAssets(s.storage, nxfspages.References(content))...)
+			}
+			reserved, err := s.reservePublication(published)
+			if err == nil {
+				undo = reserved
+			}
+			return err
+		}); errorResponse != nil {
+			undo()
+			return *errorResponse
+		}
+		for _, pagePath := range published {
+			s.invalidateRenditions(publishedPageRelPath(pagePath))
+		}
+
+		s.recordWorkflow(s.workflow.Published(publishedPage, helper.GetRequestInfo(ctx).User))
+		return helper.SuccessResponse(http.StatusOK, nil)
+	})
+}
+
+// unpublish - unpublish the page identified by the received encoded path
+func (s *DefaultApiService) unpublish(ctx context.Context, encodedPath string) net.NxfsResponse {
+
+	_, publishedRelPath := pagePaths(encodedPath)
+	locks := []nxfslock.Request{nxfslock.WriteRequest(publishedRelPath)}
+
+	return s.mutate(ctx, nxfsaudit.OpUnpublish, publishedRelPath, locks, func() net.NxfsResponse {
+		if errorResponse := nxfspages.UnpublishPage(s.storage, encodedPath); errorResponse != nil {
+			return *errorResponse
+		}
+		s.quota.Removed(publishedRelPath)
+		s.invalidateRenditions(publishedRelPath)
+
+		s.recordWorkflow(s.workflow.Unpublished(pagePathOf(publishedRelPath, helper.GetPublishedPagesRelativePath()), helper.GetRequestInfo(ctx).User))
+		return helper.SuccessResponse(http.StatusOK, nil)
+	})
+}
+
+// reservePublication - check the types of the received drafts, relative to the pages folders, and reserve the space they take once copied to the published pages folder
+func (s *DefaultApiService) reservePublication(pagePaths []string) (func(), error) {
+	files := make([]nxfsmime.File, 0, len(pagePaths))
+	writes := make([]nxfsquota.Write, 0, len(pagePaths))
+	for _, pagePath := range pagePaths {
+		draftPath := path.Join(helper.GetDraftPagesRelativePath(), pagePath)
+		draft, err := s.storage.Stat(draftPath)
+		if err != nil {
+			return nil, nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path")
+		}
+		files = append(files, nxfsmime.File{Path: publishedPageRelPath(pagePath), MimeType: s.contentType(draftPath)})
+		writes = append(writes, nxfsquota.Write{Path: publishedPageRelPath(pagePath), Size: draft.Size()})
+	}
+	if err := s.types.Check(files...); err != nil {
+		return nil, err
+	}
+	return s.quota.Reserve(writes...)
+}
+
+// schedule - store a schedule executing the received operation on the page at the received RFC 3339 time, that must be in the future
+func (s *DefaultApiService) schedule(ctx context.Context, operation model.ScheduleOperation, encodedPath string, at string, withAssets bool) net.NxfsResponse {
+
+	scheduledAt, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_at", fmt.Sprintf("The at parameter must be an RFC 3339 timestamp: %q", err.Error())))
+	}
+
+	now := time.Now().UTC()
+	if !scheduledAt.After(now) {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_at", "The at parameter must be in the future"))
+	}
+
+	decodedPath, errResponse := nxfsfiles.DecodePath(encodedPath)
+	if errResponse != nil {
+		return *errResponse
+	}
+
+	schedule := model.Schedule{
+		Id:         helper.NewRandomId(),
+		Operation:  operation,
+		Path:       decodedPath,
+		At:         scheduledAt.UTC(),
+		WithAssets: withAssets,
+		CreatedBy:  helper.GetRequestInfo(ctx).User,
+		CreatedAt:  now,
+		Status:     model.SchedulePending,
+	}
+	if err = s.schedules.Add(schedule); err != nil {
+		return *helper.ErrorResponse(err)
+	}
+
+	return helper.SuccessResponse(http.StatusAccepted, schedule)
+}
+
+// executeSchedule - execute a due schedule on behalf of its creator, through the same path of the immediate operations
+func (s *DefaultApiService) executeSchedule(schedule model.Schedule) error {
+
+	ctx := helper.ContextWithRequestInfo(context.Background(), helper.RequestInfo{User: schedule.CreatedBy, ClientIp: schedulerClientIp})
+	encodedPath := url.PathEscape(schedule.Path)
+
+	var response net.NxfsResponse
+	switch schedule.Operation {
+	case model.SchedulePublish:
+		response = s.publish(ctx, encodedPath, schedule.WithAssets)
+	case model.ScheduleUnpublish:
+		response = s.unpublish(ctx, encodedPath)
+	default:
+		return fmt.Errorf("unknown operation %q", schedule.Operation)
+	}
+
+	if result, ok := response.Body.(*model.Result); ok && response.Code >= http.StatusBadRequest {
+		return fmt.Errorf("%s: %s", result.Code, result.Message)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfsaudit"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfsfiles"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfsmime"
+	"github.com/entando/entando-nxfs/server/nxfsquota"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ApiNxfsUploadsPost - Starts a resumable upload
+func (s *DefaultApiService) ApiNxfsUploadsPost(ctx context.Context, uploadRequest model.UploadRequest) (net.NxfsResponse, error) {
+
+	relPath := nxfsstorage.CleanPath(uploadRequest.Path)
+	if "" == relPath {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_upload", "The path of the upload must identify a file")), nil
+	}
+
+	// the uploads that will surely be rejected fail now rather than once they're complete, their content is checked when they're finalized
+	if mimeType := nxfsmime.FromExtension(relPath); "" != mimeType {
+		if err := s.types.Check(nxfsmime.File{Path: relPath, MimeType: mimeType}); err != nil {
+			return *helper.ErrorResponse(err), nil
+		}
+	}
+	if uploadRequest.Length > 0 {
+		undo, err := s.quota.Reserve(nxfsquota.Write{Path: relPath, Size: uploadRequest.Length})
+		if err != nil {
+			return *helper.ErrorResponse(err), nil
+		}
+		undo()
+	}
+
+	upload, err := s.uploads.Create(relPath, uploadRequest.Length, uploadRequest.Checksum, helper.GetRequestInfo(ctx).User)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	return helper.SuccessResponse(http.StatusCreated, upload), nil
+}
+
+// ApiNxfsUploadsIdGet - Gets a resumable upload and its progress
+func (s *DefaultApiService) ApiNxfsUploadsIdGet(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	upload, err := s.uploads.Get(id)
+	if err == nil {
+		err = checkUploader(ctx, upload)
+	}
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	return helper.SuccessResponse(http.StatusOK, upload), nil
+}
+
+// ApiNxfsUploadsIdPatch - Appends a chunk to a resumable upload
+func (s *DefaultApiService) ApiNxfsUploadsIdPatch(ctx context.Context, id string, offset string, chunk io.Reader) (net.NxfsResponse, error) {
+
+	chunkOffset, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || chunkOffset < 0 {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrInvalid, "invalid_offset", "The offset parameter must be the position of the chunk in the file")), nil
+	}
+
+	return s.withUpload(ctx, id, func(upload model.Upload) net.NxfsResponse {
+		upload, err := s.uploads.Append(upload, chunkOffset, chunk)
+		if err != nil {
+			return *helper.ErrorResponse(err)
+		}
+		return helper.SuccessResponse(http.StatusOK, upload)
+	}), nil
+}
+
+// ApiNxfsUploadsIdDelete - Aborts a resumable upload, discarding the received chunks
+func (s *DefaultApiService) ApiNxfsUploadsIdDelete(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	return s.withUpload(ctx, id, func(upload model.Upload) net.NxfsResponse {
+		if err := s.uploads.Remove(upload.Id); err != nil {
+			return *helper.ErrorResponse(err)
+		}
+		return helper.SuccessResponse(http.StatusNoContent, nil)
+	}), nil
+}
+
+// ApiNxfsUploadsIdFinalizePost - Writes the file of a complete resumable upload to its path
+func (s *DefaultApiService) ApiNxfsUploadsIdFinalizePost(ctx context.Context, id string) (net.NxfsResponse, error) {
+
+	return s.withUpload(ctx, id, func(upload model.Upload) net.NxfsResponse {
+		if upload.Offset < upload.Length {
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrConflict, "upload_incomplete", "The upload has received "+
+				strconv.FormatInt(upload.Offset, 10)+" of its "+strconv.FormatInt(upload.Length, 10)+" bytes"))
+		}
+		partPath := s.uploads.PartPath(upload.Id)
+		if "" != upload.Checksum && nxfsfiles.HashFile(partPath) != upload.Checksum {
+			// the received content is useless, the file must be uploaded again
+			if err := s.uploads.Remove(upload.Id); err != nil {
+				return *helper.ErrorResponse(err)
+			}
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrUnprocessable, "checksum_mismatch", "The received content doesn't match the checksum of the upload, it has been discarded"))
+		}
+
+		return s.mutate(ctx, nxfsaudit.OpUpload, upload.Path, []nxfslock.Request{nxfslock.WriteRequest(upload.Path)}, func() net.NxfsResponse {
+			part, err := os.Open(partPath)
+			if err != nil {
+				return *helper.ErrorResponse(nxfserrors.FromOS(err, "upload_store_error", "An error occurred during the reading of the upload"))
+			}
+			defer part.Close()
+
+			// a rejected upload is kept until it expires, it can be finalized again once the cause is removed
+			scan, err := s.writeFile(ctx, upload.Path, part, upload.Length)
+			if err != nil {
+				return *helper.ErrorResponse(err)
+			}
+			head := make([]byte, nxfsmime.SniffLength)
+			n, _ := part.ReadAt(head, 0)
+			if err = s.uploads.Remove(upload.Id); err != nil {
+				log.Printf("Removal of the finalized upload %s failed: %s", upload.Id, err.Error())
+			}
+
+			savedFile, err := s.storage.Stat(upload.Path)
+			if err != nil {
+				return *helper.ErrorResponse(nxfserrors.FromOS(err, "stat_error", "An error occurred during the access to the path"))
+			}
+			savedObject := helper.ToDirectoryObject(filepath.Dir(upload.Path), savedFile)
+			savedObject.MimeType = nxfsmime.Detect(upload.Path, head[:n])
+			savedObject.Scan = scan
+			return helper.SuccessResponse(http.StatusCreated, savedObject)
+		})
+	}), nil
+}
+
+// withUpload - execute a change of the upload identified by the received id, preventing the other requests from
+// changing it meanwhile. only the user who started the upload, or an administrator, can change it
+func (s *DefaultApiService) withUpload(ctx context.Context, id string, change func(upload model.Upload) net.NxfsResponse) net.NxfsResponse {
+	upload, release, err := s.uploads.Acquire(id)
+	if err != nil {
+		return *helper.ErrorResponse(err)
+	}
+	defer release()
+
+	if err = checkUploader(ctx, upload); err != nil {
+		return *helper.ErrorResponse(err)
+	}
+	return change(upload)
+}
+
+// checkUploader - return an error if the caller is neither the user who started the upload nor an administrator
+func checkUploader(ctx context.Context, upload model.Upload) error {
+	requestInfo := helper.GetRequestInfo(ctx)
+	if requestInfo.User != upload.CreatedBy && !requestInfo.IsAdmin() {
+		return nxfserrors.New(nxfserrors.ErrPermission, "upload_forbidden", "Only the user who started the upload, or an administrator, can access it")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/entando/entando-nxfs/server/helper"
+	"github.com/entando/entando-nxfs/server/model"
+	"github.com/entando/entando-nxfs/server/net"
+	"github.com/entando/entando-nxfs/server/nxfserrors"
+	"github.com/entando/entando-nxfs/server/nxfslock"
+	"github.com/entando/entando-nxfs/server/nxfspages"
+	"github.com/entando/entando-nxfs/server/nxfsstorage"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// ApiNxfsWorkflowGet - Lists the draft pages subject to the review workflow, optionally filtered by state
+func (s *DefaultApiService) ApiNxfsWorkflowGet(ctx context.Context, state string) (net.NxfsResponse, error) {
+
+	release, err := s.locks.Lock(ctx, nxfslock.ReadRequest(helper.GetDraftPagesRelativePath()))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	var pagePaths []string
+	draftPagesPath := helper.GetDraftPagesRelativePath()
+	err = nxfsstorage.Walk(s.storage, draftPagesPath, func(relPath string, fileInfo os.FileInfo) error {
+		if pagePath := pagePathOf(relPath, draftPagesPath); !fileInfo.IsDir() && nxfspages.IsPage(pagePath) && s.workflow.Enabled(pagePath) {
+			pagePaths = append(pagePaths, pagePath)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return *helper.ErrorResponse(nxfserrors.FromOS(err, "dir_listing_err", "An error occurred during the listing of the draft pages")), nil
+	}
+	sort.Strings(pagePaths)
+
+	pages, err := s.workflow.List(pagePaths, model.WorkflowState(state))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, model.PageWorkflowList{List: pages}), nil
+}
+
+// ApiNxfsWorkflowEncodedPathGet - Gets the review workflow state and history of a draft page
+func (s *DefaultApiService) ApiNxfsWorkflowEncodedPathGet(ctx context.Context, encodedPath string) (net.NxfsResponse, error) {
+
+	pagePath, err := s.workflowPagePath(encodedPath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	page, err := s.workflow.Get(pagePath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, page), nil
+}
+
+// ApiNxfsWorkflowEncodedPathPost - Moves a draft page to another review workflow state
+func (s *DefaultApiService) ApiNxfsWorkflowEncodedPathPost(ctx context.Context, encodedPath string, workflowTransition model.WorkflowTransition) (net.NxfsResponse, error) {
+
+	pagePath, err := s.workflowPagePath(encodedPath)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	requestInfo := helper.GetRequestInfo(ctx)
+	if workflowTransition.Action == model.WorkflowApprove || workflowTransition.Action == model.WorkflowReject {
+		if !requestInfo.HasRole(helper.GetReviewerRole()) && !requestInfo.IsAdmin() {
+			return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrPermission, "reviewer_required", "Only reviewers can approve or reject the pages")), nil
+		}
+	}
+
+	draftRelPath, _ := pagePaths(encodedPath)
+	release, err := s.locks.Lock(ctx, nxfslock.ReadRequest(draftRelPath))
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+	defer release()
+
+	draftHash := nxfsstorage.Hash(s.storage, draftRelPath)
+	if "" == draftHash {
+		return *helper.ErrorResponse(nxfserrors.New(nxfserrors.ErrNotFound, "path_not_found", fmt.Sprintf("The draft page %s doesn't exist", pagePath))), nil
+	}
+
+	page, err := s.workflow.Transition(pagePath, workflowTransition.Action, requestInfo.User, workflowTransition.Comment, draftHash)
+	if err != nil {
+		return *helper.ErrorResponse(err), nil
+	}
+
+	return helper.SuccessResponse(http.StatusOK, page), nil
+}
+
+// recordWorkflow - log the failure to record an automatic workflow transition, the operation that caused it already succeeded
+func (s *DefaultApiService) recordWorkflow(err error) {
+	if err != nil {
+		log.Printf("Recording of the workflow state failed: %s", err.Error())
+	}
+}
+
+// workflowPagePath - return the page, relative to the pages folders, identified by the received encoded path,
+// or a workflow_disabled error if it's not subject to the review workflow
+func (s *DefaultApiService) workflowPagePath(encodedPath string) (string, error) {
+	draftRelPath, _ := pagePaths(encodedPath)
+	pagePath := pagePathOf(draftRelPath, helper.GetDraftPagesRelativePath())
+	if !s.workflow.Enabled(pagePath) {
+		return "", nxfserrors.New(nxfserrors.ErrUnprocessable, "workflow_disabled", fmt.Sprintf("The page %s is not subject to the review workflow", pagePath))
+	}
+	return pagePath, nil
+}